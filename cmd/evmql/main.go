@@ -12,6 +12,7 @@ import (
 	"github.com/devlongs/evmql/config"
 	"github.com/devlongs/evmql/internal/cache"
 	"github.com/devlongs/evmql/internal/executor"
+	"github.com/devlongs/evmql/internal/format"
 	"github.com/devlongs/evmql/internal/logger"
 	"github.com/devlongs/evmql/internal/parser"
 	"github.com/devlongs/evmql/internal/repl"
@@ -27,6 +28,7 @@ var (
 	networkName     = flag.String("network", "", "Network to connect to (mainnet, sepolia, etc.)")
 	nodeURL         = flag.String("node", "", "Ethereum node URL (overrides config)")
 	interactiveMode = flag.Bool("interactive", true, "Run in interactive mode")
+	outputFormat    = flag.String("format", "", "Result output format (table, json, csv)")
 )
 
 const (
@@ -75,6 +77,10 @@ func main() {
 		cfg.Node.URL = *nodeURL
 	}
 
+	if *outputFormat != "" {
+		cfg.Query.OutputFormat = *outputFormat
+	}
+
 	if err := config.ValidateConfig(cfg); err != nil {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
@@ -121,16 +127,27 @@ func main() {
 		if found {
 			logger.Warn("network mismatch", "connected_to", networkInfo.Name, "expected", "default network")
 		} else {
-			logger.Warn("unknown network", "chain_id", chainID)
+			logger.Warn("unknown network, only address book names are available", "chain_id", chainID)
 		}
 	}
 
 	// Initialize parser and executor
 	queryParser := parser.NewParser()
+	queryParser.SetAliases(cfg.AddressAliases(chainID.Int64()))
+	abis, err := cfg.LoadABIs(chainID.Int64())
+	if err != nil {
+		log.Fatalf("Failed to load ABIs: %v", err)
+	}
+	queryParser.SetABIs(abis)
+	queryParser.SetShowGasEstimates(cfg.Query.ShowGasEstimates)
 	queryExecutor := executor.NewQueryExecutor(client)
+	queryExecutor.SetABIs(abis)
+	queryExecutor.SetChainID(chainID)
 
 	// Set timeout for query execution
 	queryExecutor.SetTimeout(time.Duration(cfg.Query.TimeoutSeconds) * time.Second)
+	queryExecutor.SetDefaultBlockRange(cfg.Query.DefaultBlockRange)
+	queryExecutor.SetDefaultGasLimit(cfg.Query.DefaultGasLimit)
 
 	// Initialize cache if enabled
 	if cfg.Cache.Enabled {
@@ -152,6 +169,9 @@ func main() {
 			MaxHistoryLen: cfg.REPL.MaxHistoryLen,
 			ColorOutput:   cfg.REPL.ColorOutput,
 			ShowTimings:   cfg.REPL.ShowTimings,
+			OutputFormat:  cfg.Query.OutputFormat,
+			PrettyPrint:   cfg.Query.PrettyPrintResults,
+			Labels:        cfg.AddressLabels(chainID.Int64()),
 		}
 		repl.Start(queryParser, queryExecutor, replConfig)
 	} else {
@@ -166,12 +186,23 @@ func main() {
 			queryCtx, queryCancel := context.WithTimeout(ctx, time.Duration(cfg.Query.TimeoutSeconds)*time.Second)
 			defer queryCancel()
 
+			formatter, err := format.New(cfg.Query.OutputFormat, format.Options{
+				Pretty: cfg.Query.PrettyPrintResults,
+				Labels: cfg.AddressLabels(chainID.Int64()),
+			})
+			if err != nil {
+				log.Fatalf("Invalid output format: %v", err)
+			}
+
 			result, err := queryExecutor.Execute(queryCtx, query)
 			if err != nil {
 				logger.Error("query execution failed", "error", err)
 				log.Fatalf("Error executing query: %v", err)
 			}
-			logger.Info("query result", "result", result)
+			logger.Info("query result", "rows", result.Len())
+			if err := formatter.Format(os.Stdout, result); err != nil {
+				log.Fatalf("Error formatting result: %v", err)
+			}
 		} else {
 			logger.Info("no query provided", "hint", "use -interactive flag for REPL mode or provide a query as an argument")
 		}
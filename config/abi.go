@@ -0,0 +1,64 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// LoadABIs reads the ABI files registered in the configuration, keyed by contract
+// address. Contracts are given by address or by a name from AddressAliases of the
+// chain chainID.
+func (config *Config) LoadABIs(chainID int64) (map[common.Address]abi.ABI, error) {
+	aliases := config.AddressAliases(chainID)
+	abis := make(map[common.Address]abi.ABI, len(config.ABIs))
+	for contract, path := range config.ABIs {
+		address, ok := aliases[strings.ToLower(contract)]
+		if !ok {
+			if !common.IsHexAddress(contract) {
+				return nil, fmt.Errorf("abis: unknown contract %q (use an address, a contract name or an address book name)", contract)
+			}
+			address = common.HexToAddress(contract)
+		}
+
+		parsed, err := ReadABI(path)
+		if err != nil {
+			return nil, fmt.Errorf("abis: %s: %w", contract, err)
+		}
+		abis[address] = parsed
+	}
+	return abis, nil
+}
+
+// ReadABI parses a JSON ABI file, holding either the ABI array itself or a compiler
+// artifact with an "abi" field, as written by Hardhat and Foundry
+func ReadABI(path string) (abi.ABI, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("error reading ABI file: %w", err)
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var artifact struct {
+			ABI json.RawMessage `json:"abi"`
+		}
+		if err := json.Unmarshal(trimmed, &artifact); err != nil {
+			return abi.ABI{}, fmt.Errorf("invalid ABI file %s: %w", path, err)
+		}
+		if len(artifact.ABI) == 0 {
+			return abi.ABI{}, fmt.Errorf("ABI file %s has no abi field", path)
+		}
+		data = artifact.ABI
+	}
+
+	parsed, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("invalid ABI file %s: %w", path, err)
+	}
+	return parsed, nil
+}
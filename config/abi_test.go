@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const transferABI = `[{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}]`
+
+func TestLoadABIs(t *testing.T) {
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "token.json")
+	artifact := filepath.Join(dir, "Vault.json")
+	if err := os.WriteFile(bare, []byte(transferABI), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(artifact, []byte(`{"contractName":"Vault","abi":`+transferABI+`}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	vault := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+
+	cfg := DefaultConfig()
+	cfg.AddressBook = map[string]string{"vault": vault.Hex()}
+	cfg.ABIs = map[string]string{
+		usdc.Hex(): bare,
+		"Vault":    artifact,
+	}
+
+	abis, err := cfg.LoadABIs(cfg.DefaultChainID)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, address := range []common.Address{usdc, vault} {
+		if _, ok := abis[address].Events["Transfer"]; !ok {
+			t.Errorf("Expected the ABI of %s to declare Transfer", address.Hex())
+		}
+	}
+
+	tests := []struct {
+		name        string
+		abis        map[string]string
+		expectedErr string
+	}{
+		{"Unknown contract", map[string]string{"dai": bare}, `unknown contract "dai"`},
+		{"Missing file", map[string]string{"vault": filepath.Join(dir, "missing.json")}, "error reading ABI file"},
+		{"Artifact without an ABI", map[string]string{"vault": writeFile(t, dir, "empty.json", `{"bytecode":"0x"}`)}, "has no abi field"},
+		{"Invalid ABI", map[string]string{"vault": writeFile(t, dir, "invalid.json", `[{"type":"event","name":"Bad","inputs":[{"type":"strin"}]}]`)}, "invalid ABI file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.ABIs = tt.abis
+			_, err := cfg.LoadABIs(cfg.DefaultChainID)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -22,6 +24,13 @@ type Config struct {
 	REPL           REPLConfig     `json:"repl" mapstructure:"repl"`
 	Networks       NetworksConfig `json:"networks" mapstructure:"networks"`
 	DefaultChainID int64          `json:"default_chain_id" mapstructure:"default_chain_id"`
+
+	// AddressBook names wallets and contracts for use in queries, on every network
+	AddressBook map[string]string `json:"address_book" mapstructure:"address_book"`
+
+	// ABIs maps contracts, by address or by contract or address book name, to JSON
+	// ABI files used to decode their events
+	ABIs map[string]string `json:"abis" mapstructure:"abis"`
 }
 
 // Ethereum node connection settings
@@ -35,14 +44,15 @@ type NodeConfig struct {
 
 // settings for query execution
 type QueryConfig struct {
-	DefaultBlockRange  int64 `json:"default_block_range" mapstructure:"default_block_range"`
-	MaxBlockRange      int64 `json:"max_block_range" mapstructure:"max_block_range"`
-	DefaultGasLimit    int64 `json:"default_gas_limit" mapstructure:"default_gas_limit"`
-	ResultSizeLimit    int   `json:"result_size_limit" mapstructure:"result_size_limit"`
-	TimeoutSeconds     int   `json:"timeout_seconds" mapstructure:"timeout_seconds"`
-	ShowGasEstimates   bool  `json:"show_gas_estimates" mapstructure:"show_gas_estimates"`
-	PrettyPrintResults bool  `json:"pretty_print_results" mapstructure:"pretty_print_results"`
-	IncludeRawData     bool  `json:"include_raw_data" mapstructure:"include_raw_data"`
+	DefaultBlockRange  int64  `json:"default_block_range" mapstructure:"default_block_range"`
+	MaxBlockRange      int64  `json:"max_block_range" mapstructure:"max_block_range"`
+	DefaultGasLimit    int64  `json:"default_gas_limit" mapstructure:"default_gas_limit"`
+	ResultSizeLimit    int    `json:"result_size_limit" mapstructure:"result_size_limit"`
+	TimeoutSeconds     int    `json:"timeout_seconds" mapstructure:"timeout_seconds"`
+	ShowGasEstimates   bool   `json:"show_gas_estimates" mapstructure:"show_gas_estimates"`
+	PrettyPrintResults bool   `json:"pretty_print_results" mapstructure:"pretty_print_results"`
+	IncludeRawData     bool   `json:"include_raw_data" mapstructure:"include_raw_data"`
+	OutputFormat       string `json:"output_format" mapstructure:"output_format"`
 }
 
 // query caching settings
@@ -89,8 +99,9 @@ func DefaultConfig() *Config {
 			DefaultGasLimit:    3000000,
 			ResultSizeLimit:    1000,
 			TimeoutSeconds:     30,
-			ShowGasEstimates:   true,
+			ShowGasEstimates:   false, // estimating costs a request per called address
 			PrettyPrintResults: true,
+			OutputFormat:       "table",
 		},
 		Cache: CacheConfig{
 			Enabled:      true,
@@ -258,6 +269,14 @@ func ValidateConfig(config *Config) error {
 		return errors.New("max block range cannot exceed 10000 to prevent resource exhaustion")
 	}
 
+	if config.Query.DefaultBlockRange < 0 {
+		return errors.New("default block range cannot be negative")
+	}
+
+	if config.Query.DefaultBlockRange > config.Query.MaxBlockRange {
+		return errors.New("default block range cannot exceed max block range")
+	}
+
 	if config.Query.TimeoutSeconds <= 0 {
 		return errors.New("query timeout must be positive")
 	}
@@ -276,6 +295,10 @@ func ValidateConfig(config *Config) error {
 			return errors.New("network name contains invalid characters")
 		}
 
+		if err := validateAddressNames(network.Contracts); err != nil {
+			return fmt.Errorf("network %s contracts: %w", name, err)
+		}
+
 		if strings.Contains(network.NodeURL, "YOUR_KEY") {
 			return fmt.Errorf("network contains placeholder 'YOUR_KEY' - please set a valid API key")
 		}
@@ -285,6 +308,26 @@ func ValidateConfig(config *Config) error {
 		}
 	}
 
+	if err := validateAddressNames(config.AddressBook); err != nil {
+		return fmt.Errorf("address book: %w", err)
+	}
+
+	return nil
+}
+
+// addressNamePattern matches names usable in place of an address in a query
+var addressNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateAddressNames checks a map of names to addresses, as used for contracts and the address book
+func validateAddressNames(entries map[string]string) error {
+	for name, address := range entries {
+		if !addressNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid name %q (use letters, digits and underscores)", name)
+		}
+		if !common.IsHexAddress(address) {
+			return fmt.Errorf("%s is not a valid address: %s", name, address)
+		}
+	}
 	return nil
 }
 
@@ -318,3 +361,49 @@ func (config *Config) GetContractAddress(contractName string) (common.Address, b
 
 	return common.HexToAddress(addressStr), true
 }
+
+// AddressAliases returns the names usable in place of an address in queries on the
+// chain chainID: the contracts of its network, if it is configured, and the address
+// book, which wins when both use a name. Names are lower-cased, as queries match
+// them case-insensitively.
+func (config *Config) AddressAliases(chainID int64) map[string]common.Address {
+	aliases := make(map[string]common.Address)
+	if network, found := config.GetNetworkByChainID(chainID); found {
+		addAliases(aliases, network.Contracts)
+	}
+	addAliases(aliases, config.AddressBook)
+	return aliases
+}
+
+// AddressLabels returns the names to show next to addresses of the chain chainID in
+// output, preferring address book entries over contract names
+func (config *Config) AddressLabels(chainID int64) map[common.Address]string {
+	labels := make(map[common.Address]string)
+	if network, found := config.GetNetworkByChainID(chainID); found {
+		addLabels(labels, network.Contracts)
+	}
+	addLabels(labels, config.AddressBook)
+	return labels
+}
+
+func addAliases(aliases map[string]common.Address, entries map[string]string) {
+	for name, address := range entries {
+		if common.IsHexAddress(address) {
+			aliases[strings.ToLower(name)] = common.HexToAddress(address)
+		}
+	}
+}
+
+func addLabels(labels map[common.Address]string, entries map[string]string) {
+	// An address with several names is labelled with the alphabetically first one
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, name := range names {
+		if common.IsHexAddress(entries[name]) {
+			labels[common.HexToAddress(entries[name])] = name
+		}
+	}
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -33,6 +36,10 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.DefaultChainID == 0 {
 		t.Error("Default chain ID should not be zero")
 	}
+
+	if cfg.Query.ShowGasEstimates {
+		t.Error("Gas estimates should be opt-in")
+	}
 }
 
 func TestValidateConfig_Valid(t *testing.T) {
@@ -108,6 +115,17 @@ func TestValidateConfig_MaxBlockRangeTooLarge(t *testing.T) {
 	}
 }
 
+func TestValidateConfig_DefaultBlockRangeTooLarge(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Node.URL = "http://localhost:8545"
+	cfg.Query.DefaultBlockRange = cfg.Query.MaxBlockRange + 1
+
+	err := ValidateConfig(cfg)
+	if err == nil {
+		t.Error("Expected error for default block range exceeding max block range")
+	}
+}
+
 func TestValidateConfig_TimeoutTooLarge(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.Node.URL = "http://localhost:8545"
@@ -131,6 +149,13 @@ func TestValidateConfig_NegativeValues(t *testing.T) {
 				c.Query.MaxBlockRange = -1
 			},
 		},
+		{
+			name: "Negative default block range",
+			modifier: func(c *Config) {
+				c.Node.URL = "http://localhost:8545"
+				c.Query.DefaultBlockRange = -1
+			},
+		},
 		{
 			name: "Negative timeout",
 			modifier: func(c *Config) {
@@ -290,3 +315,112 @@ func TestGetContractAddress(t *testing.T) {
 		t.Error("Should not find non-existent contract")
 	}
 }
+
+func TestAddressAliases(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Networks["mainnet"] = NetworkConfig{
+		ChainID: 1,
+		Name:    "Mainnet",
+		Contracts: map[string]string{
+			"USDT": "0xdac17f958d2ee523a2206206994597c13d831ec7",
+			"usdc": "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48",
+		},
+	}
+	cfg.Networks["sepolia"] = NetworkConfig{
+		ChainID:   11155111,
+		Name:      "Sepolia",
+		Contracts: map[string]string{"weth": "0xfff9976782d46cc05630d1f6ebab18b2324d6b14"},
+	}
+	cfg.AddressBook = map[string]string{
+		"treasury": "0x742d35cc6634c0532925a3b844bc454e4438f44e",
+		"usdc":     "0x0000000000000000000000000000000000000001",
+	}
+	cfg.DefaultChainID = 1
+
+	aliases := cfg.AddressAliases(1)
+	expected := map[string]string{
+		"usdt":     "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+		"usdc":     "0x0000000000000000000000000000000000000001", // the address book wins
+		"treasury": "0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+	}
+	if len(aliases) != len(expected) {
+		t.Errorf("Expected %d aliases, got %v", len(expected), aliases)
+	}
+	for name, address := range expected {
+		if aliases[name].Hex() != address {
+			t.Errorf("Alias %s: expected %s, got %s", name, address, aliases[name].Hex())
+		}
+	}
+
+	labels := cfg.AddressLabels(1)
+	if label := labels[common.HexToAddress("0xdac17f958d2ee523a2206206994597c13d831ec7")]; label != "USDT" {
+		t.Errorf("Expected contract label USDT, got %q", label)
+	}
+	if label := labels[common.HexToAddress("0x742d35cc6634c0532925a3b844bc454e4438f44e")]; label != "treasury" {
+		t.Errorf("Expected address book label treasury, got %q", label)
+	}
+	if _, ok := labels[common.HexToAddress("0xfff9976782d46cc05630d1f6ebab18b2324d6b14")]; ok {
+		t.Error("Expected contracts of other networks to be left out")
+	}
+
+	// The connected chain picks the contracts, whatever the default network
+	aliases = cfg.AddressAliases(11155111)
+	if _, ok := aliases["usdt"]; ok {
+		t.Error("Expected mainnet contracts to be left out on Sepolia")
+	}
+	if aliases["weth"].Hex() != "0xfFf9976782d46CC05630D1f6eBAb18b2324d6B14" {
+		t.Errorf("Expected the Sepolia weth, got %s", aliases["weth"].Hex())
+	}
+	if label := cfg.AddressLabels(11155111)[common.HexToAddress("0xdac17f958d2ee523a2206206994597c13d831ec7")]; label != "" {
+		t.Errorf("Expected no mainnet label on Sepolia, got %q", label)
+	}
+
+	// An unknown chain only has the address book
+	aliases = cfg.AddressAliases(999)
+	if len(aliases) != 2 || aliases["usdc"].Hex() != "0x0000000000000000000000000000000000000001" {
+		t.Errorf("Expected only the address book on an unknown chain, got %v", aliases)
+	}
+}
+
+func TestValidateConfig_AddressNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		addressBook map[string]string
+		contracts   map[string]string
+		expectedErr string
+	}{
+		{
+			name:        "Invalid address book name",
+			addressBook: map[string]string{"my wallet": "0x742d35cc6634c0532925a3b844bc454e4438f44e"},
+			expectedErr: "address book: invalid name",
+		},
+		{
+			name:        "Invalid address book address",
+			addressBook: map[string]string{"wallet": "0x1234"},
+			expectedErr: "wallet is not a valid address",
+		},
+		{
+			name:        "Invalid contract address",
+			contracts:   map[string]string{"usdc": "usdc.eth"},
+			expectedErr: "network test contracts: usdc is not a valid address",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Node:  NodeConfig{URL: "http://localhost:8545"},
+				Query: QueryConfig{MaxBlockRange: 1000, TimeoutSeconds: 30},
+				Networks: NetworksConfig{
+					"test": {ChainID: 1, NodeURL: "http://localhost:8545", Contracts: tt.contracts},
+				},
+				AddressBook: tt.addressBook,
+			}
+
+			err := ValidateConfig(cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
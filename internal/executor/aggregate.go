@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+// groupRecords collapses records into one record per GROUP BY key, holding the key
+// columns and the value of every aggregate under its canonical name, then applies
+// HAVING. Groups keep the order in which their first row appeared. Without GROUP BY
+// all records form a single group, which exists even when there are no records.
+func groupRecords(records []queries.Record, query *queries.Query) ([]queries.Record, error) {
+	if !query.Grouped() {
+		return records, nil
+	}
+
+	type group struct {
+		record       queries.Record
+		accumulators []*queries.Accumulator
+	}
+
+	newGroup := func(record queries.Record) *group {
+		g := &group{record: queries.Record{}}
+		for _, expr := range query.GroupBy {
+			if column, ok := expr.(*queries.ColumnRef); ok {
+				if value, ok := record[column.Name]; ok {
+					g.record[column.Name] = value
+				}
+			}
+		}
+		for _, agg := range query.Aggregates {
+			g.accumulators = append(g.accumulators, queries.NewAccumulator(agg))
+		}
+		return g
+	}
+
+	var groups []*group
+	index := make(map[string]*group)
+	for _, record := range records {
+		key, err := groupKey(record, query.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+		g, ok := index[key]
+		if !ok {
+			g = newGroup(record)
+			index[key] = g
+			groups = append(groups, g)
+		}
+		for i, acc := range g.accumulators {
+			if err := acc.Add(record); err != nil {
+				return nil, fmt.Errorf("error evaluating %s: %w", query.Aggregates[i], err)
+			}
+		}
+	}
+
+	if len(groups) == 0 && len(query.GroupBy) == 0 {
+		groups = append(groups, newGroup(queries.Record{}))
+	}
+
+	grouped := make([]queries.Record, 0, len(groups))
+	for _, g := range groups {
+		for i, acc := range g.accumulators {
+			if value := acc.Result(); value != nil {
+				g.record[query.Aggregates[i].String()] = value
+			}
+		}
+
+		ok, err := queries.Matches(query.Having, g.record)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating HAVING clause: %w", err)
+		}
+		if ok {
+			grouped = append(grouped, g.record)
+		}
+	}
+	return grouped, nil
+}
+
+// groupKey identifies the group of a record. Values carry their Go type so that,
+// for example, NULL and the string "NULL" never share a group.
+func groupKey(record queries.Record, groupBy []queries.Expr) (string, error) {
+	var key strings.Builder
+	for _, expr := range groupBy {
+		value, err := expr.Eval(record)
+		if err != nil {
+			return "", fmt.Errorf("error evaluating GROUP BY %s: %w", expr, err)
+		}
+		fmt.Fprintf(&key, "%T:%s\x00", value, queries.FormatValue(value))
+	}
+	return key.String(), nil
+}
@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGroupRecords(t *testing.T) {
+	alice := common.HexToAddress("0x01")
+	bob := common.HexToAddress("0x02")
+
+	// Large enough that a float64 sum would lose precision
+	big1, _ := new(big.Int).SetString("1000000000000000000000000001", 10)
+	big2, _ := new(big.Int).SetString("2000000000000000000000000002", 10)
+
+	records := []queries.Record{
+		{"from": bob, "value": big.NewInt(5)},
+		{"from": alice, "value": big1},
+		{"from": bob},
+		{"from": alice, "value": big2},
+		{"from": bob, "value": big.NewInt(2)},
+	}
+
+	from := &queries.ColumnRef{Name: "from"}
+	value := &queries.ColumnRef{Name: "value"}
+	count := queries.Aggregate{Func: "COUNT"}
+	sum := queries.Aggregate{Func: "SUM", Arg: value}
+	avg := queries.Aggregate{Func: "AVG", Arg: value}
+	countValue := queries.Aggregate{Func: "COUNT", Arg: value}
+	maxValue := queries.Aggregate{Func: "MAX", Arg: value}
+
+	query := &queries.Query{
+		GroupBy:    []queries.Expr{from},
+		Aggregates: []queries.Aggregate{count, sum, avg, countValue, maxValue},
+	}
+
+	groups, err := groupRecords(records, query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(groups))
+	}
+
+	// Groups keep the order of their first row
+	if groups[0]["from"] != bob || groups[1]["from"] != alice {
+		t.Fatalf("Unexpected group order: %v, %v", groups[0]["from"], groups[1]["from"])
+	}
+
+	expected := []map[string]string{
+		{"COUNT(*)": "3", "SUM(value)": "7", "AVG(value)": "3", "COUNT(value)": "2", "MAX(value)": "5"},
+		{"COUNT(*)": "2", "SUM(value)": "3000000000000000000000000003", "AVG(value)": "1500000000000000000000000001", "COUNT(value)": "2", "MAX(value)": "2000000000000000000000000002"},
+	}
+	for i, values := range expected {
+		for name, want := range values {
+			if got := queries.FormatValue(groups[i][name]); got != want {
+				t.Errorf("Group %d %s: expected %s, got %s", i, name, want, got)
+			}
+		}
+	}
+
+	query.Having = &queries.Comparison{Op: ">", Left: &queries.ColumnRef{Name: "COUNT(*)"}, Right: &queries.Literal{Value: big.NewInt(2)}}
+	groups, err = groupRecords(records, query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(groups) != 1 || groups[0]["from"] != bob {
+		t.Errorf("Expected HAVING to keep only the first group, got %v", groups)
+	}
+}
+
+func TestGroupRecords_Empty(t *testing.T) {
+	query := &queries.Query{
+		Aggregates: []queries.Aggregate{
+			{Func: "COUNT"},
+			{Func: "SUM", Arg: &queries.ColumnRef{Name: "value"}},
+		},
+	}
+
+	groups, err := groupRecords(nil, query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("Expected a single group without GROUP BY, got %d", len(groups))
+	}
+	if got := queries.FormatValue(groups[0]["COUNT(*)"]); got != "0" {
+		t.Errorf("Expected COUNT(*) of 0, got %s", got)
+	}
+	if groups[0]["SUM(value)"] != nil {
+		t.Errorf("Expected NULL SUM over no rows, got %v", groups[0]["SUM(value)"])
+	}
+
+	query.GroupBy = []queries.Expr{&queries.ColumnRef{Name: "from"}}
+	groups, err = groupRecords(nil, query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("Expected no groups, got %d", len(groups))
+	}
+}
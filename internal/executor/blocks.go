@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// resolveBlocks returns a copy of query whose block bounds are concrete numbers,
+// with time ranges located by block timestamp and open ranges turned into windows
+// of blocks, along with metadata recording them.
+// Tags are resolved once per query, so "BLOCK latest latest" is always a single block.
+func (qe *QueryExecutor) resolveBlocks(ctx context.Context, query *queries.Query) (*queries.Query, queries.ResultMeta, error) {
+	resolved := *query
+	var meta queries.ResultMeta
+
+	numbers := make(map[int64]*big.Int)
+	resolve := func(block *big.Int) (*big.Int, error) {
+		if !queries.IsBlockTag(block) {
+			return block, nil
+		}
+		if number, ok := numbers[block.Int64()]; ok {
+			return number, nil
+		}
+		header, err := qe.client.HeaderByNumber(ctx, block)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving %s block: %w", queries.FormatBlock(block), err)
+		}
+		numbers[block.Int64()] = header.Number
+		return header.Number, nil
+	}
+
+	// Transactions named by hash are found wherever they are, and the transaction
+	// pool is read as it stands
+	if len(query.TxHashes) > 0 || query.Method == "PENDING" {
+		return &resolved, meta, nil
+	}
+
+	latest := big.NewInt(rpc.LatestBlockNumber.Int64())
+	pending := big.NewInt(rpc.PendingBlockNumber.Int64())
+
+	if query.FromTime != nil || query.ToTime != nil {
+		head, err := resolve(latest)
+		if err != nil {
+			return nil, meta, err
+		}
+		from, to, err := qe.blocksForTimes(ctx, head.Uint64(), query.FromTime, query.ToTime)
+		if err != nil {
+			return nil, meta, err
+		}
+		resolved.FromBlock, resolved.ToBlock = from, to
+	}
+
+	if queries.IsStateMethod(query.Method) {
+		block := resolved.FromBlock
+		if block == nil {
+			block = resolved.ToBlock
+		}
+		if block == nil {
+			block = latest
+		}
+		number, err := resolve(block)
+		if err != nil {
+			return nil, meta, err
+		}
+		meta.FromBlock, meta.ToBlock = number, number
+
+		// The pending state can only be addressed through its tag
+		if block.Cmp(pending) != 0 {
+			resolved.FromBlock, resolved.ToBlock = number, number
+		}
+		return &resolved, meta, nil
+	}
+
+	// Without a start, scan a window ending at the given block, or at the latest one
+	if resolved.FromBlock == nil {
+		to := resolved.ToBlock
+		if to == nil || to.Cmp(pending) == 0 {
+			to = latest
+		}
+		number, err := resolve(to)
+		if err != nil {
+			return nil, meta, err
+		}
+		window := resolved.BlockWindow
+		if window <= 0 {
+			window = qe.defaultBlockRange
+		}
+		from := new(big.Int).Sub(number, big.NewInt(window-1))
+		if from.Sign() < 0 {
+			from.SetInt64(0)
+		}
+		resolved.FromBlock, resolved.ToBlock = from, number
+	}
+
+	// Ranges end at the latest block; the pending block cannot be fetched by number
+	bounds := []*big.Int{resolved.FromBlock, resolved.ToBlock}
+	for i, block := range bounds {
+		if block.Cmp(pending) == 0 {
+			block = latest
+		}
+		number, err := resolve(block)
+		if err != nil {
+			return nil, meta, err
+		}
+		bounds[i] = number
+	}
+	if bounds[0].Cmp(bounds[1]) > 0 {
+		return nil, meta, fmt.Errorf("from block %s (%s) is after to block %s (%s)",
+			bounds[0], queries.FormatBlock(query.FromBlock), bounds[1], queries.FormatBlock(query.ToBlock))
+	}
+
+	resolved.FromBlock, resolved.ToBlock = bounds[0], bounds[1]
+	meta.FromBlock, meta.ToBlock = bounds[0], bounds[1]
+	return &resolved, meta, nil
+}
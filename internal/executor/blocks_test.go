@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestResolveBlocks(t *testing.T) {
+	chain := &testChain{
+		head:   1000,
+		tagged: map[rpc.BlockNumber]uint64{rpc.SafeBlockNumber: 990, rpc.FinalizedBlockNumber: 960},
+	}
+	qe := newTestExecutor(t, chain)
+	qe.SetDefaultBlockRange(50)
+
+	tag := func(name string) *big.Int {
+		n, _ := queries.BlockTag(name)
+		return n
+	}
+
+	tests := []struct {
+		name         string
+		query        *queries.Query
+		expectedFrom string // FromBlock of the resolved query
+		expectedTo   string
+		metaFrom     string
+		metaTo       string
+		expectedErr  string
+	}{
+		{
+			name:         "Balance defaults to the latest block",
+			query:        &queries.Query{Method: "BALANCE"},
+			expectedFrom: "1000",
+			expectedTo:   "1000",
+			metaFrom:     "1000",
+			metaTo:       "1000",
+		},
+		{
+			name:         "Pending balance keeps its tag",
+			query:        &queries.Query{Method: "BALANCE", FromBlock: tag("pending"), ToBlock: tag("pending")},
+			expectedFrom: "pending",
+			expectedTo:   "pending",
+			metaFrom:     "1001",
+			metaTo:       "1001",
+		},
+		{
+			name:         "Transactions default window",
+			query:        &queries.Query{Method: "TRANSACTIONS"},
+			expectedFrom: "951",
+			expectedTo:   "1000",
+			metaFrom:     "951",
+			metaTo:       "1000",
+		},
+		{
+			name:         "Last blocks",
+			query:        &queries.Query{Method: "LOGS", ToBlock: tag("latest"), BlockWindow: 500},
+			expectedFrom: "501",
+			expectedTo:   "1000",
+			metaFrom:     "501",
+			metaTo:       "1000",
+		},
+		{
+			name:         "Until uses the default window",
+			query:        &queries.Query{Method: "TRANSACTIONS", ToBlock: big.NewInt(120)},
+			expectedFrom: "71",
+			expectedTo:   "120",
+			metaFrom:     "71",
+			metaTo:       "120",
+		},
+		{
+			name:         "Window is clamped at genesis",
+			query:        &queries.Query{Method: "LOGS", ToBlock: big.NewInt(10), BlockWindow: 500},
+			expectedFrom: "0",
+			expectedTo:   "10",
+			metaFrom:     "0",
+			metaTo:       "10",
+		},
+		{
+			name:         "Since runs to latest",
+			query:        &queries.Query{Method: "LOGS", FromBlock: big.NewInt(990), ToBlock: tag("latest")},
+			expectedFrom: "990",
+			expectedTo:   "1000",
+			metaFrom:     "990",
+			metaTo:       "1000",
+		},
+		{
+			name:         "Tags on both ends",
+			query:        &queries.Query{Method: "LOGS", FromBlock: tag("finalized"), ToBlock: tag("safe")},
+			expectedFrom: "960",
+			expectedTo:   "990",
+			metaFrom:     "960",
+			metaTo:       "990",
+		},
+		{
+			name:         "Pending range ends at latest",
+			query:        &queries.Query{Method: "LOGS", FromBlock: big.NewInt(5), ToBlock: tag("pending")},
+			expectedFrom: "5",
+			expectedTo:   "1000",
+			metaFrom:     "5",
+			metaTo:       "1000",
+		},
+		{
+			name:         "Logs default window",
+			query:        &queries.Query{Method: "LOGS"},
+			expectedFrom: "951",
+			expectedTo:   "1000",
+			metaFrom:     "951",
+			metaTo:       "1000",
+		},
+		{
+			name:        "Range that resolves backwards",
+			query:       &queries.Query{Method: "LOGS", FromBlock: tag("latest"), ToBlock: tag("finalized")},
+			expectedErr: "from block 1000 (latest) is after to block 960 (finalized)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, meta, err := qe.resolveBlocks(context.Background(), tt.query)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("Expected error containing '%s', got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if got := queries.FormatBlock(resolved.FromBlock); got != tt.expectedFrom {
+				t.Errorf("Expected from block %s, got %s", tt.expectedFrom, got)
+			}
+			if got := queries.FormatBlock(resolved.ToBlock); got != tt.expectedTo {
+				t.Errorf("Expected to block %s, got %s", tt.expectedTo, got)
+			}
+			if got := meta.FromBlock.String(); got != tt.metaFrom {
+				t.Errorf("Expected metadata from block %s, got %s", tt.metaFrom, got)
+			}
+			if got := meta.ToBlock.String(); got != tt.metaTo {
+				t.Errorf("Expected metadata to block %s, got %s", tt.metaTo, got)
+			}
+		})
+	}
+}
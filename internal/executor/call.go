@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// getCalls runs the query's function against every queried address with eth_call.
+// A call that reverts or returns data that does not decode yields a row with the
+// reason in its error field rather than failing the query.
+func (qe *QueryExecutor) getCalls(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	method := query.Call
+	packed, err := method.Inputs.Pack(query.CallArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding arguments of %s: %w", method.Sig, err)
+	}
+	data := append(append([]byte(nil), method.ID...), packed...)
+
+	msg := ethereum.CallMsg{Data: data}
+	var sender string
+	if query.Sender != nil {
+		msg.From, sender = *query.Sender, query.Sender.Hex()
+	}
+	estimate := query.Uses("gas_estimate")
+
+	return qe.getAccounts(ctx, query, func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error) {
+		record := callRecord(address, method, recordBlock)
+		if estimate {
+			gas, err := qe.estimateGas(ctx, query.Sender, address, data, block)
+			if err != nil {
+				return nil, fmt.Errorf("error estimating gas of %s on %s: %w", method.Sig, address.Hex(), err)
+			}
+			if gas.reason == "" {
+				record["gas_estimate"] = uint64ToBig(gas.gas)
+			}
+		}
+
+		msg := msg
+		msg.To = &address
+		out, err := qe.stateAt("call", []interface{}{sender, address.Hex(), hexutil.Encode(data)}, block, func() (interface{}, error) {
+			return qe.client.CallContract(ctx, msg, block)
+		})
+		if err != nil {
+			reason, reverted := revertReason(err)
+			if !reverted {
+				return nil, fmt.Errorf("error calling %s on %s: %w", method.Sig, address.Hex(), err)
+			}
+			record["error"] = reason
+			return []queries.Record{record}, nil
+		}
+		addCallResult(record, method, out.([]byte))
+		return []queries.Record{record}, nil
+	})
+}
+
+// addCallResult decodes the data returned by a call of method into the result fields
+// of record (see queries.ResultFields)
+func addCallResult(record queries.Record, method *abi.Method, out []byte) {
+	outputs := method.Outputs
+	if len(outputs) == 0 {
+		record["result"] = out
+		return
+	}
+	// Accounts without code answer every call with empty data
+	if len(out) == 0 {
+		record["error"] = "call returned no data"
+		return
+	}
+	values, err := outputs.Unpack(out)
+	if err != nil {
+		record["error"] = fmt.Sprintf("cannot decode result: %v", err)
+		return
+	}
+	if len(outputs) == 1 {
+		record["result"] = argValue(values[0])
+		return
+	}
+	record["result"] = encodeArgs(outputs, values)
+	for i, output := range outputs {
+		record[queries.ResultPrefix+queries.ArgName(output, i)] = argValue(values[i])
+	}
+}
+
+// revertReason reports whether err is a node's answer that the call reverted, with
+// the decoded Error(string) reason when the contract gave one
+func revertReason(err error) (string, bool) {
+	if !strings.Contains(strings.ToLower(err.Error()), "revert") {
+		return "", false
+	}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if text, ok := dataErr.ErrorData().(string); ok {
+			if data, decodeErr := hexutil.Decode(text); decodeErr == nil {
+				if reason, unpackErr := abi.UnpackRevert(data); unpackErr == nil {
+					return "execution reverted: " + reason, true
+				}
+			}
+		}
+	}
+	return err.Error(), true
+}
@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// testToken is the contract testChain answers calls of, with the functions of testTokenABI
+var testToken = common.HexToAddress("0x0000000000000000000000000000000000007070")
+
+const testTokenABI = `[
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getReserves","stateMutability":"view","inputs":[],"outputs":[{"name":"reserve0","type":"uint112"},{"name":"reserve1","type":"uint112"},{"name":"","type":"uint32"}]},
+	{"type":"function","name":"pause","stateMutability":"view","inputs":[],"outputs":[]},
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+var testTokenContract = mustParseABI(testTokenABI)
+
+// revertError is a node's answer to a call that reverted with an Error(string) reason
+type revertError struct {
+	reason string
+}
+
+func (e revertError) Error() string  { return "execution reverted: " + e.reason }
+func (e revertError) ErrorCode() int { return 3 }
+func (e revertError) ErrorData() interface{} {
+	reason, _ := abi.Arguments{{Type: mustType("string")}}.Pack(e.reason)
+	return hexutil.Encode(append([]byte{0x08, 0xc3, 0x79, 0xa0}, reason...))
+}
+
+// tokenCall answers a call of testToken at block n: an owner's balance is n times the
+// last byte of its address, the reserves are n and 2n, the total supply is n whole
+// tokens of 6 decimals, and pause always reverts
+func (c *testChain) tokenCall(input []byte, n uint64) (hexutil.Bytes, error) {
+	method, err := testTokenContract.MethodById(input[:4])
+	if err != nil {
+		return nil, revertError{reason: "unknown function"}
+	}
+	c.mu.Lock()
+	if c.tokenCalls == nil {
+		c.tokenCalls = make(map[string]int)
+	}
+	c.tokenCalls[method.Name]++
+	c.mu.Unlock()
+	values, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	block := new(big.Int).SetUint64(n)
+	switch method.Name {
+	case "balanceOf":
+		owner := values[0].(common.Address)
+		return method.Outputs.Pack(new(big.Int).Mul(block, big.NewInt(int64(owner[common.AddressLength-1]))))
+	case "getReserves":
+		return method.Outputs.Pack(block, new(big.Int).Mul(block, big.NewInt(2)), uint32(n))
+	case "name":
+		return method.Outputs.Pack("Test Token")
+	case "symbol":
+		return method.Outputs.Pack("TT")
+	case "decimals":
+		return method.Outputs.Pack(uint8(6))
+	case "totalSupply":
+		return method.Outputs.Pack(new(big.Int).Mul(block, big.NewInt(1e6)))
+	}
+	return nil, revertError{reason: "paused"}
+}
+
+func TestExecute_Call(t *testing.T) {
+	chain, alice, _ := newENSChain()
+	empty := common.HexToAddress("0x0000000000000000000000000000000000000e77")
+	balanceOf := testTokenContract.Methods["balanceOf"]
+	getReserves := testTokenContract.Methods["getReserves"]
+	pause := testTokenContract.Methods["pause"]
+
+	tests := []struct {
+		name      string
+		method    *abi.Method
+		args      []interface{}
+		addresses []common.Address
+		block     int64
+		expected  []queries.Record
+	}{
+		{
+			name:      "Single result",
+			method:    &balanceOf,
+			args:      []interface{}{common.HexToAddress("0x0000000000000000000000000000000000000003")},
+			addresses: []common.Address{testToken},
+			block:     900,
+			expected: []queries.Record{
+				{"address": testToken, "function": "balanceOf(address)", "result": big.NewInt(2700), "block_number": big.NewInt(900)},
+			},
+		},
+		{
+			name:      "ENS argument",
+			method:    &balanceOf,
+			args:      []interface{}{queries.ENSName("alice.eth")},
+			addresses: []common.Address{testToken},
+			block:     400,
+			expected: []queries.Record{
+				{"address": testToken, "function": "balanceOf(address)", "result": big.NewInt(400 * int64(alice[common.AddressLength-1])), "block_number": big.NewInt(400)},
+			},
+		},
+		{
+			name:      "Several results",
+			method:    &getReserves,
+			addresses: []common.Address{testToken},
+			block:     900,
+			expected: []queries.Record{
+				{
+					"address":         testToken,
+					"function":        "getReserves()",
+					"result":          `{"reserve0":900,"reserve1":1800,"arg2":900}`,
+					"result.reserve0": big.NewInt(900),
+					"result.reserve1": big.NewInt(1800),
+					"result.arg2":     big.NewInt(900),
+					"block_number":    big.NewInt(900),
+				},
+			},
+		},
+		{
+			name:      "Revert and missing code",
+			method:    &pause,
+			addresses: []common.Address{testToken, empty},
+			block:     900,
+			expected: []queries.Record{
+				{"address": testToken, "function": "pause()", "error": "execution reverted: paused", "block_number": big.NewInt(900)},
+				{"address": empty, "function": "pause()", "result": []byte{}, "block_number": big.NewInt(900)},
+			},
+		},
+		{
+			name:      "No data",
+			method:    &balanceOf,
+			args:      []interface{}{alice},
+			addresses: []common.Address{empty},
+			block:     900,
+			expected: []queries.Record{
+				{"address": empty, "function": "balanceOf(address)", "error": "call returned no data", "block_number": big.NewInt(900)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qe := newTestExecutor(t, chain)
+			query := &queries.Query{
+				Method:    "CALL",
+				Addresses: tt.addresses,
+				Call:      tt.method,
+				CallArgs:  tt.args,
+				FromBlock: big.NewInt(tt.block),
+				ToBlock:   big.NewInt(tt.block),
+			}
+			result, err := qe.Execute(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.Len() != len(tt.expected) {
+				t.Fatalf("Expected %d rows, got %d", len(tt.expected), result.Len())
+			}
+
+			schema := query.Schema().Default().Names()
+			for i, expected := range tt.expected {
+				for j, name := range schema {
+					if name == "block_timestamp" {
+						continue
+					}
+					got, want := result.Rows[i][j], expected[name]
+					if cmp, err := queries.CompareValues(got, want); !reflect.DeepEqual(got, want) && (err != nil || cmp != 0) {
+						t.Errorf("Row %d: expected %s %v, got %v", i, name, want, got)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/internal/selectors"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// maxCallDepth bounds how deeply calls batched by multicall wrappers are unwrapped
+const maxCallDepth = 3
+
+// batchFunctions names the wrappers whose arguments hold further calls: either
+// bytes[] calls to the same contract or tuples of a target address and call data
+var batchFunctions = map[string]bool{
+	"multicall":            true,
+	"aggregate":            true,
+	"tryAggregate":         true,
+	"blockAndAggregate":    true,
+	"tryBlockAndAggregate": true,
+	"aggregate3":           true,
+	"aggregate3Value":      true,
+}
+
+// decodedCall is a contract call decoded from its input
+type decodedCall struct {
+	to       *common.Address
+	selector []byte
+	method   *abi.Method // nil when the selector is not known
+	args     []interface{}
+	calls    []decodedCall // calls batched by a multicall wrapper
+}
+
+// lookupMethod identifies the function called by input, preferring the registered
+// ABI of the called contract over the bundled selector list
+func (qe *QueryExecutor) lookupMethod(to *common.Address, selector []byte) (*abi.Method, bool) {
+	if to != nil {
+		if contract, ok := qe.abis[*to]; ok {
+			if method, err := contract.MethodById(selector); err == nil {
+				return method, true
+			}
+		}
+	}
+	method, ok := selectors.Lookup(selector)
+	return &method, ok
+}
+
+// decodeCall decodes the function and arguments of a call to to. It returns false
+// for input too short to hold a selector.
+func (qe *QueryExecutor) decodeCall(to *common.Address, input []byte, depth int) (decodedCall, bool) {
+	if len(input) < 4 {
+		return decodedCall{}, false
+	}
+	call := decodedCall{to: to, selector: input[:4]}
+
+	method, ok := qe.lookupMethod(to, call.selector)
+	if !ok {
+		return call, true
+	}
+	args, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		logger.Debug("call not decoded", "method", method.Sig, "error", err)
+		return call, true
+	}
+	call.method, call.args = method, args
+
+	if batchFunctions[method.RawName] && depth < maxCallDepth {
+		for _, arg := range args {
+			for _, nested := range batchedCalls(to, arg) {
+				if decoded, ok := qe.decodeCall(nested.to, nested.input, depth+1); ok {
+					call.calls = append(call.calls, decoded)
+				}
+			}
+		}
+	}
+	return call, true
+}
+
+// batchedCall is a call found in the arguments of a multicall wrapper
+type batchedCall struct {
+	to    *common.Address
+	input []byte
+}
+
+// batchedCalls extracts the calls in a wrapper argument: a bytes[] of calls to the
+// wrapper's own contract, or an array of tuples whose first address is the target
+// and whose last bytes field is the call data
+func batchedCalls(to *common.Address, arg interface{}) []batchedCall {
+	if inputs, ok := arg.([][]byte); ok {
+		calls := make([]batchedCall, len(inputs))
+		for i, input := range inputs {
+			calls[i] = batchedCall{to: to, input: input}
+		}
+		return calls
+	}
+
+	v := reflect.ValueOf(arg)
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	var calls []batchedCall
+	for i := 0; i < v.Len(); i++ {
+		var call batchedCall
+		element := v.Index(i)
+		for j := 0; j < element.NumField(); j++ {
+			switch field := element.Field(j).Interface().(type) {
+			case common.Address:
+				if call.to == nil {
+					target := field
+					call.to = &target
+				}
+			case []byte:
+				call.input = field
+			}
+		}
+		if call.to != nil && call.input != nil {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// addCallFields fills in the selector, method, args and calls fields of a
+// transaction record from its input
+func (qe *QueryExecutor) addCallFields(record queries.Record, to *common.Address, input []byte) {
+	call, ok := qe.decodeCall(to, input, 0)
+	if !ok {
+		return
+	}
+	record["selector"] = call.selector
+	if call.method == nil {
+		return
+	}
+	record["method"] = call.method.RawName
+	record["args"] = encodeArgs(call.method.Inputs, call.args)
+	if len(call.calls) > 0 {
+		record["calls"] = encodeCalls(call.calls)
+	}
+}
+
+// encodeArgs renders decoded arguments as a JSON object, keeping their order
+func encodeArgs(inputs abi.Arguments, values []interface{}) string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(queries.ArgName(inputs[i], i))
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.WriteString(encodeValue(value))
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// encodeCalls renders batched calls as a JSON array of objects with the target,
+// the method and its arguments, or the bare selector when the method is unknown
+func encodeCalls(calls []decodedCall) string {
+	parts := make([]string, len(calls))
+	for i, call := range calls {
+		fields := []string{fmt.Sprintf(`"to":%q`, call.to.Hex())}
+		if call.method == nil {
+			fields = append(fields, fmt.Sprintf(`"selector":%q`, hexutil.Encode(call.selector)))
+		} else {
+			name, _ := json.Marshal(call.method.RawName)
+			fields = append(fields, `"method":`+string(name), `"args":`+encodeArgs(call.method.Inputs, call.args))
+		}
+		if len(call.calls) > 0 {
+			fields = append(fields, `"calls":`+encodeCalls(call.calls))
+		}
+		parts[i] = "{" + strings.Join(fields, ",") + "}"
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// encodeValue renders a value decoded by the ABI package as JSON, with integers as
+// numbers and addresses, hashes and bytes as hex strings
+func encodeValue(value interface{}) string {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return fmt.Sprintf("%q", v.Hex())
+	case common.Hash:
+		return fmt.Sprintf("%q", v.Hex())
+	case []byte:
+		return fmt.Sprintf("%q", hexutil.Encode(v))
+	case string:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	case bool, uint8, uint16, uint32, uint64, int8, int16, int32, int64:
+		return fmt.Sprint(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return fmt.Sprintf("%q", hexutil.Encode(b))
+		}
+		parts := make([]string, rv.Len())
+		for i := range parts {
+			parts[i] = encodeValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	case reflect.Struct:
+		// Tuples decode into structs whose JSON tags carry the ABI field names
+		parts := make([]string, rv.NumField())
+		for i := range parts {
+			field := rv.Type().Field(i)
+			name := field.Name
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" {
+				name = tag
+			}
+			parts[i] = fmt.Sprintf("%q:%s", name, encodeValue(rv.Field(i).Interface()))
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+	encoded, _ := json.Marshal(fmt.Sprint(value))
+	return string(encoded)
+}
@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/devlongs/evmql/internal/selectors"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestAddCallFields(t *testing.T) {
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	router := common.HexToAddress("0xE592427A0AEce92De3Edee1F18E0157C05861564")
+	multicall := common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+	holder := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+
+	pack := func(signature string, args ...interface{}) []byte {
+		method, err := selectors.ParseSignature(signature)
+		if err != nil {
+			t.Fatalf("Invalid signature %s: %v", signature, err)
+		}
+		data, err := method.Inputs.Pack(args...)
+		if err != nil {
+			t.Fatalf("Failed to pack %s: %v", signature, err)
+		}
+		return append(method.ID, data...)
+	}
+	transfer := pack("transfer(address,uint256)", holder, big.NewInt(1000))
+	unknown := hexutil.MustDecode("0xdeadbeef")
+
+	// Tuples are packed from structs whose fields follow the ABI components
+	type call3 struct {
+		F0 common.Address
+		F1 bool
+		F2 []byte
+	}
+	aggregate := pack("aggregate3((address,bool,bytes)[])", []call3{{token, false, transfer}, {router, true, unknown}})
+
+	qe := NewQueryExecutor(nil)
+	qe.SetABIs(map[common.Address]abi.ABI{token: mustParseABI(`[{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}]`)})
+
+	tests := []struct {
+		name     string
+		to       *common.Address
+		input    []byte
+		expected queries.Record
+	}{
+		{
+			name:  "Registered ABI names the arguments",
+			to:    &token,
+			input: transfer,
+			expected: queries.Record{
+				"selector": transfer[:4],
+				"method":   "transfer",
+				"args":     `{"to":"` + holder.Hex() + `","amount":1000}`,
+			},
+		},
+		{
+			name:  "Bundled selector",
+			to:    &router,
+			input: transfer,
+			expected: queries.Record{
+				"selector": transfer[:4],
+				"method":   "transfer",
+				"args":     `{"arg0":"` + holder.Hex() + `","arg1":1000}`,
+			},
+		},
+		{
+			name:     "Unknown selector",
+			to:       &router,
+			input:    unknown,
+			expected: queries.Record{"selector": unknown},
+		},
+		{
+			name:     "Plain transfer",
+			to:       &holder,
+			input:    nil,
+			expected: queries.Record{},
+		},
+		{
+			name:  "Multicall",
+			to:    &multicall,
+			input: aggregate,
+			expected: queries.Record{
+				"selector": aggregate[:4],
+				"method":   "aggregate3",
+				"args": `{"arg0":[{"f0":"` + token.Hex() + `","f1":false,"f2":"` + hexutil.Encode(transfer) + `"},` +
+					`{"f0":"` + router.Hex() + `","f1":true,"f2":"0xdeadbeef"}]}`,
+				"calls": `[{"to":"` + token.Hex() + `","method":"transfer","args":{"to":"` + holder.Hex() + `","amount":1000}},` +
+					`{"to":"` + router.Hex() + `","selector":"0xdeadbeef"}]`,
+			},
+		},
+		{
+			name:  "Multicall of calls to the same contract",
+			to:    &router,
+			input: pack("multicall(bytes[])", [][]byte{transfer}),
+			expected: queries.Record{
+				"selector": hexutil.MustDecode("0xac9650d8"),
+				"method":   "multicall",
+				"args":     `{"arg0":["` + hexutil.Encode(transfer) + `"]}`,
+				"calls":    `[{"to":"` + router.Hex() + `","method":"transfer","args":{"arg0":"` + holder.Hex() + `","arg1":1000}}]`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := queries.Record{}
+			qe.addCallFields(record, tt.to, tt.input)
+			if len(record) != len(tt.expected) {
+				t.Errorf("Expected fields %v, got %v", tt.expected, record)
+			}
+			for name, want := range tt.expected {
+				if got := record[name]; hexOrString(got) != hexOrString(want) {
+					t.Errorf("Expected %s = %v, got %v", name, hexOrString(want), hexOrString(got))
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeCalls(t *testing.T) {
+	holder := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	method, err := selectors.ParseSignature("transfer(address,uint256)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := method.Inputs.Pack(holder, big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []queries.Record{{"input": append(method.ID, data...)}, {"input": method.ID}}
+	decoded := decodeCalls(records, &method)
+
+	if decoded[0]["args.arg0"] != holder {
+		t.Errorf("Expected args.arg0 %s, got %v", holder.Hex(), decoded[0]["args.arg0"])
+	}
+	if v, ok := decoded[0]["args.arg1"].(*big.Int); !ok || v.Int64() != 5 {
+		t.Errorf("Expected args.arg1 5, got %v", decoded[0]["args.arg1"])
+	}
+	if decoded[0]["method"] != "transfer" {
+		t.Errorf("Expected method transfer, got %v", decoded[0]["method"])
+	}
+	if _, ok := decoded[1]["args.arg0"]; ok {
+		t.Error("Expected truncated input to leave the arguments NULL")
+	}
+	if _, ok := records[0]["args.arg0"]; ok {
+		t.Error("Expected the original records to be left unchanged")
+	}
+}
+
+// hexOrString renders byte fields as hex so that they compare as strings
+func hexOrString(value interface{}) interface{} {
+	if b, ok := value.([]byte); ok {
+		return hexutil.Encode(b)
+	}
+	return value
+}
@@ -0,0 +1,160 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// decodeLogs returns copies of records with the arguments of event decoded into
+// args.<name> fields. Logs that do not decode, such as those of another event
+// sharing its topic0, keep only their raw fields.
+func decodeLogs(records []queries.Record, event *abi.Event) []queries.Record {
+	decoded := make([]queries.Record, len(records))
+	for i, record := range records {
+		copied := copyRecord(record, len(event.Inputs))
+		decoded[i] = copied
+
+		args, err := decodeLog(record, event)
+		if err != nil {
+			logger.Debug("log not decoded", "event", event.Sig, "tx_hash", record["tx_hash"], "log_index", record["log_index"], "error", err)
+			continue
+		}
+		for name, value := range args {
+			copied[queries.ArgPrefix+name] = value
+		}
+	}
+	return decoded
+}
+
+// decodeCalls returns copies of transaction records with the arguments of method,
+// which their input calls, decoded into args.<name> fields. Input that does not
+// decode leaves the arguments NULL.
+func decodeCalls(records []queries.Record, method *abi.Method) []queries.Record {
+	decoded := make([]queries.Record, len(records))
+	for i, record := range records {
+		copied := copyRecord(record, len(method.Inputs))
+		decoded[i] = copied
+
+		input, _ := record["input"].([]byte)
+		if len(input) < 4 {
+			continue
+		}
+		args, err := method.Inputs.Unpack(input[4:])
+		if err != nil {
+			logger.Debug("call not decoded", "method", method.Sig, "hash", record["hash"], "error", err)
+			continue
+		}
+		// An inline declaration may name a function the selector list does not know
+		copied["method"] = method.RawName
+		copied["args"] = encodeArgs(method.Inputs, args)
+		for j, arg := range method.Inputs {
+			copied[queries.ArgPrefix+queries.ArgName(arg, j)] = argValue(args[j])
+		}
+	}
+	return decoded
+}
+
+// decodeLog decodes the arguments of event from the topics and data of a log record
+func decodeLog(record queries.Record, event *abi.Event) (map[string]interface{}, error) {
+	var topics []common.Hash
+	for i := 0; i < 4; i++ {
+		topic, ok := record[fmt.Sprintf("topic%d", i)].(common.Hash)
+		if !ok {
+			break
+		}
+		topics = append(topics, topic)
+	}
+	if !event.Anonymous {
+		if len(topics) == 0 || topics[0] != event.ID {
+			return nil, errors.New("topic0 does not match the event")
+		}
+		topics = topics[1:]
+	}
+
+	var indexed, nonIndexed abi.Arguments
+	for i, arg := range event.Inputs {
+		arg.Name = queries.ArgName(arg, i)
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		} else {
+			nonIndexed = append(nonIndexed, arg)
+		}
+	}
+	if len(topics) != len(indexed) {
+		return nil, fmt.Errorf("log has %d indexed topics, the event %d", len(topics), len(indexed))
+	}
+
+	values := make(map[string]interface{}, len(event.Inputs))
+	for i, arg := range indexed {
+		// Indexed tuples are stored as their hash, which the ABI package does not parse
+		if arg.Type.T == abi.TupleTy {
+			values[arg.Name] = topics[i]
+			continue
+		}
+		if err := abi.ParseTopicsIntoMap(values, abi.Arguments{arg}, topics[i:i+1]); err != nil {
+			return nil, err
+		}
+	}
+	data, _ := record["data"].([]byte)
+	if err := nonIndexed.UnpackIntoMap(values, data); err != nil {
+		return nil, err
+	}
+
+	for name, value := range values {
+		values[name] = argValue(value)
+	}
+	return values, nil
+}
+
+// copyRecord returns a copy of record with room for extra more fields, so that
+// records shared with the cache are never changed
+func copyRecord(record queries.Record, extra int) queries.Record {
+	copied := make(queries.Record, len(record)+extra)
+	for name, value := range record {
+		copied[name] = value
+	}
+	return copied
+}
+
+// argValue converts a value decoded by the ABI package to the Go type of its column
+// (see queries.ArgType)
+func argValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int, common.Address, common.Hash, bool, string, []byte:
+		return v
+	case uint8:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint16:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint32:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint64:
+		return new(big.Int).SetUint64(v)
+	case int8:
+		return big.NewInt(int64(v))
+	case int16:
+		return big.NewInt(int64(v))
+	case int32:
+		return big.NewInt(int64(v))
+	case int64:
+		return big.NewInt(v)
+	}
+
+	// Fixed-size byte arrays, such as bytes32
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return b
+	}
+
+	// Arrays and tuples
+	return encodeValue(value)
+}
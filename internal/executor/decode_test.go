@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeLogs(t *testing.T) {
+	contract := mustParseABI(`[
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+		{"type":"event","name":"Settled","inputs":[{"name":"id","type":"bytes32","indexed":true},{"name":"","type":"uint8","indexed":false},{"name":"amounts","type":"uint256[]","indexed":false},{"name":"memo","type":"string","indexed":false}]}
+	]`)
+	transfer, settled := contract.Events["Transfer"], contract.Events["Settled"]
+
+	from := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	to := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	value, err := transfer.Inputs.NonIndexed().Pack(big.NewInt(1500))
+	if err != nil {
+		t.Fatalf("Failed to pack transfer data: %v", err)
+	}
+
+	records := []queries.Record{
+		{
+			"topic0": transfer.ID,
+			"topic1": common.BytesToHash(from.Bytes()),
+			"topic2": common.BytesToHash(to.Bytes()),
+			"data":   value,
+		},
+		// An ERC-721 Transfer has the same topic0 but indexes its third parameter
+		{
+			"topic0": transfer.ID,
+			"topic1": common.BytesToHash(from.Bytes()),
+			"topic2": common.BytesToHash(to.Bytes()),
+			"topic3": common.BigToHash(big.NewInt(7)),
+			"data":   []byte{},
+		},
+	}
+
+	decoded := decodeLogs(records, &transfer)
+	if len(decoded) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(decoded))
+	}
+	if decoded[0]["args.from"] != from || decoded[0]["args.to"] != to {
+		t.Errorf("Expected args.from %s and args.to %s, got %v and %v", from.Hex(), to.Hex(), decoded[0]["args.from"], decoded[0]["args.to"])
+	}
+	if v, ok := decoded[0]["args.value"].(*big.Int); !ok || v.Int64() != 1500 {
+		t.Errorf("Expected args.value 1500, got %v", decoded[0]["args.value"])
+	}
+	if _, ok := decoded[1]["args.from"]; ok {
+		t.Error("Expected the ERC-721 log to stay undecoded")
+	}
+	if decoded[1]["topic3"] == nil {
+		t.Error("Expected the undecoded log to keep its raw fields")
+	}
+	if _, ok := records[0]["args.from"]; ok {
+		t.Error("Expected the original records to be left unchanged")
+	}
+
+	id := common.HexToHash("0x01")
+	data, err := settled.Inputs.NonIndexed().Pack(uint8(3), []*big.Int{big.NewInt(1), big.NewInt(2)}, "paid")
+	if err != nil {
+		t.Fatalf("Failed to pack settlement data: %v", err)
+	}
+	decoded = decodeLogs([]queries.Record{{"topic0": settled.ID, "topic1": id, "data": data}}, &settled)
+
+	expected := []struct {
+		name  string
+		value interface{}
+		typ   queries.Type
+	}{
+		{"args.id", id.Bytes(), queries.TypeBytes},
+		{"args.arg1", big.NewInt(3), queries.TypeInt},
+		{"args.amounts", "[1,2]", queries.TypeString},
+		{"args.memo", "paid", queries.TypeString},
+	}
+	fields := queries.ArgFields(settled.Inputs)
+	for i, want := range expected {
+		got := decoded[0][want.name]
+		if n, ok := want.value.(*big.Int); ok {
+			if v, ok := got.(*big.Int); !ok || v.Cmp(n) != 0 {
+				t.Errorf("Expected %s = %v, got %v (%T)", want.name, want.value, got, got)
+			}
+		} else if !reflect.DeepEqual(got, want.value) {
+			t.Errorf("Expected %s = %v, got %v (%T)", want.name, want.value, got, got)
+		}
+		if fields[i].Name != want.name || fields[i].Type != want.typ {
+			t.Errorf("Expected field %s of type %s, got %s of type %s", want.name, want.typ, fields[i].Name, fields[i].Type)
+		}
+	}
+}
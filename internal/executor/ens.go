@@ -0,0 +1,292 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ensRegistry is the address of the ENS registry, the same on mainnet and the public testnets
+var ensRegistry = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+// ensABI covers the registry and resolver functions used to resolve names both ways
+const ensABI = `[
+	{"type":"function","name":"resolver","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"addr","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"name","stateMutability":"view","inputs":[{"name":"node","type":"bytes32"}],"outputs":[{"name":"","type":"string"}]}
+]`
+
+var ensContract = mustParseABI(ensABI)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("invalid ABI: %v", err))
+	}
+	return parsed
+}
+
+// namehash computes the ENS node of a name, as defined by EIP-137
+func namehash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node.Bytes(), crypto.Keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// reverseNode returns the ENS node holding the primary name of address
+func reverseNode(address common.Address) common.Hash {
+	return namehash(strings.ToLower(address.Hex()[2:]) + ".addr.reverse")
+}
+
+// ensCall calls a single-argument ENS function at block. It returns nil when the
+// target has no code, as on chains without ENS.
+func (qe *QueryExecutor) ensCall(ctx context.Context, to common.Address, method string, node common.Hash, block *big.Int) (interface{}, error) {
+	data, err := ensContract.Pack(method, node)
+	if err != nil {
+		return nil, err
+	}
+	out, err := qe.client.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, block)
+	if err != nil {
+		return nil, fmt.Errorf("error calling ENS %s: %w", method, err)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	values, err := ensContract.Unpack(method, out)
+	if err != nil || len(values) != 1 {
+		return nil, fmt.Errorf("invalid ENS %s response: %x", method, out)
+	}
+	return values[0], nil
+}
+
+// ensResolver returns the resolver of node at block, or the zero address if it has none
+func (qe *QueryExecutor) ensResolver(ctx context.Context, node common.Hash, block *big.Int) (common.Address, error) {
+	value, err := qe.ensCall(ctx, ensRegistry, "resolver", node, block)
+	if err != nil {
+		return common.Address{}, err
+	}
+	resolver, _ := value.(common.Address)
+	return resolver, nil
+}
+
+// ensAddress resolves an ENS name at block, returning the zero address when the name
+// does not point to one. Results at numbered blocks are cached.
+func (qe *QueryExecutor) ensAddress(ctx context.Context, name string, block *big.Int) (common.Address, error) {
+	cacheable := block != nil && !queries.IsBlockTag(block)
+	cacheKey := cache.GenerateKey("ens_address", name, block)
+	if cacheable {
+		if cached, found := qe.cache.Get(cacheKey); found {
+			logger.Debug("cache hit", "key", cacheKey)
+			if address, ok := cached.(common.Address); ok {
+				return address, nil
+			}
+		}
+	}
+
+	node := namehash(name)
+	resolver, err := qe.ensResolver(ctx, node, block)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var address common.Address
+	if resolver != (common.Address{}) {
+		value, err := qe.ensCall(ctx, resolver, "addr", node, block)
+		if err != nil {
+			return common.Address{}, err
+		}
+		address, _ = value.(common.Address)
+	}
+
+	if cacheable {
+		qe.cache.Set(cacheKey, address, 0)
+	}
+	return address, nil
+}
+
+// ensName returns the primary ENS name of address at block, or "" if it has none.
+// Names whose forward record does not point back to address are not reported.
+func (qe *QueryExecutor) ensName(ctx context.Context, address common.Address, block *big.Int) (string, error) {
+	cacheable := block != nil && !queries.IsBlockTag(block)
+	cacheKey := cache.GenerateKey("ens_name", address.Hex(), block)
+	if cacheable {
+		if cached, found := qe.cache.Get(cacheKey); found {
+			logger.Debug("cache hit", "key", cacheKey)
+			if name, ok := cached.(string); ok {
+				return name, nil
+			}
+		}
+	}
+
+	var name string
+	node := reverseNode(address)
+	resolver, err := qe.ensResolver(ctx, node, block)
+	if err != nil {
+		return "", err
+	}
+	if resolver != (common.Address{}) {
+		value, err := qe.ensCall(ctx, resolver, "name", node, block)
+		if err != nil {
+			return "", err
+		}
+		name, _ = value.(string)
+	}
+	if name != "" {
+		forward, err := qe.ensAddress(ctx, name, block)
+		if err != nil {
+			return "", err
+		}
+		if forward != address {
+			name = ""
+		}
+	}
+
+	if cacheable {
+		qe.cache.Set(cacheKey, name, 0)
+	}
+	return name, nil
+}
+
+// resolveNames returns a copy of query with its ENS names, in FROM, TOKEN, COLLECTION
+// and SENDER, as CALL arguments and as address literals in WHERE and HAVING, resolved
+// at the query's last block
+func (qe *QueryExecutor) resolveNames(ctx context.Context, query *queries.Query) (*queries.Query, error) {
+	block := query.ToBlock
+	resolved := make(map[string]common.Address)
+	resolve := func(name string) (common.Address, error) {
+		if address, ok := resolved[name]; ok {
+			return address, nil
+		}
+		address, err := qe.ensAddress(ctx, name, block)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("error resolving ENS name %s: %w", name, err)
+		}
+		if address == (common.Address{}) {
+			return common.Address{}, fmt.Errorf("ENS name %s does not resolve to an address at block %s", name, queries.FormatBlock(block))
+		}
+		logger.Debug("resolved ENS name", "name", name, "address", address.Hex(), "block", block)
+		resolved[name] = address
+		return address, nil
+	}
+	mapName := func(value interface{}) (interface{}, error) {
+		if name, ok := value.(queries.ENSName); ok {
+			return resolve(string(name))
+		}
+		return value, nil
+	}
+
+	// resolveAll appends the addresses of names to addresses, dropping repeats
+	resolveAll := func(addresses []common.Address, names []string) ([]common.Address, error) {
+		addresses = append([]common.Address(nil), addresses...)
+		for _, name := range names {
+			address, err := resolve(name)
+			if err != nil {
+				return nil, err
+			}
+			if !containsAddress(addresses, address) {
+				addresses = append(addresses, address)
+			}
+		}
+		return addresses, nil
+	}
+
+	result := *query
+	if len(query.Names) > 0 {
+		addresses, err := resolveAll(query.Addresses, query.Names)
+		if err != nil {
+			return nil, err
+		}
+		result.Address, result.Addresses, result.Names = addresses[0], addresses, nil
+	}
+	if len(query.TokenNames) > 0 {
+		tokens, err := resolveAll(query.Tokens, query.TokenNames)
+		if err != nil {
+			return nil, err
+		}
+		result.Tokens, result.TokenNames = tokens, nil
+	}
+	if len(query.CollectionNames) > 0 {
+		collections, err := resolveAll(query.Collections, query.CollectionNames)
+		if err != nil {
+			return nil, err
+		}
+		result.Collections, result.CollectionNames = collections, nil
+	}
+	if query.SenderName != "" {
+		sender, err := resolve(query.SenderName)
+		if err != nil {
+			return nil, err
+		}
+		result.Sender, result.SenderName = &sender, ""
+	}
+
+	if len(query.CallArgs) > 0 {
+		result.CallArgs = make([]interface{}, len(query.CallArgs))
+		for i, arg := range query.CallArgs {
+			value, err := mapName(arg)
+			if err != nil {
+				return nil, err
+			}
+			result.CallArgs[i] = value
+		}
+	}
+
+	var err error
+	if result.Where, err = queries.MapLiterals(query.Where, mapName); err != nil {
+		return nil, err
+	}
+	if result.Having, err = queries.MapLiterals(query.Having, mapName); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// addENSNames fills in the ens_name field of records from their address field
+func (qe *QueryExecutor) addENSNames(ctx context.Context, records []queries.Record, block *big.Int) error {
+	names := make(map[common.Address]string)
+	for _, record := range records {
+		if _, ok := record["ens_name"]; ok {
+			continue
+		}
+		address, ok := record["address"].(common.Address)
+		if !ok {
+			continue
+		}
+		name, seen := names[address]
+		if !seen {
+			var err error
+			name, err = qe.ensName(ctx, address, block)
+			if err != nil {
+				return fmt.Errorf("error looking up ENS name of %s: %w", address.Hex(), err)
+			}
+			names[address] = name
+		}
+		if name != "" {
+			record["ens_name"] = name
+		}
+	}
+	return nil
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNamehash(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "", expected: "0x0000000000000000000000000000000000000000000000000000000000000000"},
+		{name: "eth", expected: "0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae"},
+		{name: "foo.eth", expected: "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f"},
+	}
+
+	for _, tt := range tests {
+		if got := namehash(tt.name).Hex(); got != tt.expected {
+			t.Errorf("namehash(%q): expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+// newENSChain returns a chain where alice.eth moves from alice to bob at block 500
+func newENSChain() (*testChain, common.Address, common.Address) {
+	alice := common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	chain := &testChain{
+		head: 1000,
+		ens: map[string][]ensEntry{
+			"alice.eth": {{address: alice, since: 0}, {address: bob, since: 500}},
+		},
+		reverse: map[common.Address]string{alice: "alice.eth"},
+	}
+	return chain, alice, bob
+}
+
+func TestResolveNames(t *testing.T) {
+	chain, alice, bob := newENSChain()
+	qe := newTestExecutor(t, chain)
+
+	where := &queries.In{
+		X:    &queries.ColumnRef{Name: "address"},
+		List: []queries.Expr{&queries.Literal{Value: queries.ENSName("alice.eth")}, &queries.Literal{Value: bob}},
+	}
+
+	tests := []struct {
+		block    int64
+		expected common.Address
+	}{
+		{block: 400, expected: alice},
+		{block: 500, expected: bob},
+	}
+
+	for _, tt := range tests {
+		query := &queries.Query{
+			Method:    "LOGS",
+			Names:     []string{"alice.eth"},
+			FromBlock: big.NewInt(tt.block - 10),
+			ToBlock:   big.NewInt(tt.block),
+			Where:     where,
+		}
+
+		resolved, err := qe.resolveNames(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Block %d: expected no error, got: %v", tt.block, err)
+		}
+		if len(resolved.Addresses) != 1 || resolved.Address != tt.expected {
+			t.Errorf("Block %d: expected addresses [%s], got %v", tt.block, tt.expected.Hex(), resolved.Addresses)
+		}
+		literal := resolved.Where.(*queries.In).List[0].(*queries.Literal)
+		if literal.Value != tt.expected {
+			t.Errorf("Block %d: expected WHERE literal %s, got %v", tt.block, tt.expected.Hex(), literal.Value)
+		}
+		if _, ok := query.Where.(*queries.In).List[0].(*queries.Literal).Value.(queries.ENSName); !ok {
+			t.Errorf("Block %d: expected the original query to be left untouched", tt.block)
+		}
+	}
+
+	_, err := qe.resolveNames(context.Background(), &queries.Query{Method: "LOGS", Names: []string{"nobody.eth"}, ToBlock: big.NewInt(100)})
+	if err == nil || !strings.Contains(err.Error(), "ENS name nobody.eth does not resolve to an address at block 100") {
+		t.Errorf("Expected an unresolved name error, got %v", err)
+	}
+}
+
+func TestResolveNames_Clauses(t *testing.T) {
+	chain, _, bob := newENSChain()
+	qe := newTestExecutor(t, chain)
+	block := big.NewInt(500)
+
+	tests := []struct {
+		name     string
+		query    queries.Query
+		expected queries.Query
+	}{
+		{
+			name:     "Token",
+			query:    queries.Query{Method: "TOKEN_BALANCE", Tokens: []common.Address{testToken}, TokenNames: []string{"alice.eth"}, ToBlock: block},
+			expected: queries.Query{Method: "TOKEN_BALANCE", Tokens: []common.Address{testToken, bob}, ToBlock: block},
+		},
+		{
+			name:     "Token given twice",
+			query:    queries.Query{Method: "TOKEN_BALANCE", Tokens: []common.Address{bob}, TokenNames: []string{"alice.eth"}, ToBlock: block},
+			expected: queries.Query{Method: "TOKEN_BALANCE", Tokens: []common.Address{bob}, ToBlock: block},
+		},
+		{
+			name:     "Collection",
+			query:    queries.Query{Method: "NFTS", CollectionNames: []string{"alice.eth"}, ToBlock: block},
+			expected: queries.Query{Method: "NFTS", Collections: []common.Address{bob}, ToBlock: block},
+		},
+		{
+			name:     "Sender",
+			query:    queries.Query{Method: "CALL", SenderName: "alice.eth", ToBlock: block},
+			expected: queries.Query{Method: "CALL", Sender: &bob, ToBlock: block},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := qe.resolveNames(context.Background(), &tt.query)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(*resolved, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *resolved)
+			}
+		})
+	}
+}
+
+func TestResolveNames_Latest(t *testing.T) {
+	chain, alice, bob := newENSChain()
+	chain.head = 400
+	qe := newTestExecutor(t, chain)
+	qe.SetCache(cache.NewInMemoryCache(100, time.Minute, time.Minute))
+
+	// Without a block, as in PENDING queries, names resolve at the latest block,
+	// which moves on, so they are resolved again every time
+	query := &queries.Query{Method: "PENDING", Names: []string{"alice.eth"}}
+	for _, expected := range []common.Address{alice, bob} {
+		resolved, err := qe.resolveNames(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(resolved.Addresses) != 1 || resolved.Address != expected {
+			t.Errorf("Expected addresses [%s], got %v", expected.Hex(), resolved.Addresses)
+		}
+		chain.head = 600
+	}
+}
+
+func TestExecute_ENSNameColumn(t *testing.T) {
+	chain, alice, bob := newENSChain()
+	qe := newTestExecutor(t, chain)
+
+	tests := []struct {
+		block    int64
+		expected interface{}
+	}{
+		{block: 400, expected: "alice.eth"},
+		// alice.eth points at bob by now, so alice's reverse record no longer verifies
+		{block: 600, expected: nil},
+	}
+
+	for _, tt := range tests {
+		query := &queries.Query{
+			Method:    "BALANCE",
+			Addresses: []common.Address{alice, bob},
+			FromBlock: big.NewInt(tt.block),
+			ToBlock:   big.NewInt(tt.block),
+			Fields: []queries.SelectItem{
+				{Name: "address", Expr: &queries.ColumnRef{Name: "address"}, Type: queries.TypeAddress},
+				{Name: "ens_name", Expr: &queries.ColumnRef{Name: "ens_name"}, Type: queries.TypeString},
+			},
+		}
+
+		result, err := qe.Execute(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Block %d: expected no error, got: %v", tt.block, err)
+		}
+		if result.Len() != 2 {
+			t.Fatalf("Block %d: expected 2 rows, got %d", tt.block, result.Len())
+		}
+		if got := result.Rows[0][1]; got != tt.expected {
+			t.Errorf("Block %d: expected ens_name %v for alice, got %v", tt.block, tt.expected, got)
+		}
+		if got := result.Rows[1][1]; got != nil {
+			t.Errorf("Block %d: expected no ens_name for bob, got %v", tt.block, got)
+		}
+	}
+}
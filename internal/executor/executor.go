@@ -1,37 +1,54 @@
 package executor
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math/big"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/devlongs/evmql/internal/cache"
 	"github.com/devlongs/evmql/internal/logger"
 	"github.com/devlongs/evmql/queries"
-	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 // QueryExecutor is responsible for executing queries
 type QueryExecutor struct {
-	client     *ethclient.Client
-	timeout    time.Duration
-	maxWorkers int
-	cache      cache.Cache
+	client            *ethclient.Client
+	timeout           time.Duration
+	maxWorkers        int
+	defaultBlockRange int64
+	defaultGasLimit   uint64
+	cache             cache.Cache
+	abis              map[common.Address]abi.ABI
+	noBlockReceipts   atomic.Bool // set once the node turns down eth_getBlockReceipts
+	noTraceFilter     atomic.Bool // set once the node turns down trace_filter
+	noTxPool          atomic.Bool // set once the node turns down txpool_content
+
+	mu          sync.Mutex
+	chainID     *big.Int                      // the connected chain, fetched on first use unless set
+	tokens      map[string]tokenMetadata      // token metadata by chain and token
+	collections map[string]collectionStandard // NFT standards by chain and collection
 }
 
 // NewQueryExecutor creates a new QueryExecutor instance
 func NewQueryExecutor(client *ethclient.Client) *QueryExecutor {
 	return &QueryExecutor{
-		client:     client,
-		timeout:    30 * time.Second,
-		maxWorkers: 5,
-		cache:      cache.NewNoOpCache(), // Default to no caching
+		client:            client,
+		timeout:           30 * time.Second,
+		maxWorkers:        5,
+		defaultBlockRange: 100,
+		defaultGasLimit:   3000000,
+		cache:             cache.NewNoOpCache(), // Default to no caching
+		tokens:            make(map[string]tokenMetadata),
+		collections:       make(map[string]collectionStandard),
 	}
 }
 
@@ -40,6 +57,20 @@ func (qe *QueryExecutor) SetCache(c cache.Cache) {
 	qe.cache = c
 }
 
+// SetABIs sets the contract ABIs used to decode transaction input, ahead of the
+// bundled function selectors
+func (qe *QueryExecutor) SetABIs(abis map[common.Address]abi.ABI) {
+	qe.abis = abis
+}
+
+// SetChainID sets the ID of the connected chain, which keys the token metadata the
+// executor keeps, saving a request for it
+func (qe *QueryExecutor) SetChainID(chainID *big.Int) {
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	qe.chainID = chainID
+}
+
 // SetTimeout sets the query execution timeout
 func (qe *QueryExecutor) SetTimeout(timeout time.Duration) {
 	qe.timeout = timeout
@@ -52,8 +83,24 @@ func (qe *QueryExecutor) SetMaxWorkers(maxWorkers int) {
 	}
 }
 
-// Execute runs the query and returns the result
-func (qe *QueryExecutor) Execute(ctx context.Context, query *queries.Query) (interface{}, error) {
+// SetDefaultBlockRange sets how many blocks, ending at the latest one, are scanned
+// by queries that do not give a starting block
+func (qe *QueryExecutor) SetDefaultBlockRange(blocks int64) {
+	if blocks > 0 {
+		qe.defaultBlockRange = blocks
+	}
+}
+
+// SetDefaultGasLimit sets the most gas a gas estimate may reach; calls that need more
+// are reported as failing
+func (qe *QueryExecutor) SetDefaultGasLimit(gas int64) {
+	if gas > 0 {
+		qe.defaultGasLimit = uint64(gas)
+	}
+}
+
+// Execute runs the query and returns its rows
+func (qe *QueryExecutor) Execute(ctx context.Context, query *queries.Query) (*queries.ResultSet, error) {
 	// Create a context with timeout if not already set
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
@@ -63,25 +110,81 @@ func (qe *QueryExecutor) Execute(ctx context.Context, query *queries.Query) (int
 
 	logger.Info("executing query",
 		"method", query.Method,
-		"address", query.Address.Hex(),
+		"addresses", query.AddressList(),
+		"names", query.Names,
 		"from_block", query.FromBlock,
 		"to_block", query.ToBlock)
 
 	startTime := time.Now()
-	var result interface{}
-	var err error
+	var records []queries.Record
+
+	resolved, meta, err := qe.resolveBlocks(ctx, query)
+	if err == nil {
+		resolved, err = qe.resolveNames(ctx, resolved)
+	}
+	if err != nil {
+		logger.Error("query execution failed",
+			"method", query.Method,
+			"error", err)
+		return nil, err
+	}
+	query = resolved
 
 	switch query.Method {
 	case "BALANCE":
-		result, err = qe.getBalance(ctx, query)
+		records, err = qe.getBalance(ctx, query)
+	case "CALL":
+		records, err = qe.getCalls(ctx, query)
+	case "CODE":
+		records, err = qe.getCode(ctx, query)
+	case "GAS_ESTIMATE":
+		records, err = qe.getGasEstimates(ctx, query)
+	case "NONCE":
+		records, err = qe.getNonce(ctx, query)
+	case "STORAGE":
+		records, err = qe.getStorage(ctx, query)
+	case "TOKEN_BALANCE":
+		records, err = qe.getTokenBalances(ctx, query)
+	case "TOKEN_INFO":
+		records, err = qe.getTokenInfo(ctx, query)
+	case "OWNER":
+		records, err = qe.getOwners(ctx, query)
+	case "NFTS":
+		records, err = qe.getNFTs(ctx, query)
+	case "BLOCKS":
+		records, err = qe.getBlocks(ctx, query)
+	case "FEES":
+		records, err = qe.getFees(ctx, query)
 	case "LOGS":
-		result, err = qe.getLogs(ctx, query)
-	case "TRANSACTIONS":
-		result, err = qe.getTransactionsConcurrent(ctx, query)
+		records, err = qe.getLogs(ctx, query)
+	case "TRANSFERS":
+		records, err = qe.getTransfers(ctx, query)
+	case "TRACES", "INTERNAL_TRANSFERS":
+		records, err = qe.getTraces(ctx, query)
+	case "STATE_DIFF":
+		records, err = qe.getStateDiffs(ctx, query)
+	case "PENDING":
+		records, err = qe.getPending(ctx, query)
+	case "TRANSACTIONS", "RECEIPTS":
+		records, err = qe.getTransactionsConcurrent(ctx, query)
 	default:
 		err = fmt.Errorf("unsupported select method: %s", query.Method)
 	}
 
+	var result *queries.ResultSet
+	if err == nil {
+		records, err = groupRecords(records, query)
+	}
+	if err == nil {
+		err = sortRecords(records, query.OrderBy)
+	}
+	if err == nil {
+		result, err = project(paginate(records, query.Offset, query.Limit), query)
+	}
+	if err == nil {
+		result.Meta = meta
+	}
+
 	duration := time.Since(startTime)
 	if err != nil {
 		logger.Error("query execution failed",
@@ -91,260 +194,236 @@ func (qe *QueryExecutor) Execute(ctx context.Context, query *queries.Query) (int
 	} else {
 		logger.Info("query execution completed",
 			"method", query.Method,
+			"rows", result.Len(),
+			"from_block", meta.FromBlock,
+			"to_block", meta.ToBlock,
 			"duration", duration)
 	}
 
 	return result, err
 }
 
-func (qe *QueryExecutor) getBalance(ctx context.Context, query *queries.Query) (*big.Int, error) {
-	blockNumber := (*big.Int)(nil)
-	if query.FromBlock != nil {
-		blockNumber = query.FromBlock
-	}
-
-	// Generate cache key
-	cacheKey := cache.GenerateKey("balance", query.Address.Hex(), blockNumber)
-
-	// Check cache first
-	if cached, found := qe.cache.Get(cacheKey); found {
-		logger.Debug("cache hit", "key", cacheKey)
-		if balance, ok := cached.(*big.Int); ok {
-			return balance, nil
+func (qe *QueryExecutor) getBalance(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	return qe.getAccounts(ctx, query, func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error) {
+		balance, err := qe.balanceAt(ctx, address, block)
+		if err != nil {
+			return nil, err
 		}
-	}
+		return []queries.Record{balanceRecord(address, balance, recordBlock)}, nil
+	})
+}
 
-	balance, err := qe.client.BalanceAt(ctx, query.Address, blockNumber)
+// balanceAt fetches the balance of address at blockNumber, caching balances of numbered blocks
+func (qe *QueryExecutor) balanceAt(ctx context.Context, address common.Address, blockNumber *big.Int) (*big.Int, error) {
+	balance, err := qe.stateAt("balance", []interface{}{address.Hex()}, blockNumber, func() (interface{}, error) {
+		return qe.client.BalanceAt(ctx, address, blockNumber)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error fetching balance: %w", err)
+		return nil, fmt.Errorf("error fetching balance of %s: %w", address.Hex(), err)
 	}
-
-	// Cache the result
-	qe.cache.Set(cacheKey, balance, 0)
-	logger.Debug("cached balance", "key", cacheKey)
-
-	return balance, nil
+	return balance.(*big.Int), nil
 }
 
-func (qe *QueryExecutor) getLogs(ctx context.Context, query *queries.Query) ([]types.Log, error) {
-	if query.FromBlock == nil || query.ToBlock == nil {
-		return nil, fmt.Errorf("both from and to block numbers must be specified for logs query")
-	}
+// maxAnyAddressLogRange bounds the blocks a LOGS query without an address may
+// scan, since a topic shared by many contracts can match a great many logs
+const maxAnyAddressLogRange = 1000
 
+func (qe *QueryExecutor) getLogs(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
 	// Validate block range
 	blockRange := new(big.Int).Sub(query.ToBlock, query.FromBlock)
 	if blockRange.Cmp(big.NewInt(10000)) > 0 {
 		return nil, fmt.Errorf("block range too large for logs query: %d blocks (maximum: 10000)", blockRange.Int64())
 	}
+	if query.AnyAddress && blockRange.Cmp(big.NewInt(maxAnyAddressLogRange)) > 0 {
+		return nil, fmt.Errorf("block range too large for logs query without an address: %d blocks (maximum: %d); narrow it with LAST n BLOCKS", blockRange.Int64(), maxAnyAddressLogRange)
+	}
+
+	filterQuery, satisfiable := logFilter(query)
+	if !satisfiable {
+		logger.Debug("logs filter can never match")
+		return nil, nil
+	}
 
 	// Generate cache key
-	cacheKey := cache.GenerateKey("logs", query.Address.Hex(), query.FromBlock, query.ToBlock)
+	cacheKey := cache.GenerateKey("logs", query.FromBlock, query.ToBlock, filterQuery.Addresses, filterQuery.Topics)
 
 	// Check cache first
 	if cached, found := qe.cache.Get(cacheKey); found {
 		logger.Debug("cache hit", "key", cacheKey)
-		if logs, ok := cached.([]types.Log); ok {
-			return logs, nil
+		if records, ok := cached.([]queries.Record); ok {
+			return qe.completeRecords(ctx, records, query)
 		}
 	}
 
-	filterQuery := ethereum.FilterQuery{
-		FromBlock: query.FromBlock,
-		ToBlock:   query.ToBlock,
-		Addresses: []common.Address{query.Address},
-	}
-
-	logs, err := qe.client.FilterLogs(ctx, filterQuery)
+	scan, err := qe.scanLogs(ctx, filterQuery, query, scanLimit(query))
 	if err != nil {
-		return nil, fmt.Errorf("error fetching logs: %w", err)
+		return nil, err
 	}
 
-	// Enforce result size limit
-	const maxLogs = 10000
-	if len(logs) > maxLogs {
-		return nil, fmt.Errorf("result too large: %d logs (maximum: %d)", len(logs), maxLogs)
+	// Only a scan of the whole range can be reused by later queries
+	if scan.complete {
+		qe.cache.Set(cacheKey, scan.records, 0)
+		logger.Debug("cached logs", "key", cacheKey, "count", len(scan.records))
 	}
 
-	// Cache the result
-	qe.cache.Set(cacheKey, logs, 0)
-	logger.Debug("cached logs", "key", cacheKey, "count", len(logs))
-
-	return logs, nil
+	return qe.completeRecords(ctx, scan.records, query)
 }
 
-func (qe *QueryExecutor) getTransactions(ctx context.Context, query *queries.Query) ([]*types.Transaction, error) {
-	var transactions []*types.Transaction
-
-	if query.FromBlock == nil {
-		// If no block range is specified, use the latest block
-		latestBlock, err := qe.client.BlockByNumber(ctx, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get latest block: %w", err)
-		}
-		query.FromBlock = latestBlock.Number()
-		query.ToBlock = query.FromBlock
-	} else if query.ToBlock == nil {
-		// If only from block is specified, use it as the only block
-		query.ToBlock = query.FromBlock
-	}
+// getBlocks returns the headers of every block in the query's range, fetched concurrently
+func (qe *QueryExecutor) getBlocks(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	fromBlock, toBlock := query.FromBlock, query.ToBlock
 
-	// Ensure the block range is not too large to prevent heavy queries
-	blockRange := new(big.Int).Sub(query.ToBlock, query.FromBlock)
+	blockRange := new(big.Int).Sub(toBlock, fromBlock)
 	if blockRange.Cmp(big.NewInt(1000)) > 0 {
-		return nil, fmt.Errorf("block range too large for transactions query: %s blocks (maximum: 1000)", blockRange.String())
+		return nil, fmt.Errorf("block range too large for blocks query: %d blocks (maximum: 1000)", blockRange.Int64())
 	}
 
-	// Process each block in the range
-	for blockNum := new(big.Int).Set(query.FromBlock); blockNum.Cmp(query.ToBlock) <= 0; blockNum = new(big.Int).Add(blockNum, big.NewInt(1)) {
-		// Check for context cancellation
-		if ctx.Err() != nil {
-			return nil, fmt.Errorf("query cancelled after processing %d transactions: %w", len(transactions), ctx.Err())
-		}
-
-		block, err := qe.client.BlockByNumber(ctx, blockNum)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get block %s: %w", blockNum.String(), err)
+	cacheKey := cache.GenerateKey("blocks", fromBlock, toBlock)
+	if cached, found := qe.cache.Get(cacheKey); found {
+		logger.Debug("cache hit", "key", cacheKey)
+		if records, ok := cached.([]queries.Record); ok {
+			return qe.completeRecords(ctx, records, query)
 		}
+	}
 
-		for _, tx := range block.Transactions() {
-			msg, err := core.TransactionToMessage(tx, types.NewLondonSigner(tx.ChainId()), nil)
-			if err != nil {
-				// Log error but continue processing
-				continue
-			}
-
-			// Check if transaction is from or to the specified address
-			if (tx.To() != nil && *tx.To() == query.Address) || msg.From == query.Address {
-				transactions = append(transactions, tx)
+	scan, err := qe.scanBlocks(ctx, fromBlock, toBlock, query.Where, scanLimit(query), func(block *types.Block) ([]queries.Record, error) {
+		return []queries.Record{blockRecord(block)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-				// Enforce result size limit
-				if len(transactions) >= 10000 {
-					return nil, fmt.Errorf("result set too large: 10000+ transactions found - please narrow your block range")
-				}
-			}
-		}
+	// Only a full scan of the range is worth caching
+	if scan.complete {
+		qe.cache.Set(cacheKey, scan.records, 0)
+		logger.Debug("cached blocks", "key", cacheKey, "count", len(scan.records))
 	}
 
-	return transactions, nil
+	return qe.completeRecords(ctx, scan.records, query)
 }
 
-// getTransactionsConcurrent processes blocks concurrently for better performance
-func (qe *QueryExecutor) getTransactionsConcurrent(ctx context.Context, query *queries.Query) ([]*types.Transaction, error) {
-	var fromBlock, toBlock *big.Int
-
-	if query.FromBlock == nil || query.ToBlock == nil {
-		latestBlock, err := qe.client.BlockNumber(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("error getting latest block: %w", err)
+// completeRecords applies WHERE to records, first decoding event or call arguments and
+// adding the fields that are fetched separately, block timestamps, receipts and ENS
+// names, when the query needs them
+func (qe *QueryExecutor) completeRecords(ctx context.Context, records []queries.Record, query *queries.Query) ([]queries.Record, error) {
+	usesTimestamps, usesENS := query.Uses("block_timestamp"), query.Uses("ens_name")
+	switch {
+	case query.Event != nil:
+		records = decodeLogs(records, query.Event)
+	case query.Function != nil:
+		records = decodeCalls(records, query.Function)
+	case usesTimestamps || usesReceipts(query) || usesENS:
+		// Records may be shared with the cache, so fields are only added to copies
+		copied := make([]queries.Record, len(records))
+		for i, record := range records {
+			copied[i] = copyRecord(record, 1)
+		}
+		records = copied
+	}
+	if usesTimestamps {
+		if err := qe.addBlockTimestamps(ctx, records); err != nil {
+			return nil, err
+		}
+	}
+	if usesReceipts(query) {
+		hashField := "hash"
+		if query.Method == "RECEIPTS" {
+			hashField = "tx_hash"
+		}
+		if err := qe.addReceipts(ctx, records, hashField); err != nil {
+			return nil, err
+		}
+	}
+	if usesENS {
+		if err := qe.addENSNames(ctx, records, query.ToBlock); err != nil {
+			return nil, err
 		}
-		fromBlock = big.NewInt(int64(latestBlock - 100))
-		toBlock = big.NewInt(int64(latestBlock))
-	} else {
-		fromBlock = query.FromBlock
-		toBlock = query.ToBlock
 	}
+	return filterRecords(records, query.Where)
+}
+
+// getTransactionsConcurrent processes blocks concurrently for better performance,
+// matching every queried address in a single pass over the range. It serves both
+// TRANSACTIONS and RECEIPTS, whose receipt fields are added once the scan is done.
+func (qe *QueryExecutor) getTransactionsConcurrent(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	fromBlock, toBlock := query.FromBlock, query.ToBlock
+	kind := strings.ToLower(query.Method)
 
 	// Validate block range
 	blockRange := new(big.Int).Sub(toBlock, fromBlock)
 	if blockRange.Cmp(big.NewInt(1000)) > 0 {
-		return nil, fmt.Errorf("block range too large for transactions query: %d blocks (maximum: 1000)", blockRange.Int64())
+		return nil, fmt.Errorf("block range too large for %s query: %d blocks (maximum: 1000)", kind, blockRange.Int64())
+	}
+
+	addresses := queryAddresses(query)
+	if len(addresses) == 0 {
+		logger.Debug("transactions filter can never match")
+		return nil, nil
+	}
+	watched := make(map[common.Address]bool, len(addresses))
+	for _, address := range addresses {
+		watched[address] = true
+	}
+
+	// Calls of a named function are picked out while scanning
+	var selector []byte
+	if query.Function != nil {
+		selector = query.Function.ID
 	}
 
 	// Generate cache key
-	cacheKey := cache.GenerateKey("transactions", query.Address.Hex(), fromBlock, toBlock)
+	cacheKey := cache.GenerateKey(kind, addresses, fromBlock, toBlock, selector)
 
 	// Check cache first
 	if cached, found := qe.cache.Get(cacheKey); found {
 		logger.Debug("cache hit", "key", cacheKey)
-		if txs, ok := cached.([]*types.Transaction); ok {
-			return txs, nil
+		if records, ok := cached.([]queries.Record); ok {
+			return qe.completeRecords(ctx, records, query)
 		}
 	}
 
-	// Create worker pool
-	type blockResult struct {
-		blockNum     *big.Int
-		transactions []*types.Transaction
-		err          error
-	}
-
-	blockChan := make(chan *big.Int, qe.maxWorkers)
-	resultChan := make(chan blockResult, qe.maxWorkers)
-	var wg sync.WaitGroup
-
-	// Start workers
-	for i := 0; i < qe.maxWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for blockNum := range blockChan {
-				if ctx.Err() != nil {
-					return
-				}
-
-				block, err := qe.client.BlockByNumber(ctx, blockNum)
-				if err != nil {
-					resultChan <- blockResult{blockNum: blockNum, err: fmt.Errorf("failed to get block %s: %w", blockNum.String(), err)}
-					continue
-				}
-
-				var blockTxs []*types.Transaction
-				for _, tx := range block.Transactions() {
-					msg, err := core.TransactionToMessage(tx, types.NewLondonSigner(tx.ChainId()), nil)
-					if err != nil {
-						continue
-					}
-
-					if (tx.To() != nil && *tx.To() == query.Address) || msg.From == query.Address {
-						blockTxs = append(blockTxs, tx)
-					}
-				}
-
-				resultChan <- blockResult{blockNum: blockNum, transactions: blockTxs}
-			}
-		}()
+	build := transactionRecord
+	if query.Method == "RECEIPTS" {
+		build = receiptRecord
 	}
 
-	// Send blocks to workers
-	go func() {
-		for blockNum := new(big.Int).Set(query.FromBlock); blockNum.Cmp(query.ToBlock) <= 0; blockNum = new(big.Int).Add(blockNum, big.NewInt(1)) {
-			select {
-			case <-ctx.Done():
-				close(blockChan)
-				return
-			case blockChan <- new(big.Int).Set(blockNum):
+	scan, err := qe.scanBlocks(ctx, fromBlock, toBlock, query.Where, scanLimit(query), func(block *types.Block) ([]queries.Record, error) {
+		var blockRecords []queries.Record
+		for i, tx := range block.Transactions() {
+			from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+			if err != nil {
+				return nil, fmt.Errorf("error recovering sender of transaction %s: %w", tx.Hash().Hex(), err)
 			}
-		}
-		close(blockChan)
-	}()
-
-	// Collect results
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	var allTransactions []*types.Transaction
-	for result := range resultChan {
-		if result.err != nil {
-			return nil, result.err
-		}
-		allTransactions = append(allTransactions, result.transactions...)
 
-		// Enforce result size limit
-		const maxTransactions = 10000
-		if len(allTransactions) > maxTransactions {
-			return nil, fmt.Errorf("result too large: %d transactions (maximum: %d)", len(allTransactions), maxTransactions)
+			// Attribute the transaction to its sender when both ends are watched
+			var record queries.Record
+			switch {
+			case watched[from]:
+				record = build(from, tx, from, block, i)
+			case tx.To() != nil && watched[*tx.To()]:
+				record = build(*tx.To(), tx, from, block, i)
+			default:
+				continue
+			}
+			if selector != nil && !bytes.HasPrefix(tx.Data(), selector) {
+				continue
+			}
+			if query.Method == "TRANSACTIONS" {
+				qe.addCallFields(record, tx.To(), tx.Data())
+			}
+			blockRecords = append(blockRecords, record)
 		}
+		return blockRecords, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if ctx.Err() != nil {
-		return nil, fmt.Errorf("query cancelled after processing %d transactions: %w", len(allTransactions), ctx.Err())
+	// Only a full scan of the range is worth caching
+	if scan.complete {
+		qe.cache.Set(cacheKey, scan.records, 0)
+		logger.Debug("cached "+kind, "key", cacheKey, "count", len(scan.records))
 	}
 
-	// Cache the result
-	qe.cache.Set(cacheKey, allTransactions, 0)
-	logger.Debug("cached transactions", "key", cacheKey, "count", len(allTransactions))
-
-	return allTransactions, nil
+	return qe.completeRecords(ctx, scan.records, query)
 }
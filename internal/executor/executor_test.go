@@ -1,7 +1,9 @@
 package executor
 
 import (
+	"context"
 	"math/big"
+	"strings"
 	"testing"
 	"time"
 
@@ -71,6 +73,23 @@ func TestSetMaxWorkers(t *testing.T) {
 	}
 }
 
+func TestSetDefaultBlockRange(t *testing.T) {
+	exec := NewQueryExecutor(nil)
+	if exec.defaultBlockRange != 100 {
+		t.Errorf("Expected default block range of 100, got %d", exec.defaultBlockRange)
+	}
+
+	exec.SetDefaultBlockRange(0)
+	if exec.defaultBlockRange != 100 {
+		t.Errorf("Expected non-positive range to be ignored, got %d", exec.defaultBlockRange)
+	}
+
+	exec.SetDefaultBlockRange(1000)
+	if exec.defaultBlockRange != 1000 {
+		t.Errorf("Expected block range 1000, got %d", exec.defaultBlockRange)
+	}
+}
+
 func TestQueryValidation_BlockRanges(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -251,3 +270,105 @@ func TestBlockRangeCalculations(t *testing.T) {
 		})
 	}
 }
+
+func TestExecute_BalanceOfSeveralAddresses(t *testing.T) {
+	chain := &testChain{head: 1000}
+	qe := newTestExecutor(t, chain)
+
+	first := common.HexToAddress("0x0000000000000000000000000000000000000007")
+	second := common.HexToAddress("0x0000000000000000000000000000000000000003")
+	query := &queries.Query{
+		Method:    "BALANCE",
+		Address:   first,
+		Addresses: []common.Address{first, second},
+		FromBlock: big.NewInt(900),
+		ToBlock:   big.NewInt(900),
+	}
+
+	result, err := qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 2 {
+		t.Fatalf("Expected one row per address, got %d", result.Len())
+	}
+	for i, expected := range []struct {
+		address common.Address
+		balance int64
+	}{{first, 7}, {second, 3}} {
+		row := result.Rows[i]
+		if row[0] != expected.address {
+			t.Errorf("Row %d: expected address %s, got %v", i, expected.address.Hex(), row[0])
+		}
+		if balance, ok := row[1].(*big.Int); !ok || balance.Int64() != expected.balance {
+			t.Errorf("Row %d: expected balance %d, got %v", i, expected.balance, row[1])
+		}
+	}
+	if chain.balanceCalls != 2 {
+		t.Errorf("Expected 2 balance requests, got %d", chain.balanceCalls)
+	}
+}
+
+func TestGetLogs_AnyAddressRange(t *testing.T) {
+	qe := NewQueryExecutor(nil)
+	query := &queries.Query{
+		Method:     "LOGS",
+		AnyAddress: true,
+		FromBlock:  big.NewInt(1000000),
+		ToBlock:    big.NewInt(1000000 + maxAnyAddressLogRange + 1),
+	}
+
+	// The range is checked before anything is fetched
+	_, err := qe.getLogs(context.Background(), query)
+	if err == nil || !strings.Contains(err.Error(), "without an address") {
+		t.Errorf("Expected a block range error, got %v", err)
+	}
+}
+
+func TestExecute_Blocks(t *testing.T) {
+	chain := &testChain{head: 1000}
+	qe := newTestExecutor(t, chain)
+
+	query := &queries.Query{
+		Method:    "BLOCKS",
+		FromBlock: big.NewInt(100),
+		ToBlock:   big.NewInt(104),
+		Where:     &queries.Comparison{Op: ">", Left: &queries.ColumnRef{Name: "gas_used"}, Right: &queries.Literal{Value: big.NewInt(101000)}},
+		Limit:     2,
+	}
+
+	result, err := qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 2 {
+		t.Fatalf("Expected 2 rows, got %d", result.Len())
+	}
+
+	schema := queries.Schemas["BLOCKS"].Names()
+	for i, expected := range []uint64{102, 103} {
+		row := make(map[string]interface{})
+		for j, name := range schema {
+			row[name] = result.Rows[i][j]
+		}
+		header := testHeader(expected)
+		if number, ok := row["number"].(*big.Int); !ok || number.Uint64() != expected {
+			t.Errorf("Row %d: expected block %d, got %v", i, expected, row["number"])
+		}
+		if row["hash"] != header.Hash() {
+			t.Errorf("Row %d: expected hash %s, got %v", i, header.Hash().Hex(), row["hash"])
+		}
+		if timestamp, ok := row["timestamp"].(time.Time); !ok || uint64(timestamp.Unix()) != header.Time {
+			t.Errorf("Row %d: expected timestamp %d, got %v", i, header.Time, row["timestamp"])
+		}
+		if baseFee, ok := row["base_fee"].(*big.Int); !ok || baseFee.Uint64() != expected {
+			t.Errorf("Row %d: expected base fee %d, got %v", i, expected, row["base_fee"])
+		}
+		if txCount, ok := row["tx_count"].(*big.Int); !ok || txCount.Sign() != 0 {
+			t.Errorf("Row %d: expected no transactions, got %v", i, row["tx_count"])
+		}
+		if row["withdrawals_root"] != nil {
+			t.Errorf("Row %d: expected no withdrawals root before Shanghai, got %v", i, row["withdrawals_root"])
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// feePercentiles are the percentiles of the reward_p<n> columns of FEES
+var feePercentiles = []float64{10, 25, 50, 75, 90}
+
+// feeHistory is an eth_feeHistory result. BaseFee and BlobBaseFee hold one more entry
+// than there are blocks, for the block after the newest.
+type feeHistory struct {
+	OldestBlock      hexutil.Uint64   `json:"oldestBlock"`
+	Reward           [][]*hexutil.Big `json:"reward"`
+	BaseFee          []*hexutil.Big   `json:"baseFeePerGas"`
+	GasUsedRatio     []float64        `json:"gasUsedRatio"`
+	BlobBaseFee      []*hexutil.Big   `json:"baseFeePerBlobGas"`
+	BlobGasUsedRatio []float64        `json:"blobGasUsedRatio"`
+}
+
+// gasEstimate is the outcome of eth_estimateGas: the gas a call takes, or why it
+// cannot be estimated
+type gasEstimate struct {
+	gas    uint64
+	reason string
+}
+
+// getFees returns the fee market of every block of the query's range from a single
+// eth_feeHistory request
+func (qe *QueryExecutor) getFees(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+	if to < from {
+		return nil, nil
+	}
+	if to-from > 1000 {
+		return nil, fmt.Errorf("block range too large for fees query: %d blocks (maximum: 1000)", to-from)
+	}
+
+	cacheKey := cache.GenerateKey("fees", query.FromBlock, query.ToBlock)
+	if cached, found := qe.cache.Get(cacheKey); found {
+		logger.Debug("cache hit", "key", cacheKey)
+		if records, ok := cached.([]queries.Record); ok {
+			return qe.completeRecords(ctx, records, query)
+		}
+	}
+
+	var history feeHistory
+	count := hexutil.Uint64(to - from + 1)
+	if err := qe.client.Client().CallContext(ctx, &history, "eth_feeHistory", count, hexutil.EncodeUint64(to), feePercentiles); err != nil {
+		return nil, fmt.Errorf("error fetching fee history of blocks %d to %d: %w", from, to, err)
+	}
+
+	records := make([]queries.Record, len(history.GasUsedRatio))
+	for i := range history.GasUsedRatio {
+		records[i] = feeRecord(&history, i)
+	}
+
+	qe.cache.Set(cacheKey, records, 0)
+	logger.Debug("cached fees", "key", cacheKey, "count", len(records))
+	return qe.completeRecords(ctx, records, query)
+}
+
+// getGasEstimates estimates the gas the query's call takes on every queried address,
+// up to the default gas limit. A call that reverts or needs more gas yields a row with
+// the reason in its error field rather than failing the query.
+func (qe *QueryExecutor) getGasEstimates(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	method := query.Call
+	packed, err := method.Inputs.Pack(query.CallArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding arguments of %s: %w", method.Sig, err)
+	}
+	data := append(append([]byte(nil), method.ID...), packed...)
+
+	return qe.getAccounts(ctx, query, func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error) {
+		estimate, err := qe.estimateGas(ctx, query.Sender, address, data, block)
+		if err != nil {
+			return nil, fmt.Errorf("error estimating gas of %s on %s: %w", method.Sig, address.Hex(), err)
+		}
+		return []queries.Record{gasEstimateRecord(address, method, query.Sender, qe.defaultGasLimit, estimate, recordBlock)}, nil
+	})
+}
+
+// estimateGas asks eth_estimateGas for the gas a call of data on to takes at block,
+// capped at the default gas limit. Calls that revert or need more gas than the limit
+// are reported through the estimate's reason rather than as errors.
+func (qe *QueryExecutor) estimateGas(ctx context.Context, sender *common.Address, to common.Address, data []byte, block *big.Int) (gasEstimate, error) {
+	args := map[string]interface{}{
+		"to":    to,
+		"input": hexutil.Bytes(data),
+		"gas":   hexutil.Uint64(qe.defaultGasLimit),
+	}
+	var from string
+	if sender != nil {
+		args["from"] = *sender
+		from = sender.Hex()
+	}
+	blockArg := rpc.LatestBlockNumber
+	if block != nil {
+		blockArg = rpc.BlockNumber(block.Int64())
+	}
+
+	estimate, err := qe.stateAt("gas_estimate", []interface{}{from, to.Hex(), hexutil.Encode(data), qe.defaultGasLimit}, block, func() (interface{}, error) {
+		var gas hexutil.Uint64
+		err := qe.client.Client().CallContext(ctx, &gas, "eth_estimateGas", args, blockArg)
+		if err != nil {
+			if reason, reverted := revertReason(err); reverted {
+				return gasEstimate{reason: reason}, nil
+			}
+			if strings.Contains(err.Error(), "gas required exceeds") {
+				return gasEstimate{reason: err.Error()}, nil
+			}
+			return nil, err
+		}
+		return gasEstimate{gas: uint64(gas)}, nil
+	})
+	if err != nil {
+		return gasEstimate{}, err
+	}
+	return estimate.(gasEstimate), nil
+}
+
+// basisPoints renders a ratio in hundredths of a percent
+func basisPoints(ratio float64) *big.Int {
+	return big.NewInt(int64(math.Round(ratio * 10000)))
+}
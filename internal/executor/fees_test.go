@@ -0,0 +1,231 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// FeeHistory answers eth_feeHistory: block n has a base fee of n wei, a blob base fee of
+// 2n, is 0.4% full of gas and a quarter full of blobs, and pays p times n at percentile p
+func (c *testChain) FeeHistory(count hexutil.Uint64, newest rpc.BlockNumber, percentiles []float64) (*feeHistory, error) {
+	c.mu.Lock()
+	c.feeHistoryCalls++
+	c.mu.Unlock()
+
+	last := uint64(newest.Int64())
+	oldest := last + 1 - uint64(count)
+	history := &feeHistory{OldestBlock: hexutil.Uint64(oldest)}
+	for n := oldest; n <= last+1; n++ {
+		history.BaseFee = append(history.BaseFee, (*hexutil.Big)(new(big.Int).SetUint64(n)))
+		history.BlobBaseFee = append(history.BlobBaseFee, (*hexutil.Big)(new(big.Int).SetUint64(2*n)))
+		if n > last {
+			break
+		}
+		history.GasUsedRatio = append(history.GasUsedRatio, 0.004)
+		history.BlobGasUsedRatio = append(history.BlobGasUsedRatio, 0.25)
+		rewards := make([]*hexutil.Big, len(percentiles))
+		for i, p := range percentiles {
+			rewards[i] = (*hexutil.Big)(new(big.Int).SetUint64(uint64(p) * n))
+		}
+		history.Reward = append(history.Reward, rewards)
+	}
+	return history, nil
+}
+
+// EstimateGas answers eth_estimateGas for testToken: a call takes 21000 gas plus 1000
+// per byte of input plus the last byte of the sender, and fails like eth_call when it
+// reverts or when it needs more than the given gas
+func (c *testChain) EstimateGas(args callArgs, block rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	if _, err := c.Call(args, block); err != nil {
+		return 0, err
+	}
+	input := args.Input
+	if len(input) == 0 {
+		input = args.Data
+	}
+	gas := uint64(21000 + 1000*len(input))
+	if args.From != nil {
+		gas += uint64(args.From[common.AddressLength-1])
+	}
+	if args.Gas != nil && gas > uint64(*args.Gas) {
+		return 0, fmt.Errorf("gas required exceeds allowance (%d)", uint64(*args.Gas))
+	}
+	return hexutil.Uint64(gas), nil
+}
+
+func TestExecute_Fees(t *testing.T) {
+	chain := &testChain{head: 2000}
+	qe := newTestExecutor(t, chain)
+	query := &queries.Query{
+		Method:    "FEES",
+		FromBlock: big.NewInt(10),
+		ToBlock:   big.NewInt(1010),
+	}
+	result, err := qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 1001 {
+		t.Fatalf("Expected 1001 rows, got %d", result.Len())
+	}
+	if chain.feeHistoryCalls != 1 {
+		t.Errorf("Expected 1 eth_feeHistory request, got %d", chain.feeHistoryCalls)
+	}
+
+	schema := queries.Schemas["FEES"].Default().Names()
+	for i, n := range []int64{10, 500, 1010} {
+		row := make(map[string]interface{})
+		for j, name := range schema {
+			row[name] = result.Rows[n-10][j]
+		}
+		expected := map[string]int64{
+			"block_number":      n,
+			"base_fee":          n,
+			"gas_used_bps":      40,
+			"reward_p10":        10 * n,
+			"reward_p50":        50 * n,
+			"reward_p90":        90 * n,
+			"blob_base_fee":     2 * n,
+			"blob_gas_used_bps": 2500,
+		}
+		for name, want := range expected {
+			if got, ok := row[name].(*big.Int); !ok || got.Int64() != want {
+				t.Errorf("Row %d: expected %s %d, got %v", i, name, want, row[name])
+			}
+		}
+	}
+
+	query.ToBlock = big.NewInt(1011)
+	_, err = qe.Execute(context.Background(), query)
+	if err == nil || !strings.Contains(err.Error(), "block range too large for fees query: 1001 blocks (maximum: 1000)") {
+		t.Errorf("Expected a block range error, got: %v", err)
+	}
+}
+
+func TestExecute_GasEstimate(t *testing.T) {
+	chain := &testChain{head: 1000}
+	sender := common.HexToAddress("0x0000000000000000000000000000000000000005")
+	balanceOf := testTokenContract.Methods["balanceOf"]
+	getReserves := testTokenContract.Methods["getReserves"]
+	pause := testTokenContract.Methods["pause"]
+	owner := common.HexToAddress("0x0000000000000000000000000000000000000003")
+
+	tests := []struct {
+		name     string
+		method   *abi.Method
+		args     []interface{}
+		sender   *common.Address
+		gasLimit int64
+		expected int64 // 0 when the estimate fails
+		error    string
+	}{
+		{
+			name:     "Estimate",
+			method:   &getReserves,
+			expected: 25000,
+		},
+		{
+			name:     "Sender",
+			method:   &balanceOf,
+			args:     []interface{}{owner},
+			sender:   &sender,
+			expected: 57005,
+		},
+		{
+			name:   "Revert",
+			method: &pause,
+			error:  "execution reverted: paused",
+		},
+		{
+			name:     "Over the gas limit",
+			method:   &balanceOf,
+			args:     []interface{}{owner},
+			gasLimit: 50000,
+			error:    "gas required exceeds allowance (50000)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qe := newTestExecutor(t, chain)
+			if tt.gasLimit > 0 {
+				qe.SetDefaultGasLimit(tt.gasLimit)
+			}
+			query := &queries.Query{
+				Method:    "GAS_ESTIMATE",
+				Addresses: []common.Address{testToken},
+				Call:      tt.method,
+				CallArgs:  tt.args,
+				Sender:    tt.sender,
+				FromBlock: big.NewInt(900),
+				ToBlock:   big.NewInt(900),
+			}
+			result, err := qe.Execute(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.Len() != 1 {
+				t.Fatalf("Expected 1 row, got %d", result.Len())
+			}
+
+			row := make(map[string]interface{})
+			for j, name := range queries.Schemas["GAS_ESTIMATE"].Default().Names() {
+				row[name] = result.Rows[0][j]
+			}
+			expectedSender := common.Address{}
+			if tt.sender != nil {
+				expectedSender = *tt.sender
+			}
+			if row["sender"] != expectedSender {
+				t.Errorf("Expected sender %s, got %v", expectedSender.Hex(), row["sender"])
+			}
+			if tt.error != "" {
+				if row["error"] != tt.error || row["gas_estimate"] != nil {
+					t.Errorf("Expected error %q and no estimate, got %v and %v", tt.error, row["error"], row["gas_estimate"])
+				}
+				return
+			}
+			if gas, ok := row["gas_estimate"].(*big.Int); !ok || gas.Int64() != tt.expected {
+				t.Errorf("Expected gas estimate %d, got %v (error %v)", tt.expected, row["gas_estimate"], row["error"])
+			}
+		})
+	}
+}
+
+func TestExecute_CallGasEstimate(t *testing.T) {
+	qe := newTestExecutor(t, &testChain{head: 1000})
+	getReserves := testTokenContract.Methods["getReserves"]
+	query := &queries.Query{
+		Method:    "CALL",
+		Addresses: []common.Address{testToken},
+		Call:      &getReserves,
+		Fields: []queries.SelectItem{
+			{Expr: &queries.ColumnRef{Name: "result.reserve0"}},
+			{Expr: &queries.ColumnRef{Name: "gas_estimate"}},
+		},
+		FromBlock: big.NewInt(900),
+		ToBlock:   big.NewInt(900),
+	}
+	result, err := qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 1 {
+		t.Fatalf("Expected 1 row, got %d", result.Len())
+	}
+	if reserve, ok := result.Rows[0][0].(*big.Int); !ok || reserve.Int64() != 900 {
+		t.Errorf("Expected reserve0 900, got %v", result.Rows[0][0])
+	}
+	if gas, ok := result.Rows[0][1].(*big.Int); !ok || gas.Int64() != 25000 {
+		t.Errorf("Expected gas estimate 25000, got %v", result.Rows[0][1])
+	}
+}
@@ -0,0 +1,26 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+// filterRecords returns the records matching the WHERE predicate, leaving the input untouched
+func filterRecords(records []queries.Record, where queries.Expr) ([]queries.Record, error) {
+	if where == nil {
+		return records, nil
+	}
+
+	matched := make([]queries.Record, 0, len(records))
+	for _, record := range records {
+		ok, err := queries.Matches(where, record)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating WHERE clause: %w", err)
+		}
+		if ok {
+			matched = append(matched, record)
+		}
+	}
+	return matched, nil
+}
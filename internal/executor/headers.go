@@ -0,0 +1,182 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/queries"
+)
+
+// headerTime returns the timestamp of a block, caching it by block number
+func (qe *QueryExecutor) headerTime(ctx context.Context, number uint64) (uint64, error) {
+	cacheKey := cache.GenerateKey("header_time", number)
+	if cached, found := qe.cache.Get(cacheKey); found {
+		if timestamp, ok := cached.(uint64); ok {
+			return timestamp, nil
+		}
+	}
+
+	header, err := qe.client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get block header %d: %w", number, err)
+	}
+
+	qe.cache.Set(cacheKey, header.Time, 0)
+	return header.Time, nil
+}
+
+// headerTimes fetches the timestamps of several blocks concurrently
+func (qe *QueryExecutor) headerTimes(ctx context.Context, numbers []uint64) (map[uint64]uint64, error) {
+	times := make(map[uint64]uint64, len(numbers))
+	var mu sync.Mutex
+	var firstErr error
+
+	sem := make(chan struct{}, qe.maxWorkers)
+	var wg sync.WaitGroup
+	for _, number := range numbers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(number uint64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			timestamp, err := qe.headerTime(ctx, number)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			times[number] = timestamp
+		}(number)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return times, nil
+}
+
+// addBlockTimestamps fills in block_timestamp on records that lack it, from their block_number
+func (qe *QueryExecutor) addBlockTimestamps(ctx context.Context, records []queries.Record) error {
+	seen := make(map[uint64]bool)
+	var numbers []uint64
+	for _, record := range records {
+		if _, ok := record["block_timestamp"]; ok {
+			continue
+		}
+		if number, ok := record["block_number"].(*big.Int); ok && !seen[number.Uint64()] {
+			seen[number.Uint64()] = true
+			numbers = append(numbers, number.Uint64())
+		}
+	}
+	if len(numbers) == 0 {
+		return nil
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	times, err := qe.headerTimes(ctx, numbers)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, ok := record["block_timestamp"]; ok {
+			continue
+		}
+		if number, ok := record["block_number"].(*big.Int); ok {
+			record["block_timestamp"] = blockTime(times[number.Uint64()])
+		}
+	}
+	return nil
+}
+
+// lastBlockBefore binary-searches [0, latest] for the last block whose timestamp is
+// before t, or at t when inclusive. It returns false if there is no such block.
+func (qe *QueryExecutor) lastBlockBefore(ctx context.Context, latest uint64, t time.Time, inclusive bool) (uint64, bool, error) {
+	target := t.Unix()
+	if !inclusive {
+		target--
+	}
+	if target < 0 {
+		return 0, false, nil
+	}
+	before := func(number uint64) (bool, error) {
+		timestamp, err := qe.headerTime(ctx, number)
+		return timestamp <= uint64(target), err
+	}
+
+	ok, err := before(0)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	ok, err = before(latest)
+	if err != nil || ok {
+		return latest, ok, err
+	}
+
+	// Invariant: block lo is before the target and block hi is not
+	lo, hi := uint64(0), latest
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		ok, err := before(mid)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, true, nil
+}
+
+// blocksForTimes turns a time range into block numbers: from is the first block at
+// or after fromTime and to the last block at or before toTime. Either may be nil.
+func (qe *QueryExecutor) blocksForTimes(ctx context.Context, latest uint64, fromTime, toTime *time.Time) (*big.Int, *big.Int, error) {
+	var from, to *big.Int
+
+	if fromTime != nil {
+		number, ok, err := qe.lastBlockBefore(ctx, latest, *fromTime, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			number++
+		}
+		if number > latest {
+			return nil, nil, fmt.Errorf("no block at or after %s (latest block is %d)", queries.FormatValue(*fromTime), latest)
+		}
+		from = new(big.Int).SetUint64(number)
+	}
+
+	if toTime != nil {
+		number, ok, err := qe.lastBlockBefore(ctx, latest, *toTime, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("no block at or before %s", queries.FormatValue(*toTime))
+		}
+		to = new(big.Int).SetUint64(number)
+	}
+
+	if from != nil && to != nil && from.Cmp(to) > 0 {
+		return nil, nil, fmt.Errorf("no blocks between %s and %s", queries.FormatValue(*fromTime), queries.FormatValue(*toTime))
+	}
+	return from, to, nil
+}
+
+// blockTime converts a header timestamp into a block_timestamp value
+func blockTime(timestamp uint64) time.Time {
+	return time.Unix(int64(timestamp), 0).UTC()
+}
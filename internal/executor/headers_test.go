@@ -0,0 +1,97 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/queries"
+)
+
+func TestBlocksForTimes(t *testing.T) {
+	chain := &testChain{head: 1000}
+	qe := newTestExecutor(t, chain)
+
+	at := func(seconds int64) *time.Time {
+		t := time.Unix(testChainGenesis+seconds, 0).UTC()
+		return &t
+	}
+
+	tests := []struct {
+		name         string
+		fromTime     *time.Time
+		toTime       *time.Time
+		expectedFrom string
+		expectedTo   string
+		expectedErr  string
+	}{
+		{name: "Exact block time", toTime: at(120), expectedFrom: "<nil>", expectedTo: "10"},
+		{name: "Between blocks rounds down", toTime: at(125), expectedFrom: "<nil>", expectedTo: "10"},
+		{name: "After the head", toTime: at(1000000), expectedFrom: "<nil>", expectedTo: "1000"},
+		{name: "Range rounds inwards", fromTime: at(121), toTime: at(359), expectedFrom: "11", expectedTo: "29"},
+		{name: "Range on block times", fromTime: at(120), toTime: at(360), expectedFrom: "10", expectedTo: "30"},
+		{name: "Start before genesis", fromTime: at(-100), toTime: at(0), expectedFrom: "0", expectedTo: "0"},
+		{name: "Before genesis", toTime: at(-1), expectedErr: "no block at or before"},
+		{name: "Start after the head", fromTime: at(12001), expectedErr: "no block at or after"},
+		{name: "No block in range", fromTime: at(121), toTime: at(125), expectedErr: "no blocks between"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := qe.blocksForTimes(context.Background(), chain.head, tt.fromTime, tt.toTime)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("Expected error containing '%s', got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got := from.String(); got != tt.expectedFrom {
+				t.Errorf("Expected from block %s, got %s", tt.expectedFrom, got)
+			}
+			if got := to.String(); got != tt.expectedTo {
+				t.Errorf("Expected to block %s, got %s", tt.expectedTo, got)
+			}
+		})
+	}
+}
+
+func TestResolveBlocks_TimeRanges(t *testing.T) {
+	chain := &testChain{head: 1000}
+	qe := newTestExecutor(t, chain)
+	qe.SetCache(cache.NewInMemoryCache(1000, time.Minute, time.Minute))
+
+	at := time.Unix(testChainGenesis+600, 0).UTC()
+	query := &queries.Query{Method: "BALANCE", ToTime: &at, BlockWindow: 1}
+
+	resolved, meta, err := qe.resolveBlocks(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if resolved.FromBlock.Int64() != 50 || meta.FromBlock.Int64() != 50 {
+		t.Errorf("Expected block 50, got %s (metadata %s)", resolved.FromBlock, meta.FromBlock)
+	}
+
+	// The second lookup is answered from the header cache, apart from resolving latest
+	chain.headerCalls = 0
+	if _, _, err := qe.resolveBlocks(context.Background(), query); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if chain.headerCalls != 1 {
+		t.Errorf("Expected cached headers to be reused, got %d header requests", chain.headerCalls)
+	}
+
+	from := time.Unix(testChainGenesis+120, 0).UTC()
+	query = &queries.Query{Method: "LOGS", FromTime: &from, ToTime: &at}
+	resolved, _, err = qe.resolveBlocks(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if resolved.FromBlock.Int64() != 10 || resolved.ToBlock.Int64() != 50 {
+		t.Errorf("Expected blocks 10 to 50, got %s to %s", resolved.FromBlock, resolved.ToBlock)
+	}
+}
@@ -0,0 +1,362 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// erc721ABI covers the ERC-721 and ERC-165 functions read by NFTS and OWNER
+const erc721ABI = `[
+	{"type":"function","name":"supportsInterface","stateMutability":"view","inputs":[{"name":"interfaceId","type":"bytes4"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"ownerOf","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"tokenOfOwnerByIndex","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"index","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+// erc1155ABI covers the ERC-1155 function read by NFTS
+const erc1155ABI = `[
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"},{"name":"id","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+var (
+	erc721Contract  = mustParseABI(erc721ABI)
+	erc1155Contract = mustParseABI(erc1155ABI)
+)
+
+// ERC-165 interface IDs of the NFT standards
+var (
+	erc721EnumerableInterface = [4]byte{0x78, 0x0e, 0x9d, 0x63}
+	erc1155Interface          = [4]byte{0xd9, 0xb6, 0x7a, 0x26}
+)
+
+// collectionStandard describes the interfaces an NFT contract implements
+type collectionStandard struct {
+	standard   string // ERC-721 or ERC-1155
+	enumerable bool   // lists the tokens of an owner through tokenOfOwnerByIndex
+}
+
+// collectionStandard returns the standard of collection, asked through ERC-165 once per
+// collection and chain. Contracts that do not answer are taken as plain ERC-721.
+func (qe *QueryExecutor) collectionStandard(ctx context.Context, collection common.Address) (collectionStandard, error) {
+	chainID, err := qe.chain(ctx)
+	if err != nil {
+		return collectionStandard{}, err
+	}
+	key := cache.GenerateKey("collection", chainID, collection.Hex())
+	qe.mu.Lock()
+	standard, found := qe.collections[key]
+	qe.mu.Unlock()
+	if found {
+		return standard, nil
+	}
+
+	supports := func(id [4]byte) (bool, error) {
+		value, err := qe.callContract(ctx, erc721Contract, collection, "supportsInterface", nil, id)
+		supported, _ := value.(bool)
+		return supported, err
+	}
+	standard.standard = "ERC-721"
+	multi, err := supports(erc1155Interface)
+	if err != nil {
+		return collectionStandard{}, err
+	}
+	if multi {
+		standard.standard = "ERC-1155"
+	} else if standard.enumerable, err = supports(erc721EnumerableInterface); err != nil {
+		return collectionStandard{}, err
+	}
+
+	qe.mu.Lock()
+	qe.collections[key] = standard
+	qe.mu.Unlock()
+	logger.Debug("read collection standard", "collection", collection.Hex(), "standard", standard.standard, "enumerable", standard.enumerable)
+	return standard, nil
+}
+
+// callContract calls a function of contract on to at block and returns its only
+// result, or nil when the contract reverts or answers with data that does not decode
+func (qe *QueryExecutor) callContract(ctx context.Context, contract abi.ABI, to common.Address, method string, block *big.Int, args ...interface{}) (interface{}, error) {
+	data, err := contract.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	out, err := qe.callView(ctx, to, method, data, block)
+	if err != nil || out == nil {
+		return nil, err
+	}
+	values, err := contract.Unpack(method, out)
+	if err != nil || len(values) != 1 {
+		return nil, nil
+	}
+	return values[0], nil
+}
+
+// ownerOf returns the owner of an ERC-721 token at block, or nil when the collection
+// does not answer, as for burned tokens and ERC-1155 collections
+func (qe *QueryExecutor) ownerOf(ctx context.Context, collection common.Address, id, block *big.Int) (*common.Address, error) {
+	value, err := qe.stateAt("nft_owner", []interface{}{collection.Hex(), id}, block, func() (interface{}, error) {
+		return qe.callContract(ctx, erc721Contract, collection, "ownerOf", block, id)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching owner of %s #%s: %w", collection.Hex(), id, err)
+	}
+	owner, ok := value.(common.Address)
+	if !ok {
+		return nil, nil
+	}
+	return &owner, nil
+}
+
+// getOwners reads the owner of every queried token ID in every queried collection
+func (qe *QueryExecutor) getOwners(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	return qe.getAccounts(ctx, query, func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error) {
+		var records []queries.Record
+		for _, id := range query.TokenIDs {
+			owner, err := qe.ownerOf(ctx, address, id, block)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, ownerRecord(address, id, owner, recordBlock))
+		}
+		return records, nil
+	})
+}
+
+// getNFTs finds the tokens of the queried collections held by the queried wallets at
+// the end of the query's range. Enumerable ERC-721 collections list them directly;
+// otherwise the tokens received within the range, found in their Transfer logs, are
+// checked against the owners or balances at its end, so tokens received earlier are
+// only found by widening the range.
+func (qe *QueryExecutor) getNFTs(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	wallets := queryAddresses(query)
+	if len(wallets) == 0 {
+		return nil, nil
+	}
+	block := query.ToBlock
+
+	var records []queries.Record
+	for _, collection := range query.Collections {
+		standard, err := qe.collectionStandard(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+
+		var holdings []queries.Record
+		if standard.enumerable {
+			holdings, err = qe.enumerateNFTs(ctx, collection, wallets, block)
+		} else {
+			holdings, err = qe.reconstructNFTs(ctx, query, collection, standard.standard, wallets)
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, holdings...)
+		if len(records) > maxScanRecords {
+			return nil, fmt.Errorf("result too large: %d tokens (maximum: %d)", len(records), maxScanRecords)
+		}
+	}
+	return qe.completeRecords(ctx, records, query)
+}
+
+// nftBalance returns the number of tokens of an ERC-721 collection wallet holds at
+// block, or nil when the collection does not answer
+func (qe *QueryExecutor) nftBalance(ctx context.Context, collection, wallet common.Address, block *big.Int) (*big.Int, error) {
+	value, err := qe.stateAt("nft_balance", []interface{}{collection.Hex(), wallet.Hex()}, block, func() (interface{}, error) {
+		return qe.callContract(ctx, erc721Contract, collection, "balanceOf", block, wallet)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s balance of %s: %w", collection.Hex(), wallet.Hex(), err)
+	}
+	balance, _ := value.(*big.Int)
+	return balance, nil
+}
+
+// enumerateNFTs lists the tokens of an enumerable ERC-721 collection held by each wallet
+func (qe *QueryExecutor) enumerateNFTs(ctx context.Context, collection common.Address, wallets []common.Address, block *big.Int) ([]queries.Record, error) {
+	var records []queries.Record
+	for _, wallet := range wallets {
+		balance, err := qe.nftBalance(ctx, collection, wallet, block)
+		if err != nil {
+			return nil, err
+		}
+		if balance == nil || balance.Sign() == 0 {
+			continue
+		}
+		if !balance.IsInt64() || balance.Int64() > maxScanRecords {
+			return nil, fmt.Errorf("result too large: %s holds %s tokens of %s (maximum: %d)", wallet.Hex(), balance, collection.Hex(), maxScanRecords)
+		}
+
+		ids := make([]*big.Int, balance.Int64())
+		err = qe.parallel(len(ids), func(i int) error {
+			index := big.NewInt(int64(i))
+			value, err := qe.stateAt("nft_index", []interface{}{collection.Hex(), wallet.Hex(), index}, block, func() (interface{}, error) {
+				return qe.callContract(ctx, erc721Contract, collection, "tokenOfOwnerByIndex", block, wallet, index)
+			})
+			if err != nil {
+				return fmt.Errorf("error enumerating tokens of %s in %s: %w", wallet.Hex(), collection.Hex(), err)
+			}
+			ids[i], _ = value.(*big.Int)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if id != nil {
+				records = append(records, nftRecord(wallet, collection, "ERC-721", id, big.NewInt(1), block))
+			}
+		}
+	}
+	return records, nil
+}
+
+// reconstructNFTs finds the tokens of collection received by each wallet within the
+// query's range through the LOGS pipeline, and keeps those still held at its end
+func (qe *QueryExecutor) reconstructNFTs(ctx context.Context, query *queries.Query, collection common.Address, standard string, wallets []common.Address) ([]queries.Record, error) {
+	block := query.ToBlock
+
+	// Wallets holding no ERC-721 token need no log scan
+	var balances map[common.Address]*big.Int
+	if standard == "ERC-721" {
+		balances = make(map[common.Address]*big.Int)
+		var holders []common.Address
+		for _, wallet := range wallets {
+			balance, err := qe.nftBalance(ctx, collection, wallet, block)
+			if err != nil {
+				return nil, err
+			}
+			if balance == nil || balance.Sign() > 0 {
+				balances[wallet] = balance
+				holders = append(holders, wallet)
+			}
+		}
+		if wallets = holders; len(wallets) == 0 {
+			return nil, nil
+		}
+	}
+
+	received, err := qe.receivedTokens(ctx, query, collection, standard, wallets)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []queries.Record
+	for _, wallet := range wallets {
+		held := 0
+		for _, id := range received[wallet] {
+			amount := big.NewInt(1)
+			if standard == "ERC-1155" {
+				value, err := qe.stateAt("nft_balance", []interface{}{collection.Hex(), wallet.Hex(), id}, block, func() (interface{}, error) {
+					return qe.callContract(ctx, erc1155Contract, collection, "balanceOf", block, wallet, id)
+				})
+				if err != nil {
+					return nil, fmt.Errorf("error fetching %s #%s balance of %s: %w", collection.Hex(), id, wallet.Hex(), err)
+				}
+				if amount, _ = value.(*big.Int); amount == nil || amount.Sign() == 0 {
+					continue
+				}
+			} else {
+				owner, err := qe.ownerOf(ctx, collection, id, block)
+				if err != nil {
+					return nil, err
+				}
+				if owner == nil || *owner != wallet {
+					continue
+				}
+			}
+			records = append(records, nftRecord(wallet, collection, standard, id, amount, block))
+			held++
+		}
+
+		if balance := balances[wallet]; balance != nil && big.NewInt(int64(held)).Cmp(balance) < 0 {
+			logger.Debug("tokens received before the range were not found", "wallet", wallet.Hex(), "collection", collection.Hex(), "balance", balance)
+		}
+	}
+	return records, nil
+}
+
+// receivedTokens returns the IDs of the tokens of collection transferred to each wallet
+// within the query's range, read as a LOGS query of the collection's transfer events
+func (qe *QueryExecutor) receivedTokens(ctx context.Context, query *queries.Query, collection common.Address, standard string, wallets []common.Address) (map[common.Address][]*big.Int, error) {
+	recipients := make([]queries.Expr, len(wallets))
+	for i, wallet := range wallets {
+		recipients[i] = &queries.Literal{Value: addressTopic(wallet)}
+	}
+	// ERC-1155 transfers index the operator before the sender and recipient
+	events := []queries.Expr{&queries.Literal{Value: transferTopic}}
+	recipientTopic := "topic2"
+	if standard == "ERC-1155" {
+		events = []queries.Expr{&queries.Literal{Value: transferSingleTopic}, &queries.Literal{Value: transferBatchTopic}}
+		recipientTopic = "topic3"
+	}
+
+	logsQuery := &queries.Query{
+		Method:    "LOGS",
+		Addresses: []common.Address{collection},
+		FromBlock: query.FromBlock,
+		ToBlock:   query.ToBlock,
+		Where: &queries.Logical{
+			Op:    "AND",
+			Left:  &queries.In{X: &queries.ColumnRef{Name: "topic0"}, List: events},
+			Right: &queries.In{X: &queries.ColumnRef{Name: recipientTopic}, List: recipients},
+		},
+		// Only the log itself is needed, not the fields fetched separately
+		Fields: []queries.SelectItem{{Name: "data", Expr: &queries.ColumnRef{Name: "data"}, Type: queries.TypeBytes}},
+	}
+	logs, err := qe.getLogs(ctx, logsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	received := make(map[common.Address][]*big.Int)
+	seen := make(map[string]bool)
+	for _, record := range logs {
+		for _, transfer := range transferRecords(recordLog(record)) {
+			to, _ := transfer["to"].(common.Address)
+			id, ok := transfer["token_id"].(*big.Int)
+			key := to.Hex() + ":" + fmt.Sprint(id)
+			if !ok || transfer["standard"] != standard || seen[key] {
+				continue
+			}
+			seen[key] = true
+			received[to] = append(received[to], id)
+		}
+	}
+	for _, ids := range received {
+		sort.Slice(ids, func(i, j int) bool { return ids[i].Cmp(ids[j]) < 0 })
+	}
+	return received, nil
+}
+
+// recordLog rebuilds the log a LOGS record was made from, as far as transferRecords
+// reads it
+func recordLog(record queries.Record) types.Log {
+	log := types.Log{}
+	log.Address, _ = record["address"].(common.Address)
+	log.Data, _ = record["data"].([]byte)
+	for i := 0; i < 4; i++ {
+		topic, ok := record[topicField(i)].(common.Hash)
+		if !ok {
+			break
+		}
+		log.Topics = append(log.Topics, topic)
+	}
+	if number, ok := record["block_number"].(*big.Int); ok {
+		log.BlockNumber = number.Uint64()
+	}
+	log.BlockHash, _ = record["block_hash"].(common.Hash)
+	log.TxHash, _ = record["tx_hash"].(common.Hash)
+	if index, ok := record["log_index"].(*big.Int); ok {
+		log.Index = uint(index.Uint64())
+	}
+	return log
+}
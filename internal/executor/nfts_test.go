@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// testCollection is an NFT contract of a testChain. Its owners and balances do not
+// change with the block.
+type testCollection struct {
+	multi      bool                               // ERC-1155 rather than ERC-721
+	enumerable bool                               // ERC-721 with tokenOfOwnerByIndex
+	owners     map[int64]common.Address           // ERC-721 owners by token ID
+	balances   map[common.Address]map[int64]int64 // ERC-1155 balances by holder and token ID
+}
+
+// call answers a call of the collection; ownerOf reverts for tokens without an owner
+func (c *testCollection) call(input []byte) (hexutil.Bytes, error) {
+	method, err := erc721Contract.MethodById(input[:4])
+	if err != nil {
+		method, err = erc1155Contract.MethodById(input[:4])
+	}
+	if err != nil {
+		return nil, revertError{reason: "unknown function"}
+	}
+	values, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens lists the ERC-721 tokens of an owner in ID order
+	tokens := func(owner common.Address) []int64 {
+		var ids []int64
+		for id, o := range c.owners {
+			if o == owner {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		return ids
+	}
+
+	switch {
+	case method.Name == "supportsInterface":
+		id := values[0].([4]byte)
+		return method.Outputs.Pack((c.multi && id == erc1155Interface) || (c.enumerable && id == erc721EnumerableInterface))
+	case method.Name == "ownerOf":
+		owner, ok := c.owners[values[0].(*big.Int).Int64()]
+		if !ok {
+			return nil, revertError{reason: "invalid token ID"}
+		}
+		return method.Outputs.Pack(owner)
+	case method.Name == "balanceOf" && len(values) == 1:
+		return method.Outputs.Pack(big.NewInt(int64(len(tokens(values[0].(common.Address))))))
+	case method.Name == "balanceOf":
+		return method.Outputs.Pack(big.NewInt(c.balances[values[0].(common.Address)][values[1].(*big.Int).Int64()]))
+	case method.Name == "tokenOfOwnerByIndex" && c.enumerable:
+		ids := tokens(values[0].(common.Address))
+		index := values[1].(*big.Int).Int64()
+		if index >= int64(len(ids)) {
+			return nil, revertError{reason: "index out of bounds"}
+		}
+		return method.Outputs.Pack(big.NewInt(ids[index]))
+	}
+	return nil, revertError{reason: "unsupported"}
+}
+
+func TestExecute_NFTs(t *testing.T) {
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000a11e")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000bad")
+	operator := common.HexToAddress("0x0000000000000000000000000000000000000090")
+	enumerable := common.HexToAddress("0x0000000000000000000000000000000000000e21")
+	plain := common.HexToAddress("0x0000000000000000000000000000000000000721")
+	multi := common.HexToAddress("0x0000000000000000000000000000000000001155")
+
+	batch, err := transferBatchData.Pack([]*big.Int{big.NewInt(1), big.NewInt(2)}, []*big.Int{big.NewInt(10), big.NewInt(20)})
+	if err != nil {
+		t.Fatalf("Failed to pack batch: %v", err)
+	}
+	single := append(common.BigToHash(big.NewInt(3)).Bytes(), common.BigToHash(big.NewInt(4)).Bytes()...)
+	tokenID := func(id int64) common.Hash { return common.BigToHash(big.NewInt(id)) }
+
+	chain := &testChain{
+		head: 1000,
+		logs: []types.Log{
+			// Token 6 was sold on after the wallet received it, token 9 before the range
+			transferLog(plain, 10, 0, nil, transferTopic, addressTopic(other), addressTopic(wallet), tokenID(5)),
+			transferLog(plain, 11, 0, nil, transferTopic, addressTopic(other), addressTopic(wallet), tokenID(6)),
+			transferLog(plain, 12, 0, nil, transferTopic, addressTopic(wallet), addressTopic(other), tokenID(6)),
+			transferLog(multi, 11, 1, single, transferSingleTopic, addressTopic(operator), addressTopic(other), addressTopic(wallet)),
+			transferLog(multi, 12, 1, batch, transferBatchTopic, addressTopic(operator), addressTopic(other), addressTopic(wallet)),
+		},
+		collections: map[common.Address]*testCollection{
+			enumerable: {enumerable: true, owners: map[int64]common.Address{3: wallet, 1: wallet, 2: other}},
+			plain:      {owners: map[int64]common.Address{5: wallet, 6: other, 9: wallet}},
+			multi:      {multi: true, balances: map[common.Address]map[int64]int64{wallet: {1: 0, 2: 20, 3: 4}}},
+		},
+	}
+
+	type nft struct {
+		collection common.Address
+		standard   string
+		id         int64
+		amount     int64
+	}
+	tests := []struct {
+		name        string
+		collections []common.Address
+		expected    []nft
+	}{
+		{
+			name:        "Enumerable ERC-721",
+			collections: []common.Address{enumerable},
+			expected: []nft{
+				{collection: enumerable, standard: "ERC-721", id: 1, amount: 1},
+				{collection: enumerable, standard: "ERC-721", id: 3, amount: 1},
+			},
+		},
+		{
+			name:        "ERC-721 reconstructed from transfers",
+			collections: []common.Address{plain},
+			expected: []nft{
+				{collection: plain, standard: "ERC-721", id: 5, amount: 1},
+			},
+		},
+		{
+			name:        "ERC-1155 reconstructed from transfers",
+			collections: []common.Address{multi},
+			expected: []nft{
+				{collection: multi, standard: "ERC-1155", id: 2, amount: 20},
+				{collection: multi, standard: "ERC-1155", id: 3, amount: 4},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qe := newTestExecutor(t, chain)
+			query := &queries.Query{
+				Method:      "NFTS",
+				Addresses:   []common.Address{wallet},
+				Collections: tt.collections,
+				FromBlock:   big.NewInt(10),
+				ToBlock:     big.NewInt(20),
+			}
+			result, err := qe.Execute(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.Len() != len(tt.expected) {
+				t.Fatalf("Expected %d rows, got %d: %v", len(tt.expected), result.Len(), result.Rows)
+			}
+
+			schema := queries.Schemas["NFTS"].Default().Names()
+			for i, expected := range tt.expected {
+				row := make(map[string]interface{})
+				for j, name := range schema {
+					row[name] = result.Rows[i][j]
+				}
+				if row["address"] != wallet || row["collection"] != expected.collection || row["standard"] != expected.standard {
+					t.Errorf("Row %d: expected %s token of %s, got %v %v %v", i, expected.standard, expected.collection.Hex(), row["address"], row["standard"], row["collection"])
+				}
+				if id, ok := row["token_id"].(*big.Int); !ok || id.Int64() != expected.id {
+					t.Errorf("Row %d: expected token ID %d, got %v", i, expected.id, row["token_id"])
+				}
+				if amount, ok := row["amount"].(*big.Int); !ok || amount.Int64() != expected.amount {
+					t.Errorf("Row %d: expected amount %d, got %v", i, expected.amount, row["amount"])
+				}
+				if block, ok := row["block_number"].(*big.Int); !ok || block.Int64() != 20 {
+					t.Errorf("Row %d: expected block 20, got %v", i, row["block_number"])
+				}
+			}
+		})
+	}
+}
+
+func TestExecute_Owner(t *testing.T) {
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000a11e")
+	collection := common.HexToAddress("0x0000000000000000000000000000000000000721")
+	chain := &testChain{
+		head:        1000,
+		collections: map[common.Address]*testCollection{collection: {owners: map[int64]common.Address{5: wallet}}},
+	}
+	qe := newTestExecutor(t, chain)
+
+	query := &queries.Query{
+		Method:    "OWNER",
+		Addresses: []common.Address{collection},
+		TokenIDs:  []*big.Int{big.NewInt(5), big.NewInt(7)},
+		FromBlock: big.NewInt(900),
+		ToBlock:   big.NewInt(900),
+	}
+	result, err := qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 2 {
+		t.Fatalf("Expected 2 rows, got %d: %v", result.Len(), result.Rows)
+	}
+
+	owners := make([]interface{}, result.Len())
+	for i, name := range queries.Schemas["OWNER"].Default().Names() {
+		if name == "owner" {
+			for j := range owners {
+				owners[j] = result.Rows[j][i]
+			}
+		}
+	}
+	if owner := owners[0]; owner != wallet {
+		t.Errorf("Expected token 5 to be owned by %s, got %v", wallet.Hex(), owner)
+	}
+	// ownerOf reverts for a token that was never minted or was burned
+	if owner := owners[1]; owner != nil {
+		t.Errorf("Expected no owner for token 7, got %v", owner)
+	}
+}
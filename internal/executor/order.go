@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+// scanLimit returns how many matching rows a scan must produce before it can stop
+// early, or 0 if the whole range is needed. That is only possible when the result
+// keeps the natural block order, i.e. there is a LIMIT and no ORDER BY or grouping,
+// and when WHERE can be told from the scanned records alone.
+func scanLimit(query *queries.Query) int {
+	if query.Limit <= 0 || len(query.OrderBy) > 0 || query.Grouped() {
+		return 0
+	}
+	for _, column := range completedFields(query) {
+		if queries.References(query.Where, column) {
+			return 0
+		}
+	}
+	return query.Offset + query.Limit
+}
+
+// completedFields lists the fields completeRecords adds to scanned records, which are
+// still missing while the scan counts matches
+func completedFields(query *queries.Query) []string {
+	fields := []string{"ens_name"}
+	if query.Method == "TRANSACTIONS" || query.Method == "RECEIPTS" {
+		fields = append(fields, receiptFields...)
+	}
+	if query.Event != nil {
+		fields = append(fields, queries.ArgFields(query.Event.Inputs).Names()...)
+	}
+	if query.Function != nil {
+		fields = append(fields, "method", "args")
+		fields = append(fields, queries.ArgFields(query.Function.Inputs).Names()...)
+	}
+	return fields
+}
+
+// sortRecords orders records by the ORDER BY keys. The sort is stable, so rows with
+// equal keys keep their natural block order. NULLs sort last ascending and first descending.
+func sortRecords(records []queries.Record, order []queries.OrderItem) error {
+	if len(order) == 0 {
+		return nil
+	}
+
+	// Evaluate the keys once up front so that comparison errors surface cleanly
+	keys := make([][]interface{}, len(records))
+	for i, record := range records {
+		keys[i] = make([]interface{}, len(order))
+		for j, item := range order {
+			value, err := item.Expr.Eval(record)
+			if err != nil {
+				return fmt.Errorf("error evaluating ORDER BY %s: %w", item.Expr, err)
+			}
+			keys[i][j] = value
+		}
+	}
+
+	indexes := make([]int, len(records))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	var sortErr error
+	sort.SliceStable(indexes, func(a, b int) bool {
+		for j, item := range order {
+			cmp, err := compareNullable(keys[indexes[a]][j], keys[indexes[b]][j])
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			if cmp == 0 {
+				continue
+			}
+			if item.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	if sortErr != nil {
+		return fmt.Errorf("error sorting results: %w", sortErr)
+	}
+
+	sorted := make([]queries.Record, len(records))
+	for i, index := range indexes {
+		sorted[i] = records[index]
+	}
+	copy(records, sorted)
+	return nil
+}
+
+// compareNullable orders values treating NULL as greater than any other value
+func compareNullable(a, b interface{}) (int, error) {
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return 1, nil
+	case b == nil:
+		return -1, nil
+	}
+	return queries.CompareValues(a, b)
+}
+
+// paginate applies OFFSET and LIMIT
+func paginate(records []queries.Record, offset, limit int) []queries.Record {
+	if offset >= len(records) {
+		return nil
+	}
+	records = records[offset:]
+	if limit > 0 && limit < len(records) {
+		records = records[:limit]
+	}
+	return records
+}
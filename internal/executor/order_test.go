@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+func TestSortRecords(t *testing.T) {
+	records := []queries.Record{
+		{"id": big.NewInt(1), "value": big.NewInt(5), "block_number": big.NewInt(10)},
+		{"id": big.NewInt(2), "block_number": big.NewInt(10)},
+		{"id": big.NewInt(3), "value": big.NewInt(7), "block_number": big.NewInt(11)},
+		{"id": big.NewInt(4), "value": big.NewInt(5), "block_number": big.NewInt(9)},
+	}
+
+	tests := []struct {
+		name     string
+		order    []queries.OrderItem
+		expected []int64
+	}{
+		{
+			name:     "No keys keeps block order",
+			expected: []int64{1, 2, 3, 4},
+		},
+		{
+			name:     "Ascending is stable and puts NULL last",
+			order:    []queries.OrderItem{{Expr: &queries.ColumnRef{Name: "value"}}},
+			expected: []int64{1, 4, 3, 2},
+		},
+		{
+			name:     "Descending puts NULL first",
+			order:    []queries.OrderItem{{Expr: &queries.ColumnRef{Name: "value"}, Desc: true}},
+			expected: []int64{2, 3, 1, 4},
+		},
+		{
+			name: "Secondary key breaks ties",
+			order: []queries.OrderItem{
+				{Expr: &queries.ColumnRef{Name: "value"}},
+				{Expr: &queries.ColumnRef{Name: "block_number"}},
+			},
+			expected: []int64{4, 1, 3, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := append([]queries.Record(nil), records...)
+			if err := sortRecords(sorted, tt.order); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			for i, id := range tt.expected {
+				if got := sorted[i]["id"].(*big.Int).Int64(); got != id {
+					t.Errorf("Row %d: expected id %d, got %d", i, id, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	records := make([]queries.Record, 5)
+	for i := range records {
+		records[i] = queries.Record{"id": big.NewInt(int64(i))}
+	}
+
+	tests := []struct {
+		name     string
+		offset   int
+		limit    int
+		expected int
+		first    int64
+	}{
+		{name: "No limit", expected: 5, first: 0},
+		{name: "Limit", limit: 2, expected: 2, first: 0},
+		{name: "Offset and limit", offset: 3, limit: 5, expected: 2, first: 3},
+		{name: "Offset past end", offset: 5, limit: 1, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := paginate(records, tt.offset, tt.limit)
+			if len(page) != tt.expected {
+				t.Fatalf("Expected %d rows, got %d", tt.expected, len(page))
+			}
+			if len(page) > 0 && page[0]["id"].(*big.Int).Int64() != tt.first {
+				t.Errorf("Expected first id %d, got %v", tt.first, page[0]["id"])
+			}
+		})
+	}
+}
+
+func TestScanLimit(t *testing.T) {
+	orderBy := []queries.OrderItem{{Expr: &queries.ColumnRef{Name: "value"}}}
+
+	tests := []struct {
+		name     string
+		query    *queries.Query
+		expected int
+	}{
+		{name: "No limit", query: &queries.Query{}, expected: 0},
+		{name: "Limit and offset", query: &queries.Query{Limit: 10, Offset: 5}, expected: 15},
+		{name: "Order by needs every row", query: &queries.Query{Limit: 10, OrderBy: orderBy}, expected: 0},
+		{name: "Receipt field in WHERE", query: &queries.Query{Method: "TRANSACTIONS", Limit: 10, Where: &queries.IsNull{X: &queries.ColumnRef{Name: "status"}}}, expected: 0},
+		{name: "ENS name in WHERE", query: &queries.Query{Method: "BALANCE", Limit: 10, Where: &queries.IsNull{X: &queries.ColumnRef{Name: "ens_name"}}}, expected: 0},
+		{name: "Scanned field in WHERE", query: &queries.Query{Method: "TRANSACTIONS", Limit: 10, Where: &queries.IsNull{X: &queries.ColumnRef{Name: "to"}}}, expected: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanLimit(tt.query); got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
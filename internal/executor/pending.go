@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// poolTx is a transaction waiting in the node's transaction pool
+type poolTx struct {
+	tx   *types.Transaction
+	from common.Address
+	pool string // pending or queued
+}
+
+// getPending returns the transactions of the pool sent from or to the queried
+// addresses, attributed like TRANSACTIONS. The pool changes with every block, so
+// nothing is cached.
+func (qe *QueryExecutor) getPending(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	addresses := queryAddresses(query)
+	if len(addresses) == 0 {
+		logger.Debug("pending filter can never match")
+		return nil, nil
+	}
+	watched := make(map[common.Address]bool, len(addresses))
+	for _, address := range addresses {
+		watched[address] = true
+	}
+
+	var selector []byte
+	if query.Function != nil {
+		selector = query.Function.ID
+	}
+
+	txs, err := qe.poolTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []queries.Record
+	for _, ptx := range txs {
+		var record queries.Record
+		switch to := ptx.tx.To(); {
+		case watched[ptx.from]:
+			record = pendingRecord(ptx.from, ptx)
+		case to != nil && watched[*to]:
+			record = pendingRecord(*to, ptx)
+		default:
+			continue
+		}
+		if selector != nil && !bytes.HasPrefix(ptx.tx.Data(), selector) {
+			continue
+		}
+		qe.addCallFields(record, ptx.tx.To(), ptx.tx.Data())
+		records = append(records, record)
+		if len(records) > maxScanRecords {
+			return nil, fmt.Errorf("result too large: %d pending transactions (maximum: %d)", len(records), maxScanRecords)
+		}
+	}
+	return qe.completeRecords(ctx, records, query)
+}
+
+// poolTransactions reads the pool with txpool_content, falling back to
+// eth_pendingTransactions on nodes without it. The fallback has no queued
+// transactions, and some nodes limit it to the transactions of their own accounts.
+// Transactions are returned pending first, then by sender and nonce.
+func (qe *QueryExecutor) poolTransactions(ctx context.Context) ([]*poolTx, error) {
+	if !qe.noTxPool.Load() {
+		txs, err := qe.txPoolContent(ctx)
+		if err == nil || !methodNotFound(err) {
+			return txs, err
+		}
+		qe.noTxPool.Store(true)
+		logger.Debug("txpool_content is not supported, reading eth_pendingTransactions")
+	}
+
+	var pending []json.RawMessage
+	if err := qe.client.Client().CallContext(ctx, &pending, "eth_pendingTransactions"); err != nil {
+		return nil, fmt.Errorf("error reading pending transactions: %w", err)
+	}
+	txs := make([]*poolTx, 0, len(pending))
+	for _, raw := range pending {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("error decoding pending transaction: %w", err)
+		}
+		var sender struct {
+			From common.Address `json:"from"`
+		}
+		if err := json.Unmarshal(raw, &sender); err != nil {
+			return nil, fmt.Errorf("error decoding sender of pending transaction %s: %w", tx.Hash().Hex(), err)
+		}
+		txs = append(txs, &poolTx{tx: tx, from: sender.From, pool: "pending"})
+	}
+	sortPool(txs)
+	return txs, nil
+}
+
+// txPoolContent reads txpool_content, which lists the pending and queued
+// transactions by sender and nonce
+func (qe *QueryExecutor) txPoolContent(ctx context.Context) ([]*poolTx, error) {
+	var content map[string]map[common.Address]map[string]*types.Transaction
+	if err := qe.client.Client().CallContext(ctx, &content, "txpool_content"); err != nil {
+		if methodNotFound(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error reading transaction pool: %w", err)
+	}
+
+	var txs []*poolTx
+	for _, pool := range []string{"pending", "queued"} {
+		for from, byNonce := range content[pool] {
+			for _, tx := range byNonce {
+				txs = append(txs, &poolTx{tx: tx, from: from, pool: pool})
+			}
+		}
+	}
+	sortPool(txs)
+	return txs, nil
+}
+
+// sortPool orders transactions pending first, then by sender and nonce
+func sortPool(txs []*poolTx) {
+	sort.SliceStable(txs, func(i, j int) bool {
+		a, b := txs[i], txs[j]
+		if a.pool != b.pool {
+			return a.pool == "pending"
+		}
+		if c := bytes.Compare(a.from[:], b.from[:]); c != 0 {
+			return c < 0
+		}
+		return a.tx.Nonce() < b.tx.Nonce()
+	})
+}
@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Content answers txpool_content with the pending and queued transactions by sender
+// and nonce; every transaction of a testChain is sent by testSender
+func (c *testChain) Content() (map[string]map[common.Address]map[string]*types.Transaction, error) {
+	if c.noTxPool {
+		return nil, methodNotFoundError{method: "txpool_content"}
+	}
+	content := make(map[string]map[common.Address]map[string]*types.Transaction)
+	for pool, txs := range map[string][]*types.Transaction{"pending": c.pending, "queued": c.queued} {
+		content[pool] = make(map[common.Address]map[string]*types.Transaction)
+		for _, tx := range txs {
+			if content[pool][testSender] == nil {
+				content[pool][testSender] = make(map[string]*types.Transaction)
+			}
+			content[pool][testSender][strconv.FormatUint(tx.Nonce(), 10)] = tx
+		}
+	}
+	return content, nil
+}
+
+// PendingTransactions answers eth_pendingTransactions with the pending transactions
+func (c *testChain) PendingTransactions() ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	for _, tx := range c.pending {
+		data, err := json.Marshal(tx)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		fields["from"] = testSender
+		result = append(result, fields)
+	}
+	return result, nil
+}
+
+func TestExecute_Pending(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000bad")
+
+	chain := &testChain{
+		head: 1000,
+		// Listed out of nonce order, as the pool has no order
+		pending: []*types.Transaction{testTx(t, 6, &other, 50000), testTx(t, 5, &contract, 50000)},
+		queued:  []*types.Transaction{testTx(t, 9, &contract, 50000)},
+	}
+
+	type pending struct {
+		nonce int64
+		pool  string
+	}
+	tests := []struct {
+		name     string
+		address  common.Address
+		where    queries.Expr
+		noTxPool bool
+		expected []pending
+	}{
+		{
+			name:     "Sent to a contract",
+			address:  contract,
+			expected: []pending{{nonce: 5, pool: "pending"}, {nonce: 9, pool: "queued"}},
+		},
+		{
+			name:     "Sent from an account",
+			address:  testSender,
+			where:    &queries.Comparison{Op: "=", Left: &queries.ColumnRef{Name: "pool"}, Right: &queries.Literal{Value: "pending"}},
+			expected: []pending{{nonce: 5, pool: "pending"}, {nonce: 6, pool: "pending"}},
+		},
+		{
+			name:     "Without txpool_content",
+			address:  contract,
+			noTxPool: true,
+			expected: []pending{{nonce: 5, pool: "pending"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain.noTxPool = tt.noTxPool
+			qe := newTestExecutor(t, chain)
+			query := &queries.Query{
+				Method:    "PENDING",
+				Addresses: []common.Address{tt.address},
+				Where:     tt.where,
+			}
+			result, err := qe.Execute(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.Len() != len(tt.expected) {
+				t.Fatalf("Expected %d rows, got %d: %v", len(tt.expected), result.Len(), result.Rows)
+			}
+			if result.Meta.FromBlock != nil {
+				t.Errorf("Expected no block range, got %v", result.Meta.FromBlock)
+			}
+
+			schema := queries.Schemas["PENDING"].Default().Names()
+			for i, expected := range tt.expected {
+				row := make(map[string]interface{})
+				for j, name := range schema {
+					row[name] = result.Rows[i][j]
+				}
+				if row["address"] != tt.address || row["from"] != testSender {
+					t.Errorf("Row %d: expected a transaction of %s from %s, got %v from %v", i, tt.address.Hex(), testSender.Hex(), row["address"], row["from"])
+				}
+				if nonce, ok := row["nonce"].(*big.Int); !ok || nonce.Int64() != expected.nonce {
+					t.Errorf("Row %d: expected nonce %d, got %v", i, expected.nonce, row["nonce"])
+				}
+				if row["pool"] != expected.pool {
+					t.Errorf("Row %d: expected pool %s, got %v", i, expected.pool, row["pool"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+// selectItems returns the projected columns of the query, defaulting to the fields of SELECT *
+func selectItems(query *queries.Query) []queries.SelectItem {
+	if len(query.Fields) > 0 {
+		return query.Fields
+	}
+
+	schema := query.Schema().Default()
+	items := make([]queries.SelectItem, len(schema))
+	for i, field := range schema {
+		items[i] = queries.SelectItem{Name: field.Name, Expr: &queries.ColumnRef{Name: field.Name}, Type: field.Type}
+	}
+	return items
+}
+
+// project evaluates the select list over each record to build the result set
+func project(records []queries.Record, query *queries.Query) (*queries.ResultSet, error) {
+	items := selectItems(query)
+
+	rs := &queries.ResultSet{
+		Columns: make([]queries.Column, len(items)),
+		Rows:    make([][]interface{}, 0, len(records)),
+	}
+	for i, item := range items {
+		rs.Columns[i] = queries.Column{Name: item.Name, Type: item.Type}
+	}
+
+	for _, record := range records {
+		row := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := item.Expr.Eval(record)
+			if err != nil {
+				return nil, fmt.Errorf("error evaluating column %s: %w", item.Name, err)
+			}
+			row[i] = value
+		}
+		rs.Rows = append(rs.Rows, row)
+	}
+
+	return rs, nil
+}
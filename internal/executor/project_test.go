@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestProject_SelectedFields(t *testing.T) {
+	to := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	records := []queries.Record{
+		{"hash": common.HexToHash("0x01"), "to": to, "value": big.NewInt(7)},
+		{"hash": common.HexToHash("0x02"), "value": big.NewInt(9)},
+	}
+
+	query := &queries.Query{
+		Method: "TRANSACTIONS",
+		Fields: []queries.SelectItem{
+			{Name: "to", Expr: &queries.ColumnRef{Name: "to"}, Type: queries.TypeAddress},
+			{Name: "wei", Expr: &queries.ColumnRef{Name: "value"}, Type: queries.TypeInt},
+		},
+	}
+
+	rs, err := project(records, query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(rs.Columns) != 2 || rs.Columns[0].Name != "to" || rs.Columns[1].Name != "wei" {
+		t.Fatalf("Unexpected columns: %+v", rs.Columns)
+	}
+	if rs.Len() != 2 {
+		t.Fatalf("Expected 2 rows, got %d", rs.Len())
+	}
+	if rs.Rows[0][0] != to {
+		t.Errorf("Expected first row to be %s, got %v", to.Hex(), rs.Rows[0][0])
+	}
+	if rs.Rows[1][0] != nil {
+		t.Errorf("Expected NULL for missing field, got %v", rs.Rows[1][0])
+	}
+	if rs.Rows[1][1].(*big.Int).Int64() != 9 {
+		t.Errorf("Expected aliased value 9, got %v", rs.Rows[1][1])
+	}
+}
+
+func TestProject_DefaultsToSchema(t *testing.T) {
+	rs, err := project(nil, &queries.Query{Method: "LOGS"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Optional columns such as ens_name are only produced when selected by name
+	schema := queries.Schemas["LOGS"].Default()
+	if len(rs.Columns) != len(schema) {
+		t.Fatalf("Expected %d columns, got %d", len(schema), len(rs.Columns))
+	}
+	for i, field := range schema {
+		if rs.Columns[i].Name != field.Name || rs.Columns[i].Type != field.Type {
+			t.Errorf("Column %d: expected %s %s, got %s %s", i, field.Name, field.Type, rs.Columns[i].Name, rs.Columns[i].Type)
+		}
+	}
+	if rs.Len() != 0 {
+		t.Errorf("Expected no rows, got %d", rs.Len())
+	}
+}
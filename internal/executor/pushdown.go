@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// logFilter builds the RPC filter for a LOGS query, covering every queried address in
+// one request. Top-level WHERE conditions of the form "address = x", "address IN (...)"
+// and "topicN = x" / "topicN IN (...)" are pushed down so the node only returns
+// candidate logs; the full predicate is still evaluated client-side afterwards. It
+// returns false when the conditions can never match.
+func logFilter(query *queries.Query) (ethereum.FilterQuery, bool) {
+	filter := ethereum.FilterQuery{
+		FromBlock: query.FromBlock,
+		ToBlock:   query.ToBlock,
+	}
+	// Without an address the filter matches every contract, by topic alone
+	if !query.AnyAddress {
+		filter.Addresses = queryAddresses(query)
+		if len(filter.Addresses) == 0 {
+			return filter, false
+		}
+	}
+
+	// Anonymous events have no topic0 to match on
+	if query.Event != nil && !query.Event.Anonymous {
+		filter.Topics = [][]common.Hash{{query.Event.ID}}
+	}
+
+	for _, term := range queries.Conjuncts(query.Where) {
+		column, values, ok := equalityValues(term)
+		if !ok {
+			continue
+		}
+
+		position := topicPosition(column)
+		if position < 0 {
+			continue
+		}
+		for len(filter.Topics) <= position {
+			filter.Topics = append(filter.Topics, nil)
+		}
+		filter.Topics[position] = intersectHashes(filter.Topics[position], values)
+		if len(filter.Topics[position]) == 0 {
+			return filter, false
+		}
+	}
+
+	return filter, true
+}
+
+// queryAddresses returns the addresses a query reads, narrowed by top-level
+// "address = x" and "address IN (...)" conditions. It is empty when those
+// conditions exclude every address.
+func queryAddresses(query *queries.Query) []common.Address {
+	addresses := query.AddressList()
+	for _, term := range queries.Conjuncts(query.Where) {
+		if column, values, ok := equalityValues(term); ok && column == "address" {
+			addresses = intersectAddresses(addresses, values)
+		}
+	}
+	return addresses
+}
+
+// equalityValues matches "column = literal" and "column IN (literals)", returning
+// the literal values
+func equalityValues(expr queries.Expr) (string, []interface{}, bool) {
+	column, list, ok := queries.EqualityTerm(expr)
+	if !ok {
+		return "", nil, false
+	}
+	values := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		literal, ok := item.(*queries.Literal)
+		if !ok || literal.Value == nil {
+			return "", nil, false
+		}
+		values = append(values, literal.Value)
+	}
+	return column, values, true
+}
+
+// topicPosition returns N for the column "topicN", or -1
+func topicPosition(column string) int {
+	for i := 0; i < 4; i++ {
+		if column == topicField(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// intersectAddresses keeps the addresses of current that appear in values, in order
+func intersectAddresses(current []common.Address, values []interface{}) []common.Address {
+	var result []common.Address
+	for _, existing := range current {
+		for _, value := range values {
+			if address, ok := value.(common.Address); ok && address == existing {
+				result = append(result, existing)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// intersectHashes narrows a topic position; a nil current set matches any topic
+func intersectHashes(current []common.Hash, values []interface{}) []common.Hash {
+	var result []common.Hash
+	for _, value := range values {
+		hash, ok := value.(common.Hash)
+		if !ok {
+			continue
+		}
+		if current == nil {
+			result = append(result, hash)
+			continue
+		}
+		for _, existing := range current {
+			if existing == hash {
+				result = append(result, hash)
+				break
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,193 @@
+package executor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestLogFilter_Pushdown(t *testing.T) {
+	contract := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	approval := common.HexToHash("0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925")
+	holder := common.BytesToHash(other.Bytes())
+
+	lit := func(v interface{}) queries.Expr { return &queries.Literal{Value: v} }
+	col := func(name string) queries.Expr { return &queries.ColumnRef{Name: name} }
+
+	where := &queries.Logical{
+		Op: "AND",
+		Left: &queries.Logical{
+			Op:    "AND",
+			Left:  &queries.In{X: col("topic0"), List: []queries.Expr{lit(transfer), lit(approval)}},
+			Right: &queries.Comparison{Op: "=", Left: lit(holder), Right: col("topic2")},
+		},
+		Right: &queries.Comparison{Op: ">", Left: col("log_index"), Right: lit(big.NewInt(3))},
+	}
+
+	query := &queries.Query{
+		Method:    "LOGS",
+		Address:   contract,
+		FromBlock: big.NewInt(100),
+		ToBlock:   big.NewInt(200),
+		Where:     where,
+	}
+
+	filter, ok := logFilter(query)
+	if !ok {
+		t.Fatal("Expected filter to be satisfiable")
+	}
+
+	if len(filter.Addresses) != 1 || filter.Addresses[0] != contract {
+		t.Errorf("Expected addresses [%s], got %v", contract.Hex(), filter.Addresses)
+	}
+
+	if len(filter.Topics) != 3 {
+		t.Fatalf("Expected 3 topic positions, got %d", len(filter.Topics))
+	}
+	if len(filter.Topics[0]) != 2 || filter.Topics[0][0] != transfer || filter.Topics[0][1] != approval {
+		t.Errorf("Expected topic0 OR-set [transfer, approval], got %v", filter.Topics[0])
+	}
+	if filter.Topics[1] != nil {
+		t.Errorf("Expected topic1 to be a wildcard, got %v", filter.Topics[1])
+	}
+	if len(filter.Topics[2]) != 1 || filter.Topics[2][0] != holder {
+		t.Errorf("Expected topic2 [%s], got %v", holder.Hex(), filter.Topics[2])
+	}
+}
+
+func TestLogFilter_Unsatisfiable(t *testing.T) {
+	contract := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	query := &queries.Query{
+		Method:  "LOGS",
+		Address: contract,
+		Where:   &queries.Comparison{Op: "=", Left: &queries.ColumnRef{Name: "address"}, Right: &queries.Literal{Value: other}},
+	}
+
+	if _, ok := logFilter(query); ok {
+		t.Error("Expected filter on a different address to be unsatisfiable")
+	}
+}
+
+func TestLogFilter_IgnoresNonPushableTerms(t *testing.T) {
+	contract := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+	// OR and NOT IN cannot be expressed as an RPC filter and must stay client-side
+	query := &queries.Query{
+		Method:  "LOGS",
+		Address: contract,
+		Where: &queries.Logical{
+			Op:    "OR",
+			Left:  &queries.Comparison{Op: "=", Left: &queries.ColumnRef{Name: "topic0"}, Right: &queries.Literal{Value: transfer}},
+			Right: &queries.In{X: &queries.ColumnRef{Name: "topic1"}, List: []queries.Expr{&queries.Literal{Value: transfer}}, Negate: true},
+		},
+	}
+
+	filter, ok := logFilter(query)
+	if !ok {
+		t.Fatal("Expected filter to be satisfiable")
+	}
+	if len(filter.Topics) != 0 {
+		t.Errorf("Expected no topic pushdown, got %v", filter.Topics)
+	}
+}
+
+func TestLogFilter_MultipleAddresses(t *testing.T) {
+	first := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	second := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	third := common.HexToAddress("0x0000000000000000000000000000000000000003")
+
+	lit := func(v interface{}) queries.Expr { return &queries.Literal{Value: v} }
+
+	query := &queries.Query{
+		Method:    "LOGS",
+		Address:   first,
+		Addresses: []common.Address{first, second, third},
+	}
+	filter, ok := logFilter(query)
+	if !ok || len(filter.Addresses) != 3 {
+		t.Fatalf("Expected one filter over all 3 addresses, got %v (satisfiable: %v)", filter.Addresses, ok)
+	}
+
+	// WHERE address IN narrows the set, keeping the order of FROM
+	query.Where = &queries.In{X: &queries.ColumnRef{Name: "address"}, List: []queries.Expr{lit(third), lit(first)}}
+	filter, ok = logFilter(query)
+	if !ok || len(filter.Addresses) != 2 || filter.Addresses[0] != first || filter.Addresses[1] != third {
+		t.Errorf("Expected addresses [%s %s], got %v", first.Hex(), third.Hex(), filter.Addresses)
+	}
+
+	query.Where = &queries.Comparison{Op: "=", Left: &queries.ColumnRef{Name: "address"}, Right: lit(common.HexToAddress("0x04"))}
+	if _, ok := logFilter(query); ok {
+		t.Error("Expected filter on an address outside FROM to be unsatisfiable")
+	}
+}
+
+func TestLogFilter_AnyAddress(t *testing.T) {
+	transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+	query := &queries.Query{
+		Method:     "LOGS",
+		AnyAddress: true,
+		FromBlock:  big.NewInt(100),
+		ToBlock:    big.NewInt(200),
+		Where:      &queries.Comparison{Op: "=", Left: &queries.ColumnRef{Name: "topic0"}, Right: &queries.Literal{Value: transfer}},
+	}
+
+	filter, ok := logFilter(query)
+	if !ok {
+		t.Fatal("Expected filter to be satisfiable")
+	}
+	if filter.Addresses != nil {
+		t.Errorf("Expected no address filter, got %v", filter.Addresses)
+	}
+	if len(filter.Topics) != 1 || len(filter.Topics[0]) != 1 || filter.Topics[0][0] != transfer {
+		t.Errorf("Expected topic0 [%s], got %v", transfer.Hex(), filter.Topics)
+	}
+}
+
+func TestLogFilter_Event(t *testing.T) {
+	contract := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	transfer := mustParseABI(`[{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256"}]}]`).Events["Transfer"]
+
+	query := &queries.Query{Method: "LOGS", Address: contract, Event: &transfer}
+	filter, ok := logFilter(query)
+	if !ok || len(filter.Topics) != 1 || len(filter.Topics[0]) != 1 || filter.Topics[0][0] != transfer.ID {
+		t.Fatalf("Expected topic0 [%s], got %v (satisfiable: %v)", transfer.ID.Hex(), filter.Topics, ok)
+	}
+
+	// A topic0 condition for another event can never match
+	other := common.HexToHash("0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925")
+	query.Where = &queries.Comparison{Op: "=", Left: &queries.ColumnRef{Name: "topic0"}, Right: &queries.Literal{Value: other}}
+	if _, ok := logFilter(query); ok {
+		t.Error("Expected filter on another event to be unsatisfiable")
+	}
+}
+
+func TestFilterRecords(t *testing.T) {
+	records := []queries.Record{
+		{"value": big.NewInt(1)},
+		{"value": big.NewInt(5)},
+		{"value": big.NewInt(10)},
+	}
+
+	where := &queries.Comparison{Op: ">=", Left: &queries.ColumnRef{Name: "value"}, Right: &queries.Literal{Value: big.NewInt(5)}}
+
+	matched, err := filterRecords(records, where)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(matched) != 2 {
+		t.Errorf("Expected 2 matching records, got %d", len(matched))
+	}
+
+	all, err := filterRecords(records, nil)
+	if err != nil || len(all) != 3 {
+		t.Errorf("Expected nil predicate to keep all records, got %d (err: %v)", len(all), err)
+	}
+}
@@ -0,0 +1,196 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// receiptFields are the fields read from transaction receipts. RECEIPTS produces
+// them and TRANSACTIONS joins them in when the query names them.
+var receiptFields = []string{
+	"status",
+	"gas_used",
+	"cumulative_gas_used",
+	"effective_gas_price",
+	"contract_address",
+	"logs_count",
+	"blob_gas_used",
+	"blob_gas_price",
+}
+
+// usesReceipts reports whether a TRANSACTIONS or RECEIPTS query reads any receipt field
+func usesReceipts(query *queries.Query) bool {
+	if query.Method != "TRANSACTIONS" && query.Method != "RECEIPTS" {
+		return false
+	}
+	for _, field := range receiptFields {
+		if query.Uses(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// addReceiptFields sets the fields of record that come from receipt
+func addReceiptFields(record queries.Record, receipt *types.Receipt) {
+	// Receipts from before Byzantium carry a state root instead of a status
+	if len(receipt.PostState) == 0 {
+		record["status"] = uint64ToBig(receipt.Status)
+	}
+	record["gas_used"] = uint64ToBig(receipt.GasUsed)
+	record["cumulative_gas_used"] = uint64ToBig(receipt.CumulativeGasUsed)
+	if receipt.EffectiveGasPrice != nil {
+		record["effective_gas_price"] = receipt.EffectiveGasPrice
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		record["contract_address"] = receipt.ContractAddress
+	}
+	record["logs_count"] = uint64ToBig(uint64(len(receipt.Logs)))
+	if receipt.BlobGasPrice != nil {
+		record["blob_gas_used"] = uint64ToBig(receipt.BlobGasUsed)
+		record["blob_gas_price"] = receipt.BlobGasPrice
+	}
+}
+
+// addReceipts fills in the receipt fields of records, whose transaction hash is held
+// in hashField
+func (qe *QueryExecutor) addReceipts(ctx context.Context, records []queries.Record, hashField string) error {
+	blocks := make(map[common.Hash][]common.Hash)
+	for _, record := range records {
+		if _, ok := record["gas_used"]; ok {
+			continue
+		}
+		block, ok := record["block_hash"].(common.Hash)
+		if !ok {
+			continue
+		}
+		if tx, ok := record[hashField].(common.Hash); ok {
+			blocks[block] = append(blocks[block], tx)
+		}
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	receipts, err := qe.receipts(ctx, blocks)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if tx, ok := record[hashField].(common.Hash); ok {
+			if receipt, ok := receipts[tx]; ok {
+				addReceiptFields(record, receipt)
+			}
+		}
+	}
+	return nil
+}
+
+// receipts returns the receipts of the transactions listed under the hash of the block
+// that includes them, keyed by transaction hash. Each block's receipts are fetched with
+// a single eth_getBlockReceipts call; nodes without it are asked for one receipt at a time.
+func (qe *QueryExecutor) receipts(ctx context.Context, blocks map[common.Hash][]common.Hash) (map[common.Hash]*types.Receipt, error) {
+	result := make(map[common.Hash]*types.Receipt)
+	var mu sync.Mutex
+	store := func(block common.Hash, receipt *types.Receipt) {
+		result[receipt.TxHash] = receipt
+		qe.cache.Set(cache.GenerateKey("receipt", block, receipt.TxHash), receipt, 0)
+	}
+
+	var missing []common.Hash
+	for block, txs := range blocks {
+		complete := true
+		for _, tx := range txs {
+			if cached, found := qe.cache.Get(cache.GenerateKey("receipt", block, tx)); found {
+				if receipt, ok := cached.(*types.Receipt); ok {
+					result[tx] = receipt
+					continue
+				}
+			}
+			complete = false
+		}
+		if !complete {
+			missing = append(missing, block)
+		}
+	}
+
+	if len(missing) > 0 && !qe.noBlockReceipts.Load() {
+		err := qe.parallel(len(missing), func(i int) error {
+			block := missing[i]
+			receipts, err := qe.client.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(block, false))
+			if err != nil {
+				return err
+			}
+			wanted := make(map[common.Hash]bool, len(blocks[block]))
+			for _, tx := range blocks[block] {
+				wanted[tx] = true
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, receipt := range receipts {
+				if wanted[receipt.TxHash] {
+					store(block, receipt)
+				}
+			}
+			return nil
+		})
+		switch {
+		case err != nil && methodNotFound(err):
+			qe.noBlockReceipts.Store(true)
+			logger.Debug("eth_getBlockReceipts is not supported, fetching receipts one at a time")
+		case err != nil:
+			return nil, fmt.Errorf("error fetching block receipts: %w", err)
+		}
+	}
+
+	type pendingReceipt struct {
+		block, tx common.Hash
+	}
+	var pending []pendingReceipt
+	for block, txs := range blocks {
+		for _, tx := range txs {
+			if _, ok := result[tx]; !ok {
+				pending = append(pending, pendingReceipt{block: block, tx: tx})
+			}
+		}
+	}
+	err := qe.parallel(len(pending), func(i int) error {
+		receipt, err := qe.client.TransactionReceipt(ctx, pending[i].tx)
+		if err != nil {
+			return fmt.Errorf("error fetching receipt of %s: %w", pending[i].tx.Hex(), err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		store(pending[i].block, receipt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// methodNotFound reports whether err is a node's answer that it does not implement
+// the requested method
+func methodNotFound(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601 {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "method not found") ||
+		strings.Contains(message, "does not exist") ||
+		strings.Contains(message, "not supported")
+}
@@ -0,0 +1,261 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// newReceiptChain returns a chain where testSender pays alice in block 500, sends a
+// failing call to bob in the same block and deploys a contract in block 501
+func newReceiptChain(t *testing.T) (*testChain, common.Address, common.Address) {
+	alice := common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	chain := &testChain{
+		head: 1000,
+		txs: map[uint64][]*types.Transaction{
+			500: {testTx(t, 0, &alice, 50000), testTx(t, 1, &bob, 25000)},
+			501: {testTx(t, 2, nil, 100000)},
+		},
+	}
+	return chain, alice, bob
+}
+
+func TestExecute_Receipts(t *testing.T) {
+	for _, noBlockReceipts := range []bool{false, true} {
+		chain, _, _ := newReceiptChain(t)
+		chain.noBlockReceipts = noBlockReceipts
+		qe := newTestExecutor(t, chain)
+
+		query := &queries.Query{
+			Method:    "RECEIPTS",
+			Addresses: []common.Address{testSender},
+			FromBlock: big.NewInt(500),
+			ToBlock:   big.NewInt(501),
+		}
+		result, err := qe.Execute(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if result.Len() != 3 {
+			t.Fatalf("Expected 3 rows, got %d", result.Len())
+		}
+
+		schema := queries.Schemas["RECEIPTS"].Default().Names()
+		deployment := crypto.CreateAddress(testSender, 2)
+		for i, expected := range []struct {
+			status     int64
+			cumulative int64
+			contract   interface{}
+		}{
+			{status: 1, cumulative: 21000, contract: nil},
+			{status: 0, cumulative: 42000, contract: nil},
+			{status: 1, cumulative: 21000, contract: deployment},
+		} {
+			row := make(map[string]interface{})
+			for j, name := range schema {
+				row[name] = result.Rows[i][j]
+			}
+			if status, ok := row["status"].(*big.Int); !ok || status.Int64() != expected.status {
+				t.Errorf("Row %d: expected status %d, got %v", i, expected.status, row["status"])
+			}
+			if cumulative, ok := row["cumulative_gas_used"].(*big.Int); !ok || cumulative.Int64() != expected.cumulative {
+				t.Errorf("Row %d: expected cumulative gas %d, got %v", i, expected.cumulative, row["cumulative_gas_used"])
+			}
+			if price, ok := row["effective_gas_price"].(*big.Int); !ok || price.Int64() != 100 {
+				t.Errorf("Row %d: expected effective gas price 100, got %v", i, row["effective_gas_price"])
+			}
+			if row["contract_address"] != expected.contract {
+				t.Errorf("Row %d: expected contract address %v, got %v", i, expected.contract, row["contract_address"])
+			}
+			if row["blob_gas_used"] != nil {
+				t.Errorf("Row %d: expected no blob gas, got %v", i, row["blob_gas_used"])
+			}
+		}
+
+		if noBlockReceipts {
+			if chain.receiptCalls != 3 {
+				t.Errorf("Expected 3 receipt requests without eth_getBlockReceipts, got %d", chain.receiptCalls)
+			}
+		} else if chain.blockReceiptCalls != 2 || chain.receiptCalls != 0 {
+			t.Errorf("Expected 2 block receipt requests and no others, got %d and %d", chain.blockReceiptCalls, chain.receiptCalls)
+		}
+	}
+}
+
+func TestExecute_TransactionReceiptFields(t *testing.T) {
+	chain, _, bob := newReceiptChain(t)
+	qe := newTestExecutor(t, chain)
+
+	// Receipts are only fetched when a receipt field is named
+	query := &queries.Query{
+		Method:    "TRANSACTIONS",
+		Addresses: []common.Address{testSender},
+		FromBlock: big.NewInt(500),
+		ToBlock:   big.NewInt(501),
+	}
+	result, err := qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 3 {
+		t.Fatalf("Expected 3 rows, got %d", result.Len())
+	}
+	if chain.blockReceiptCalls != 0 {
+		t.Errorf("Expected no receipt requests, got %d", chain.blockReceiptCalls)
+	}
+
+	query.Fields = []queries.SelectItem{
+		{Name: "to", Expr: &queries.ColumnRef{Name: "to"}, Type: queries.TypeAddress},
+		{Name: "gas_used", Expr: &queries.ColumnRef{Name: "gas_used"}, Type: queries.TypeInt},
+	}
+	query.Where = &queries.Comparison{Op: "=", Left: &queries.ColumnRef{Name: "status"}, Right: &queries.Literal{Value: big.NewInt(0)}}
+	result, err = qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 1 {
+		t.Fatalf("Expected 1 failed transaction, got %d", result.Len())
+	}
+	if result.Rows[0][0] != bob {
+		t.Errorf("Expected the failed transaction to go to bob, got %v", result.Rows[0][0])
+	}
+	if gasUsed, ok := result.Rows[0][1].(*big.Int); !ok || gasUsed.Int64() != 21000 {
+		t.Errorf("Expected 21000 gas used, got %v", result.Rows[0][1])
+	}
+}
+
+func TestExecute_ReceiptFieldLimit(t *testing.T) {
+	chain, _, _ := newReceiptChain(t)
+	qe := newTestExecutor(t, chain)
+
+	// Receipt fields are NULL until the scan is done, so they must not stop it early
+	query := &queries.Query{
+		Method:    "TRANSACTIONS",
+		Addresses: []common.Address{testSender},
+		FromBlock: big.NewInt(500),
+		ToBlock:   big.NewInt(501),
+		Where: &queries.Logical{
+			Op:    "OR",
+			Left:  &queries.IsNull{X: &queries.ColumnRef{Name: "status"}},
+			Right: &queries.IsNull{X: &queries.ColumnRef{Name: "contract_address"}, Negate: true},
+		},
+		Limit: 1,
+	}
+	result, err := qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 1 {
+		t.Fatalf("Expected the deployment, got %d rows", result.Len())
+	}
+	schema := queries.Schemas["TRANSACTIONS"].Default().Names()
+	for j, name := range schema {
+		if name == "block_number" {
+			if number, ok := result.Rows[0][j].(*big.Int); !ok || number.Int64() != 501 {
+				t.Errorf("Expected the deployment in block 501, got block %v", result.Rows[0][j])
+			}
+		}
+	}
+}
+
+func TestExecute_ReceiptFieldsLeaveCacheUnchanged(t *testing.T) {
+	chain, _, _ := newReceiptChain(t)
+	qe := newTestExecutor(t, chain)
+	memory := cache.NewInMemoryCache(1000, time.Minute, time.Minute)
+	qe.SetCache(memory)
+
+	query := &queries.Query{
+		Method:    "TRANSACTIONS",
+		Addresses: []common.Address{testSender},
+		FromBlock: big.NewInt(500),
+		ToBlock:   big.NewInt(501),
+	}
+	if _, err := qe.Execute(context.Background(), query); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// A cache hit that needs receipts adds them to copies of the cached records
+	query.Where = &queries.Comparison{Op: "=", Left: &queries.ColumnRef{Name: "status"}, Right: &queries.Literal{Value: big.NewInt(0)}}
+	result, err := qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 1 {
+		t.Fatalf("Expected 1 failed transaction, got %d", result.Len())
+	}
+
+	cached, found := memory.Get(cache.GenerateKey("transactions", []common.Address{testSender}, query.FromBlock, query.ToBlock, []byte(nil)))
+	if !found {
+		t.Fatal("Expected the transactions to be cached")
+	}
+	for i, record := range cached.([]queries.Record) {
+		if _, ok := record["status"]; ok {
+			t.Errorf("Cached record %d: expected no receipt fields, got status %v", i, record["status"])
+		}
+	}
+}
+
+// testBlobTx returns a blob transaction from testSender carrying one blob
+func testBlobTx(t *testing.T, nonce uint64, to common.Address) *types.Transaction {
+	t.Helper()
+
+	tx, err := types.SignNewTx(testKey, types.LatestSignerForChainID(big.NewInt(1)), &types.BlobTx{
+		ChainID:    uint256.NewInt(1),
+		Nonce:      nonce,
+		GasTipCap:  uint256.NewInt(1),
+		GasFeeCap:  uint256.NewInt(100),
+		Gas:        50000,
+		To:         to,
+		Value:      uint256.NewInt(1),
+		BlobFeeCap: uint256.NewInt(10),
+		BlobHashes: []common.Hash{{0x01}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+func TestExecute_BlobReceipts(t *testing.T) {
+	chain, alice, _ := newReceiptChain(t)
+	chain.txs[502] = []*types.Transaction{testBlobTx(t, 3, alice)}
+
+	for _, method := range []string{"TRANSACTIONS", "RECEIPTS"} {
+		t.Run(method, func(t *testing.T) {
+			qe := newTestExecutor(t, chain)
+			query := &queries.Query{
+				Method:    method,
+				Addresses: []common.Address{testSender},
+				FromBlock: big.NewInt(502),
+				ToBlock:   big.NewInt(502),
+				Fields: []queries.SelectItem{
+					{Name: "blob_gas_used", Expr: &queries.ColumnRef{Name: "blob_gas_used"}, Type: queries.TypeInt},
+					{Name: "blob_gas_price", Expr: &queries.ColumnRef{Name: "blob_gas_price"}, Type: queries.TypeInt},
+				},
+			}
+			result, err := qe.Execute(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.Len() != 1 {
+				t.Fatalf("Expected the blob transaction, got %d rows", result.Len())
+			}
+			if used, ok := result.Rows[0][0].(*big.Int); !ok || used.Uint64() != params.BlobTxBlobGasPerBlob {
+				t.Errorf("Expected %d blob gas used, got %v", params.BlobTxBlobGasPerBlob, result.Rows[0][0])
+			}
+			if price, ok := result.Rows[0][1].(*big.Int); !ok || price.Int64() != 7 {
+				t.Errorf("Expected a blob gas price of 7, got %v", result.Rows[0][1])
+			}
+		})
+	}
+}
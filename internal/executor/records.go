@@ -0,0 +1,379 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// balanceRecord builds the BALANCE fields for an account
+func balanceRecord(address common.Address, balance, blockNumber *big.Int) queries.Record {
+	record := queries.Record{
+		"address": address,
+		"balance": balance,
+	}
+	if blockNumber != nil {
+		record["block_number"] = blockNumber
+	}
+	return record
+}
+
+// codeRecord builds the CODE fields for an account
+func codeRecord(address common.Address, code []byte, blockNumber *big.Int) queries.Record {
+	record := queries.Record{
+		"address":     address,
+		"code":        code,
+		"size":        uint64ToBig(uint64(len(code))),
+		"code_hash":   crypto.Keccak256Hash(code),
+		"is_contract": len(code) > 0 && !isDelegation(code),
+	}
+	if blockNumber != nil {
+		record["block_number"] = blockNumber
+	}
+	return record
+}
+
+// isDelegation reports whether code is an EIP-7702 delegation designator, the code
+// an externally owned account carries while it delegates to a contract
+func isDelegation(code []byte) bool {
+	return len(code) == 3+common.AddressLength && bytes.HasPrefix(code, []byte{0xef, 0x01, 0x00})
+}
+
+// nonceRecord builds the NONCE fields for an account
+func nonceRecord(address common.Address, nonce uint64, blockNumber *big.Int) queries.Record {
+	record := queries.Record{
+		"address": address,
+		"nonce":   uint64ToBig(nonce),
+	}
+	if blockNumber != nil {
+		record["block_number"] = blockNumber
+	}
+	return record
+}
+
+// storageRecord builds the STORAGE fields for a slot of an account
+func storageRecord(address common.Address, slot, value common.Hash, blockNumber *big.Int) queries.Record {
+	record := queries.Record{
+		"address": address,
+		"slot":    slot,
+		"value":   value,
+	}
+	if blockNumber != nil {
+		record["block_number"] = blockNumber
+	}
+	return record
+}
+
+// callRecord builds the CALL fields for a call of method on a contract, before its
+// result is decoded
+func callRecord(address common.Address, method *abi.Method, blockNumber *big.Int) queries.Record {
+	record := queries.Record{
+		"address":  address,
+		"function": method.Sig,
+	}
+	if blockNumber != nil {
+		record["block_number"] = blockNumber
+	}
+	return record
+}
+
+// gasEstimateRecord builds the GAS_ESTIMATE fields for a call of method on address
+// from sender, nil for the zero address
+func gasEstimateRecord(address common.Address, method *abi.Method, sender *common.Address, gasLimit uint64, estimate gasEstimate, blockNumber *big.Int) queries.Record {
+	record := queries.Record{
+		"address":   address,
+		"function":  method.Sig,
+		"sender":    common.Address{},
+		"gas_limit": uint64ToBig(gasLimit),
+	}
+	if sender != nil {
+		record["sender"] = *sender
+	}
+	if estimate.reason != "" {
+		record["error"] = estimate.reason
+	} else {
+		record["gas_estimate"] = uint64ToBig(estimate.gas)
+	}
+	if blockNumber != nil {
+		record["block_number"] = blockNumber
+	}
+	return record
+}
+
+// feeRecord builds the FEES fields for block i of history
+func feeRecord(history *feeHistory, i int) queries.Record {
+	record := queries.Record{
+		"block_number": uint64ToBig(uint64(history.OldestBlock) + uint64(i)),
+		"base_fee":     new(big.Int),
+		"gas_used_bps": basisPoints(history.GasUsedRatio[i]),
+	}
+	if i < len(history.BaseFee) && history.BaseFee[i] != nil {
+		record["base_fee"] = history.BaseFee[i].ToInt()
+	}
+	for j, p := range feePercentiles {
+		reward := new(big.Int)
+		if i < len(history.Reward) && j < len(history.Reward[i]) && history.Reward[i][j] != nil {
+			reward = history.Reward[i][j].ToInt()
+		}
+		record[fmt.Sprintf("reward_p%d", int(p))] = reward
+	}
+	if i < len(history.BlobBaseFee) && history.BlobBaseFee[i] != nil {
+		record["blob_base_fee"] = history.BlobBaseFee[i].ToInt()
+	}
+	if i < len(history.BlobGasUsedRatio) {
+		record["blob_gas_used_bps"] = basisPoints(history.BlobGasUsedRatio[i])
+	}
+	return record
+}
+
+// tokenBalanceRecord builds the TOKEN_BALANCE fields for a holder of token. balance is
+// nil when the token did not answer balanceOf.
+func tokenBalanceRecord(address, token common.Address, metadata tokenMetadata, balance, blockNumber *big.Int) queries.Record {
+	record := queries.Record{
+		"address": address,
+		"token":   token,
+	}
+	addTokenMetadata(record, metadata)
+	if balance != nil {
+		record["balance"] = balance
+		if metadata.decimals != nil {
+			record["amount"] = formatUnits(balance, *metadata.decimals)
+		}
+	}
+	if blockNumber != nil {
+		record["block_number"] = blockNumber
+	}
+	return record
+}
+
+// tokenInfoRecord builds the TOKEN_INFO fields for a token
+func tokenInfoRecord(address common.Address, metadata tokenMetadata, supply, blockNumber *big.Int) queries.Record {
+	record := queries.Record{"address": address}
+	addTokenMetadata(record, metadata)
+	if metadata.name != "" {
+		record["name"] = metadata.name
+	}
+	if supply != nil {
+		record["total_supply"] = supply
+	}
+	if blockNumber != nil {
+		record["block_number"] = blockNumber
+	}
+	return record
+}
+
+// traceRecord builds the TRACES fields for a call frame, attributed to the queried
+// address it was called from or to
+func traceRecord(address common.Address, frame *traceFrame) queries.Record {
+	record := queries.Record{
+		"address":       address,
+		"tx_hash":       frame.txHash,
+		"tx_index":      uint64ToBig(frame.txIndex),
+		"trace_address": formatTraceAddress(frame.traceAddress),
+		"depth":         big.NewInt(int64(len(frame.traceAddress))),
+		"type":          frame.kind,
+		"from":          frame.from,
+		"value":         frame.value,
+		"gas":           uint64ToBig(frame.gas),
+		"gas_used":      uint64ToBig(frame.gasUsed),
+		"input":         frame.input,
+		"block_number":  uint64ToBig(frame.blockNumber),
+	}
+	if frame.to != nil {
+		record["to"] = *frame.to
+	}
+	if frame.output != nil {
+		record["output"] = frame.output
+	}
+	if frame.err != "" {
+		record["error"] = frame.err
+	}
+	return record
+}
+
+// stateDiffRecord builds the STATE_DIFF fields for change
+func stateDiffRecord(change *stateChange) queries.Record {
+	record := queries.Record{
+		"address":      change.address,
+		"tx_hash":      change.txHash,
+		"tx_index":     uint64ToBig(change.txIndex),
+		"field":        change.field,
+		"before":       change.before,
+		"after":        change.after,
+		"block_number": uint64ToBig(change.blockNumber),
+	}
+	if change.slot != nil {
+		record["slot"] = *change.slot
+	}
+	if change.delta != nil {
+		record["delta"] = change.delta
+	}
+	return record
+}
+
+// ownerRecord builds the OWNER fields for token id of collection. owner is nil when
+// the collection did not answer ownerOf.
+func ownerRecord(collection common.Address, id *big.Int, owner *common.Address, blockNumber *big.Int) queries.Record {
+	record := queries.Record{
+		"address":  collection,
+		"token_id": id,
+	}
+	if owner != nil {
+		record["owner"] = *owner
+	}
+	if blockNumber != nil {
+		record["block_number"] = blockNumber
+	}
+	return record
+}
+
+// nftRecord builds the NFTS fields for amount tokens id of collection held by wallet
+func nftRecord(wallet, collection common.Address, standard string, id, amount, blockNumber *big.Int) queries.Record {
+	return queries.Record{
+		"address":      wallet,
+		"collection":   collection,
+		"standard":     standard,
+		"token_id":     id,
+		"amount":       amount,
+		"block_number": blockNumber,
+	}
+}
+
+func addTokenMetadata(record queries.Record, metadata tokenMetadata) {
+	if metadata.symbol != "" {
+		record["symbol"] = metadata.symbol
+	}
+	if metadata.decimals != nil {
+		record["decimals"] = uint64ToBig(uint64(*metadata.decimals))
+	}
+}
+
+// logRecord builds the LOGS fields for a log entry
+func logRecord(log types.Log) queries.Record {
+	record := queries.Record{
+		"address":      log.Address,
+		"data":         log.Data,
+		"block_number": uint64ToBig(log.BlockNumber),
+		"block_hash":   log.BlockHash,
+		"tx_hash":      log.TxHash,
+		"tx_index":     uint64ToBig(uint64(log.TxIndex)),
+		"log_index":    uint64ToBig(uint64(log.Index)),
+		"removed":      log.Removed,
+	}
+	for i, topic := range log.Topics {
+		if i > 3 {
+			break
+		}
+		record[topicField(i)] = topic
+	}
+	return record
+}
+
+// transactionRecord builds the TRANSACTIONS fields for a transaction included in block,
+// attributed to the queried address it was sent from or to
+func transactionRecord(address common.Address, tx *types.Transaction, from common.Address, block *types.Block, index int) queries.Record {
+	record := queries.Record{
+		"address":                  address,
+		"hash":                     tx.Hash(),
+		"from":                     from,
+		"value":                    tx.Value(),
+		"gas":                      uint64ToBig(tx.Gas()),
+		"gas_price":                tx.GasPrice(),
+		"max_fee_per_gas":          tx.GasFeeCap(),
+		"max_priority_fee_per_gas": tx.GasTipCap(),
+		"nonce":                    uint64ToBig(tx.Nonce()),
+		"input":                    tx.Data(),
+		"type":                     uint64ToBig(uint64(tx.Type())),
+		"block_number":             block.Number(),
+		"block_timestamp":          blockTime(block.Time()),
+		"block_hash":               block.Hash(),
+		"tx_index":                 uint64ToBig(uint64(index)),
+	}
+	if tx.To() != nil {
+		record["to"] = *tx.To()
+	}
+	return record
+}
+
+// pendingRecord builds the PENDING fields for a transaction of the pool, attributed to
+// address like transactionRecord
+func pendingRecord(address common.Address, ptx *poolTx) queries.Record {
+	tx := ptx.tx
+	record := queries.Record{
+		"address":                  address,
+		"hash":                     tx.Hash(),
+		"from":                     ptx.from,
+		"value":                    tx.Value(),
+		"gas":                      uint64ToBig(tx.Gas()),
+		"gas_price":                tx.GasPrice(),
+		"max_fee_per_gas":          tx.GasFeeCap(),
+		"max_priority_fee_per_gas": tx.GasTipCap(),
+		"nonce":                    uint64ToBig(tx.Nonce()),
+		"input":                    tx.Data(),
+		"type":                     uint64ToBig(uint64(tx.Type())),
+		"pool":                     ptx.pool,
+	}
+	if tx.To() != nil {
+		record["to"] = *tx.To()
+	}
+	return record
+}
+
+// receiptRecord builds the RECEIPTS fields known before the receipt of a transaction
+// included in block is fetched, attributed like transactionRecord
+func receiptRecord(address common.Address, tx *types.Transaction, from common.Address, block *types.Block, index int) queries.Record {
+	record := queries.Record{
+		"address":         address,
+		"tx_hash":         tx.Hash(),
+		"from":            from,
+		"type":            uint64ToBig(uint64(tx.Type())),
+		"block_number":    block.Number(),
+		"block_timestamp": blockTime(block.Time()),
+		"block_hash":      block.Hash(),
+		"tx_index":        uint64ToBig(uint64(index)),
+	}
+	if tx.To() != nil {
+		record["to"] = *tx.To()
+	}
+	return record
+}
+
+// blockRecord builds the BLOCKS fields for a block. Fields introduced by later forks
+// are left out for blocks from before them.
+func blockRecord(block *types.Block) queries.Record {
+	record := queries.Record{
+		"number":      block.Number(),
+		"hash":        block.Hash(),
+		"parent_hash": block.ParentHash(),
+		"timestamp":   blockTime(block.Time()),
+		"miner":       block.Coinbase(),
+		"gas_used":    uint64ToBig(block.GasUsed()),
+		"gas_limit":   uint64ToBig(block.GasLimit()),
+		"tx_count":    uint64ToBig(uint64(len(block.Transactions()))),
+	}
+	if baseFee := block.BaseFee(); baseFee != nil {
+		record["base_fee"] = baseFee
+	}
+	if blobGasUsed := block.BlobGasUsed(); blobGasUsed != nil {
+		record["blob_gas_used"] = uint64ToBig(*blobGasUsed)
+	}
+	if root := block.Header().WithdrawalsHash; root != nil {
+		record["withdrawals_root"] = *root
+	}
+	return record
+}
+
+// topicField returns the field name for the topic at position i
+func topicField(i int) string {
+	return "topic" + string(rune('0'+i))
+}
+
+func uint64ToBig(n uint64) *big.Int {
+	return new(big.Int).SetUint64(n)
+}
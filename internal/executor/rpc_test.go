@@ -0,0 +1,409 @@
+package executor
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// testChainGenesis is the timestamp of block 0 of a testChain; blocks follow every 12 seconds
+const testChainGenesis = 1700000000
+
+// testChain is an in-process stand-in for a node's "eth" namespace
+type testChain struct {
+	head   uint64
+	tagged map[rpc.BlockNumber]uint64 // block numbers reported for safe and finalized
+
+	ens     map[string][]ensEntry     // forward ENS records
+	reverse map[common.Address]string // primary ENS names
+
+	code            map[common.Address][]byte          // code of the accounts that have any
+	txs             map[uint64][]*types.Transaction    // transactions of each block
+	logs            []types.Log                        // logs of every block, in order
+	collections     map[common.Address]*testCollection // NFT contracts answering calls
+	traces          map[uint64][]txTrace               // call trees of the transactions of each block
+	stateDiffs      map[common.Hash]*stateDiff         // state changes of the transactions of txs
+	pending         []*types.Transaction               // executable transactions of the pool
+	queued          []*types.Transaction               // transactions of the pool waiting on an earlier nonce
+	noBlockReceipts bool                               // answer eth_getBlockReceipts as unimplemented
+	noTraceFilter   bool                               // answer trace_filter as unimplemented
+	noTxPool        bool                               // answer txpool_content as unimplemented
+
+	mu                sync.Mutex
+	headerCalls       int
+	balanceCalls      int
+	stateCalls        int
+	blockReceiptCalls int
+	receiptCalls      int
+	chainIDCalls      int
+	logCalls          int
+	traceFilterCalls  int
+	traceBlockCalls   int
+	feeHistoryCalls   int
+	tokenCalls        map[string]int // calls of testToken by function
+}
+
+func (c *testChain) GetBlockByNumber(number rpc.BlockNumber, full bool) (map[string]interface{}, error) {
+	n := uint64(number.Int64())
+	switch number {
+	case rpc.LatestBlockNumber:
+		n = c.head
+	case rpc.PendingBlockNumber:
+		n = c.head + 1
+	case rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		n = c.tagged[number]
+	}
+	if n > c.head+1 {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	c.headerCalls++
+	c.mu.Unlock()
+
+	encoded, err := json.Marshal(c.header(n))
+	if err != nil {
+		return nil, err
+	}
+	var block map[string]interface{}
+	if err := json.Unmarshal(encoded, &block); err != nil {
+		return nil, err
+	}
+	txs := c.txs[n]
+	if txs == nil {
+		txs = types.Transactions{}
+	}
+	block["transactions"] = txs
+	return block, nil
+}
+
+// header returns the header of block n, committing to its transactions
+func (c *testChain) header(n uint64) *types.Header {
+	header := testHeader(n)
+	if txs := c.txs[n]; len(txs) > 0 {
+		header.TxHash = types.DeriveSha(types.Transactions(txs), trie.NewStackTrie(nil))
+	}
+	return header
+}
+
+// testHeader returns the header of block n of a testChain. Blocks carry no
+// transactions; block n used n thousand gas and pays a base fee of n wei.
+func testHeader(n uint64) *types.Header {
+	return &types.Header{
+		Number:      new(big.Int).SetUint64(n),
+		Difficulty:  new(big.Int),
+		Time:        testChainGenesis + 12*n,
+		GasUsed:     1000 * n,
+		GasLimit:    30000000,
+		BaseFee:     new(big.Int).SetUint64(n),
+		TxHash:      types.EmptyTxsHash,
+		UncleHash:   types.EmptyUncleHash,
+		ReceiptHash: types.EmptyReceiptsHash,
+	}
+}
+
+// GetBalance reports the last byte of the address as the balance of every account
+func (c *testChain) GetBalance(address common.Address, block rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	c.mu.Lock()
+	c.balanceCalls++
+	c.mu.Unlock()
+
+	return (*hexutil.Big)(big.NewInt(int64(address[common.AddressLength-1]))), nil
+}
+
+// methodNotFoundError is how a node answers a method it does not implement
+type methodNotFoundError struct{ method string }
+
+func (e methodNotFoundError) Error() string {
+	return "the method " + e.method + " does not exist/is not available"
+}
+
+func (e methodNotFoundError) ErrorCode() int { return -32601 }
+
+func (c *testChain) GetBlockReceipts(block rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	if c.noBlockReceipts {
+		return nil, methodNotFoundError{method: "eth_getBlockReceipts"}
+	}
+
+	c.mu.Lock()
+	c.blockReceiptCalls++
+	c.mu.Unlock()
+
+	hash, _ := block.Hash()
+	for n, txs := range c.txs {
+		if c.header(n).Hash() != hash {
+			continue
+		}
+		receipts := make([]*types.Receipt, len(txs))
+		for i := range txs {
+			receipts[i] = c.receipt(n, i)
+		}
+		return receipts, nil
+	}
+	return nil, nil
+}
+
+func (c *testChain) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	c.receiptCalls++
+	c.mu.Unlock()
+
+	for n, txs := range c.txs {
+		for i, tx := range txs {
+			if tx.Hash() == hash {
+				return c.receipt(n, i), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// receipt returns the receipt of transaction i of block n. Every transaction uses
+// 21000 gas and fails when given less than 30000; deployments also create a contract,
+// and blob transactions pay 7 wei per blob gas.
+func (c *testChain) receipt(n uint64, i int) *types.Receipt {
+	tx := c.txs[n][i]
+	receipt := &types.Receipt{
+		Type:              tx.Type(),
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: 21000 * uint64(i+1),
+		Logs:              []*types.Log{},
+		TxHash:            tx.Hash(),
+		GasUsed:           21000,
+		EffectiveGasPrice: tx.GasFeeCap(),
+		BlockHash:         c.header(n).Hash(),
+		BlockNumber:       new(big.Int).SetUint64(n),
+		TransactionIndex:  uint(i),
+	}
+	if tx.Gas() < 30000 {
+		receipt.Status = types.ReceiptStatusFailed
+	}
+	if tx.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(testSender, tx.Nonce())
+	}
+	if tx.Type() == types.BlobTxType {
+		receipt.BlobGasUsed = tx.BlobGas()
+		receipt.BlobGasPrice = big.NewInt(7)
+	}
+	return receipt
+}
+
+// testKey signs the transactions of a testChain
+var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// testSender is the address of testKey
+var testSender = crypto.PubkeyToAddress(testKey.PublicKey)
+
+// testTx returns a transaction from testSender, a deployment when to is nil
+func testTx(t *testing.T, nonce uint64, to *common.Address, gas uint64) *types.Transaction {
+	t.Helper()
+
+	tx, err := types.SignNewTx(testKey, types.LatestSignerForChainID(big.NewInt(1)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(100),
+		Gas:       gas,
+		To:        to,
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+func (c *testChain) GetCode(address common.Address, block rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	c.mu.Lock()
+	c.stateCalls++
+	c.mu.Unlock()
+
+	return c.code[address], nil
+}
+
+// GetTransactionCount reports the last byte of the address as the nonce of every account
+func (c *testChain) GetTransactionCount(address common.Address, block rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	c.mu.Lock()
+	c.stateCalls++
+	c.mu.Unlock()
+
+	return hexutil.Uint64(address[common.AddressLength-1]), nil
+}
+
+// GetStorageAt reports the slot number plus the last byte of the address as the value
+// of every slot
+func (c *testChain) GetStorageAt(address common.Address, slot string, block rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	c.mu.Lock()
+	c.stateCalls++
+	c.mu.Unlock()
+
+	value := new(big.Int).Add(common.HexToHash(slot).Big(), big.NewInt(int64(address[common.AddressLength-1])))
+	return common.BigToHash(value).Bytes(), nil
+}
+
+// ensEntry is a forward ENS record of a testChain, set from block since onwards
+type ensEntry struct {
+	address common.Address
+	since   uint64
+}
+
+// testResolver is the resolver testChain reports for every ENS node it knows
+var testResolver = common.HexToAddress("0x00000000000000000000000000000000000e0e0e")
+
+// ChainId reports chain 1
+func (c *testChain) ChainId() *hexutil.Big {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chainIDCalls++
+	return (*hexutil.Big)(big.NewInt(1))
+}
+
+// logFilterArgs holds the fields of an eth_getLogs request
+type logFilterArgs struct {
+	FromBlock *hexutil.Big     `json:"fromBlock"`
+	ToBlock   *hexutil.Big     `json:"toBlock"`
+	Addresses []common.Address `json:"address"`
+	Topics    [][]common.Hash  `json:"topics"`
+}
+
+// GetLogs returns the logs matching a filter over numbered blocks
+func (c *testChain) GetLogs(filter logFilterArgs) ([]types.Log, error) {
+	c.mu.Lock()
+	c.logCalls++
+	c.mu.Unlock()
+
+	matches := func(log types.Log) bool {
+		n := new(big.Int).SetUint64(log.BlockNumber)
+		if n.Cmp(filter.FromBlock.ToInt()) < 0 || n.Cmp(filter.ToBlock.ToInt()) > 0 {
+			return false
+		}
+		if len(filter.Addresses) > 0 && !containsAddress(filter.Addresses, log.Address) {
+			return false
+		}
+		if len(filter.Topics) > len(log.Topics) {
+			return false
+		}
+		for i, wanted := range filter.Topics {
+			found := len(wanted) == 0
+			for _, topic := range wanted {
+				found = found || topic == log.Topics[i]
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	logs := []types.Log{}
+	for _, log := range c.logs {
+		if matches(log) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// callArgs holds the fields of an eth_call request that testChain reads
+type callArgs struct {
+	From  *common.Address `json:"from"`
+	To    *common.Address `json:"to"`
+	Gas   *hexutil.Uint64 `json:"gas"`
+	Input hexutil.Bytes   `json:"input"`
+	Data  hexutil.Bytes   `json:"data"`
+}
+
+// Call answers ENS registry and resolver calls and calls of testToken and the test
+// NFT collections; every other address has no code
+func (c *testChain) Call(args callArgs, block rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	n := c.head
+	if number, ok := block.Number(); ok && number >= 0 {
+		n = uint64(number)
+	}
+	input := args.Input
+	if len(input) == 0 {
+		input = args.Data
+	}
+	if args.To == nil || len(input) < 4 {
+		return nil, nil
+	}
+	if *args.To == testToken {
+		return c.tokenCall(input, n)
+	}
+	if collection, ok := c.collections[*args.To]; ok {
+		return collection.call(input)
+	}
+	method, err := ensContract.MethodById(input[:4])
+	if err != nil {
+		return nil, nil
+	}
+	values, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, err
+	}
+	node := common.Hash(values[0].([32]byte))
+
+	switch {
+	case *args.To == ensRegistry && method.Name == "resolver":
+		for name := range c.ens {
+			if namehash(name) == node {
+				return method.Outputs.Pack(testResolver)
+			}
+		}
+		for address := range c.reverse {
+			if reverseNode(address) == node {
+				return method.Outputs.Pack(testResolver)
+			}
+		}
+		return method.Outputs.Pack(common.Address{})
+	case *args.To == testResolver && method.Name == "addr":
+		var address common.Address
+		for name, entries := range c.ens {
+			if namehash(name) != node {
+				continue
+			}
+			for _, entry := range entries {
+				if entry.since <= n {
+					address = entry.address
+				}
+			}
+		}
+		return method.Outputs.Pack(address)
+	case *args.To == testResolver && method.Name == "name":
+		for address, name := range c.reverse {
+			if reverseNode(address) == node {
+				return method.Outputs.Pack(name)
+			}
+		}
+		return method.Outputs.Pack("")
+	}
+	return nil, nil
+}
+
+// newTestExecutor returns an executor talking to chain over an in-process RPC connection
+func newTestExecutor(t *testing.T, chain *testChain) *QueryExecutor {
+	t.Helper()
+
+	server := rpc.NewServer()
+	for _, namespace := range []string{"eth", "debug", "trace", "txpool"} {
+		if err := server.RegisterName(namespace, chain); err != nil {
+			t.Fatalf("Failed to register test chain: %v", err)
+		}
+	}
+	client := rpc.DialInProc(server)
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+
+	return NewQueryExecutor(ethclient.NewClient(client))
+}
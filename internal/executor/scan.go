@@ -0,0 +1,249 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// blockProcessor turns a fetched block into records
+type blockProcessor func(block *types.Block) ([]queries.Record, error)
+
+// scanResult holds the records produced by scanBlocks in block order
+type scanResult struct {
+	records  []queries.Record
+	complete bool // false when the scan stopped early because enough rows matched
+}
+
+// maxScanRecords bounds the number of records a single scan may produce
+const maxScanRecords = 10000
+
+// logChunkSize is the number of blocks requested per eth_getLogs call when a LOGS
+// scan can stop early
+const logChunkSize = 1000
+
+// scanBlocks fetches the blocks in [from, to] with a pool of workers and returns the
+// records produced by process in ascending block order, regardless of which worker
+// finishes first. If stopAfter is positive, scanning stops as soon as that many
+// records in block order satisfy where.
+func (qe *QueryExecutor) scanBlocks(ctx context.Context, from, to *big.Int, where queries.Expr, stopAfter int, process blockProcessor) (*scanResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type blockResult struct {
+		index   int
+		records []queries.Record
+		err     error
+	}
+
+	type blockJob struct {
+		index  int
+		number *big.Int
+	}
+
+	jobChan := make(chan blockJob, qe.maxWorkers)
+	resultChan := make(chan blockResult, qe.maxWorkers)
+	var wg sync.WaitGroup
+
+	// Start workers
+	for i := 0; i < qe.maxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobChan {
+				if ctx.Err() != nil {
+					return
+				}
+
+				res := blockResult{index: job.index}
+				block, err := qe.client.BlockByNumber(ctx, job.number)
+				if err != nil {
+					res.err = fmt.Errorf("failed to get block %s: %w", job.number.String(), err)
+				} else {
+					res.records, res.err = process(block)
+				}
+
+				// The collector stops reading once it has enough rows
+				select {
+				case resultChan <- res:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Send blocks to workers
+	go func() {
+		defer close(jobChan)
+		index := 0
+		for blockNum := new(big.Int).Set(from); blockNum.Cmp(to) <= 0; blockNum = new(big.Int).Add(blockNum, big.NewInt(1)) {
+			select {
+			case <-ctx.Done():
+				return
+			case jobChan <- blockJob{index: index, number: blockNum}:
+			}
+			index++
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	// Collect results, releasing them strictly in block order
+	result := &scanResult{complete: true}
+	pending := make(map[int][]queries.Record)
+	next := 0
+	matched := 0
+
+	for res := range resultChan {
+		if res.err != nil {
+			return nil, res.err
+		}
+		pending[res.index] = res.records
+
+		for {
+			records, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			result.records = append(result.records, records...)
+
+			// Enforce result size limit
+			if len(result.records) > maxScanRecords {
+				return nil, fmt.Errorf("result too large: %d rows (maximum: %d)", len(result.records), maxScanRecords)
+			}
+
+			if stopAfter <= 0 {
+				continue
+			}
+			n, err := countMatches(records, where)
+			if err != nil {
+				return nil, err
+			}
+			matched += n
+			if matched >= stopAfter {
+				result.complete = false
+				return result, nil
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("query cancelled after processing %d blocks: %w", next, ctx.Err())
+	}
+
+	return result, nil
+}
+
+// scanLogs runs a log filter and returns the matching logs as records. With a
+// positive stopAfter the range is requested in chunks of logChunkSize blocks, in
+// order, stopping once that many records satisfy the query's WHERE clause.
+func (qe *QueryExecutor) scanLogs(ctx context.Context, filter ethereum.FilterQuery, query *queries.Query, stopAfter int) (*scanResult, error) {
+	result := &scanResult{complete: true}
+
+	step := new(big.Int).Sub(filter.ToBlock, filter.FromBlock)
+	if stopAfter > 0 {
+		step = big.NewInt(logChunkSize - 1)
+	}
+
+	matched := 0
+	for from := new(big.Int).Set(filter.FromBlock); from.Cmp(filter.ToBlock) <= 0; {
+		to := new(big.Int).Add(from, step)
+		if to.Cmp(filter.ToBlock) > 0 {
+			to.Set(filter.ToBlock)
+		}
+
+		chunk := filter
+		chunk.FromBlock, chunk.ToBlock = from, to
+		logs, err := qe.client.FilterLogs(ctx, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching logs: %w", err)
+		}
+
+		records := make([]queries.Record, len(logs))
+		for i, log := range logs {
+			records[i] = logRecord(log)
+		}
+		result.records = append(result.records, records...)
+
+		// Enforce result size limit
+		if len(result.records) > maxScanRecords {
+			return nil, fmt.Errorf("result too large: %d logs (maximum: %d)", len(result.records), maxScanRecords)
+		}
+
+		from = new(big.Int).Add(to, big.NewInt(1))
+		if stopAfter <= 0 {
+			continue
+		}
+		if queries.References(query.Where, "block_timestamp") {
+			if err := qe.addBlockTimestamps(ctx, records); err != nil {
+				return nil, err
+			}
+		}
+		n, err := countMatches(records, query.Where)
+		if err != nil {
+			return nil, err
+		}
+		matched += n
+		if matched >= stopAfter {
+			result.complete = from.Cmp(filter.ToBlock) > 0
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+// countMatches returns how many records satisfy where
+func countMatches(records []queries.Record, where queries.Expr) (int, error) {
+	n := 0
+	for _, record := range records {
+		ok, err := queries.Matches(where, record)
+		if err != nil {
+			return 0, fmt.Errorf("error evaluating WHERE clause: %w", err)
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// parallel calls fn for every index in [0, n), running at most maxWorkers calls at a
+// time, and returns the first error any of them reports
+func (qe *QueryExecutor) parallel(n int, fn func(i int) error) error {
+	var mu sync.Mutex
+	var firstErr error
+
+	sem := make(chan struct{}, qe.maxWorkers)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(i); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return firstErr
+}
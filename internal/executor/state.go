@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// accountReader builds the records of one account read at block. recordBlock is the
+// block number to report, nil for the pending state.
+type accountReader func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error)
+
+// getAccounts reads every queried account at the query's single block
+func (qe *QueryExecutor) getAccounts(ctx context.Context, query *queries.Query, read accountReader) ([]queries.Record, error) {
+	blockNumber := query.FromBlock
+
+	// The pending state is addressed by its tag and has no block number to report
+	recordBlock := blockNumber
+	if queries.IsBlockTag(blockNumber) {
+		recordBlock = nil
+	}
+
+	var records []queries.Record
+	for _, address := range queryAddresses(query) {
+		accountRecords, err := read(address, blockNumber, recordBlock)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, accountRecords...)
+	}
+
+	return qe.completeRecords(ctx, records, query)
+}
+
+// stateAt reads a piece of account state at blockNumber through fetch. Values read at
+// numbered blocks are cached under kind, params and the block; the latest (nil) and
+// pending states change between calls, so they are never cached.
+func (qe *QueryExecutor) stateAt(kind string, params []interface{}, blockNumber *big.Int, fetch func() (interface{}, error)) (interface{}, error) {
+	if blockNumber == nil || queries.IsBlockTag(blockNumber) {
+		return fetch()
+	}
+
+	cacheKey := cache.GenerateKey(kind, append(params, blockNumber)...)
+	if cached, found := qe.cache.Get(cacheKey); found {
+		logger.Debug("cache hit", "key", cacheKey)
+		return cached, nil
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	qe.cache.Set(cacheKey, value, 0)
+	logger.Debug("cached "+kind, "key", cacheKey)
+	return value, nil
+}
+
+func (qe *QueryExecutor) getCode(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	return qe.getAccounts(ctx, query, func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error) {
+		code, err := qe.stateAt("code", []interface{}{address.Hex()}, block, func() (interface{}, error) {
+			return qe.client.CodeAt(ctx, address, block)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error fetching code of %s: %w", address.Hex(), err)
+		}
+		return []queries.Record{codeRecord(address, code.([]byte), recordBlock)}, nil
+	})
+}
+
+func (qe *QueryExecutor) getNonce(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	return qe.getAccounts(ctx, query, func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error) {
+		nonce, err := qe.stateAt("nonce", []interface{}{address.Hex()}, block, func() (interface{}, error) {
+			return qe.client.NonceAt(ctx, address, block)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error fetching nonce of %s: %w", address.Hex(), err)
+		}
+		return []queries.Record{nonceRecord(address, nonce.(uint64), recordBlock)}, nil
+	})
+}
+
+// getStorage reads every queried slot of every queried account, one row each
+func (qe *QueryExecutor) getStorage(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	return qe.getAccounts(ctx, query, func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error) {
+		var records []queries.Record
+		for _, slot := range query.Slots {
+			value, err := qe.stateAt("storage", []interface{}{address.Hex(), slot.Hex()}, block, func() (interface{}, error) {
+				return qe.client.StorageAt(ctx, address, slot, block)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error fetching storage slot %s of %s: %w", slot.Hex(), address.Hex(), err)
+			}
+			records = append(records, storageRecord(address, slot, common.BytesToHash(value.([]byte)), recordBlock))
+		}
+		return records, nil
+	})
+}
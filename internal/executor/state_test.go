@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestExecute_AccountState(t *testing.T) {
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000c0d")
+	delegated := common.HexToAddress("0x00000000000000000000000000000000000000d7")
+	account := common.HexToAddress("0x0000000000000000000000000000000000000007")
+	code := []byte{0x60, 0x80, 0x60, 0x40, 0x52}
+	delegation := append([]byte{0xef, 0x01, 0x00}, contract.Bytes()...)
+
+	tests := []struct {
+		name     string
+		query    *queries.Query
+		expected []queries.Record
+	}{
+		{
+			name: "Code",
+			query: &queries.Query{
+				Method:    "CODE",
+				Addresses: []common.Address{contract, delegated, account},
+			},
+			expected: []queries.Record{
+				{"address": contract, "code": code, "size": big.NewInt(5), "code_hash": crypto.Keccak256Hash(code), "is_contract": true},
+				{"address": delegated, "code": delegation, "size": big.NewInt(23), "code_hash": crypto.Keccak256Hash(delegation), "is_contract": false},
+				{"address": account, "code": []byte{}, "size": big.NewInt(0), "code_hash": crypto.Keccak256Hash(nil), "is_contract": false},
+			},
+		},
+		{
+			name: "Nonce",
+			query: &queries.Query{
+				Method:    "NONCE",
+				Addresses: []common.Address{account},
+			},
+			expected: []queries.Record{
+				{"address": account, "nonce": big.NewInt(7)},
+			},
+		},
+		{
+			name: "Storage",
+			query: &queries.Query{
+				Method:    "STORAGE",
+				Addresses: []common.Address{account},
+				Slots:     []common.Hash{common.BigToHash(big.NewInt(0)), common.BigToHash(big.NewInt(5))},
+			},
+			expected: []queries.Record{
+				{"address": account, "slot": common.BigToHash(big.NewInt(0)), "value": common.BigToHash(big.NewInt(7))},
+				{"address": account, "slot": common.BigToHash(big.NewInt(5)), "value": common.BigToHash(big.NewInt(12))},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &testChain{head: 1000, code: map[common.Address][]byte{contract: code, delegated: delegation}}
+			qe := newTestExecutor(t, chain)
+			memory := cache.NewInMemoryCache(100, time.Minute, time.Minute)
+			defer memory.Stop()
+			qe.SetCache(memory)
+
+			schema := queries.Schemas[tt.query.Method].Default().Names()
+			for _, block := range []int64{900, 900, rpc.PendingBlockNumber.Int64()} {
+				query := *tt.query
+				query.FromBlock, query.ToBlock = big.NewInt(block), big.NewInt(block)
+				result, err := qe.Execute(context.Background(), &query)
+				if err != nil {
+					t.Fatalf("Block %d: expected no error, got: %v", block, err)
+				}
+				if result.Len() != len(tt.expected) {
+					t.Fatalf("Block %d: expected %d rows, got %d", block, len(tt.expected), result.Len())
+				}
+				for i, expected := range tt.expected {
+					for j, name := range schema {
+						want, ok := expected[name]
+						if !ok {
+							continue
+						}
+						got := result.Rows[i][j]
+						if c, err := queries.CompareValues(got, want); err != nil || c != 0 {
+							t.Errorf("Block %d, row %d: expected %s %v, got %v", block, i, name, want, got)
+						}
+					}
+				}
+			}
+
+			// The repeated read at block 900 is served from the cache; the pending one is not
+			if reads := 2 * len(tt.expected); chain.stateCalls != reads {
+				t.Errorf("Expected %d state requests, got %d", reads, chain.stateCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// stateDiffTracer configures debug_traceTransaction and debug_traceBlockByNumber to
+// report the state each transaction changed instead of its calls
+var stateDiffTracer = map[string]interface{}{
+	"tracer":       "prestateTracer",
+	"tracerConfig": map[string]interface{}{"diffMode": true},
+}
+
+// accountState is an account as reported by the prestateTracer. Fields left out are
+// zero before a transaction, and unchanged after it.
+type accountState struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   *uint64                     `json:"nonce,omitempty"`
+	Code    *hexutil.Bytes              `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// stateDiff is the prestateTracer's diff of one transaction: the accounts it changed
+// as they were before it ran in Pre, and their changed fields in Post. Accounts left
+// out of Post were destroyed, and storage slots left out of either side are zero.
+type stateDiff struct {
+	Pre  map[common.Address]*accountState `json:"pre"`
+	Post map[common.Address]*accountState `json:"post"`
+}
+
+// txStateDiff is the diff of one transaction of a debug_traceBlockByNumber result
+type txStateDiff struct {
+	TxHash common.Hash `json:"txHash"`
+	Result *stateDiff  `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// stateChange is one field of an account that a transaction changed
+type stateChange struct {
+	txHash      common.Hash
+	txIndex     uint64
+	blockNumber uint64
+	address     common.Address
+	field       string
+	slot        *common.Hash
+	before      string
+	after       string
+	delta       *big.Int
+}
+
+// getStateDiffs returns the state changes of the query's transactions, or of every
+// transaction of its blocks, made to the queried accounts or to every account when
+// the query names none
+func (qe *QueryExecutor) getStateDiffs(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	var addresses []common.Address
+	if !query.AnyAddress {
+		if addresses = queryAddresses(query); len(addresses) == 0 {
+			return nil, nil
+		}
+	}
+
+	var cacheKey string
+	if len(query.TxHashes) > 0 {
+		cacheKey = cache.GenerateKey("state_diff", query.TxHashes, addresses)
+	} else {
+		blockRange := new(big.Int).Sub(query.ToBlock, query.FromBlock)
+		if blockRange.Cmp(big.NewInt(maxTraceRange)) > 0 {
+			return nil, fmt.Errorf("block range too large for state_diff query: %d blocks (maximum: %d)", blockRange.Int64(), maxTraceRange)
+		}
+		cacheKey = cache.GenerateKey("state_diff", query.FromBlock, query.ToBlock, addresses)
+	}
+	if cached, found := qe.cache.Get(cacheKey); found {
+		logger.Debug("cache hit", "key", cacheKey)
+		if records, ok := cached.([]queries.Record); ok {
+			return qe.completeRecords(ctx, records, query)
+		}
+	}
+
+	var changes []*stateChange
+	var err error
+	if len(query.TxHashes) > 0 {
+		changes, err = qe.traceTxStateDiffs(ctx, query.TxHashes)
+	} else {
+		changes, err = qe.traceBlockStateDiffs(ctx, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []queries.Record
+	for _, change := range changes {
+		if query.AnyAddress || containsAddress(addresses, change.address) {
+			records = append(records, stateDiffRecord(change))
+		}
+		if len(records) > maxScanRecords {
+			return nil, fmt.Errorf("result too large: %d state changes (maximum: %d)", len(records), maxScanRecords)
+		}
+	}
+
+	qe.cache.Set(cacheKey, records, 0)
+	logger.Debug("cached state diffs", "key", cacheKey, "count", len(records))
+	return qe.completeRecords(ctx, records, query)
+}
+
+// traceTxStateDiffs traces each transaction of hashes, locating it through its receipt
+func (qe *QueryExecutor) traceTxStateDiffs(ctx context.Context, hashes []common.Hash) ([]*stateChange, error) {
+	results := make([][]*stateChange, len(hashes))
+	err := qe.parallel(len(hashes), func(i int) error {
+		receipt, err := qe.client.TransactionReceipt(ctx, hashes[i])
+		if err != nil {
+			return fmt.Errorf("error fetching receipt for transaction %s: %w", hashes[i].Hex(), err)
+		}
+		var diff stateDiff
+		if err := qe.client.Client().CallContext(ctx, &diff, "debug_traceTransaction", hashes[i], stateDiffTracer); err != nil {
+			return fmt.Errorf("error tracing transaction %s: %w", hashes[i].Hex(), err)
+		}
+		results[i] = diffChanges(&diff, &stateChange{
+			txHash:      hashes[i],
+			txIndex:     uint64(receipt.TransactionIndex),
+			blockNumber: receipt.BlockNumber.Uint64(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*stateChange
+	for _, result := range results {
+		changes = append(changes, result...)
+	}
+	return changes, nil
+}
+
+// traceBlockStateDiffs traces every block of the query's range
+func (qe *QueryExecutor) traceBlockStateDiffs(ctx context.Context, query *queries.Query) ([]*stateChange, error) {
+	from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+	if to < from {
+		return nil, nil
+	}
+	blocks := make([][]txStateDiff, to-from+1)
+	err := qe.parallel(len(blocks), func(i int) error {
+		number := from + uint64(i)
+		err := qe.client.Client().CallContext(ctx, &blocks[i], "debug_traceBlockByNumber", hexutil.EncodeUint64(number), stateDiffTracer)
+		if err != nil {
+			return fmt.Errorf("error tracing block %d: %w", number, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*stateChange
+	for i, block := range blocks {
+		for index, trace := range block {
+			if trace.Result == nil {
+				return nil, fmt.Errorf("error tracing transaction %s: %s", trace.TxHash.Hex(), trace.Error)
+			}
+			changes = append(changes, diffChanges(trace.Result, &stateChange{
+				txHash:      trace.TxHash,
+				txIndex:     uint64(index),
+				blockNumber: from + uint64(i),
+			})...)
+		}
+	}
+	return changes, nil
+}
+
+// diffChanges lists the fields a transaction changed, by account and then in the
+// order balance, nonce, code and storage by slot. base holds the fields of the
+// transaction.
+func diffChanges(diff *stateDiff, base *stateChange) []*stateChange {
+	accounts := make([]common.Address, 0, len(diff.Pre)+len(diff.Post))
+	for address := range diff.Pre {
+		accounts = append(accounts, address)
+	}
+	for address := range diff.Post {
+		if _, ok := diff.Pre[address]; !ok {
+			accounts = append(accounts, address)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return bytes.Compare(accounts[i][:], accounts[j][:]) < 0 })
+
+	var changes []*stateChange
+	add := func(address common.Address, field string, slot *common.Hash, before, after string, delta *big.Int) {
+		change := *base
+		change.address, change.field, change.slot = address, field, slot
+		change.before, change.after, change.delta = before, after, delta
+		changes = append(changes, &change)
+	}
+
+	for _, address := range accounts {
+		pre, post := diff.Pre[address], diff.Post[address]
+		destroyed := post == nil
+		if pre == nil {
+			pre = &accountState{}
+		}
+		if post == nil {
+			post = &accountState{}
+		}
+
+		before, after := new(big.Int), new(big.Int)
+		if pre.Balance != nil {
+			before = pre.Balance.ToInt()
+		}
+		if post.Balance != nil {
+			after = post.Balance.ToInt()
+		}
+		if (post.Balance != nil || destroyed) && before.Cmp(after) != 0 {
+			add(address, "balance", nil, before.String(), after.String(), new(big.Int).Sub(after, before))
+		}
+
+		var nonceBefore, nonceAfter uint64
+		if pre.Nonce != nil {
+			nonceBefore = *pre.Nonce
+		}
+		if post.Nonce != nil {
+			nonceAfter = *post.Nonce
+		}
+		if (post.Nonce != nil || destroyed) && nonceBefore != nonceAfter {
+			delta := new(big.Int).Sub(uint64ToBig(nonceAfter), uint64ToBig(nonceBefore))
+			add(address, "nonce", nil, uint64ToBig(nonceBefore).String(), uint64ToBig(nonceAfter).String(), delta)
+		}
+
+		var codeBefore, codeAfter []byte
+		if pre.Code != nil {
+			codeBefore = *pre.Code
+		}
+		if post.Code != nil {
+			codeAfter = *post.Code
+		}
+		if (post.Code != nil || destroyed) && !bytes.Equal(codeBefore, codeAfter) {
+			add(address, "code", nil, hexutil.Encode(codeBefore), hexutil.Encode(codeAfter), nil)
+		}
+
+		slots := make([]common.Hash, 0, len(pre.Storage)+len(post.Storage))
+		for slot := range pre.Storage {
+			slots = append(slots, slot)
+		}
+		for slot := range post.Storage {
+			if _, ok := pre.Storage[slot]; !ok {
+				slots = append(slots, slot)
+			}
+		}
+		sort.Slice(slots, func(i, j int) bool { return bytes.Compare(slots[i][:], slots[j][:]) < 0 })
+		for _, slot := range slots {
+			if pre.Storage[slot] != post.Storage[slot] {
+				add(address, "storage", &slot, pre.Storage[slot].Hex(), post.Storage[slot].Hex(), nil)
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TraceTransaction answers debug_traceTransaction with the state diff of a transaction
+func (c *testChain) TraceTransaction(hash common.Hash, config map[string]interface{}) (*stateDiff, error) {
+	return c.stateDiff(hash), nil
+}
+
+// stateDiff returns the state changes of a transaction, which are none unless set
+func (c *testChain) stateDiff(hash common.Hash) *stateDiff {
+	if diff, ok := c.stateDiffs[hash]; ok {
+		return diff
+	}
+	return &stateDiff{}
+}
+
+func TestExecute_StateDiff(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	destroyed := common.HexToAddress("0x00000000000000000000000000000000000000de")
+	send := testTx(t, 0, &contract, 50000)
+	destroy := testTx(t, 1, &destroyed, 50000)
+
+	balance := func(wei int64) *hexutil.Big { return (*hexutil.Big)(big.NewInt(wei)) }
+	nonce := func(n uint64) *uint64 { return &n }
+	code := hexutil.Bytes{0x60, 0x00}
+	slot := func(n int64) common.Hash { return common.BigToHash(big.NewInt(n)) }
+
+	chain := &testChain{
+		head: 1000,
+		txs:  map[uint64][]*types.Transaction{10: {send}, 11: {destroy}},
+		stateDiffs: map[common.Hash]*stateDiff{
+			// Slot 1 changes, slot 2 is set from zero and slot 3 is cleared
+			send.Hash(): {
+				Pre: map[common.Address]*accountState{
+					testSender: {Balance: balance(100)},
+					contract:   {Balance: balance(0), Storage: map[common.Hash]common.Hash{slot(1): slot(5), slot(3): slot(9)}},
+				},
+				Post: map[common.Address]*accountState{
+					testSender: {Balance: balance(99), Nonce: nonce(1)},
+					contract:   {Balance: balance(1), Storage: map[common.Hash]common.Hash{slot(1): slot(7), slot(2): slot(1)}},
+				},
+			},
+			destroy.Hash(): {
+				Pre: map[common.Address]*accountState{
+					testSender: {Balance: balance(99), Nonce: nonce(1)},
+					destroyed:  {Balance: balance(3), Code: &code},
+				},
+				Post: map[common.Address]*accountState{
+					testSender: {Balance: balance(101), Nonce: nonce(2)},
+				},
+			},
+		},
+	}
+
+	type change struct {
+		address common.Address
+		field   string
+		slot    *common.Hash
+		before  string
+		after   string
+		delta   int64 // ignored for code and storage
+	}
+	slotOf := func(n int64) *common.Hash { s := slot(n); return &s }
+	tests := []struct {
+		name      string
+		txHashes  []common.Hash
+		addresses []common.Address
+		expected  []change
+	}{
+		{
+			name:     "Transaction",
+			txHashes: []common.Hash{send.Hash()},
+			expected: []change{
+				{address: contract, field: "balance", before: "0", after: "1", delta: 1},
+				{address: contract, field: "storage", slot: slotOf(1), before: slot(5).Hex(), after: slot(7).Hex()},
+				{address: contract, field: "storage", slot: slotOf(2), before: slot(0).Hex(), after: slot(1).Hex()},
+				{address: contract, field: "storage", slot: slotOf(3), before: slot(9).Hex(), after: slot(0).Hex()},
+				{address: testSender, field: "balance", before: "100", after: "99", delta: -1},
+				{address: testSender, field: "nonce", before: "0", after: "1", delta: 1},
+			},
+		},
+		{
+			name:      "Blocks filtered to an account",
+			addresses: []common.Address{destroyed},
+			expected: []change{
+				{address: destroyed, field: "balance", before: "3", after: "0", delta: -3},
+				{address: destroyed, field: "code", before: "0x6000", after: "0x"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qe := newTestExecutor(t, chain)
+			query := &queries.Query{
+				Method:     "STATE_DIFF",
+				Addresses:  tt.addresses,
+				AnyAddress: len(tt.addresses) == 0,
+				TxHashes:   tt.txHashes,
+			}
+			if len(tt.txHashes) == 0 {
+				query.FromBlock, query.ToBlock = big.NewInt(10), big.NewInt(11)
+			}
+			result, err := qe.Execute(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.Len() != len(tt.expected) {
+				t.Fatalf("Expected %d rows, got %d: %v", len(tt.expected), result.Len(), result.Rows)
+			}
+
+			schema := queries.Schemas["STATE_DIFF"].Default().Names()
+			for i, expected := range tt.expected {
+				row := make(map[string]interface{})
+				for j, name := range schema {
+					row[name] = result.Rows[i][j]
+				}
+				if row["address"] != expected.address || row["field"] != expected.field {
+					t.Errorf("Row %d: expected %s of %s, got %v of %v", i, expected.field, expected.address.Hex(), row["field"], row["address"])
+				}
+				if row["before"] != expected.before || row["after"] != expected.after {
+					t.Errorf("Row %d: expected %s to %s, got %v to %v", i, expected.before, expected.after, row["before"], row["after"])
+				}
+				if expected.slot == nil && row["slot"] != nil {
+					t.Errorf("Row %d: expected no slot, got %v", i, row["slot"])
+				}
+				if expected.slot != nil && row["slot"] != *expected.slot {
+					t.Errorf("Row %d: expected slot %s, got %v", i, expected.slot.Hex(), row["slot"])
+				}
+				switch delta, ok := row["delta"].(*big.Int); {
+				case expected.field == "balance" || expected.field == "nonce":
+					if !ok || delta.Int64() != expected.delta {
+						t.Errorf("Row %d: expected delta %d, got %v", i, expected.delta, row["delta"])
+					}
+				case row["delta"] != nil:
+					t.Errorf("Row %d: expected no delta, got %v", i, row["delta"])
+				}
+			}
+		})
+	}
+}
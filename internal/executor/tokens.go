@@ -0,0 +1,190 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// erc20ABI covers the ERC-20 functions read by TOKEN_BALANCE and TOKEN_INFO
+const erc20ABI = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+var erc20Contract = mustParseABI(erc20ABI)
+
+// tokenMetadata holds the properties of a token that never change. Name and symbol
+// are "" and decimals nil for tokens that do not implement them.
+type tokenMetadata struct {
+	name     string
+	symbol   string
+	decimals *uint8
+}
+
+// chain returns the ID of the chain the executor reads, asking the node the first time
+// unless SetChainID gave it
+func (qe *QueryExecutor) chain(ctx context.Context) (*big.Int, error) {
+	qe.mu.Lock()
+	chainID := qe.chainID
+	qe.mu.Unlock()
+	if chainID != nil {
+		return chainID, nil
+	}
+
+	chainID, err := qe.client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching chain ID: %w", err)
+	}
+	qe.mu.Lock()
+	qe.chainID = chainID
+	qe.mu.Unlock()
+	return chainID, nil
+}
+
+// tokenMetadata returns the name, symbol and decimals of token. They are read once
+// per token and chain, at the latest block, and kept for the life of the executor.
+func (qe *QueryExecutor) tokenMetadata(ctx context.Context, token common.Address) (tokenMetadata, error) {
+	chainID, err := qe.chain(ctx)
+	if err != nil {
+		return tokenMetadata{}, err
+	}
+	key := cache.GenerateKey("token", chainID, token.Hex())
+	qe.mu.Lock()
+	metadata, found := qe.tokens[key]
+	qe.mu.Unlock()
+	if found {
+		return metadata, nil
+	}
+
+	for _, method := range []string{"name", "symbol", "decimals"} {
+		value, err := qe.callToken(ctx, token, method, nil)
+		if err != nil {
+			return tokenMetadata{}, err
+		}
+		switch v := value.(type) {
+		case string:
+			if method == "name" {
+				metadata.name = v
+			} else {
+				metadata.symbol = v
+			}
+		case uint8:
+			metadata.decimals = &v
+		}
+	}
+
+	qe.mu.Lock()
+	qe.tokens[key] = metadata
+	qe.mu.Unlock()
+	logger.Debug("read token metadata", "token", token.Hex(), "symbol", metadata.symbol, "chain_id", chainID)
+	return metadata, nil
+}
+
+// callToken calls an ERC-20 function on token at block and returns its result, or nil
+// when the token reverts or answers with data that does not decode, as contracts that
+// are not tokens do
+func (qe *QueryExecutor) callToken(ctx context.Context, token common.Address, method string, block *big.Int, args ...interface{}) (interface{}, error) {
+	data, err := erc20Contract.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	out, err := qe.callView(ctx, token, method, data, block)
+	if err != nil || out == nil {
+		return nil, err
+	}
+	values, err := erc20Contract.Unpack(method, out)
+	if err == nil && len(values) == 1 {
+		return values[0], nil
+	}
+	// Some early tokens, such as MKR, return their name and symbol as bytes32
+	if (method == "name" || method == "symbol") && len(out) == 32 {
+		return string(bytes.TrimRight(out, "\x00")), nil
+	}
+	return nil, nil
+}
+
+// callView sends a read-only call of method to contract at block and returns its
+// output, or nil when the contract reverts
+func (qe *QueryExecutor) callView(ctx context.Context, contract common.Address, method string, data []byte, block *big.Int) ([]byte, error) {
+	out, err := qe.client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, block)
+	if err != nil {
+		if _, reverted := revertReason(err); reverted {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error calling %s on %s: %w", method, contract.Hex(), err)
+	}
+	if out == nil {
+		out = []byte{}
+	}
+	return out, nil
+}
+
+// getTokenBalances reads the balance of every queried token for every queried holder,
+// one row each
+func (qe *QueryExecutor) getTokenBalances(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	return qe.getAccounts(ctx, query, func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error) {
+		var records []queries.Record
+		for _, token := range query.Tokens {
+			metadata, err := qe.tokenMetadata(ctx, token)
+			if err != nil {
+				return nil, err
+			}
+			value, err := qe.stateAt("token_balance", []interface{}{token.Hex(), address.Hex()}, block, func() (interface{}, error) {
+				return qe.callToken(ctx, token, "balanceOf", block, address)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error fetching %s balance of %s: %w", token.Hex(), address.Hex(), err)
+			}
+			balance, _ := value.(*big.Int)
+			records = append(records, tokenBalanceRecord(address, token, metadata, balance, recordBlock))
+		}
+		return records, nil
+	})
+}
+
+// getTokenInfo reads the metadata and total supply of every queried token
+func (qe *QueryExecutor) getTokenInfo(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	return qe.getAccounts(ctx, query, func(address common.Address, block, recordBlock *big.Int) ([]queries.Record, error) {
+		metadata, err := qe.tokenMetadata(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		value, err := qe.stateAt("total_supply", []interface{}{address.Hex()}, block, func() (interface{}, error) {
+			return qe.callToken(ctx, address, "totalSupply", block)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error fetching total supply of %s: %w", address.Hex(), err)
+		}
+		supply, _ := value.(*big.Int)
+		return []queries.Record{tokenInfoRecord(address, metadata, supply, recordBlock)}, nil
+	})
+}
+
+// formatUnits renders an amount in a token's smallest unit as a decimal number of
+// whole tokens, as in "1.5" for 1500000 with 6 decimals
+func formatUnits(amount *big.Int, decimals uint8) string {
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-int(decimals)], strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+	if amount.Sign() < 0 {
+		whole = "-" + whole
+	}
+	if fraction == "" {
+		return whole
+	}
+	return whole + "." + fraction
+}
@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestExecute_Tokens(t *testing.T) {
+	holder := common.HexToAddress("0x0000000000000000000000000000000000000003")
+	notToken := common.HexToAddress("0x0000000000000000000000000000000000000e77")
+
+	tests := []struct {
+		name     string
+		query    *queries.Query
+		expected []queries.Record
+	}{
+		{
+			name: "Balances",
+			query: &queries.Query{
+				Method:    "TOKEN_BALANCE",
+				Addresses: []common.Address{holder},
+				Tokens:    []common.Address{testToken, notToken},
+			},
+			expected: []queries.Record{
+				{"address": holder, "token": testToken, "symbol": "TT", "decimals": big.NewInt(6), "balance": big.NewInt(2700), "amount": "0.0027", "block_number": big.NewInt(900)},
+				{"address": holder, "token": notToken, "block_number": big.NewInt(900)},
+			},
+		},
+		{
+			name: "Info",
+			query: &queries.Query{
+				Method:    "TOKEN_INFO",
+				Addresses: []common.Address{testToken},
+			},
+			expected: []queries.Record{
+				{"address": testToken, "name": "Test Token", "symbol": "TT", "decimals": big.NewInt(6), "total_supply": big.NewInt(900e6), "block_number": big.NewInt(900)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &testChain{head: 1000}
+			qe := newTestExecutor(t, chain)
+
+			schema := queries.Schemas[tt.query.Method].Default().Names()
+			for run := 0; run < 2; run++ {
+				query := *tt.query
+				query.FromBlock, query.ToBlock = big.NewInt(900), big.NewInt(900)
+				result, err := qe.Execute(context.Background(), &query)
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				if result.Len() != len(tt.expected) {
+					t.Fatalf("Expected %d rows, got %d", len(tt.expected), result.Len())
+				}
+				for i, expected := range tt.expected {
+					for j, name := range schema {
+						if name == "block_timestamp" {
+							continue
+						}
+						got, want := result.Rows[i][j], expected[name]
+						if got == nil || want == nil {
+							if got != want {
+								t.Errorf("Row %d: expected %s %v, got %v", i, name, want, got)
+							}
+							continue
+						}
+						if c, err := queries.CompareValues(got, want); err != nil || c != 0 {
+							t.Errorf("Row %d: expected %s %v, got %v", i, name, want, got)
+						}
+					}
+				}
+			}
+
+			// Metadata is read once per token, under the chain ID asked for once
+			if chain.chainIDCalls != 1 {
+				t.Errorf("Expected 1 chain ID request, got %d", chain.chainIDCalls)
+			}
+			for _, method := range []string{"name", "symbol", "decimals"} {
+				if chain.tokenCalls[method] != 1 {
+					t.Errorf("Expected 1 call of %s, got %d", method, chain.tokenCalls[method])
+				}
+			}
+		})
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		amount   *big.Int
+		decimals uint8
+		expected string
+	}{
+		{amount: big.NewInt(1500000), decimals: 6, expected: "1.5"},
+		{amount: big.NewInt(2700), decimals: 6, expected: "0.0027"},
+		{amount: big.NewInt(42), decimals: 0, expected: "42"},
+		{amount: big.NewInt(0), decimals: 18, expected: "0"},
+		{amount: big.NewInt(3e18), decimals: 18, expected: "3"},
+		{amount: big.NewInt(-5), decimals: 1, expected: "-0.5"},
+	}
+
+	for _, tt := range tests {
+		if got := formatUnits(tt.amount, tt.decimals); got != tt.expected {
+			t.Errorf("formatUnits(%s, %d): expected %s, got %s", tt.amount, tt.decimals, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// maxTraceRange bounds the blocks a TRACES or INTERNAL_TRANSFERS query covers, since
+// nodes without trace_filter re-execute every block of the range
+const maxTraceRange = 1000
+
+// callFrame is a call as reported by the callTracer of debug_traceBlockByNumber, with
+// the calls it made nested in Calls
+type callFrame struct {
+	Type         string          `json:"type"`
+	From         common.Address  `json:"from"`
+	To           *common.Address `json:"to,omitempty"`
+	Value        *hexutil.Big    `json:"value,omitempty"`
+	Gas          hexutil.Uint64  `json:"gas"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Input        hexutil.Bytes   `json:"input"`
+	Output       hexutil.Bytes   `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	Calls        []callFrame     `json:"calls,omitempty"`
+}
+
+// txTrace is the call tree of one transaction of a debug_traceBlockByNumber result
+type txTrace struct {
+	TxHash common.Hash `json:"txHash"`
+	Result *callFrame  `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// flatTrace is a call frame as reported by trace_filter and trace_transaction, which
+// place it in its transaction's call tree through TraceAddress
+type flatTrace struct {
+	Type   string `json:"type"` // call, create or suicide; rewards have no transaction
+	Action struct {
+		CallType       string          `json:"callType,omitempty"`
+		CreationMethod string          `json:"creationMethod,omitempty"` // create or create2, where the node tells them apart
+		From           *common.Address `json:"from,omitempty"`
+		To             *common.Address `json:"to,omitempty"`
+		Value          *hexutil.Big    `json:"value,omitempty"`
+		Gas            hexutil.Uint64  `json:"gas"`
+		Input          hexutil.Bytes   `json:"input,omitempty"`
+		Init           hexutil.Bytes   `json:"init,omitempty"`
+		Address        *common.Address `json:"address,omitempty"` // the self-destructed contract
+		RefundAddress  *common.Address `json:"refundAddress,omitempty"`
+		Balance        *hexutil.Big    `json:"balance,omitempty"`
+	} `json:"action"`
+	Result *struct {
+		GasUsed hexutil.Uint64  `json:"gasUsed"`
+		Output  hexutil.Bytes   `json:"output,omitempty"`
+		Address *common.Address `json:"address,omitempty"` // the created contract
+	} `json:"result,omitempty"`
+	Error               string       `json:"error,omitempty"`
+	TraceAddress        []int        `json:"traceAddress"`
+	TransactionHash     *common.Hash `json:"transactionHash"`
+	TransactionPosition *uint64      `json:"transactionPosition"`
+	BlockNumber         uint64       `json:"blockNumber"`
+}
+
+// traceFrame is one call frame of a transaction, whichever API reported it
+type traceFrame struct {
+	txHash       common.Hash
+	txIndex      uint64
+	blockNumber  uint64
+	traceAddress []int // indexes of the frame and its ancestors among their siblings
+	kind         string
+	from         common.Address
+	to           *common.Address
+	value        *big.Int
+	gas          uint64
+	gasUsed      uint64
+	input        []byte
+	output       []byte
+	err          string
+}
+
+// movesValue reports whether the frame sent ether, which calls that run another
+// contract's code in the caller's context never do
+func (f *traceFrame) movesValue() bool {
+	return f.value != nil && f.value.Sign() > 0 && f.kind != "DELEGATECALL" && f.kind != "STATICCALL"
+}
+
+// within reports whether the frame is other or was called, directly or not, by it
+func (f *traceFrame) within(other *traceFrame) bool {
+	if f.txHash != other.txHash || len(f.traceAddress) < len(other.traceAddress) {
+		return false
+	}
+	for i, index := range other.traceAddress {
+		if f.traceAddress[i] != index {
+			return false
+		}
+	}
+	return true
+}
+
+// getTraces returns the call frames of the query's blocks that the queried addresses
+// called or were called by, one row per address. INTERNAL_TRANSFERS keeps the frames
+// that moved ether below the top-level call and were not reverted.
+func (qe *QueryExecutor) getTraces(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	blockRange := new(big.Int).Sub(query.ToBlock, query.FromBlock)
+	if blockRange.Cmp(big.NewInt(maxTraceRange)) > 0 {
+		return nil, fmt.Errorf("block range too large for %s query: %d blocks (maximum: %d)", strings.ToLower(query.Method), blockRange.Int64(), maxTraceRange)
+	}
+
+	addresses := queryAddresses(query)
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+	internal := query.Method == "INTERNAL_TRANSFERS"
+
+	cacheKey := cache.GenerateKey(strings.ToLower(query.Method), query.FromBlock, query.ToBlock, addresses)
+	if cached, found := qe.cache.Get(cacheKey); found {
+		logger.Debug("cache hit", "key", cacheKey)
+		if records, ok := cached.([]queries.Record); ok {
+			return qe.completeRecords(ctx, records, query)
+		}
+	}
+
+	frames, err := qe.traceFrames(ctx, query, addresses, internal)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []queries.Record
+	for i, frame := range frames {
+		if internal && (len(frame.traceAddress) == 0 || !frame.movesValue() || reverted(frames, i)) {
+			continue
+		}
+		for _, address := range addresses {
+			if frame.from == address || (frame.to != nil && *frame.to == address) {
+				records = append(records, traceRecord(address, frame))
+			}
+		}
+		if len(records) > maxScanRecords {
+			return nil, fmt.Errorf("result too large: %d traces (maximum: %d)", len(records), maxScanRecords)
+		}
+	}
+
+	qe.cache.Set(cacheKey, records, 0)
+	logger.Debug("cached traces", "key", cacheKey, "count", len(records))
+	return qe.completeRecords(ctx, records, query)
+}
+
+// reverted reports whether frames[i] failed or was undone by the failure of a frame
+// that called it. The frames of a transaction are in call order, so those ancestors
+// come before it.
+func reverted(frames []*traceFrame, i int) bool {
+	for j := i; j >= 0 && frames[j].txHash == frames[i].txHash; j-- {
+		if frames[j].err != "" && frames[i].within(frames[j]) {
+			return true
+		}
+	}
+	return false
+}
+
+// traceFrames returns the call frames of the query's blocks, in block, transaction and
+// call order, through trace_filter when the node has it and by tracing every block
+// otherwise. trace_filter only returns the frames involving addresses; with complete
+// set, every frame of the transactions whose frames moved ether is returned instead, so
+// that reverted can see their ancestors.
+func (qe *QueryExecutor) traceFrames(ctx context.Context, query *queries.Query, addresses []common.Address, complete bool) ([]*traceFrame, error) {
+	if !qe.noTraceFilter.Load() {
+		frames, err := qe.filterTraces(ctx, query, addresses, complete)
+		if err == nil || !methodNotFound(err) {
+			return frames, err
+		}
+		qe.noTraceFilter.Store(true)
+		logger.Debug("trace_filter is not supported, tracing blocks with debug_traceBlockByNumber")
+	}
+	return qe.traceBlocks(ctx, query)
+}
+
+// filterTraces asks trace_filter for the frames called from or to addresses, in two
+// requests since it matches frames that satisfy both its from and to conditions
+func (qe *QueryExecutor) filterTraces(ctx context.Context, query *queries.Query, addresses []common.Address, complete bool) ([]*traceFrame, error) {
+	filters := []map[string]interface{}{
+		{"fromBlock": hexutil.EncodeBig(query.FromBlock), "toBlock": hexutil.EncodeBig(query.ToBlock), "fromAddress": addresses},
+		{"fromBlock": hexutil.EncodeBig(query.FromBlock), "toBlock": hexutil.EncodeBig(query.ToBlock), "toAddress": addresses},
+	}
+	results := make([][]flatTrace, len(filters))
+	err := qe.parallel(len(filters), func(i int) error {
+		return qe.client.Client().CallContext(ctx, &results[i], "trace_filter", filters[i])
+	})
+	if err != nil {
+		if methodNotFound(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error filtering traces: %w", err)
+	}
+
+	// A frame between two queried addresses matches both filters
+	var traces []flatTrace
+	seen := make(map[string]bool)
+	for _, result := range results {
+		for _, trace := range result {
+			key := traceKey(trace)
+			if key != "" && !seen[key] {
+				seen[key] = true
+				traces = append(traces, trace)
+			}
+		}
+	}
+
+	if complete {
+		// Only transactions that moved ether below their top-level call are needed
+		var hashes []common.Hash
+		for _, trace := range traces {
+			frame := flatFrame(trace)
+			if len(frame.traceAddress) > 0 && frame.movesValue() && !containsHash(hashes, frame.txHash) {
+				hashes = append(hashes, frame.txHash)
+			}
+		}
+		txTraces := make([][]flatTrace, len(hashes))
+		err := qe.parallel(len(hashes), func(i int) error {
+			if err := qe.client.Client().CallContext(ctx, &txTraces[i], "trace_transaction", hashes[i]); err != nil {
+				return fmt.Errorf("error tracing transaction %s: %w", hashes[i].Hex(), err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		traces = nil
+		for _, txTrace := range txTraces {
+			traces = append(traces, txTrace...)
+		}
+	}
+
+	var frames []*traceFrame
+	for _, trace := range traces {
+		if frame := flatFrame(trace); frame != nil {
+			frames = append(frames, frame)
+		}
+	}
+	sortFrames(frames)
+	return frames, nil
+}
+
+// traceKey identifies a transaction's frame, or is "" for frames outside transactions
+func traceKey(trace flatTrace) string {
+	if trace.TransactionHash == nil || trace.Type == "reward" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%v", trace.TransactionHash.Hex(), trace.TraceAddress)
+}
+
+// flatFrame converts a trace_filter frame, or returns nil for block rewards
+func flatFrame(trace flatTrace) *traceFrame {
+	if traceKey(trace) == "" {
+		return nil
+	}
+	frame := &traceFrame{
+		txHash:       *trace.TransactionHash,
+		blockNumber:  trace.BlockNumber,
+		traceAddress: trace.TraceAddress,
+		gas:          uint64(trace.Action.Gas),
+		err:          trace.Error,
+		value:        new(big.Int),
+	}
+	if trace.TransactionPosition != nil {
+		frame.txIndex = *trace.TransactionPosition
+	}
+	if trace.Action.From != nil {
+		frame.from = *trace.Action.From
+	}
+	if trace.Action.Value != nil {
+		frame.value = trace.Action.Value.ToInt()
+	}
+	if trace.Result != nil {
+		frame.gasUsed = uint64(trace.Result.GasUsed)
+		frame.output = trace.Result.Output
+	}
+
+	switch trace.Type {
+	case "create":
+		frame.kind = "CREATE"
+		if trace.Action.CreationMethod != "" {
+			frame.kind = strings.ToUpper(trace.Action.CreationMethod)
+		}
+		frame.input = trace.Action.Init
+		if trace.Result != nil {
+			frame.to = trace.Result.Address
+		}
+	case "suicide":
+		frame.kind = "SELFDESTRUCT"
+		if trace.Action.Address != nil {
+			frame.from = *trace.Action.Address
+		}
+		frame.to = trace.Action.RefundAddress
+		if trace.Action.Balance != nil {
+			frame.value = trace.Action.Balance.ToInt()
+		}
+	default:
+		frame.kind = strings.ToUpper(trace.Action.CallType)
+		frame.to = trace.Action.To
+		frame.input = trace.Action.Input
+	}
+	return frame
+}
+
+// traceBlocks traces every block of the query's range with the callTracer and
+// flattens the call trees of their transactions
+func (qe *QueryExecutor) traceBlocks(ctx context.Context, query *queries.Query) ([]*traceFrame, error) {
+	from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+	if to < from {
+		return nil, nil
+	}
+	blocks := make([][]txTrace, to-from+1)
+	err := qe.parallel(len(blocks), func(i int) error {
+		number := from + uint64(i)
+		err := qe.client.Client().CallContext(ctx, &blocks[i], "debug_traceBlockByNumber", hexutil.EncodeUint64(number), map[string]interface{}{"tracer": "callTracer"})
+		if err != nil {
+			return fmt.Errorf("error tracing block %d: %w", number, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []*traceFrame
+	for i, block := range blocks {
+		for index, trace := range block {
+			if trace.Result == nil {
+				return nil, fmt.Errorf("error tracing transaction %s: %s", trace.TxHash.Hex(), trace.Error)
+			}
+			frames = flattenFrame(frames, trace.Result, &traceFrame{
+				txHash:      trace.TxHash,
+				txIndex:     uint64(index),
+				blockNumber: from + uint64(i),
+			}, nil)
+		}
+	}
+	return frames, nil
+}
+
+// flattenFrame appends call and the calls it made, depth first, to frames. base holds
+// the fields of the transaction the frames belong to.
+func flattenFrame(frames []*traceFrame, call *callFrame, base *traceFrame, traceAddress []int) []*traceFrame {
+	frame := *base
+	frame.traceAddress = traceAddress
+	frame.kind = strings.ToUpper(call.Type)
+	frame.from = call.From
+	frame.to = call.To
+	frame.value = new(big.Int)
+	if call.Value != nil {
+		frame.value = call.Value.ToInt()
+	}
+	frame.gas = uint64(call.Gas)
+	frame.gasUsed = uint64(call.GasUsed)
+	frame.input = call.Input
+	frame.output = call.Output
+	frame.err = call.Error
+	if call.RevertReason != "" {
+		frame.err = fmt.Sprintf("%s: %s", call.Error, call.RevertReason)
+	}
+	frames = append(frames, &frame)
+
+	for i := range call.Calls {
+		child := append(append([]int{}, traceAddress...), i)
+		frames = flattenFrame(frames, &call.Calls[i], base, child)
+	}
+	return frames
+}
+
+// sortFrames orders frames by block, transaction and position in the call tree, which
+// puts every frame after the frames that called it
+func sortFrames(frames []*traceFrame) {
+	sort.SliceStable(frames, func(i, j int) bool {
+		a, b := frames[i], frames[j]
+		if a.blockNumber != b.blockNumber {
+			return a.blockNumber < b.blockNumber
+		}
+		if a.txIndex != b.txIndex {
+			return a.txIndex < b.txIndex
+		}
+		for k := 0; k < len(a.traceAddress) && k < len(b.traceAddress); k++ {
+			if a.traceAddress[k] != b.traceAddress[k] {
+				return a.traceAddress[k] < b.traceAddress[k]
+			}
+		}
+		return len(a.traceAddress) < len(b.traceAddress)
+	})
+}
+
+// formatTraceAddress renders a frame's position in its call tree, as in "0.2"
+func formatTraceAddress(traceAddress []int) string {
+	parts := make([]string, len(traceAddress))
+	for i, index := range traceAddress {
+		parts[i] = strconv.Itoa(index)
+	}
+	return strings.Join(parts, ".")
+}
+
+func containsHash(hashes []common.Hash, hash common.Hash) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// traceFilterArgs is the filter object of trace_filter
+type traceFilterArgs struct {
+	FromBlock   hexutil.Uint64   `json:"fromBlock"`
+	ToBlock     hexutil.Uint64   `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+}
+
+// TraceBlockByNumber answers debug_traceBlockByNumber with the call trees of block n,
+// or with the state diffs of its transactions for the prestateTracer
+func (c *testChain) TraceBlockByNumber(number rpc.BlockNumber, config map[string]interface{}) (interface{}, error) {
+	c.mu.Lock()
+	c.traceBlockCalls++
+	c.mu.Unlock()
+
+	n := uint64(number.Int64())
+	if config["tracer"] == "prestateTracer" {
+		diffs := []txStateDiff{}
+		for _, tx := range c.txs[n] {
+			diffs = append(diffs, txStateDiff{TxHash: tx.Hash(), Result: c.stateDiff(tx.Hash())})
+		}
+		return diffs, nil
+	}
+	return c.traces[n], nil
+}
+
+// Filter answers trace_filter with the frames of the range whose sender is one of
+// FromAddress and whose recipient is one of ToAddress, either list matching any
+// address when empty
+func (c *testChain) Filter(args traceFilterArgs) ([]flatTrace, error) {
+	if c.noTraceFilter {
+		return nil, methodNotFoundError{method: "trace_filter"}
+	}
+	c.mu.Lock()
+	c.traceFilterCalls++
+	c.mu.Unlock()
+
+	var result []flatTrace
+	for n := uint64(args.FromBlock); n <= uint64(args.ToBlock); n++ {
+		for i, trace := range c.traces[n] {
+			for _, flat := range flattenTestTrace(n, i, trace.TxHash, trace.Result, nil) {
+				if (len(args.FromAddress) == 0 || containsAddress(args.FromAddress, *flat.Action.From)) &&
+					(len(args.ToAddress) == 0 || (flat.Action.To != nil && containsAddress(args.ToAddress, *flat.Action.To))) {
+					result = append(result, flat)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// Transaction answers trace_transaction with every frame of a transaction
+func (c *testChain) Transaction(hash common.Hash) ([]flatTrace, error) {
+	for n, traces := range c.traces {
+		for i, trace := range traces {
+			if trace.TxHash == hash {
+				return flattenTestTrace(n, i, hash, trace.Result, nil), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// flattenTestTrace lists a call tree the way trace_filter reports it
+func flattenTestTrace(n uint64, index int, hash common.Hash, call *callFrame, traceAddress []int) []flatTrace {
+	var trace flatTrace
+	trace.Type = "call"
+	trace.Action.CallType = strings.ToLower(call.Type)
+	trace.Action.From = &call.From
+	trace.Action.To = call.To
+	trace.Action.Value = call.Value
+	trace.Action.Gas = call.Gas
+	trace.Action.Input = call.Input
+	trace.Result = &struct {
+		GasUsed hexutil.Uint64  `json:"gasUsed"`
+		Output  hexutil.Bytes   `json:"output,omitempty"`
+		Address *common.Address `json:"address,omitempty"`
+	}{GasUsed: call.GasUsed, Output: call.Output}
+	if call.Error != "" {
+		trace.Result = nil
+		trace.Error = "Reverted"
+	}
+	trace.TraceAddress = append([]int{}, traceAddress...)
+	trace.TransactionHash = &hash
+	position := uint64(index)
+	trace.TransactionPosition = &position
+	trace.BlockNumber = n
+
+	traces := []flatTrace{trace}
+	for i := range call.Calls {
+		traces = append(traces, flattenTestTrace(n, index, hash, &call.Calls[i], append(append([]int{}, traceAddress...), i))...)
+	}
+	return traces
+}
+
+func TestExecute_Traces(t *testing.T) {
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000a11e")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000bad")
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	library := common.HexToAddress("0x00000000000000000000000000000000000000d0")
+	value := func(wei int64) *hexutil.Big { return (*hexutil.Big)(big.NewInt(wei)) }
+
+	traces := map[uint64][]txTrace{
+		10: {{
+			TxHash: common.HexToHash("0x10"),
+			Result: &callFrame{Type: "CALL", From: other, To: &contract, Value: value(0), Gas: 100000, GasUsed: 60000, Calls: []callFrame{
+				{Type: "CALL", From: contract, To: &wallet, Value: value(5), Gas: 2300},
+				// The payment to the wallet is undone when its caller reverts
+				{Type: "CALL", From: contract, To: &library, Value: value(0), Error: "execution reverted", RevertReason: "nope", Calls: []callFrame{
+					{Type: "CALL", From: library, To: &wallet, Value: value(7)},
+				}},
+			}},
+		}},
+		11: {{
+			TxHash: common.HexToHash("0x11"),
+			Result: &callFrame{Type: "CALL", From: wallet, To: &contract, Value: value(3), Calls: []callFrame{
+				{Type: "STATICCALL", From: contract, To: &wallet},
+			}},
+		}},
+	}
+
+	type trace struct {
+		txHash       common.Hash
+		traceAddress string
+		depth        int64
+		kind         string
+		value        int64
+	}
+	tests := []struct {
+		name     string
+		method   string
+		expected []trace
+	}{
+		{
+			name:   "Every frame",
+			method: "TRACES",
+			expected: []trace{
+				{txHash: common.HexToHash("0x10"), traceAddress: "0", depth: 1, kind: "CALL", value: 5},
+				{txHash: common.HexToHash("0x10"), traceAddress: "1.0", depth: 2, kind: "CALL", value: 7},
+				{txHash: common.HexToHash("0x11"), traceAddress: "", kind: "CALL", value: 3},
+				{txHash: common.HexToHash("0x11"), traceAddress: "0", depth: 1, kind: "STATICCALL", value: 0},
+			},
+		},
+		{
+			name:   "Internal transfers",
+			method: "INTERNAL_TRANSFERS",
+			expected: []trace{
+				{txHash: common.HexToHash("0x10"), traceAddress: "0", depth: 1, kind: "CALL", value: 5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, noTraceFilter := range []bool{false, true} {
+			name := tt.name
+			if noTraceFilter {
+				name += " without trace_filter"
+			}
+			t.Run(name, func(t *testing.T) {
+				chain := &testChain{head: 1000, traces: traces, noTraceFilter: noTraceFilter}
+				qe := newTestExecutor(t, chain)
+				query := &queries.Query{
+					Method:    tt.method,
+					Addresses: []common.Address{wallet},
+					FromBlock: big.NewInt(10),
+					ToBlock:   big.NewInt(12),
+				}
+				result, err := qe.Execute(context.Background(), query)
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				if result.Len() != len(tt.expected) {
+					t.Fatalf("Expected %d rows, got %d: %v", len(tt.expected), result.Len(), result.Rows)
+				}
+
+				schema := queries.Schemas[tt.method].Default().Names()
+				for i, expected := range tt.expected {
+					row := make(map[string]interface{})
+					for j, name := range schema {
+						row[name] = result.Rows[i][j]
+					}
+					if row["tx_hash"] != expected.txHash || row["trace_address"] != expected.traceAddress || row["type"] != expected.kind {
+						t.Errorf("Row %d: expected %s frame %q of %s, got %v %q of %v", i, expected.kind, expected.traceAddress, expected.txHash.Hex(), row["type"], row["trace_address"], row["tx_hash"])
+					}
+					if v, ok := row["value"].(*big.Int); !ok || v.Int64() != expected.value {
+						t.Errorf("Row %d: expected value %d, got %v", i, expected.value, row["value"])
+					}
+					if depth, ok := row["depth"].(*big.Int); !ok || depth.Int64() != expected.depth {
+						t.Errorf("Row %d: expected depth %d, got %v", i, expected.depth, row["depth"])
+					}
+				}
+
+				if noTraceFilter && chain.traceBlockCalls != 3 {
+					t.Errorf("Expected 3 traced blocks, got %d", chain.traceBlockCalls)
+				}
+				if !noTraceFilter && chain.traceBlockCalls != 0 {
+					t.Errorf("Expected trace_filter to be used, got %d traced blocks", chain.traceBlockCalls)
+				}
+			})
+		}
+	}
+}
+
+func TestExecute_TracesReportRevertReasons(t *testing.T) {
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000a11e")
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	chain := &testChain{
+		head:          1000,
+		noTraceFilter: true,
+		traces: map[uint64][]txTrace{
+			10: {{TxHash: common.HexToHash("0x10"), Result: &callFrame{Type: "CALL", From: wallet, To: &contract, Error: "execution reverted", RevertReason: "paused"}}},
+		},
+	}
+	qe := newTestExecutor(t, chain)
+
+	query := &queries.Query{
+		Method:    "TRACES",
+		Addresses: []common.Address{wallet},
+		FromBlock: big.NewInt(10),
+		ToBlock:   big.NewInt(10),
+		Fields:    []queries.SelectItem{{Name: "error", Expr: &queries.ColumnRef{Name: "error"}, Type: queries.TypeString}},
+	}
+	result, err := qe.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Len() != 1 || result.Rows[0][0] != "execution reverted: paused" {
+		t.Errorf("Expected the revert reason, got %v", result.Rows)
+	}
+}
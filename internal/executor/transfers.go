@@ -0,0 +1,243 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/devlongs/evmql/internal/cache"
+	"github.com/devlongs/evmql/internal/logger"
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// transferTopic is shared by ERC-20 and ERC-721, which index the token ID as well
+	transferTopic       = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	transferSingleTopic = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+	transferBatchTopic  = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+)
+
+// mustType parses an elementary ABI type, like mustParseABI does a contract ABI
+func mustType(name string) abi.Type {
+	t, err := abi.NewType(name, "", nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid ABI type %s: %v", name, err))
+	}
+	return t
+}
+
+// addressTopic returns the topic under which an event indexes address
+func addressTopic(address common.Address) common.Hash {
+	return common.BytesToHash(address.Bytes())
+}
+
+// transferBatchData decodes the token IDs and amounts of a TransferBatch log
+var transferBatchData = abi.Arguments{
+	{Type: mustType("uint256[]")},
+	{Type: mustType("uint256[]")},
+}
+
+// transferFilters returns the log filters matching the transfers sent or received by
+// wallets: ERC-20 and ERC-721 transfers index the sender and the recipient in topic1
+// and topic2, ERC-1155 transfers in topic2 and topic3 after the operator. A nil tokens
+// matches the transfers of every token.
+func transferFilters(query *queries.Query, wallets, tokens []common.Address) []ethereum.FilterQuery {
+	topics := make([]common.Hash, len(wallets))
+	for i, wallet := range wallets {
+		topics[i] = addressTopic(wallet)
+	}
+	single := []common.Hash{transferTopic}
+	multi := []common.Hash{transferSingleTopic, transferBatchTopic}
+
+	var filters []ethereum.FilterQuery
+	for _, positions := range [][][]common.Hash{
+		{single, topics},
+		{single, nil, topics},
+		{multi, nil, topics},
+		{multi, nil, nil, topics},
+	} {
+		filters = append(filters, ethereum.FilterQuery{
+			FromBlock: query.FromBlock,
+			ToBlock:   query.ToBlock,
+			Addresses: tokens,
+			Topics:    positions,
+		})
+	}
+	return filters
+}
+
+// getTransfers finds the token transfers sent or received by the queried wallets, one
+// row per wallet and transferred token
+func (qe *QueryExecutor) getTransfers(ctx context.Context, query *queries.Query) ([]queries.Record, error) {
+	blockRange := new(big.Int).Sub(query.ToBlock, query.FromBlock)
+	if blockRange.Cmp(big.NewInt(10000)) > 0 {
+		return nil, fmt.Errorf("block range too large for transfers query: %d blocks (maximum: 10000)", blockRange.Int64())
+	}
+
+	wallets := queryAddresses(query)
+	if len(wallets) == 0 {
+		return nil, nil
+	}
+	tokens, satisfiable := transferTokens(query.Where)
+	if !satisfiable {
+		logger.Debug("transfers filter can never match")
+		return nil, nil
+	}
+	filters := transferFilters(query, wallets, tokens)
+
+	cacheKey := cache.GenerateKey("transfers", query.FromBlock, query.ToBlock, wallets, tokens)
+	if cached, found := qe.cache.Get(cacheKey); found {
+		logger.Debug("cache hit", "key", cacheKey)
+		if records, ok := cached.([]queries.Record); ok {
+			return qe.completeRecords(ctx, records, query)
+		}
+	}
+
+	// A transfer between two queried wallets matches more than one filter
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var logs []types.Log
+	err := qe.parallel(len(filters), func(i int) error {
+		matched, err := qe.client.FilterLogs(ctx, filters[i])
+		if err != nil {
+			return fmt.Errorf("error fetching transfer logs: %w", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, log := range matched {
+			key := fmt.Sprintf("%s:%d", log.BlockHash.Hex(), log.Index)
+			if !seen[key] && !log.Removed {
+				seen[key] = true
+				logs = append(logs, log)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+
+	var records []queries.Record
+	for _, log := range logs {
+		for _, transfer := range transferRecords(log) {
+			for _, wallet := range wallets {
+				if transfer["from"] == wallet || transfer["to"] == wallet {
+					record := copyRecord(transfer, 1)
+					record["address"] = wallet
+					records = append(records, record)
+				}
+			}
+		}
+		if len(records) > maxScanRecords {
+			return nil, fmt.Errorf("result too large: %d transfers (maximum: %d)", len(records), maxScanRecords)
+		}
+	}
+
+	qe.cache.Set(cacheKey, records, 0)
+	logger.Debug("cached transfers", "key", cacheKey, "count", len(records))
+	return qe.completeRecords(ctx, records, query)
+}
+
+// transferTokens returns the tokens named by top-level "token = x" and "token IN (...)"
+// conditions of where, or nil when there are none. It returns false when the
+// conditions exclude every token.
+func transferTokens(where queries.Expr) ([]common.Address, bool) {
+	var tokens []common.Address
+	constrained := false
+	for _, term := range queries.Conjuncts(where) {
+		column, values, ok := equalityValues(term)
+		if !ok || column != "token" {
+			continue
+		}
+		if constrained {
+			tokens = intersectAddresses(tokens, values)
+		} else {
+			constrained = true
+			for _, value := range values {
+				if token, ok := value.(common.Address); ok && !containsAddress(tokens, token) {
+					tokens = append(tokens, token)
+				}
+			}
+		}
+		if len(tokens) == 0 {
+			return nil, false
+		}
+	}
+	return tokens, true
+}
+
+// transferRecords decodes a transfer log into one record per transferred token, without
+// the wallet's address field. Logs that match a transfer topic but not its layout,
+// such as those of contracts reusing the event name, yield no records.
+func transferRecords(log types.Log) []queries.Record {
+	base := func(standard string, from, to common.Hash) queries.Record {
+		return queries.Record{
+			"standard":     standard,
+			"token":        log.Address,
+			"from":         common.BytesToAddress(from.Bytes()),
+			"to":           common.BytesToAddress(to.Bytes()),
+			"block_number": uint64ToBig(log.BlockNumber),
+			"block_hash":   log.BlockHash,
+			"tx_hash":      log.TxHash,
+			"tx_index":     uint64ToBig(uint64(log.TxIndex)),
+			"log_index":    uint64ToBig(uint64(log.Index)),
+		}
+	}
+
+	topics := log.Topics
+	if len(topics) == 0 {
+		return nil
+	}
+	switch {
+	case topics[0] == transferTopic && len(topics) == 3 && len(log.Data) == 32:
+		record := base("ERC-20", topics[1], topics[2])
+		record["amount"] = new(big.Int).SetBytes(log.Data)
+		return []queries.Record{record}
+
+	case topics[0] == transferTopic && len(topics) == 4 && len(log.Data) == 0:
+		record := base("ERC-721", topics[1], topics[2])
+		record["amount"] = big.NewInt(1)
+		record["token_id"] = topics[3].Big()
+		return []queries.Record{record}
+
+	case topics[0] == transferSingleTopic && len(topics) == 4 && len(log.Data) == 64:
+		record := base("ERC-1155", topics[2], topics[3])
+		record["operator"] = common.BytesToAddress(topics[1].Bytes())
+		record["token_id"] = new(big.Int).SetBytes(log.Data[:32])
+		record["amount"] = new(big.Int).SetBytes(log.Data[32:])
+		return []queries.Record{record}
+
+	case topics[0] == transferBatchTopic && len(topics) == 4:
+		values, err := transferBatchData.Unpack(log.Data)
+		if err != nil {
+			return nil
+		}
+		ids, amounts := values[0].([]*big.Int), values[1].([]*big.Int)
+		if len(ids) != len(amounts) {
+			return nil
+		}
+		records := make([]queries.Record, len(ids))
+		for i := range ids {
+			record := base("ERC-1155", topics[2], topics[3])
+			record["operator"] = common.BytesToAddress(topics[1].Bytes())
+			record["token_id"] = ids[i]
+			record["amount"] = amounts[i]
+			records[i] = record
+		}
+		return records
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// transferLog builds a log of token in block n with the given topics and data
+func transferLog(token common.Address, n uint64, index uint, data []byte, topics ...common.Hash) types.Log {
+	return types.Log{
+		Address:     token,
+		Topics:      topics,
+		Data:        data,
+		BlockNumber: n,
+		BlockHash:   testHeader(n).Hash(),
+		TxHash:      common.BigToHash(new(big.Int).SetUint64(n*100 + uint64(index))),
+		Index:       index,
+	}
+}
+
+func TestExecute_Transfers(t *testing.T) {
+	wallet := common.HexToAddress("0x000000000000000000000000000000000000a11e")
+	friend := common.HexToAddress("0x000000000000000000000000000000000000f00d")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000bad")
+	operator := common.HexToAddress("0x0000000000000000000000000000000000000090")
+	erc20 := common.HexToAddress("0x0000000000000000000000000000000000000020")
+	erc721 := common.HexToAddress("0x0000000000000000000000000000000000000721")
+	erc1155 := common.HexToAddress("0x0000000000000000000000000000000000001155")
+
+	batch, err := transferBatchData.Pack([]*big.Int{big.NewInt(1), big.NewInt(2)}, []*big.Int{big.NewInt(10), big.NewInt(20)})
+	if err != nil {
+		t.Fatalf("Failed to pack batch: %v", err)
+	}
+	single := append(common.BigToHash(big.NewInt(3)).Bytes(), common.BigToHash(big.NewInt(4)).Bytes()...)
+
+	chain := &testChain{
+		head: 1000,
+		logs: []types.Log{
+			transferLog(erc20, 10, 0, common.BigToHash(big.NewInt(500)).Bytes(), transferTopic, addressTopic(wallet), addressTopic(other)),
+			transferLog(erc721, 10, 1, nil, transferTopic, addressTopic(other), addressTopic(wallet), common.BigToHash(big.NewInt(7))),
+			transferLog(erc1155, 11, 0, single, transferSingleTopic, addressTopic(operator), addressTopic(wallet), addressTopic(other)),
+			transferLog(erc1155, 12, 0, batch, transferBatchTopic, addressTopic(operator), addressTopic(other), addressTopic(wallet)),
+			transferLog(erc20, 12, 1, common.BigToHash(big.NewInt(1)).Bytes(), transferTopic, addressTopic(other), addressTopic(operator)),
+			transferLog(erc20, 13, 0, common.BigToHash(big.NewInt(9)).Bytes(), transferTopic, addressTopic(wallet), addressTopic(friend)),
+		},
+	}
+
+	type transfer struct {
+		address  common.Address
+		standard string
+		token    common.Address
+		amount   int64
+		tokenID  int64 // -1 for NULL
+		logIndex int64
+	}
+	tests := []struct {
+		name     string
+		where    queries.Expr
+		expected []transfer
+	}{
+		{
+			name: "Every standard",
+			expected: []transfer{
+				{address: wallet, standard: "ERC-20", token: erc20, amount: 500, tokenID: -1, logIndex: 0},
+				{address: wallet, standard: "ERC-721", token: erc721, amount: 1, tokenID: 7, logIndex: 1},
+				{address: wallet, standard: "ERC-1155", token: erc1155, amount: 4, tokenID: 3, logIndex: 0},
+				{address: wallet, standard: "ERC-1155", token: erc1155, amount: 10, tokenID: 1, logIndex: 0},
+				{address: wallet, standard: "ERC-1155", token: erc1155, amount: 20, tokenID: 2, logIndex: 0},
+				{address: wallet, standard: "ERC-20", token: erc20, amount: 9, tokenID: -1, logIndex: 0},
+				{address: friend, standard: "ERC-20", token: erc20, amount: 9, tokenID: -1, logIndex: 0},
+			},
+		},
+		{
+			name:  "Token condition",
+			where: &queries.Comparison{Op: "=", Left: &queries.ColumnRef{Name: "token"}, Right: &queries.Literal{Value: erc721}},
+			expected: []transfer{
+				{address: wallet, standard: "ERC-721", token: erc721, amount: 1, tokenID: 7, logIndex: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qe := newTestExecutor(t, chain)
+			query := &queries.Query{
+				Method:    "TRANSFERS",
+				Addresses: []common.Address{wallet, friend},
+				FromBlock: big.NewInt(10),
+				ToBlock:   big.NewInt(20),
+				Where:     tt.where,
+			}
+			result, err := qe.Execute(context.Background(), query)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result.Len() != len(tt.expected) {
+				t.Fatalf("Expected %d rows, got %d: %v", len(tt.expected), result.Len(), result.Rows)
+			}
+
+			schema := queries.Schemas["TRANSFERS"].Default().Names()
+			for i, expected := range tt.expected {
+				row := make(map[string]interface{})
+				for j, name := range schema {
+					row[name] = result.Rows[i][j]
+				}
+				if row["address"] != expected.address || row["standard"] != expected.standard || row["token"] != expected.token {
+					t.Errorf("Row %d: expected %s %s transfer of %s, got %v %v %v", i, expected.address.Hex(), expected.standard, expected.token.Hex(), row["address"], row["standard"], row["token"])
+				}
+				if amount, ok := row["amount"].(*big.Int); !ok || amount.Int64() != expected.amount {
+					t.Errorf("Row %d: expected amount %d, got %v", i, expected.amount, row["amount"])
+				}
+				if expected.tokenID < 0 && row["token_id"] != nil {
+					t.Errorf("Row %d: expected no token ID, got %v", i, row["token_id"])
+				}
+				if id, ok := row["token_id"].(*big.Int); expected.tokenID >= 0 && (!ok || id.Int64() != expected.tokenID) {
+					t.Errorf("Row %d: expected token ID %d, got %v", i, expected.tokenID, row["token_id"])
+				}
+				if index, ok := row["log_index"].(*big.Int); !ok || index.Int64() != expected.logIndex {
+					t.Errorf("Row %d: expected log index %d, got %v", i, expected.logIndex, row["log_index"])
+				}
+				if expected.standard == "ERC-1155" && row["operator"] != operator {
+					t.Errorf("Row %d: expected operator %s, got %v", i, operator.Hex(), row["operator"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package format
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+// CSVFormatter renders a result set as CSV with a header row; NULL becomes an empty cell
+type CSVFormatter struct{}
+
+func (f *CSVFormatter) Format(w io.Writer, rs *queries.ResultSet) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(rs.Columns))
+	for i, column := range rs.Columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rs.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			if value != nil {
+				record[i] = queries.FormatValue(value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,50 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Formatter renders a result set
+type Formatter interface {
+	Format(w io.Writer, rs *queries.ResultSet) error
+}
+
+// Options control how formatters render values
+type Options struct {
+	Pretty bool                      // indent structured output such as JSON
+	Labels map[common.Address]string // names shown next to known addresses in tables
+}
+
+var constructors = map[string]func(Options) Formatter{
+	"table": func(opts Options) Formatter { return &TableFormatter{Labels: opts.Labels} },
+	"json":  func(opts Options) Formatter { return &JSONFormatter{Indent: opts.Pretty} },
+	"csv":   func(opts Options) Formatter { return &CSVFormatter{} },
+}
+
+// New returns the formatter registered under name; an empty name selects "table"
+func New(name string, opts Options) (Formatter, error) {
+	if name == "" {
+		name = "table"
+	}
+	constructor, ok := constructors[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %s (supported: %s)", name, strings.Join(Names(), ", "))
+	}
+	return constructor(opts), nil
+}
+
+// Names returns the registered format names
+func Names() []string {
+	names := make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
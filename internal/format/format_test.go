@@ -0,0 +1,185 @@
+package format
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func testResultSet() *queries.ResultSet {
+	return &queries.ResultSet{
+		Columns: []queries.Column{
+			{Name: "to", Type: queries.TypeAddress},
+			{Name: "value", Type: queries.TypeInt},
+			{Name: "input", Type: queries.TypeBytes},
+		},
+		Rows: [][]interface{}{
+			{common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e"), new(big.Int).Exp(big.NewInt(10), big.NewInt(30), nil), []byte{0xa9, 0x05}},
+			{nil, big.NewInt(0), []byte{}},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		expectedErr bool
+	}{
+		{name: "Default", format: "", expectedErr: false},
+		{name: "Table", format: "table", expectedErr: false},
+		{name: "JSON uppercase", format: "JSON", expectedErr: false},
+		{name: "CSV", format: "csv", expectedErr: false},
+		{name: "Unknown", format: "xml", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := New(tt.format, Options{})
+			if tt.expectedErr {
+				if err == nil {
+					t.Error("Expected error for unknown format")
+				}
+				return
+			}
+			if err != nil || formatter == nil {
+				t.Errorf("Expected formatter, got error: %v", err)
+			}
+		})
+	}
+}
+
+func TestTableFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&TableFormatter{}).Format(&buf, testResultSet()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected 5 lines (header, separator, 2 rows, footer), got %d:\n%s", len(lines), buf.String())
+	}
+
+	if !strings.HasPrefix(lines[0], "to ") || !strings.Contains(lines[0], "| value") {
+		t.Errorf("Unexpected header: %q", lines[0])
+	}
+	if !strings.Contains(lines[2], "0x742d35Cc6634C0532925a3b844Bc454e4438f44e") || !strings.Contains(lines[2], "1000000000000000000000000000000") {
+		t.Errorf("Unexpected first row: %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], "NULL") {
+		t.Errorf("Expected NULL for missing address, got %q", lines[3])
+	}
+	if lines[4] != "(2 rows)" {
+		t.Errorf("Expected row count footer, got %q", lines[4])
+	}
+
+	// Columns are aligned to the widest cell
+	if strings.Index(lines[2], "|") != strings.Index(lines[3], "|") {
+		t.Errorf("Expected aligned columns:\n%s", buf.String())
+	}
+}
+
+func TestTableFormatter_BlockFooter(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     queries.ResultMeta
+		expected string
+	}{
+		{name: "Single block", meta: queries.ResultMeta{FromBlock: big.NewInt(7), ToBlock: big.NewInt(7)}, expected: "(2 rows, block 7)"},
+		{name: "Block range", meta: queries.ResultMeta{FromBlock: big.NewInt(7), ToBlock: big.NewInt(9)}, expected: "(2 rows, blocks 7 to 9)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := testResultSet()
+			rs.Meta = tt.meta
+
+			var buf bytes.Buffer
+			if err := (&TableFormatter{}).Format(&buf, rs); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+			if footer := lines[len(lines)-1]; footer != tt.expected {
+				t.Errorf("Expected footer %q, got %q", tt.expected, footer)
+			}
+		})
+	}
+}
+
+func TestTableFormatter_Labels(t *testing.T) {
+	labels := map[common.Address]string{common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e"): "treasury"}
+	formatter, err := New("table", Options{Labels: labels})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, testResultSet()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.HasPrefix(lines[2], "0x742d35Cc6634C0532925a3b844Bc454e4438f44e (treasury) |") {
+		t.Errorf("Expected a labelled address, got %q", lines[2])
+	}
+	if strings.Index(lines[2], "|") != strings.Index(lines[3], "|") {
+		t.Errorf("Expected aligned columns:\n%s", buf.String())
+	}
+
+	// Machine-readable formats keep plain addresses
+	buf.Reset()
+	if err := (&CSVFormatter{}).Format(&buf, testResultSet()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "treasury") {
+		t.Errorf("Expected no labels in CSV output, got %q", buf.String())
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&JSONFormatter{}).Format(&buf, testResultSet()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := `[{"to":"0x742d35Cc6634C0532925a3b844Bc454e4438f44e","value":1000000000000000000000000000000,"input":"0xa905"},{"to":null,"value":0,"input":"0x"}]` + "\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := (&JSONFormatter{Indent: true}).Format(&buf, testResultSet()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "\n    \"to\": ") {
+		t.Errorf("Expected indented output, got %s", buf.String())
+	}
+}
+
+func TestJSONFormatter_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	rs := &queries.ResultSet{Columns: []queries.Column{{Name: "hash", Type: queries.TypeHash}}}
+	if err := (&JSONFormatter{}).Format(&buf, rs); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("Expected empty array, got %q", buf.String())
+	}
+}
+
+func TestCSVFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&CSVFormatter{}).Format(&buf, testResultSet()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := "to,value,input\n" +
+		"0x742d35Cc6634C0532925a3b844Bc454e4438f44e,1000000000000000000000000000000,0xa905\n" +
+		",0,0x\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
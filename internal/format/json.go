@@ -0,0 +1,69 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/big"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+// JSONFormatter renders a result set as a JSON array of objects, keeping column order
+type JSONFormatter struct {
+	Indent bool
+}
+
+func (f *JSONFormatter) Format(w io.Writer, rs *queries.ResultSet) error {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for r, row := range rs.Rows {
+		if r > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('{')
+		for i, value := range row {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			key, err := json.Marshal(rs.Columns[i].Name)
+			if err != nil {
+				return err
+			}
+			b.Write(key)
+			b.WriteByte(':')
+
+			encoded, err := json.Marshal(jsonValue(value))
+			if err != nil {
+				return err
+			}
+			b.Write(encoded)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(']')
+
+	out := b.Bytes()
+	if f.Indent {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, out, "", "  "); err != nil {
+			return err
+		}
+		out = indented.Bytes()
+	}
+
+	if _, err := w.Write(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// jsonValue maps field values onto JSON types; integers stay exact JSON numbers
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, *big.Int:
+		return v
+	}
+	return queries.FormatValue(value)
+}
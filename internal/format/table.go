@@ -0,0 +1,88 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TableFormatter renders a result set as an aligned text table
+type TableFormatter struct {
+	Labels map[common.Address]string // names appended to known addresses, as in "0x... (usdc)"
+}
+
+func (f *TableFormatter) Format(w io.Writer, rs *queries.ResultSet) error {
+	cells := make([][]string, len(rs.Rows))
+	widths := make([]int, len(rs.Columns))
+	for i, column := range rs.Columns {
+		widths[i] = len(column.Name)
+	}
+	for r, row := range rs.Rows {
+		cells[r] = make([]string, len(row))
+		for i, value := range row {
+			cells[r][i] = f.formatCell(value)
+			if len(cells[r][i]) > widths[i] {
+				widths[i] = len(cells[r][i])
+			}
+		}
+	}
+
+	header := make([]string, len(rs.Columns))
+	separator := make([]string, len(rs.Columns))
+	for i, column := range rs.Columns {
+		header[i] = pad(column.Name, widths[i])
+		separator[i] = strings.Repeat("-", widths[i])
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(strings.Join(header, " | "), " ") + "\n")
+	b.WriteString(strings.Join(separator, "-+-") + "\n")
+	for _, row := range cells {
+		line := make([]string, len(row))
+		for i, cell := range row {
+			line[i] = pad(cell, widths[i])
+		}
+		b.WriteString(strings.TrimRight(strings.Join(line, " | "), " ") + "\n")
+	}
+
+	noun := "rows"
+	if len(rs.Rows) == 1 {
+		noun = "row"
+	}
+	fmt.Fprintf(&b, "(%d %s%s)\n", len(rs.Rows), noun, blockSuffix(rs.Meta))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatCell renders a value, labelling addresses found in Labels
+func (f *TableFormatter) formatCell(value interface{}) string {
+	text := queries.FormatValue(value)
+	if address, ok := value.(common.Address); ok {
+		if label, ok := f.Labels[address]; ok {
+			text += " (" + label + ")"
+		}
+	}
+	return text
+}
+
+// blockSuffix describes the blocks a result was read from, if any
+func blockSuffix(meta queries.ResultMeta) string {
+	switch {
+	case meta.FromBlock == nil || meta.ToBlock == nil:
+		return ""
+	case meta.FromBlock.Cmp(meta.ToBlock) == 0:
+		return fmt.Sprintf(", block %s", meta.FromBlock)
+	}
+	return fmt.Sprintf(", blocks %s to %s", meta.FromBlock, meta.ToBlock)
+}
+
+func pad(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+// aggregateFuncs lists the aggregate functions accepted in the select list, HAVING and ORDER BY
+var aggregateFuncs = map[string]bool{
+	"COUNT": true,
+	"SUM":   true,
+	"AVG":   true,
+	"MIN":   true,
+	"MAX":   true,
+}
+
+// grouping is the lowered GROUP BY clause together with the aggregates the query uses
+type grouping struct {
+	groupBy    []queries.Expr
+	aggregates []queries.Aggregate
+	schema     queries.Schema // fields of a grouped row: the group keys followed by the aggregates
+}
+
+// buildGrouping resolves GROUP BY and every aggregate call of the statement. It
+// returns nil when the query neither groups nor aggregates.
+func buildGrouping(stmt *SelectStmt, fields []*SelectField, schema queries.Schema) (*grouping, error) {
+	var calls []*CallExpr
+	for _, field := range fields {
+		collectCalls(field.Expr, &calls)
+	}
+	collectCalls(stmt.Having, &calls)
+	for _, item := range stmt.OrderBy {
+		collectCalls(item.Expr, &calls)
+	}
+
+	if len(stmt.GroupBy) == 0 && stmt.Having == nil && len(calls) == 0 {
+		return nil, nil
+	}
+	if fields == nil {
+		return nil, errorAt(stmt.From, "GROUP BY and HAVING require a select list, as in SELECT <column>, COUNT(*) FROM <method>(<address>)")
+	}
+
+	g := &grouping{}
+	for _, key := range stmt.GroupBy {
+		column, field, err := buildColumn(key, schema)
+		if err != nil {
+			return nil, err
+		}
+		g.groupBy = append(g.groupBy, column)
+		g.schema = append(g.schema, field)
+	}
+
+	for _, call := range calls {
+		agg, field, err := buildAggregate(call, schema)
+		if err != nil {
+			return nil, err
+		}
+		if _, seen := g.schema.Lookup(field.Name); seen {
+			continue
+		}
+		g.aggregates = append(g.aggregates, agg)
+		g.schema = append(g.schema, field)
+	}
+
+	for _, field := range fields {
+		if field.Star {
+			return nil, errorAt(field, "SELECT * cannot be combined with GROUP BY or aggregate functions")
+		}
+		ident, ok := field.Expr.(*Ident)
+		if !ok {
+			continue
+		}
+		if _, grouped := g.schema.Lookup(ident.Name); grouped {
+			continue
+		}
+		if _, known := schema.Lookup(ident.Name); known {
+			return nil, errorAt(ident, "column %s must appear in GROUP BY or be used in an aggregate function", ident.Name)
+		}
+	}
+
+	return g, nil
+}
+
+// buildAggregate type-checks an aggregate call and returns the field holding its result
+func buildAggregate(call *CallExpr, schema queries.Schema) (queries.Aggregate, queries.Field, error) {
+	name := strings.ToUpper(call.Func.Name)
+	if !aggregateFuncs[name] {
+		return queries.Aggregate{}, queries.Field{}, errorAt(call, "unknown function: %s (supported: %s)", call.Func.Name, supportedAggregates())
+	}
+
+	if call.Star {
+		if name != "COUNT" {
+			return queries.Aggregate{}, queries.Field{}, errorAt(call, "%s(*) is not supported; only COUNT accepts *", name)
+		}
+		agg := queries.Aggregate{Func: name}
+		return agg, queries.Field{Name: agg.String(), Type: queries.TypeInt}, nil
+	}
+
+	if len(call.Args) != 1 {
+		return queries.Aggregate{}, queries.Field{}, errorAt(call, "%s expects exactly one column argument, got %d", name, len(call.Args))
+	}
+	if _, nested := call.Args[0].(*CallExpr); nested {
+		return queries.Aggregate{}, queries.Field{}, errorAt(call.Args[0], "aggregate functions cannot be nested")
+	}
+	arg, field, err := buildColumn(call.Args[0], schema)
+	if err != nil {
+		return queries.Aggregate{}, queries.Field{}, err
+	}
+
+	resultType := queries.TypeInt
+	switch name {
+	case "SUM", "AVG":
+		if field.Type != queries.TypeInt {
+			return queries.Aggregate{}, queries.Field{}, errorAt(call, "%s is not supported for %s column %s", name, field.Type, field.Name)
+		}
+	case "MIN", "MAX":
+		if !field.Type.Ordered() {
+			return queries.Aggregate{}, queries.Field{}, errorAt(call, "%s is not supported for %s column %s", name, field.Type, field.Name)
+		}
+		resultType = field.Type
+	}
+
+	agg := queries.Aggregate{Func: name, Arg: arg}
+	return agg, queries.Field{Name: agg.String(), Type: resultType, Nullable: name != "COUNT"}, nil
+}
+
+// collectCalls appends the function calls found in expr to calls
+func collectCalls(expr Expr, calls *[]*CallExpr) {
+	switch e := expr.(type) {
+	case *CallExpr:
+		*calls = append(*calls, e)
+	case *UnaryExpr:
+		collectCalls(e.X, calls)
+	case *BinaryExpr:
+		collectCalls(e.Left, calls)
+		collectCalls(e.Right, calls)
+	case *InExpr:
+		collectCalls(e.X, calls)
+	case *BetweenExpr:
+		collectCalls(e.X, calls)
+		collectCalls(e.Low, calls)
+		collectCalls(e.High, calls)
+	case *IsNullExpr:
+		collectCalls(e.X, calls)
+	}
+}
+
+// callText renders a call the way aggregates are named, such as "SUM(value)"
+func callText(call *CallExpr) string {
+	if call.Star {
+		return strings.ToUpper(call.Func.Name) + "(*)"
+	}
+	args := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		args[i] = nodeText(arg)
+	}
+	return strings.ToUpper(call.Func.Name) + "(" + strings.Join(args, ", ") + ")"
+}
+
+func supportedAggregates() string {
+	names := make([]string, 0, len(aggregateFuncs))
+	for name := range aggregateFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+func TestParseQuery_GroupBy(t *testing.T) {
+	tests := []struct {
+		name               string
+		queryStr           string
+		expectedFields     []string
+		expectedTypes      []queries.Type
+		expectedGroupBy    []string
+		expectedAggregates []string
+		expectedHaving     string
+		expectedOrder      []string
+	}{
+		{
+			name:               "Group with HAVING on an aggregate not selected",
+			queryStr:           "SELECT from, SUM(value) AS total FROM TRANSACTIONS(" + testAddress + ") BLOCK 1 2 GROUP BY from HAVING count(*) > 5",
+			expectedFields:     []string{"from", "total"},
+			expectedTypes:      []queries.Type{queries.TypeAddress, queries.TypeInt},
+			expectedGroupBy:    []string{"from"},
+			expectedAggregates: []string{"SUM(value)", "COUNT(*)"},
+			expectedHaving:     "COUNT(*) > 5",
+		},
+		{
+			name:               "Whole-result aggregates",
+			queryStr:           "SELECT COUNT(*), MIN(block_number), max(log_index), AVG(tx_index) FROM LOGS(" + testAddress + ") BLOCK 1 2",
+			expectedFields:     []string{"COUNT(*)", "MIN(block_number)", "MAX(log_index)", "AVG(tx_index)"},
+			expectedTypes:      []queries.Type{queries.TypeInt, queries.TypeInt, queries.TypeInt, queries.TypeInt},
+			expectedAggregates: []string{"COUNT(*)", "MIN(block_number)", "MAX(log_index)", "AVG(tx_index)"},
+		},
+		{
+			name:               "Order by aggregate and alias",
+			queryStr:           "SELECT block_number, COUNT(*) AS logs FROM LOGS(" + testAddress + ") BLOCK 1 2 GROUP BY block_number ORDER BY logs DESC, COUNT(log_index)",
+			expectedFields:     []string{"block_number", "logs"},
+			expectedTypes:      []queries.Type{queries.TypeInt, queries.TypeInt},
+			expectedGroupBy:    []string{"block_number"},
+			expectedAggregates: []string{"COUNT(*)", "COUNT(log_index)"},
+			expectedOrder:      []string{"COUNT(*)", "COUNT(log_index)"},
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !query.Grouped() {
+				t.Fatal("Expected a grouped query")
+			}
+
+			if len(query.Fields) != len(tt.expectedFields) {
+				t.Fatalf("Expected %d fields, got %d", len(tt.expectedFields), len(query.Fields))
+			}
+			for i, name := range tt.expectedFields {
+				if query.Fields[i].Name != name || query.Fields[i].Type != tt.expectedTypes[i] {
+					t.Errorf("Field %d: expected %s %s, got %s %s", i, name, tt.expectedTypes[i], query.Fields[i].Name, query.Fields[i].Type)
+				}
+			}
+
+			if len(query.GroupBy) != len(tt.expectedGroupBy) {
+				t.Fatalf("Expected %d group keys, got %d", len(tt.expectedGroupBy), len(query.GroupBy))
+			}
+			for i, name := range tt.expectedGroupBy {
+				if query.GroupBy[i].String() != name {
+					t.Errorf("Group key %d: expected %s, got %s", i, name, query.GroupBy[i])
+				}
+			}
+
+			if len(query.Aggregates) != len(tt.expectedAggregates) {
+				t.Fatalf("Expected aggregates %v, got %v", tt.expectedAggregates, query.Aggregates)
+			}
+			for i, name := range tt.expectedAggregates {
+				if query.Aggregates[i].String() != name {
+					t.Errorf("Aggregate %d: expected %s, got %s", i, name, query.Aggregates[i])
+				}
+			}
+
+			having := ""
+			if query.Having != nil {
+				having = query.Having.String()
+			}
+			if having != tt.expectedHaving {
+				t.Errorf("Expected HAVING %q, got %q", tt.expectedHaving, having)
+			}
+
+			if len(query.OrderBy) != len(tt.expectedOrder) {
+				t.Fatalf("Expected %d sort keys, got %d", len(tt.expectedOrder), len(query.OrderBy))
+			}
+			for i, name := range tt.expectedOrder {
+				if query.OrderBy[i].Expr.String() != name {
+					t.Errorf("Sort key %d: expected %s, got %s", i, name, query.OrderBy[i].Expr)
+				}
+			}
+		})
+	}
+}
+
+func TestParseQuery_GroupByErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Ungrouped column",
+			queryStr:    "SELECT from, value FROM TRANSACTIONS(" + testAddress + ") GROUP BY from",
+			expectedErr: "column value must appear in GROUP BY",
+		},
+		{
+			name:        "Star with aggregate",
+			queryStr:    "SELECT *, COUNT(*) FROM TRANSACTIONS(" + testAddress + ")",
+			expectedErr: "SELECT * cannot be combined with GROUP BY",
+		},
+		{
+			name:        "Short form",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " GROUP BY from",
+			expectedErr: "GROUP BY and HAVING require a select list",
+		},
+		{
+			name:        "Aggregate in WHERE",
+			queryStr:    "SELECT from, COUNT(*) FROM TRANSACTIONS(" + testAddress + ") WHERE COUNT(*) > 1 GROUP BY from",
+			expectedErr: "aggregate COUNT(*) is not allowed here",
+		},
+		{
+			name:        "Unknown function",
+			queryStr:    "SELECT MEDIAN(value) FROM TRANSACTIONS(" + testAddress + ")",
+			expectedErr: "unknown function: MEDIAN",
+		},
+		{
+			name:        "SUM of an address",
+			queryStr:    "SELECT SUM(to) FROM TRANSACTIONS(" + testAddress + ")",
+			expectedErr: "SUM is not supported for address column to",
+		},
+		{
+			name:        "SUM(*)",
+			queryStr:    "SELECT SUM(*) FROM TRANSACTIONS(" + testAddress + ")",
+			expectedErr: "only COUNT accepts *",
+		},
+		{
+			name:        "Nested aggregates",
+			queryStr:    "SELECT SUM(COUNT(*)) FROM TRANSACTIONS(" + testAddress + ")",
+			expectedErr: "aggregate functions cannot be nested",
+		},
+		{
+			name:        "Group without BY",
+			queryStr:    "SELECT from, COUNT(*) FROM TRANSACTIONS(" + testAddress + ") GROUP from",
+			expectedErr: "expected BY after GROUP",
+		},
+		{
+			name:        "Unclosed call",
+			queryStr:    "SELECT COUNT(* FROM TRANSACTIONS(" + testAddress + ")",
+			expectedErr: "expected ')' to close COUNT",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// substituteAliases replaces names from aliases with AliasLit nodes wherever an
+// address may be written: the FROM arguments, returned as a new slice, and the
+// arguments of a CALL, the tokens of a TOKEN clause, the SENDER and the operands of
+// WHERE and HAVING, rewritten in place. Column names always win over
+// aliases, so a contract named like a column can only be used in FROM.
+func substituteAliases(stmt *SelectStmt, args []Expr, schema queries.Schema, aliases map[string]common.Address) []Expr {
+	if len(aliases) == 0 {
+		return args
+	}
+
+	substitute := func(expr Expr) Expr {
+		if ident, ok := expr.(*Ident); ok {
+			if address, ok := aliases[strings.ToLower(ident.Name)]; ok {
+				return &AliasLit{Pos: ident.Pos, Name: ident.Name, Address: address}
+			}
+		}
+		return expr
+	}
+
+	substituted := make([]Expr, len(args))
+	for i, arg := range args {
+		substituted[i] = substitute(arg)
+	}
+	if stmt.Call != nil {
+		for i, arg := range stmt.Call.Args {
+			stmt.Call.Args[i] = substitute(arg)
+		}
+	}
+	if stmt.Token != nil {
+		for i, token := range stmt.Token.Tokens {
+			stmt.Token.Tokens[i] = substitute(token)
+		}
+	}
+	if stmt.Collection != nil {
+		for i, collection := range stmt.Collection.Collections {
+			stmt.Collection.Collections[i] = substitute(collection)
+		}
+	}
+	if stmt.Sender != nil {
+		stmt.Sender.Address = substitute(stmt.Sender.Address)
+	}
+
+	operand := func(expr Expr) Expr {
+		if ident, ok := expr.(*Ident); ok {
+			if _, isColumn := schema.Lookup(ident.Name); isColumn {
+				return expr
+			}
+		}
+		return substitute(expr)
+	}
+	var walk func(expr Expr)
+	walk = func(expr Expr) {
+		switch e := expr.(type) {
+		case *BinaryExpr:
+			if e.Op == "AND" || e.Op == "OR" {
+				walk(e.Left)
+				walk(e.Right)
+				return
+			}
+			e.Left, e.Right = operand(e.Left), operand(e.Right)
+		case *UnaryExpr:
+			walk(e.X)
+		case *InExpr:
+			for i, item := range e.List {
+				e.List[i] = operand(item)
+			}
+		}
+	}
+	walk(stmt.Where)
+	walk(stmt.Having)
+
+	return substituted
+}
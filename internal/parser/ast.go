@@ -0,0 +1,257 @@
+package parser
+
+import "github.com/ethereum/go-ethereum/common"
+
+// Node is implemented by every AST node
+type Node interface {
+	Position() Position
+}
+
+// Expr is an AST node that produces a value
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// SelectStmt is the root of a parsed EVMQL query, in either the short form
+//
+//	SELECT <method> FROM <address> [<blocks>] [WHERE <predicate>]
+//
+// or the projection form
+//
+//	SELECT <field>, ... FROM <method>(<address>) [<blocks>] [WHERE <predicate>]
+//
+// STORAGE queries name their slots before the blocks, as in
+// "SELECT STORAGE FROM <address> SLOT <slot>, ... [<blocks>]", token balances name their
+// tokens there, as in "SELECT TOKEN_BALANCE FROM <holder> TOKEN (<token>, ...)", NFT
+// queries their collections or token IDs, as in "SELECT NFTS FROM <wallet> COLLECTION
+// <collection>" and "SELECT OWNER FROM <collection> TOKEN_ID <id>", state diffs their
+// transactions, as in "SELECT STATE_DIFF FROM TX <hash>", and contract calls name their
+// function after SELECT, as in "SELECT CALL balanceOf(<holder>) FROM <contract>" and
+// "SELECT GAS_ESTIMATE FOR CALL transfer(<to>, <amount>) FROM <contract> SENDER <from>".
+// Any form may end with [GROUP BY <column>, ... [HAVING <predicate>]]
+// [ORDER BY <key>, ...] [LIMIT <n>] [OFFSET <m>].
+type SelectStmt struct {
+	Pos        Position
+	Call       *CallClause
+	Fields     []*SelectField
+	From       *FromClause
+	Slot       *SlotClause
+	Token      *TokenClause
+	Collection *CollectionClause
+	TokenID    *TokenIDClause
+	Tx         *TxClause
+	Sender     *SenderClause
+	Block      *BlockClause
+	Where      Expr
+	GroupBy    []Expr
+	Having     Expr
+	OrderBy    []*OrderItem
+	Limit      Expr
+	Offset     Expr
+}
+
+// SelectField is one entry of the select list: "*" or an expression with an optional alias
+type SelectField struct {
+	Pos   Position
+	Star  bool
+	Expr  Expr
+	Alias *Ident
+}
+
+// FromClause is the data source of a query. Method is set for "<method>(<args>)"
+// sources; in the short form only Args holds the address operands.
+type FromClause struct {
+	Pos    Position
+	Method *Ident
+	Call   bool
+	Args   []Expr
+}
+
+// CallClause is the function of a CALL query, named as in "CALL balanceOf(<holder>)"
+// or declared with its return types, as in "CALL 'balanceOf(address)(uint256)'(<holder>)"
+type CallClause struct {
+	Pos      Position
+	Function Expr // an *Ident or a *StringLit
+	Args     []Expr
+}
+
+// TokenClause lists the tokens read by a TOKEN_BALANCE query: TOKEN (<token>, ...),
+// with the parentheses optional
+type TokenClause struct {
+	Pos    Position
+	Tokens []Expr
+}
+
+// CollectionClause lists the NFT collections searched by an NFTS query:
+// COLLECTION <collection>, ...
+type CollectionClause struct {
+	Pos         Position
+	Collections []Expr
+}
+
+// TokenIDClause lists the token IDs looked up by an OWNER query: TOKEN_ID <id>, ...
+type TokenIDClause struct {
+	Pos Position
+	IDs []Expr
+}
+
+// TxClause lists the transactions whose state changes a STATE_DIFF query reads:
+// TX <hash>, ..., with parentheses optional
+type TxClause struct {
+	Pos    Position
+	Hashes []Expr
+}
+
+// SenderClause is the account a call is made from: SENDER <address>
+type SenderClause struct {
+	Pos     Position
+	Address Expr
+}
+
+// SlotClause lists the storage slots read by a STORAGE query: SLOT <slot>, ...
+type SlotClause struct {
+	Pos   Position
+	Slots []Expr
+}
+
+// BlockClause is the block range of a query, written as one of
+//
+//	BLOCK <from> [[TO] <to>]
+//	SINCE <from> [UNTIL <to>]
+//	UNTIL <to>
+//	LAST <n> BLOCKS
+//	AT <time>
+//	BETWEEN <time> AND <time>
+//
+// Bounds that were not written are nil.
+type BlockClause struct {
+	Pos     Position
+	Keyword string // BLOCK, SINCE, UNTIL, LAST, AT or BETWEEN, upper-cased
+	From    Expr
+	To      Expr
+	Last    Expr
+}
+
+// OrderItem is one key of an ORDER BY clause
+type OrderItem struct {
+	Pos  Position
+	Expr Expr
+	Desc bool
+}
+
+// Ident is a bare word such as a method name, keyword or column
+type Ident struct {
+	Pos  Position
+	Name string
+}
+
+// NumberLit is a decimal number literal with an optional denomination such as "gwei"
+type NumberLit struct {
+	Pos   Position
+	Value string
+	Unit  string
+}
+
+// HexLit is a 0x-prefixed literal such as an address, hash or block number
+type HexLit struct {
+	Pos   Position
+	Value string
+}
+
+// StringLit is a quoted string literal
+type StringLit struct {
+	Pos   Position
+	Value string
+}
+
+// AliasLit is an address written as a name from the address book or the network's
+// contracts, such as usdc
+type AliasLit struct {
+	Pos     Position
+	Name    string
+	Address common.Address
+}
+
+// CallExpr is a function call such as COUNT(*) or SUM(value)
+type CallExpr struct {
+	Pos  Position
+	Func *Ident
+	Args []Expr
+	Star bool // the argument list is "*"
+}
+
+// UnaryExpr is a prefix operator applied to an expression, such as "-1" or "NOT x"
+type UnaryExpr struct {
+	Pos Position
+	Op  string
+	X   Expr
+}
+
+// BinaryExpr is a comparison (=, !=, <, >, <=, >=) or logical (AND, OR) operation
+type BinaryExpr struct {
+	Pos   Position
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+// InExpr is "x [NOT] IN (a, b, ...)"
+type InExpr struct {
+	Pos  Position
+	X    Expr
+	List []Expr
+	Not  bool
+}
+
+// BetweenExpr is "x [NOT] BETWEEN low AND high"
+type BetweenExpr struct {
+	Pos  Position
+	X    Expr
+	Low  Expr
+	High Expr
+	Not  bool
+}
+
+// IsNullExpr is "x IS [NOT] NULL"
+type IsNullExpr struct {
+	Pos Position
+	X   Expr
+	Not bool
+}
+
+func (n *SelectStmt) Position() Position       { return n.Pos }
+func (n *SelectField) Position() Position      { return n.Pos }
+func (n *FromClause) Position() Position       { return n.Pos }
+func (n *CallClause) Position() Position       { return n.Pos }
+func (n *SlotClause) Position() Position       { return n.Pos }
+func (n *TokenClause) Position() Position      { return n.Pos }
+func (n *CollectionClause) Position() Position { return n.Pos }
+func (n *SenderClause) Position() Position     { return n.Pos }
+func (n *TxClause) Position() Position         { return n.Pos }
+func (n *TokenIDClause) Position() Position    { return n.Pos }
+func (n *BlockClause) Position() Position      { return n.Pos }
+func (n *OrderItem) Position() Position        { return n.Pos }
+func (n *Ident) Position() Position            { return n.Pos }
+func (n *NumberLit) Position() Position        { return n.Pos }
+func (n *HexLit) Position() Position           { return n.Pos }
+func (n *StringLit) Position() Position        { return n.Pos }
+func (n *AliasLit) Position() Position         { return n.Pos }
+func (n *CallExpr) Position() Position         { return n.Pos }
+func (n *UnaryExpr) Position() Position        { return n.Pos }
+func (n *BinaryExpr) Position() Position       { return n.Pos }
+func (n *InExpr) Position() Position           { return n.Pos }
+func (n *BetweenExpr) Position() Position      { return n.Pos }
+func (n *IsNullExpr) Position() Position       { return n.Pos }
+
+func (*Ident) exprNode()       {}
+func (*NumberLit) exprNode()   {}
+func (*HexLit) exprNode()      {}
+func (*StringLit) exprNode()   {}
+func (*AliasLit) exprNode()    {}
+func (*CallExpr) exprNode()    {}
+func (*UnaryExpr) exprNode()   {}
+func (*BinaryExpr) exprNode()  {}
+func (*InExpr) exprNode()      {}
+func (*BetweenExpr) exprNode() {}
+func (*IsNullExpr) exprNode()  {}
@@ -0,0 +1,643 @@
+package parser
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// maxBlockRange bounds the number of blocks a single query may span
+const maxBlockRange = 10000
+
+// maxResultRows bounds LIMIT and OFFSET; the executor never returns more rows than this
+const maxResultRows = 10000
+
+// validMethods lists the methods accepted after SELECT
+var validMethods = map[string]bool{
+	"BALANCE":            true,
+	"BLOCKS":             true,
+	"CALL":               true,
+	"CODE":               true,
+	"FEES":               true,
+	"GAS_ESTIMATE":       true,
+	"INTERNAL_TRANSFERS": true,
+	"LOGS":               true,
+	"NFTS":               true,
+	"NONCE":              true,
+	"OWNER":              true,
+	"PENDING":            true,
+	"RECEIPTS":           true,
+	"STATE_DIFF":         true,
+	"STORAGE":            true,
+	"TOKEN_BALANCE":      true,
+	"TOKEN_INFO":         true,
+	"TRACES":             true,
+	"TRANSACTIONS":       true,
+	"TRANSFERS":          true,
+}
+
+// addresslessMethods read chain-wide data and take no address
+var addresslessMethods = map[string]bool{
+	"BLOCKS": true,
+	"FEES":   true,
+}
+
+// buildQuery lowers a parsed statement into an executable Query, validating it on the way
+// With showGasEstimates, "SELECT CALL ..." also selects the optional gas_estimate column.
+func buildQuery(stmt *SelectStmt, aliases map[string]common.Address, abis map[common.Address]abi.ABI, showGasEstimates bool) (*queries.Query, error) {
+	methodIdent, args, fields, err := resolveForm(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	method := strings.ToUpper(methodIdent.Name)
+	if !validMethods[method] {
+		return nil, errorAt(methodIdent, "unsupported method: %s (supported: %s)", method, supportedMethods())
+	}
+	schema := queries.Schemas[method]
+
+	args = substituteAliases(stmt, args, schema, aliases)
+
+	query := &queries.Query{
+		Type:   "SELECT",
+		Method: method,
+	}
+
+	// LOGS(<address>, 'Transfer') and TRANSACTIONS(<address>, 'transfer') select
+	// and decode one event or function, as does PENDING like TRANSACTIONS
+	var declaration *StringLit
+	if method == "LOGS" || method == "TRANSACTIONS" || method == "PENDING" {
+		declaration, args = splitDeclarationArg(args)
+	}
+
+	if addresslessMethods[method] && len(args) > 0 {
+		return nil, errorAt(args[0], "%s does not take an address", method)
+	}
+	if stmt.From.Call && len(args) == 0 && declaration == nil && !addresslessMethods[method] {
+		return nil, errorAt(stmt.From, "%s expects exactly one address argument or an address list, got 0", method)
+	}
+	addresses, names, err := buildAddresses(args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case declaration != nil && method == "LOGS":
+		if query.Event, err = buildEvent(declaration, addresses, abis); err != nil {
+			return nil, err
+		}
+		schema = query.Schema()
+	case declaration != nil:
+		if query.Function, err = buildFunction(declaration, addresses, abis); err != nil {
+			return nil, err
+		}
+		schema = query.Schema()
+	}
+
+	switch {
+	case stmt.Call != nil && method != "CALL" && method != "GAS_ESTIMATE":
+		return nil, errorAt(stmt.Call, "a function is only called by SELECT CALL <function>(<args>) FROM <contract>")
+	case stmt.Call == nil && method == "CALL":
+		return nil, errorAt(stmt.From, "CALL needs a function: use SELECT CALL <function>(<args>) FROM <contract>")
+	case stmt.Call == nil && method == "GAS_ESTIMATE":
+		return nil, errorAt(stmt.From, "GAS_ESTIMATE needs a call: use SELECT GAS_ESTIMATE FOR CALL <function>(<args>) FROM <contract>")
+	case stmt.Call != nil:
+		if query.Call, query.CallArgs, err = buildCall(stmt.Call, addresses, abis); err != nil {
+			return nil, err
+		}
+		schema = query.Schema()
+	}
+
+	grouping, err := buildGrouping(stmt, fields, schema)
+	if err != nil {
+		return nil, err
+	}
+	fieldSchema := schema
+	if grouping != nil {
+		fieldSchema = grouping.schema
+		query.GroupBy = grouping.groupBy
+		query.Aggregates = grouping.aggregates
+	}
+
+	selectItems, err := buildFields(fields, fieldSchema)
+	if err != nil {
+		return nil, err
+	}
+	if method == "CALL" && showGasEstimates && len(fields) == 0 && grouping == nil {
+		if selectItems, err = buildFields([]*SelectField{{Star: true}}, schema); err != nil {
+			return nil, err
+		}
+		selectItems = append(selectItems, queries.SelectItem{Name: "gas_estimate", Expr: &queries.ColumnRef{Name: "gas_estimate"}, Type: queries.TypeInt})
+	}
+	query.Fields = selectItems
+
+	switch {
+	case stmt.Slot != nil && method != "STORAGE":
+		return nil, errorAt(stmt.Slot, "SLOT is only supported for STORAGE")
+	case stmt.Slot == nil && method == "STORAGE":
+		return nil, errorAt(stmt.From, "STORAGE needs a slot: use SELECT STORAGE FROM <address> SLOT <slot>")
+	case stmt.Slot != nil:
+		if query.Slots, err = buildSlots(stmt.Slot); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case stmt.Token != nil && method != "TOKEN_BALANCE":
+		return nil, errorAt(stmt.Token, "TOKEN is only supported for TOKEN_BALANCE")
+	case stmt.Token == nil && method == "TOKEN_BALANCE":
+		return nil, errorAt(stmt.From, "TOKEN_BALANCE needs a token: use SELECT TOKEN_BALANCE FROM <holder> TOKEN (<token>, ...)")
+	case stmt.Token != nil:
+		if query.Tokens, query.TokenNames, err = buildAddresses(stmt.Token.Tokens); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case stmt.Collection != nil && method != "NFTS":
+		return nil, errorAt(stmt.Collection, "COLLECTION is only supported for NFTS")
+	case stmt.Collection == nil && method == "NFTS":
+		return nil, errorAt(stmt.From, "NFTS needs a collection: use SELECT NFTS FROM <wallet> COLLECTION <collection>")
+	case stmt.Collection != nil:
+		if query.Collections, query.CollectionNames, err = buildAddresses(stmt.Collection.Collections); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case stmt.TokenID != nil && method != "OWNER":
+		return nil, errorAt(stmt.TokenID, "TOKEN_ID is only supported for OWNER")
+	case stmt.TokenID == nil && method == "OWNER":
+		return nil, errorAt(stmt.From, "OWNER needs a token ID: use SELECT OWNER FROM <collection> TOKEN_ID <id>")
+	case stmt.TokenID != nil:
+		if query.TokenIDs, err = buildTokenIDs(stmt.TokenID); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case stmt.Sender != nil && method != "CALL" && method != "GAS_ESTIMATE":
+		return nil, errorAt(stmt.Sender, "SENDER is only supported for CALL and GAS_ESTIMATE")
+	case stmt.Sender != nil:
+		if name, ok := ensName(stmt.Sender.Address); ok {
+			query.SenderName = name
+			break
+		}
+		sender, err := buildAddress(stmt.Sender.Address)
+		if err != nil {
+			return nil, err
+		}
+		query.Sender = &sender
+	}
+
+	switch {
+	case stmt.Tx != nil && method != "STATE_DIFF":
+		return nil, errorAt(stmt.Tx, "TX is only supported for STATE_DIFF")
+	case stmt.Tx != nil && stmt.Block != nil:
+		return nil, errorAt(stmt.Block, "STATE_DIFF reads either the transactions of TX or a block range, not both")
+	case stmt.Tx != nil:
+		if query.TxHashes, err = buildTxHashes(stmt.Tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if stmt.Block != nil {
+		if err := buildBlockRange(stmt.Block, method, query); err != nil {
+			return nil, err
+		}
+	}
+
+	if stmt.Where != nil {
+		where, err := buildPredicate(stmt.Where, schema)
+		if err != nil {
+			return nil, err
+		}
+		query.Where = where
+	}
+
+	// "SELECT * FROM LOGS WHERE address IN (...)" takes its addresses from the filter
+	if len(addresses) == 0 && len(names) == 0 && !addresslessMethods[method] {
+		addresses, names = whereAddresses(query.Where)
+	}
+	if len(addresses) == 0 && len(names) == 0 && !addresslessMethods[method] {
+		switch method {
+		case "STATE_DIFF":
+			// State diffs are narrowed to an account, or report every account touched
+		case "LOGS":
+			// Logs of every contract are only requested by topic, as nodes would
+			// otherwise return every log in the range
+			if (query.Event == nil || query.Event.Anonymous) && !hasTopicCondition(query.Where) {
+				return nil, errorAt(stmt.From, "LOGS needs an address or an event or topic condition: use LOGS(<address>), WHERE address IN (...) or WHERE event = 'Transfer(address,address,uint256)'")
+			}
+		default:
+			return nil, errorAt(stmt.From, "%s needs an address: use %s(<address>), FROM (<address>, ...) or WHERE address IN (...)", method, method)
+		}
+		query.AnyAddress = true
+	}
+	if len(addresses) > 0 {
+		query.Address, query.Addresses = addresses[0], addresses
+	}
+	query.Names = names
+
+	if stmt.Having != nil {
+		having, err := buildPredicate(stmt.Having, grouping.schema)
+		if err != nil {
+			return nil, err
+		}
+		query.Having = having
+	}
+
+	orderBy, err := buildOrderBy(stmt.OrderBy, selectItems, fieldSchema)
+	if err != nil {
+		return nil, err
+	}
+	query.OrderBy = orderBy
+
+	if stmt.Limit != nil {
+		limit, err := buildCount(stmt.Limit, "LIMIT")
+		if err != nil {
+			return nil, err
+		}
+		if limit == 0 {
+			return nil, errorAt(stmt.Limit, "LIMIT must be a positive integer")
+		}
+		query.Limit = limit
+	}
+	if stmt.Offset != nil {
+		offset, err := buildCount(stmt.Offset, "OFFSET")
+		if err != nil {
+			return nil, err
+		}
+		query.Offset = offset
+	}
+
+	return query, nil
+}
+
+// resolveForm tells the short form "SELECT <method> FROM <address>" apart from the
+// projection form "SELECT <fields> FROM <method>(<args>)", returning the method,
+// its arguments and the projected fields (nil for all fields)
+func resolveForm(stmt *SelectStmt) (*Ident, []Expr, []*SelectField, error) {
+	from := stmt.From
+	if from.Method != nil {
+		return from.Method, from.Args, stmt.Fields, nil
+	}
+
+	// A bare method name as the source, as in "SELECT * FROM LOGS"
+	if len(from.Args) == 1 {
+		if ident, ok := from.Args[0].(*Ident); ok && validMethods[strings.ToUpper(ident.Name)] {
+			return ident, nil, stmt.Fields, nil
+		}
+	}
+
+	if len(stmt.Fields) == 1 && stmt.Fields[0].Alias == nil {
+		if ident, ok := stmt.Fields[0].Expr.(*Ident); ok {
+			return ident, from.Args, nil, nil
+		}
+	}
+
+	return nil, nil, nil, errorAt(from, "expected <method>(<address>) after FROM when selecting fields")
+}
+
+// buildFields resolves the select list against the method's schema
+func buildFields(fields []*SelectField, schema queries.Schema) ([]queries.SelectItem, error) {
+	var items []queries.SelectItem
+	for _, field := range fields {
+		if field.Star {
+			for _, f := range schema.Default() {
+				items = append(items, queries.SelectItem{Name: f.Name, Expr: &queries.ColumnRef{Name: f.Name}, Type: f.Type})
+			}
+			continue
+		}
+
+		expr, f, err := buildColumn(field.Expr, schema)
+		if err != nil {
+			return nil, err
+		}
+		name := f.Name
+		if field.Alias != nil {
+			name = field.Alias.Name
+		}
+		items = append(items, queries.SelectItem{Name: name, Expr: expr, Type: f.Type})
+	}
+	return items, nil
+}
+
+// buildOrderBy resolves sort keys, preferring select-list aliases over schema columns
+func buildOrderBy(items []*OrderItem, selectItems []queries.SelectItem, schema queries.Schema) ([]queries.OrderItem, error) {
+	var order []queries.OrderItem
+	for _, item := range items {
+		var expr queries.Expr
+		if ident, ok := item.Expr.(*Ident); ok {
+			for _, selected := range selectItems {
+				if strings.EqualFold(selected.Name, ident.Name) {
+					expr = selected.Expr
+					break
+				}
+			}
+		}
+		if expr == nil {
+			column, _, err := buildColumn(item.Expr, schema)
+			if err != nil {
+				return nil, err
+			}
+			expr = column
+		}
+		order = append(order, queries.OrderItem{Expr: expr, Desc: item.Desc})
+	}
+	return order, nil
+}
+
+// buildCount converts a LIMIT or OFFSET operand into a non-negative row count
+func buildCount(expr Expr, clause string) (int, error) {
+	if lit, ok := expr.(*NumberLit); ok && lit.Unit == "" {
+		if n, err := strconv.Atoi(lit.Value); err == nil && n <= maxResultRows {
+			return n, nil
+		}
+	}
+	return 0, errorAt(expr, "invalid %s: %s (must be a non-negative integer up to %d)", clause, TruncateForDisplay(nodeText(expr), 20), maxResultRows)
+}
+
+// buildAddresses validates a list of address operands, separating ENS names from
+// hex addresses and dropping repeats
+func buildAddresses(exprs []Expr) ([]common.Address, []string, error) {
+	var addresses []common.Address
+	var names []string
+	seen := make(map[string]bool)
+	for _, expr := range exprs {
+		if name, ok := ensName(expr); ok {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			continue
+		}
+
+		address, err := buildAddress(expr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !seen[address.Hex()] {
+			seen[address.Hex()] = true
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, names, nil
+}
+
+// whereAddresses returns the addresses and ENS names named by a top-level
+// "address = x" or "address IN (...)" condition of where, if there is one
+func whereAddresses(where queries.Expr) ([]common.Address, []string) {
+	for _, term := range queries.Conjuncts(where) {
+		column, list, ok := queries.EqualityTerm(term)
+		if !ok || column != "address" {
+			continue
+		}
+		var addresses []common.Address
+		var names []string
+		for _, item := range list {
+			literal, ok := item.(*queries.Literal)
+			if !ok {
+				addresses, names = nil, nil
+				break
+			}
+			switch value := literal.Value.(type) {
+			case common.Address:
+				addresses = append(addresses, value)
+			case queries.ENSName:
+				names = append(names, string(value))
+			}
+		}
+		if len(addresses) > 0 || len(names) > 0 {
+			return addresses, names
+		}
+	}
+	return nil, nil
+}
+
+// hasTopicCondition reports whether where has a top-level "topicN = x" or
+// "topicN IN (...)" condition that node filters can match on
+func hasTopicCondition(where queries.Expr) bool {
+	for _, term := range queries.Conjuncts(where) {
+		column, list, ok := queries.EqualityTerm(term)
+		if !ok || !isTopic(column) {
+			continue
+		}
+		literals := true
+		for _, item := range list {
+			if _, ok := item.(*queries.Literal); !ok {
+				literals = false
+			}
+		}
+		if literals {
+			return true
+		}
+	}
+	return false
+}
+
+// buildSlots resolves the storage slots of a SLOT clause, written as integers or as
+// hex values of up to 32 bytes
+func buildSlots(clause *SlotClause) ([]common.Hash, error) {
+	var slots []common.Hash
+	for _, expr := range clause.Slots {
+		slot, ok := intLiteral(expr)
+		if !ok || slot.Sign() < 0 || slot.BitLen() > 256 {
+			return nil, errorAt(expr, "invalid storage slot: %s (must be a non-negative integer or a hex value of up to 32 bytes)", TruncateForDisplay(nodeText(expr), 20))
+		}
+		slots = append(slots, common.BigToHash(slot))
+	}
+	return slots, nil
+}
+
+// buildTokenIDs resolves the token IDs of a TOKEN_ID clause, written as integers or
+// as hex values of up to 32 bytes, dropping repeats
+func buildTokenIDs(clause *TokenIDClause) ([]*big.Int, error) {
+	var ids []*big.Int
+	seen := make(map[string]bool)
+	for _, expr := range clause.IDs {
+		id, ok := intLiteral(expr)
+		if !ok || id.Sign() < 0 || id.BitLen() > 256 {
+			return nil, errorAt(expr, "invalid token ID: %s (must be a non-negative integer of up to 256 bits)", TruncateForDisplay(nodeText(expr), 20))
+		}
+		if !seen[id.String()] {
+			seen[id.String()] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// buildTxHashes resolves the transaction hashes of a TX clause, dropping repeats
+func buildTxHashes(clause *TxClause) ([]common.Hash, error) {
+	var hashes []common.Hash
+	seen := make(map[common.Hash]bool)
+	for _, expr := range clause.Hashes {
+		text, ok := hexText(expr)
+		if !ok || len(text) != 2+2*common.HashLength {
+			return nil, errorAt(expr, "invalid transaction hash: %s (must be 66 character hex starting with 0x)", TruncateForDisplay(nodeText(expr), 70))
+		}
+		b, err := hexutil.Decode(text)
+		if err != nil {
+			return nil, errorAt(expr, "invalid transaction hash: %s (must be 66 character hex starting with 0x)", TruncateForDisplay(nodeText(expr), 70))
+		}
+		hash := common.BytesToHash(b)
+		if !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+// buildAddress validates an address operand
+func buildAddress(expr Expr) (common.Address, error) {
+	if alias, ok := expr.(*AliasLit); ok {
+		return alias.Address, nil
+	}
+	text := nodeText(expr)
+	address := NormalizeAddress(text)
+	if !ValidateAddressFormat(address) {
+		return common.Address{}, errorAt(expr, "invalid Ethereum address format: %s", TruncateForDisplay(text, 50))
+	}
+	if !common.IsHexAddress(address) {
+		return common.Address{}, errorAt(expr, "invalid Ethereum address: %s (must be 42 character hex starting with 0x)", TruncateForDisplay(address, 50))
+	}
+	return common.HexToAddress(address), nil
+}
+
+// buildBlockRange validates a block range clause and sets the bounds of query.
+// BLOCK with a single bound selects one block, except for LOGS which always takes a
+// range. Open ends are left for the executor: SINCE runs up to the latest block,
+// while UNTIL and LAST scan a window ending at their block. AT and BETWEEN bound the
+// range by time, and are resolved to blocks by the executor. Ranges are only checked
+// here when both ends are concrete numbers; tags are checked once resolved.
+func buildBlockRange(clause *BlockClause, method string, query *queries.Query) error {
+	if method == "PENDING" {
+		return errorAt(clause, "PENDING reads the transaction pool and takes no block range")
+	}
+	if queries.IsStateMethod(method) && clause.Keyword != "BLOCK" && clause.Keyword != "AT" {
+		return errorAt(clause, "%s reads a single block; use BLOCK <number> or AT <time> instead of %s", method, clause.Keyword)
+	}
+
+	switch clause.Keyword {
+	case "AT":
+		at, err := buildTime(clause.To, "AT")
+		if err != nil {
+			return err
+		}
+		query.ToTime = at
+		query.BlockWindow = 1
+		return nil
+
+	case "BETWEEN":
+		from, err := buildTime(clause.From, "start")
+		if err != nil {
+			return err
+		}
+		to, err := buildTime(clause.To, "end")
+		if err != nil {
+			return err
+		}
+		if from.After(*to) {
+			return errorAt(clause, "start time cannot be after end time")
+		}
+		query.FromTime, query.ToTime = from, to
+		return nil
+	}
+
+	if clause.Last != nil {
+		count, ok := intLiteral(clause.Last)
+		if !ok || count.Sign() <= 0 || !count.IsInt64() {
+			return errorAt(clause.Last, "invalid block count: %s (must be a positive integer)", TruncateForDisplay(nodeText(clause.Last), 20))
+		}
+		if count.Int64()-1 > maxBlockRange {
+			return errorAt(clause.Last, "block range too large: %d blocks (maximum: %d)", count.Int64()-1, maxBlockRange)
+		}
+		query.ToBlock = latestBlock()
+		query.BlockWindow = count.Int64()
+		return nil
+	}
+
+	var fromBlock, toBlock *big.Int
+	var err error
+	if clause.From != nil {
+		if fromBlock, err = buildBlockNumber(clause.From, "from"); err != nil {
+			return err
+		}
+	}
+	if clause.To != nil {
+		if toBlock, err = buildBlockNumber(clause.To, "to"); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case clause.Keyword == "SINCE" && toBlock == nil:
+		toBlock = latestBlock()
+	case clause.Keyword == "BLOCK" && toBlock == nil:
+		if method == "LOGS" {
+			return errorAt(clause, "BLOCK keyword requires both from and to block numbers")
+		}
+		toBlock = fromBlock
+	case queries.IsStateMethod(method) && fromBlock.Cmp(toBlock) != 0:
+		return errorAt(clause.To, "%s reads a single block; use BLOCK <number> without a second block", method)
+	}
+	query.FromBlock, query.ToBlock = fromBlock, toBlock
+
+	if fromBlock == nil || queries.IsBlockTag(fromBlock) || queries.IsBlockTag(toBlock) {
+		return nil
+	}
+
+	if fromBlock.Cmp(toBlock) > 0 {
+		return errorAt(clause, "from block cannot be greater than to block")
+	}
+
+	blockRange := new(big.Int).Sub(toBlock, fromBlock)
+	if blockRange.Cmp(big.NewInt(maxBlockRange)) > 0 {
+		return errorAt(clause, "block range too large: %d blocks (maximum: %d)", blockRange.Int64(), maxBlockRange)
+	}
+
+	return nil
+}
+
+func latestBlock() *big.Int {
+	latest, _ := queries.BlockTag("latest")
+	return latest
+}
+
+// buildBlockNumber converts a block bound into a non-negative integer or a block tag
+func buildBlockNumber(expr Expr, which string) (*big.Int, error) {
+	switch e := expr.(type) {
+	case *NumberLit:
+		if number, ok := new(big.Int).SetString(e.Value, 10); ok && e.Unit == "" {
+			return number, nil
+		}
+	case *HexLit:
+		if len(e.Value) > 2 {
+			if number, ok := new(big.Int).SetString(e.Value[2:], 16); ok {
+				return number, nil
+			}
+		}
+	case *Ident:
+		if number, ok := queries.BlockTag(e.Name); ok {
+			return number, nil
+		}
+	}
+	return nil, errorAt(expr, "invalid %s block: %s (must be non-negative integer, hex number or one of latest, pending, safe, finalized, earliest)", which, TruncateForDisplay(nodeText(expr), 20))
+}
+
+func supportedMethods() string {
+	methods := make([]string, 0, len(validMethods))
+	for method := range validMethods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
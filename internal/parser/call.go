@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// callModifiers may stand between the parameters and the return types of a
+// declaration, as in "balanceOf(address owner) external view returns (uint256)"
+var callModifiers = map[string]bool{
+	"external": true,
+	"public":   true,
+	"view":     true,
+	"pure":     true,
+	"returns":  true,
+}
+
+// buildCall resolves the function of "CALL <function>(<args>)" and converts its
+// arguments. The function is given either as a declaration with its return types or
+// by name, looked up in the ABIs of the addresses among the functions taking as many
+// arguments as were given.
+func buildCall(clause *CallClause, addresses []common.Address, abis map[common.Address]abi.ABI) (*abi.Method, []interface{}, error) {
+	method, err := buildCallFunction(clause, addresses, abis)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(clause.Args) != len(method.Inputs) {
+		return nil, nil, errorAt(clause, "%s takes %d arguments, got %d", method.Sig, len(method.Inputs), len(clause.Args))
+	}
+
+	args := make([]interface{}, len(clause.Args))
+	for i, expr := range clause.Args {
+		input := method.Inputs[i]
+		value, ok := callArg(expr, input.Type)
+		if !ok {
+			return nil, nil, errorAt(expr, "expected %s value for argument %s of %s", input.Type, queries.ArgName(input, i), method.RawName)
+		}
+		args[i] = value
+	}
+	return method, args, nil
+}
+
+func buildCallFunction(clause *CallClause, addresses []common.Address, abis map[common.Address]abi.ABI) (*abi.Method, error) {
+	lit, ok := clause.Function.(*StringLit)
+	if !ok {
+		ident := clause.Function.(*Ident)
+		lit = &StringLit{Pos: ident.Pos, Value: ident.Name}
+	}
+
+	if strings.Contains(lit.Value, "(") {
+		name, inputs, outputs, ok := parseCallDeclaration(lit.Value)
+		if !ok {
+			return nil, errorAt(lit, "invalid function declaration: %s (expected a form such as '%s')", TruncateForDisplay(lit.Value, 60), declarationExamples["call"])
+		}
+		method := abi.NewMethod(name, name, abi.Function, "view", false, false, inputs, outputs)
+		return &method, nil
+	}
+
+	address, signature, err := lookupDeclaration(lit, "function", declarationExamples["call"], addresses, abis, func(contract abi.ABI, name string) []string {
+		var signatures []string
+		for _, method := range contract.Methods {
+			if method.RawName == name && len(method.Inputs) == len(clause.Args) {
+				signatures = append(signatures, method.Sig)
+			}
+		}
+		return signatures
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, method := range abis[address].Methods {
+		if method.Sig == signature {
+			return &method, nil
+		}
+	}
+	return nil, errorAt(lit, "function %s is not in the ABI of %s", signature, address.Hex())
+}
+
+// parseCallDeclaration parses a function declaration with optional return types,
+// written either as "balanceOf(address)(uint256)" or in Solidity's form,
+// "function balanceOf(address owner) external view returns (uint256)"
+func parseCallDeclaration(text string) (string, abi.Arguments, abi.Arguments, bool) {
+	text = strings.TrimSpace(text)
+	text = strings.TrimSpace(strings.TrimPrefix(text, "function "))
+	open := strings.Index(text, "(")
+	if open <= 0 {
+		return "", nil, nil, false
+	}
+	end := closingParen(text[open:])
+	if end < 0 {
+		return "", nil, nil, false
+	}
+	end += open
+
+	name, inputs, ok := parseDeclaration(text[:end+1], "function", false)
+	if !ok {
+		return "", nil, nil, false
+	}
+
+	rest := strings.TrimSpace(text[end+1:])
+	returns := strings.Index(rest, "(")
+	if returns < 0 {
+		returns = len(rest)
+	}
+	for _, word := range strings.Fields(rest[:returns]) {
+		if !callModifiers[word] {
+			return "", nil, nil, false
+		}
+	}
+	if returns == len(rest) {
+		return name, inputs, nil, true
+	}
+	_, outputs, ok := parseDeclaration(name+rest[returns:], "function", false)
+	if !ok {
+		return "", nil, nil, false
+	}
+	return name, inputs, outputs, true
+}
+
+// callArg converts a literal into the Go value the ABI package packs for an argument
+// of type t. Address arguments may be ENS names, left as queries.ENSName for the
+// executor to resolve.
+func callArg(expr Expr, t abi.Type) (interface{}, bool) {
+	switch t.T {
+	case abi.AddressTy:
+		if alias, ok := expr.(*AliasLit); ok {
+			return alias.Address, true
+		}
+		if text, ok := hexText(expr); ok && common.IsHexAddress(text) {
+			return common.HexToAddress(text), true
+		}
+		if name, ok := ensName(expr); ok {
+			return queries.ENSName(name), true
+		}
+	case abi.IntTy, abi.UintTy:
+		value, ok := intLiteral(expr)
+		if !ok || !fitsInt(value, t) {
+			return nil, false
+		}
+		if t.Size > 64 {
+			return value, true
+		}
+		// Integers of up to 64 bits are packed from the Go integer of their width
+		v := reflect.New(t.GetType()).Elem()
+		if t.T == abi.UintTy {
+			v.SetUint(value.Uint64())
+		} else {
+			v.SetInt(value.Int64())
+		}
+		return v.Interface(), true
+	case abi.BoolTy:
+		if ident, ok := expr.(*Ident); ok {
+			return boolLiteral(ident)
+		}
+	case abi.StringTy:
+		if lit, ok := expr.(*StringLit); ok {
+			return lit.Value, true
+		}
+	case abi.BytesTy:
+		if text, ok := hexText(expr); ok {
+			if b, err := hexutil.Decode(text); err == nil {
+				return b, true
+			}
+		}
+	case abi.FixedBytesTy:
+		if text, ok := hexText(expr); ok {
+			if b, err := hexutil.Decode(text); err == nil && len(b) == t.Size {
+				v := reflect.New(t.GetType()).Elem()
+				reflect.Copy(v, reflect.ValueOf(b))
+				return v.Interface(), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// fitsInt reports whether value is in the range of the integer type t
+func fitsInt(value *big.Int, t abi.Type) bool {
+	if t.T == abi.UintTy {
+		return value.Sign() >= 0 && value.BitLen() <= t.Size
+	}
+	if value.Sign() < 0 {
+		// -2^(n-1) is the smallest n-bit integer
+		return new(big.Int).Not(value).BitLen() < t.Size
+	}
+	return value.BitLen() < t.Size
+}
+
+// isResult reports whether column names one of several return values of a CALL
+func isResult(column string) bool {
+	return strings.HasPrefix(strings.ToLower(column), queries.ResultPrefix)
+}
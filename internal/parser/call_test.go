@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseQuery_Call(t *testing.T) {
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	holder := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	contract, err := abi.JSON(strings.NewReader(tokenABI))
+	if err != nil {
+		t.Fatalf("Invalid test ABI: %v", err)
+	}
+
+	parser := NewParser()
+	parser.SetAliases(map[string]common.Address{"token": token, "holder": holder})
+	parser.SetABIs(map[common.Address]abi.ABI{token: contract})
+
+	tests := []struct {
+		name             string
+		queryStr         string
+		expectedFunction string
+		expectedArgs     []interface{}
+		expectedResults  []string
+		block            string
+	}{
+		{
+			name:             "Inline declaration with return types",
+			queryStr:         "SELECT CALL 'balanceOf(address)(uint256)'(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) FROM token BLOCK 19000000",
+			expectedFunction: "balanceOf(address)",
+			expectedArgs:     []interface{}{holder},
+			expectedResults:  []string{"result"},
+			block:            "19000000",
+		},
+		{
+			name:             "Solidity declaration",
+			queryStr:         "SELECT CALL 'function getReserves() external view returns (uint112 reserve0, uint112 reserve1, uint32)' FROM token WHERE result.reserve0 > 0",
+			expectedFunction: "getReserves()",
+			expectedResults:  []string{"result", "result.reserve0", "result.reserve1", "result.arg2"},
+		},
+		{
+			name:             "Function from the ABI, picked by argument count",
+			queryStr:         "SELECT CALL mint(holder) FROM token",
+			expectedFunction: "mint(address)",
+			expectedArgs:     []interface{}{holder},
+			expectedResults:  []string{"result"},
+		},
+		{
+			name:             "Sized and ENS arguments",
+			queryStr:         "SELECT CALL 'check(address,uint8,int64,bool,bytes2,string)(bool)'(vitalik.eth, 255, -1, true, 0xbeef, 'x') FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedFunction: "check(address,uint8,int64,bool,bytes2,string)",
+			expectedArgs:     []interface{}{queries.ENSName("vitalik.eth"), uint8(255), int64(-1), true, [2]byte{0xbe, 0xef}, "x"},
+			expectedResults:  []string{"result"},
+		},
+		{
+			name:             "Wide integer",
+			queryStr:         "SELECT CALL 'allowance(uint256)(uint256)'(1 ether) FROM token",
+			expectedFunction: "allowance(uint256)",
+			expectedArgs:     []interface{}{big.NewInt(1e18)},
+			expectedResults:  []string{"result"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if query.Method != "CALL" {
+				t.Errorf("Expected method CALL, got %s", query.Method)
+			}
+			if query.Call == nil || query.Call.Sig != tt.expectedFunction {
+				t.Fatalf("Expected function %s, got %v", tt.expectedFunction, query.Call)
+			}
+			if len(query.CallArgs) != len(tt.expectedArgs) {
+				t.Fatalf("Expected arguments %v, got %v", tt.expectedArgs, query.CallArgs)
+			}
+			for i, arg := range tt.expectedArgs {
+				if !reflect.DeepEqual(query.CallArgs[i], arg) {
+					t.Errorf("Argument %d: expected %#v, got %#v", i, arg, query.CallArgs[i])
+				}
+			}
+
+			var results []string
+			for _, name := range query.Schema().Names() {
+				if name == "result" || isResult(name) {
+					results = append(results, name)
+				}
+			}
+			if !reflect.DeepEqual(results, tt.expectedResults) {
+				t.Errorf("Expected result columns %v, got %v", tt.expectedResults, results)
+			}
+			if got := blockString(query.FromBlock); got != tt.block {
+				t.Errorf("Expected block %q, got %q", tt.block, got)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Missing function",
+			queryStr:    "SELECT CALL FROM token",
+			expectedErr: "CALL needs a function",
+		},
+		{
+			name:        "Function without an ABI",
+			queryStr:    "SELECT CALL balanceOf(holder) FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedErr: "such as 'balanceOf(address)(uint256)'",
+		},
+		{
+			name:        "No overload takes the arguments",
+			queryStr:    "SELECT CALL mint() FROM token",
+			expectedErr: "function mint is not in the ABI of " + token.Hex(),
+		},
+		{
+			name:        "Wrong argument count",
+			queryStr:    "SELECT CALL 'balanceOf(address)(uint256)'(holder, 1) FROM token",
+			expectedErr: "balanceOf(address) takes 1 arguments, got 2",
+		},
+		{
+			name:        "Argument out of range",
+			queryStr:    "SELECT CALL 'f(int8)(bool)'(128) FROM token",
+			expectedErr: "expected int8 value for argument arg0 of f",
+		},
+		{
+			name:        "Invalid declaration",
+			queryStr:    "SELECT CALL 'balanceOf(address) payable (uint256)' FROM token",
+			expectedErr: "invalid function declaration",
+		},
+		{
+			name:        "Block range",
+			queryStr:    "SELECT CALL 'totalSupply()(uint256)' FROM token LAST 10 BLOCKS",
+			expectedErr: "CALL reads a single block",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParseQuery_GasEstimate(t *testing.T) {
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	holder := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	contract, err := abi.JSON(strings.NewReader(tokenABI))
+	if err != nil {
+		t.Fatalf("Invalid test ABI: %v", err)
+	}
+
+	parser := NewParser()
+	parser.SetAliases(map[string]common.Address{"token": token, "holder": holder})
+	parser.SetABIs(map[common.Address]abi.ABI{token: contract})
+
+	query, err := parser.ParseQuery("SELECT GAS_ESTIMATE FOR CALL mint(holder) FROM token SENDER holder BLOCK 19000000")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if query.Method != "GAS_ESTIMATE" || query.Address != token {
+		t.Errorf("Expected GAS_ESTIMATE of %s, got %s of %s", token.Hex(), query.Method, query.Address.Hex())
+	}
+	if query.Call == nil || query.Call.Sig != "mint(address)" {
+		t.Fatalf("Expected function mint(address), got %v", query.Call)
+	}
+	if !reflect.DeepEqual(query.CallArgs, []interface{}{holder}) {
+		t.Errorf("Expected arguments [%s], got %v", holder.Hex(), query.CallArgs)
+	}
+	if query.Sender == nil || *query.Sender != holder {
+		t.Errorf("Expected sender %s, got %v", holder.Hex(), query.Sender)
+	}
+	if got := blockString(query.FromBlock); got != "19000000" {
+		t.Errorf("Expected block 19000000, got %q", got)
+	}
+
+	query, err = parser.ParseQuery("SELECT CALL 'totalSupply()(uint256)' FROM token SENDER 0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if query.Sender == nil || *query.Sender != holder {
+		t.Errorf("Expected sender %s, got %v", holder.Hex(), query.Sender)
+	}
+	if query.Uses("gas_estimate") {
+		t.Errorf("Expected no gas estimate without show_gas_estimates")
+	}
+
+	query, err = parser.ParseQuery("SELECT CALL 'totalSupply()(uint256)' FROM token SENDER 'Vitalik.eth'")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if query.Sender != nil || query.SenderName != "vitalik.eth" {
+		t.Errorf("Expected sender vitalik.eth, got %v and %q", query.Sender, query.SenderName)
+	}
+
+	parser.SetShowGasEstimates(true)
+	query, err = parser.ParseQuery("SELECT CALL 'totalSupply()(uint256)' FROM token")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !query.Uses("gas_estimate") {
+		t.Errorf("Expected a gas estimate with show_gas_estimates")
+	}
+	parser.SetShowGasEstimates(false)
+
+	errorTests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Missing call",
+			queryStr:    "SELECT GAS_ESTIMATE FROM token",
+			expectedErr: "GAS_ESTIMATE needs a call",
+		},
+		{
+			name:        "Sender of another method",
+			queryStr:    "SELECT BALANCE FROM token SENDER holder",
+			expectedErr: "SENDER is only supported for CALL and GAS_ESTIMATE",
+		},
+		{
+			name:        "Block range",
+			queryStr:    "SELECT GAS_ESTIMATE FOR CALL mint(holder) FROM token LAST 10 BLOCKS",
+			expectedErr: "GAS_ESTIMATE reads a single block",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// declarationExamples shows the expected form of an inline declaration of each kind
+var declarationExamples = map[string]string{
+	"event":    "Transfer(address indexed from, address indexed to, uint256 value)",
+	"function": "transfer(address to, uint256 amount)",
+	"call":     "balanceOf(address)(uint256)",
+}
+
+// splitDeclarationArg separates the trailing event or function argument of
+// LOGS(<address>, ..., 'Transfer') or TRANSACTIONS(<address>, ..., 'transfer'), a
+// string that is neither an address nor an ENS name, from the addresses
+func splitDeclarationArg(args []Expr) (*StringLit, []Expr) {
+	if len(args) == 0 {
+		return nil, args
+	}
+	lit, ok := args[len(args)-1].(*StringLit)
+	if !ok || strings.HasPrefix(lit.Value, "0x") {
+		return nil, args
+	}
+	if _, ok := ensName(lit); ok {
+		return nil, args
+	}
+	return lit, args[:len(args)-1]
+}
+
+// buildEvent resolves the event of LOGS(<address>, '<event>'), given either as a full
+// declaration with indexed markers or by name, looked up in the ABIs of the addresses
+func buildEvent(lit *StringLit, addresses []common.Address, abis map[common.Address]abi.ABI) (*abi.Event, error) {
+	if strings.Contains(lit.Value, "(") {
+		name, inputs, ok := parseDeclaration(lit.Value, "event", true)
+		if !ok {
+			return nil, errorAt(lit, "invalid event declaration: %s (expected a form such as '%s')", TruncateForDisplay(lit.Value, 60), declarationExamples["event"])
+		}
+		event := abi.NewEvent(name, name, false, inputs)
+		return &event, nil
+	}
+
+	address, signature, err := lookupDeclaration(lit, "event", declarationExamples["event"], addresses, abis, func(contract abi.ABI, name string) []string {
+		var signatures []string
+		for _, event := range contract.Events {
+			if event.RawName == name {
+				signatures = append(signatures, event.Sig)
+			}
+		}
+		return signatures
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range abis[address].Events {
+		if event.Sig == signature {
+			return &event, nil
+		}
+	}
+	return nil, errorAt(lit, "event %s is not in the ABI of %s", signature, address.Hex())
+}
+
+// buildFunction resolves the function of TRANSACTIONS(<address>, '<function>'), given
+// either as a full declaration or by name, looked up in the ABIs of the addresses
+func buildFunction(lit *StringLit, addresses []common.Address, abis map[common.Address]abi.ABI) (*abi.Method, error) {
+	if strings.Contains(lit.Value, "(") {
+		name, inputs, ok := parseDeclaration(lit.Value, "function", false)
+		if !ok {
+			return nil, errorAt(lit, "invalid function declaration: %s (expected a form such as '%s')", TruncateForDisplay(lit.Value, 60), declarationExamples["function"])
+		}
+		method := abi.NewMethod(name, name, abi.Function, "nonpayable", false, false, inputs, nil)
+		return &method, nil
+	}
+
+	address, signature, err := lookupDeclaration(lit, "function", declarationExamples["function"], addresses, abis, func(contract abi.ABI, name string) []string {
+		var signatures []string
+		for _, method := range contract.Methods {
+			if method.RawName == name {
+				signatures = append(signatures, method.Sig)
+			}
+		}
+		return signatures
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, method := range abis[address].Methods {
+		if method.Sig == signature {
+			return &method, nil
+		}
+	}
+	return nil, errorAt(lit, "function %s is not in the ABI of %s", signature, address.Hex())
+}
+
+// lookupDeclaration finds the event or function named by lit in the ABIs registered
+// for addresses, returning the signature and a contract declaring it. The name must
+// not be overloaded, and must mean the same thing in every ABI that has it. example is
+// the declaration suggested when no ABI is registered.
+func lookupDeclaration(lit *StringLit, kind, example string, addresses []common.Address, abis map[common.Address]abi.ABI, signatures func(abi.ABI, string) []string) (common.Address, string, error) {
+	name := strings.TrimSpace(lit.Value)
+
+	var found string
+	var foundAt common.Address
+	var owners []string
+	for _, address := range addresses {
+		contract, ok := abis[address]
+		if !ok {
+			continue
+		}
+		owners = append(owners, address.Hex())
+
+		matches := signatures(contract, name)
+		sort.Strings(matches)
+		switch {
+		case len(matches) > 1:
+			return common.Address{}, "", errorAt(lit, "%s %s is overloaded in the ABI of %s (%s); give its full declaration", kind, name, address.Hex(), strings.Join(matches, ", "))
+		case len(matches) == 0:
+			continue
+		case found != "" && found != matches[0]:
+			return common.Address{}, "", errorAt(lit, "%s %s differs between the ABIs of the queried contracts; give its full declaration", kind, name)
+		case found == "":
+			found, foundAt = matches[0], address
+		}
+	}
+
+	if found == "" {
+		if len(owners) == 0 {
+			return common.Address{}, "", errorAt(lit, "no ABI registered for the queried addresses; give the full declaration of %s, such as '%s'", name, example)
+		}
+		return common.Address{}, "", errorAt(lit, "%s %s is not in the ABI of %s", kind, name, strings.Join(owners, ", "))
+	}
+	return foundAt, found, nil
+}
+
+// parseDeclaration parses an inline event or function declaration such as
+// "Transfer(address indexed from, address indexed to, uint256 value)", optionally
+// starting with its keyword. Only events may mark parameters indexed, at most three
+// of them. Tuple parameters need a JSON ABI.
+func parseDeclaration(text, keyword string, indexable bool) (string, abi.Arguments, bool) {
+	text = strings.TrimSpace(text)
+	text = strings.TrimSpace(strings.TrimPrefix(text, keyword+" "))
+	open := strings.Index(text, "(")
+	if open <= 0 || !strings.HasSuffix(text, ")") {
+		return "", nil, false
+	}
+	name := strings.TrimSpace(text[:open])
+	if !isIdentifier(name) {
+		return "", nil, false
+	}
+
+	var inputs abi.Arguments
+	indexed := 0
+	if list := text[open+1 : len(text)-1]; strings.TrimSpace(list) != "" {
+		for _, param := range splitParams(list) {
+			words := strings.Fields(param)
+			if len(words) == 0 || len(words) > 3 {
+				return "", nil, false
+			}
+			typ := canonicalType(words[0])
+			if !validType(typ) {
+				return "", nil, false
+			}
+			t, _ := abi.NewType(typ, "", nil)
+
+			arg := abi.Argument{Type: t}
+			for _, word := range words[1:] {
+				switch {
+				case word == "indexed" && indexable && !arg.Indexed:
+					arg.Indexed = true
+				case isIdentifier(word) && arg.Name == "":
+					arg.Name = word
+				default:
+					return "", nil, false
+				}
+			}
+			if arg.Indexed {
+				indexed++
+			}
+			inputs = append(inputs, arg)
+		}
+	}
+	if indexed > 3 {
+		return "", nil, false
+	}
+	return name, inputs, true
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// tokenABI declares an overloaded function next to plain ones
+const tokenABI = `[
+	{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"mint","inputs":[{"name":"to","type":"address"}],"outputs":[]},
+	{"type":"function","name":"mint","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[]}
+]`
+
+func TestParseQuery_DecodedFunctions(t *testing.T) {
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	contract, err := abi.JSON(strings.NewReader(tokenABI))
+	if err != nil {
+		t.Fatalf("Invalid test ABI: %v", err)
+	}
+
+	parser := NewParser()
+	parser.SetAliases(map[string]common.Address{"token": token})
+	parser.SetABIs(map[common.Address]abi.ABI{token: contract})
+
+	tests := []struct {
+		name             string
+		queryStr         string
+		expectedFunction string
+		expectedFields   []string
+	}{
+		{
+			name:             "Function from the ABI",
+			queryStr:         "SELECT hash, args.to, args.amount FROM TRANSACTIONS(token, 'transfer') LAST 10 BLOCKS WHERE args.amount > 100",
+			expectedFunction: "transfer(address,uint256)",
+			expectedFields:   []string{"hash", "args.to", "args.amount"},
+		},
+		{
+			name:             "Inline declaration",
+			queryStr:         "SELECT args.spender FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e, 'function approve(address spender, uint value)')",
+			expectedFunction: "approve(address,uint256)",
+			expectedFields:   []string{"args.spender"},
+		},
+		{
+			name:             "Method column",
+			queryStr:         "SELECT hash, method, args FROM TRANSACTIONS(token) WHERE method = 'transfer' AND selector = 0xa9059cbb",
+			expectedFunction: "",
+			expectedFields:   []string{"hash", "method", "args"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			switch {
+			case tt.expectedFunction == "" && query.Function != nil:
+				t.Errorf("Expected no function, got %s", query.Function.Sig)
+			case tt.expectedFunction != "" && (query.Function == nil || query.Function.Sig != tt.expectedFunction):
+				t.Errorf("Expected function %s, got %v", tt.expectedFunction, query.Function)
+			}
+			if len(query.Fields) != len(tt.expectedFields) {
+				t.Fatalf("Expected fields %v, got %v", tt.expectedFields, query.Fields)
+			}
+			for i, name := range tt.expectedFields {
+				if query.Fields[i].Name != name {
+					t.Errorf("Field %d: expected %s, got %s", i, name, query.Fields[i].Name)
+				}
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Overloaded function",
+			queryStr:    "SELECT TRANSACTIONS FROM (token, 'mint')",
+			expectedErr: "function mint is overloaded in the ABI of " + token.Hex() + " (mint(address), mint(address,uint256))",
+		},
+		{
+			name:        "Function missing from the ABI",
+			queryStr:    "SELECT TRANSACTIONS FROM (token, 'burn')",
+			expectedErr: "function burn is not in the ABI of " + token.Hex(),
+		},
+		{
+			name:        "Indexed function parameter",
+			queryStr:    "SELECT TRANSACTIONS FROM (token, 'transfer(address indexed to, uint256 amount)')",
+			expectedErr: "invalid function declaration",
+		},
+		{
+			name:        "Contract without an ABI",
+			queryStr:    "SELECT TRANSACTIONS FROM (0x742d35Cc6634C0532925a3b844Bc454e4438f44e, 'transfer')",
+			expectedErr: "such as 'transfer(address to, uint256 amount)'",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
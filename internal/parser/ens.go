@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ensName returns the lower-cased ENS name written by expr, either bare as in
+// vitalik.eth or quoted as in 'my-name.eth'
+func ensName(expr Expr) (string, bool) {
+	var text string
+	switch e := expr.(type) {
+	case *Ident:
+		text = e.Name
+	case *StringLit:
+		text = e.Value
+	default:
+		return "", false
+	}
+
+	name := strings.ToLower(text)
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	for _, label := range labels {
+		if label == "" {
+			return "", false
+		}
+		for _, r := range label {
+			if r < utf8.RuneSelf && !isENSRune(r) {
+				return "", false
+			}
+		}
+	}
+	return name, true
+}
+
+// isENSRune reports whether an ASCII character may appear in an ENS label
+func isENSRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_'
+}
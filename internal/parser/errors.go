@@ -0,0 +1,47 @@
+package parser
+
+import "fmt"
+
+// SyntaxError reports a problem at a specific location of the query text
+type SyntaxError struct {
+	Pos   Position
+	Token string // offending token as written, empty at end of input
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%s (at %s)", e.Msg, e.Pos)
+	}
+	return fmt.Sprintf("%s (at %s, near %q)", e.Msg, e.Pos, TruncateForDisplay(e.Token, 50))
+}
+
+func newSyntaxError(pos Position, token, format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{Pos: pos, Token: token, Msg: fmt.Sprintf(format, args...)}
+}
+
+// errorAt builds a SyntaxError pointing at an AST node
+func errorAt(node Node, format string, args ...interface{}) *SyntaxError {
+	return newSyntaxError(node.Position(), nodeText(node), format, args...)
+}
+
+// nodeText returns the source text of simple nodes for error messages
+func nodeText(node Node) string {
+	switch n := node.(type) {
+	case *Ident:
+		return n.Name
+	case *NumberLit:
+		return n.Value
+	case *HexLit:
+		return n.Value
+	case *StringLit:
+		return n.Value
+	case *AliasLit:
+		return n.Name
+	case *UnaryExpr:
+		return n.Op + nodeText(n.X)
+	case *CallExpr:
+		return callText(n)
+	}
+	return ""
+}
@@ -0,0 +1,238 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// eventColumn is the pseudo-column of LOGS that selects logs by event signature.
+// Conditions on it are rewritten into conditions on topic0.
+const eventColumn = "event"
+
+// isEventRef reports whether expr names the event pseudo-column of schema
+func isEventRef(expr Expr, schema queries.Schema) bool {
+	ident, ok := expr.(*Ident)
+	if !ok || !strings.EqualFold(ident.Name, eventColumn) {
+		return false
+	}
+	if _, ok := schema.Lookup(eventColumn); ok {
+		return false
+	}
+	_, ok = schema.Lookup("topic0")
+	return ok
+}
+
+// buildEventComparison rewrites "event = 'Sig(...)'" and "event != 'Sig(...)'" as a
+// comparison of topic0 with the hash of the signature
+func buildEventComparison(e *BinaryExpr, schema queries.Schema) (queries.Expr, error) {
+	operand := e.Right
+	if !isEventRef(e.Left, schema) {
+		operand = e.Left
+	}
+	if e.Op != "=" && e.Op != "!=" {
+		return nil, errorAt(e, "operator %s is not supported for event; use =, != or IN", e.Op)
+	}
+	topic, err := eventTopic(operand)
+	if err != nil {
+		return nil, err
+	}
+	return &queries.Comparison{Op: e.Op, Left: &queries.ColumnRef{Name: "topic0"}, Right: &queries.Literal{Value: topic}}, nil
+}
+
+// buildEventIn rewrites "event [NOT] IN ('Sig(...)', ...)" as a topic0 OR-set
+func buildEventIn(e *InExpr) (queries.Expr, error) {
+	list := make([]queries.Expr, len(e.List))
+	for i, item := range e.List {
+		topic, err := eventTopic(item)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = &queries.Literal{Value: topic}
+	}
+	return &queries.In{X: &queries.ColumnRef{Name: "topic0"}, List: list, Negate: e.Not}, nil
+}
+
+// eventTopic returns the topic0 of an event, given as a signature string or as the
+// topic hash itself
+func eventTopic(expr Expr) (common.Hash, error) {
+	if text, ok := hexText(expr); ok && len(text) == 2+2*common.HashLength {
+		if b, err := hexutil.Decode(text); err == nil {
+			return common.BytesToHash(b), nil
+		}
+	}
+	lit, ok := expr.(*StringLit)
+	if !ok {
+		return common.Hash{}, errorAt(expr, "expected an event signature such as 'Transfer(address,address,uint256)'")
+	}
+	signature, ok := canonicalSignature(lit.Value)
+	if !ok {
+		return common.Hash{}, errorAt(expr, "invalid event signature: %s (expected a form such as 'Transfer(address,address,uint256)')", TruncateForDisplay(lit.Value, 60))
+	}
+	return crypto.Keccak256Hash([]byte(signature)), nil
+}
+
+// canonicalSignature normalises an event signature such as
+// "event Transfer(address indexed from, address indexed to, uint value)" into the
+// canonical form hashed into topic0, "Transfer(address,address,uint256)"
+func canonicalSignature(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	text = strings.TrimSpace(strings.TrimPrefix(text, "event "))
+	open := strings.Index(text, "(")
+	if open <= 0 || !strings.HasSuffix(text, ")") {
+		return "", false
+	}
+	name := strings.TrimSpace(text[:open])
+	if !isIdentifier(name) {
+		return "", false
+	}
+	params, ok := canonicalParams(text[open+1 : len(text)-1])
+	if !ok {
+		return "", false
+	}
+	return name + "(" + params + ")", true
+}
+
+// canonicalParams normalises a comma-separated parameter list, dropping parameter
+// names and the indexed keyword and expanding uint and int to their full width
+func canonicalParams(list string) (string, bool) {
+	if strings.TrimSpace(list) == "" {
+		return "", true
+	}
+
+	var types []string
+	for _, param := range splitParams(list) {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			return "", false
+		}
+
+		var typ string
+		if strings.HasPrefix(param, "(") {
+			// A tuple, possibly an array of them: "(uint256,address)[] orders"
+			end := closingParen(param)
+			if end < 0 {
+				return "", false
+			}
+			inner, ok := canonicalParams(param[1:end])
+			if !ok {
+				return "", false
+			}
+			suffix := strings.Fields(param[end+1:] + " ")
+			typ = "(" + inner + ")"
+			if len(suffix) > 0 && strings.HasPrefix(suffix[0], "[") {
+				typ += suffix[0]
+			}
+		} else {
+			typ = canonicalType(strings.Fields(param)[0])
+			if !validType(typ) {
+				return "", false
+			}
+		}
+		types = append(types, typ)
+	}
+	return strings.Join(types, ","), true
+}
+
+// canonicalType expands the uint and int shorthands, keeping any array suffix
+func canonicalType(typ string) string {
+	base, suffix := typ, ""
+	if i := strings.Index(typ, "["); i >= 0 {
+		base, suffix = typ[:i], typ[i:]
+	}
+	switch base {
+	case "uint", "int":
+		base += "256"
+	}
+	return base + suffix
+}
+
+// validType reports whether typ is an elementary ABI type or an array of one.
+// The ABI parser accepts any integer width, so widths are checked here.
+func validType(typ string) bool {
+	t, err := abi.NewType(typ, "", nil)
+	if err != nil {
+		return false
+	}
+	for t.Elem != nil {
+		t = *t.Elem
+	}
+	if t.T == abi.IntTy || t.T == abi.UintTy {
+		return t.Size >= 8 && t.Size <= 256 && t.Size%8 == 0
+	}
+	return true
+}
+
+// splitParams splits a parameter list on the commas outside of parentheses
+func splitParams(list string) []string {
+	var params []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				params = append(params, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(params, list[start:])
+}
+
+// closingParen returns the index of the parenthesis closing the one s starts with, or -1
+func closingParen(s string) int {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isIdentifier reports whether s is a Solidity identifier
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !(r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTopic reports whether column is one of the topic columns of LOGS
+func isTopic(column string) bool {
+	return len(column) == len("topic0") && strings.HasPrefix(column, "topic")
+}
+
+// isArg reports whether column names a decoded argument, as in args.value
+func isArg(column string) bool {
+	return strings.HasPrefix(strings.ToLower(column), queries.ArgPrefix)
+}
+
+// hasArgs reports whether schema includes decoded arguments
+func hasArgs(schema queries.Schema) bool {
+	for _, field := range schema {
+		if isArg(field.Name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,355 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// transferTopic is the topic0 of Transfer(address,address,uint256)
+const transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+// approvalTopic is the topic0 of Approval(address,address,uint256)
+const approvalTopic = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+
+func TestCanonicalSignature(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{"Transfer(address,address,uint256)", "Transfer(address,address,uint256)", true},
+		{"  Transfer( address , address, uint )", "Transfer(address,address,uint256)", true},
+		{"event Transfer(address indexed from, address indexed to, uint256 value)", "Transfer(address,address,uint256)", true},
+		{"Paused()", "Paused()", true},
+		{"Batch(uint[] ids, int8[2] deltas)", "Batch(uint256[],int8[2])", true},
+		{"Filled((uint, address) order, bytes32 id)", "Filled((uint256,address),bytes32)", true},
+		{"Filled((uint256,(address,bool))[] orders)", "Filled((uint256,(address,bool))[])", true},
+		{"Transfer", "", false},
+		{"(address)", "", false},
+		{"Transfer(address,,uint256)", "", false},
+		{"Transfer(adress,address,uint256)", "", false},
+		{"Swap(uint7)", "", false},
+		{"Swap(int264[])", "", false},
+		{"1Transfer(address)", "", false},
+		{"Filled((uint256,address)", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			signature, ok := canonicalSignature(tt.input)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v (%q)", tt.ok, ok, signature)
+			}
+			if signature != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, signature)
+			}
+		})
+	}
+}
+
+func TestParseQuery_EventFilters(t *testing.T) {
+	holder := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	paddedHolder := common.BytesToHash(holder.Bytes()).Hex()
+
+	tests := []struct {
+		name          string
+		queryStr      string
+		expectedWhere string
+		anyAddress    bool
+	}{
+		{
+			name:          "Event signature",
+			queryStr:      "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 2 WHERE event = 'Transfer(address,address,uint256)'",
+			expectedWhere: "topic0 = " + transferTopic,
+		},
+		{
+			name:          "Event declaration",
+			queryStr:      "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 2 WHERE EVENT = 'event Transfer(address indexed from, address indexed to, uint value)'",
+			expectedWhere: "topic0 = " + transferTopic,
+		},
+		{
+			name:          "Event set",
+			queryStr:      "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 2 WHERE event IN ('Transfer(address,address,uint256)', 'Approval(address,address,uint256)')",
+			expectedWhere: "topic0 IN (" + transferTopic + ", " + approvalTopic + ")",
+		},
+		{
+			name:          "Event topic hash",
+			queryStr:      "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 2 WHERE event != " + transferTopic,
+			expectedWhere: "topic0 != " + transferTopic,
+		},
+		{
+			name:          "Address in a topic",
+			queryStr:      "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 2 WHERE event = 'Transfer(address,address,uint256)' AND topic2 = " + holder.Hex(),
+			expectedWhere: "(topic0 = " + transferTopic + " AND topic2 = " + paddedHolder + ")",
+		},
+		{
+			name:          "Without an address",
+			queryStr:      "SELECT * FROM LOGS LAST 100 BLOCKS WHERE event = 'Transfer(address,address,uint256)' AND topic1 IN (" + holder.Hex() + ")",
+			expectedWhere: "(topic0 = " + transferTopic + " AND topic1 IN (" + paddedHolder + "))",
+			anyAddress:    true,
+		},
+		{
+			name:          "Without an address, by topic",
+			queryStr:      "SELECT * FROM LOGS BLOCK 1 2 WHERE topic0 = " + transferTopic,
+			expectedWhere: "topic0 = " + transferTopic,
+			anyAddress:    true,
+		},
+	}
+
+	parser := NewParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if query.Where.String() != tt.expectedWhere {
+				t.Errorf("Expected WHERE %s, got %s", tt.expectedWhere, query.Where)
+			}
+			if query.AnyAddress != tt.anyAddress {
+				t.Errorf("Expected AnyAddress=%v, got %v", tt.anyAddress, query.AnyAddress)
+			}
+			if tt.anyAddress && len(query.AddressList()) != 0 {
+				t.Errorf("Expected no addresses, got %v", query.AddressList())
+			}
+		})
+	}
+}
+
+func TestParseQuery_EventFilterErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Invalid signature",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e WHERE event = 'Transfer'",
+			expectedErr: "invalid event signature: Transfer",
+		},
+		{
+			name:        "Unknown parameter type",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e WHERE event IN ('Transfer(address,address,uint256)', 'Swap(uint257)')",
+			expectedErr: "invalid event signature: Swap(uint257)",
+		},
+		{
+			name:        "Ordering operator",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e WHERE event > 'Transfer(address,address,uint256)'",
+			expectedErr: "operator > is not supported for event",
+		},
+		{
+			name:        "Not a signature",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e WHERE event = 5",
+			expectedErr: "expected an event signature",
+		},
+		{
+			name:        "Event outside LOGS",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e WHERE event = 'Transfer(address,address,uint256)'",
+			expectedErr: "unknown column: event",
+		},
+		{
+			name:        "Address in a hash column",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e WHERE tx_hash = 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedErr: "tx_hash",
+		},
+		{
+			name:        "Without an address or topic",
+			queryStr:    "SELECT * FROM LOGS BLOCK 1 2 WHERE log_index = 0",
+			expectedErr: "LOGS needs an address or an event or topic condition",
+		},
+		{
+			name:        "Topic only under OR",
+			queryStr:    "SELECT * FROM LOGS BLOCK 1 2 WHERE topic0 = " + transferTopic + " OR log_index = 0",
+			expectedErr: "LOGS needs an address or an event or topic condition",
+		},
+		{
+			name:        "Transactions without an address",
+			queryStr:    "SELECT * FROM TRANSACTIONS BLOCK 1 2 WHERE value > 0",
+			expectedErr: "TRANSACTIONS needs an address",
+		},
+	}
+
+	parser := NewParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+// erc20ABI declares the events of an ERC-20 token
+const erc20ABI = `[
+	{"type":"event","name":"Transfer","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+	{"type":"event","name":"Approval","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"spender","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+func TestParseQuery_DecodedEvents(t *testing.T) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	token, err := abi.JSON(strings.NewReader(erc20ABI))
+	if err != nil {
+		t.Fatalf("Invalid test ABI: %v", err)
+	}
+
+	parser := NewParser()
+	parser.SetAliases(map[string]common.Address{"usdc": usdc})
+	parser.SetABIs(map[common.Address]abi.ABI{usdc: token})
+
+	tests := []struct {
+		name           string
+		queryStr       string
+		expectedEvent  string
+		expectedFields []string
+		expectedWhere  string
+		anyAddress     bool
+	}{
+		{
+			name:           "Event from the ABI",
+			queryStr:       "SELECT args.from, args.to, args.value FROM LOGS(usdc, 'Transfer') LAST 10 BLOCKS",
+			expectedEvent:  "Transfer(address,address,uint256)",
+			expectedFields: []string{"args.from", "args.to", "args.value"},
+		},
+		{
+			name:           "Filter on an argument",
+			queryStr:       "SELECT args.owner FROM LOGS(usdc, 'Approval') LAST 10 BLOCKS WHERE args.value > 1000 AND args.spender = 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedEvent:  "Approval(address,address,uint256)",
+			expectedFields: []string{"args.owner"},
+			expectedWhere:  "(args.value > 1000 AND args.spender = 0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+		},
+		{
+			name:           "Inline declaration",
+			queryStr:       "SELECT args.src, args.wad FROM LOGS(0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2, 'Deposit(address indexed src, uint wad)')",
+			expectedEvent:  "Deposit(address,uint256)",
+			expectedFields: []string{"args.src", "args.wad"},
+		},
+		{
+			name:           "Unnamed parameters",
+			queryStr:       "SELECT args.arg0, args.arg1 FROM LOGS(usdc, 'Paid(address indexed, uint256)')",
+			expectedEvent:  "Paid(address,uint256)",
+			expectedFields: []string{"args.arg0", "args.arg1"},
+		},
+		{
+			name:           "Star includes arguments",
+			queryStr:       "SELECT * FROM LOGS('Transfer(address indexed from, address indexed to, uint256 value)') LAST 10 BLOCKS",
+			expectedEvent:  "Transfer(address,address,uint256)",
+			expectedFields: []string{"address", "topic0", "topic1", "topic2", "topic3", "data", "block_number", "block_timestamp", "block_hash", "tx_hash", "tx_index", "log_index", "removed", "args.from", "args.to", "args.value"},
+			anyAddress:     true,
+		},
+		{
+			name:          "ENS name is not an event",
+			queryStr:      "SELECT LOGS FROM (usdc, 'vitalik.eth')",
+			expectedEvent: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if tt.expectedEvent == "" {
+				if query.Event != nil {
+					t.Errorf("Expected no event, got %s", query.Event.Sig)
+				}
+				return
+			}
+			if query.Event == nil || query.Event.Sig != tt.expectedEvent {
+				t.Fatalf("Expected event %s, got %v", tt.expectedEvent, query.Event)
+			}
+			if len(query.Fields) != len(tt.expectedFields) {
+				t.Fatalf("Expected fields %v, got %v", tt.expectedFields, query.Fields)
+			}
+			for i, name := range tt.expectedFields {
+				if query.Fields[i].Name != name {
+					t.Errorf("Field %d: expected %s, got %s", i, name, query.Fields[i].Name)
+				}
+			}
+			if tt.expectedWhere != "" && query.Where.String() != tt.expectedWhere {
+				t.Errorf("Expected WHERE %s, got %s", tt.expectedWhere, query.Where)
+			}
+			if query.AnyAddress != tt.anyAddress {
+				t.Errorf("Expected AnyAddress=%v, got %v", tt.anyAddress, query.AnyAddress)
+			}
+		})
+	}
+}
+
+func TestParseQuery_DecodedEventErrors(t *testing.T) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	token, err := abi.JSON(strings.NewReader(erc20ABI))
+	if err != nil {
+		t.Fatalf("Invalid test ABI: %v", err)
+	}
+
+	parser := NewParser()
+	parser.SetAliases(map[string]common.Address{"usdc": usdc})
+	parser.SetABIs(map[common.Address]abi.ABI{usdc: token})
+
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Event missing from the ABI",
+			queryStr:    "SELECT LOGS FROM (usdc, 'Swap')",
+			expectedErr: "event Swap is not in the ABI of " + usdc.Hex(),
+		},
+		{
+			name:        "Contract without an ABI",
+			queryStr:    "SELECT LOGS FROM (0x742d35Cc6634C0532925a3b844Bc454e4438f44e, 'Transfer')",
+			expectedErr: "no ABI registered for the queried addresses",
+		},
+		{
+			name:        "Event name without an address",
+			queryStr:    "SELECT * FROM LOGS('Transfer')",
+			expectedErr: "no ABI registered for the queried addresses",
+		},
+		{
+			name:        "Invalid declaration",
+			queryStr:    "SELECT LOGS FROM (usdc, 'Transfer(address indexed indexed from)')",
+			expectedErr: "invalid event declaration",
+		},
+		{
+			name:        "Too many indexed parameters",
+			queryStr:    "SELECT LOGS FROM (usdc, 'E(uint indexed a, uint indexed b, uint indexed c, uint indexed d)')",
+			expectedErr: "invalid event declaration",
+		},
+		{
+			name:        "Unknown argument",
+			queryStr:    "SELECT args.amount FROM LOGS(usdc, 'Transfer')",
+			expectedErr: "unknown column: args.amount (available:",
+		},
+		{
+			name:        "Argument without an event",
+			queryStr:    "SELECT LOGS FROM usdc WHERE args.value > 0",
+			expectedErr: "name the event to decode",
+		},
+		{
+			name:        "Event argument outside LOGS",
+			queryStr:    "SELECT BALANCE FROM (usdc, 'Transfer')",
+			expectedErr: "invalid Ethereum address format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
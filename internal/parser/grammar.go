@@ -0,0 +1,947 @@
+package parser
+
+import "strings"
+
+// errInvalidFormat is the message used when the statement skeleton itself is wrong
+const errInvalidFormat = "invalid query format; expected SELECT <method> FROM <address>"
+
+// grammar is a recursive-descent parser over a token stream
+type grammar struct {
+	tokens []Token
+	pos    int
+}
+
+// ParseStatement tokenizes and parses a query into its AST
+func ParseStatement(input string) (*SelectStmt, error) {
+	tokens, err := Tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	g := &grammar{tokens: tokens}
+	return g.parseSelect()
+}
+
+func (g *grammar) peek() Token {
+	return g.tokens[g.pos]
+}
+
+func (g *grammar) next() Token {
+	tok := g.tokens[g.pos]
+	if tok.Type != TokenEOF {
+		g.pos++
+	}
+	return tok
+}
+
+// acceptKeyword consumes the next token if it is the given keyword
+func (g *grammar) acceptKeyword(keyword string) bool {
+	if g.peek().Is(keyword) {
+		g.next()
+		return true
+	}
+	return false
+}
+
+// errorAtToken builds a SyntaxError pointing at a token
+func (g *grammar) errorAtToken(tok Token, format string, args ...interface{}) *SyntaxError {
+	return newSyntaxError(tok.Pos, tok.Raw, format, args...)
+}
+
+// parseSelect parses:
+//
+//	SELECT {fields | [GAS_ESTIMATE FOR] CALL function[(args)]} FROM source [SLOT slot, ...] [TOKEN token, ...]
+//	    [COLLECTION collection, ...] [TX hash, ...] [TOKEN_ID id, ...] [SENDER address] [blocks] [WHERE expr]
+//	    [GROUP BY key, ...] [HAVING expr]
+//	    [ORDER BY key [ASC|DESC], ...] [LIMIT operand] [OFFSET operand] [;]
+func (g *grammar) parseSelect() (*SelectStmt, error) {
+	start := g.peek()
+	if !start.Is("SELECT") {
+		return nil, g.errorAtToken(start, errInvalidFormat)
+	}
+	g.next()
+
+	stmt := &SelectStmt{Pos: start.Pos}
+
+	var fields []*SelectField
+	switch tok := g.peek(); {
+	case tok.Is("CALL") && g.startsFunction(g.pos+1):
+		g.next()
+		call, err := g.parseCallClause(tok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Call = call
+		fields = []*SelectField{{Pos: tok.Pos, Expr: &Ident{Pos: tok.Pos, Name: tok.Value}}}
+	case tok.Is("GAS_ESTIMATE") && g.tokens[g.pos+1].Is("FOR") && g.tokens[g.pos+2].Is("CALL") && g.startsFunction(g.pos+3):
+		g.next()
+		g.next()
+		call, err := g.parseCallClause(g.next())
+		if err != nil {
+			return nil, err
+		}
+		stmt.Call = call
+		fields = []*SelectField{{Pos: tok.Pos, Expr: &Ident{Pos: tok.Pos, Name: tok.Value}}}
+	default:
+		var err error
+		if fields, err = g.parseFields(); err != nil {
+			return nil, err
+		}
+	}
+	stmt.Fields = fields
+
+	fromTok := g.peek()
+	if method := g.bareMethod(fields); method != nil {
+		// Methods without an address need no FROM, as in "SELECT BLOCKS LAST 10 BLOCKS"
+		stmt.From = &FromClause{Pos: method.Pos, Method: method}
+		stmt.Fields = nil
+	} else {
+		if !g.acceptKeyword("FROM") {
+			return nil, g.errorAtToken(fromTok, errInvalidFormat)
+		}
+		if g.peek().Type == TokenEOF {
+			return nil, g.errorAtToken(g.peek(), errInvalidFormat)
+		}
+		from, err := g.parseSource(fromTok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.From = from
+	}
+
+	if slotTok := g.peek(); slotTok.Is("SLOT") {
+		g.next()
+		slot, err := g.parseSlotClause(slotTok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Slot = slot
+	}
+
+	if tokenTok := g.peek(); tokenTok.Is("TOKEN") {
+		g.next()
+		token, err := g.parseTokenClause(tokenTok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Token = token
+	}
+
+	if collectionTok := g.peek(); collectionTok.Is("COLLECTION") {
+		g.next()
+		collection, err := g.parseCollectionClause(collectionTok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Collection = collection
+	}
+
+	if txTok := g.peek(); txTok.Is("TX") {
+		g.next()
+		tx, err := g.parseTxClause(txTok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Tx = tx
+	}
+
+	if idTok := g.peek(); idTok.Is("TOKEN_ID") {
+		g.next()
+		tokenID, err := g.parseTokenIDClause(idTok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.TokenID = tokenID
+	}
+
+	if senderTok := g.peek(); senderTok.Is("SENDER") {
+		g.next()
+		if tok := g.peek(); tok.Type != TokenHex && tok.Type != TokenIdent && tok.Type != TokenString {
+			return nil, g.errorAtToken(tok, "expected an address after SENDER")
+		}
+		sender, err := g.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Sender = &SenderClause{Pos: senderTok.Pos, Address: sender}
+	}
+
+	if blockTok := g.peek(); blockTok.Type == TokenIdent && blockKeywords[strings.ToUpper(blockTok.Value)] {
+		g.next()
+		block, err := g.parseBlockClause(blockTok)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Block = block
+	}
+
+	if g.acceptKeyword("WHERE") {
+		where, err := g.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Where = where
+	}
+
+	if groupTok := g.peek(); groupTok.Is("GROUP") {
+		g.next()
+		if !g.acceptKeyword("BY") {
+			return nil, g.errorAtToken(g.peek(), "expected BY after GROUP")
+		}
+		groupBy, err := g.parseGroupBy()
+		if err != nil {
+			return nil, err
+		}
+		stmt.GroupBy = groupBy
+	}
+
+	if g.acceptKeyword("HAVING") {
+		having, err := g.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Having = having
+	}
+
+	if orderTok := g.peek(); orderTok.Is("ORDER") {
+		g.next()
+		if !g.acceptKeyword("BY") {
+			return nil, g.errorAtToken(g.peek(), "expected BY after ORDER")
+		}
+		orderBy, err := g.parseOrderBy()
+		if err != nil {
+			return nil, err
+		}
+		stmt.OrderBy = orderBy
+	}
+
+	// LIMIT and OFFSET may appear in either order, each at most once
+	for {
+		tok := g.peek()
+		var target *Expr
+		switch {
+		case tok.Is("LIMIT"):
+			target = &stmt.Limit
+		case tok.Is("OFFSET"):
+			target = &stmt.Offset
+		}
+		if target == nil {
+			break
+		}
+		g.next()
+		if *target != nil {
+			return nil, g.errorAtToken(tok, "duplicate %s clause", strings.ToUpper(tok.Value))
+		}
+		if !g.startsOperand(g.peek()) {
+			return nil, g.errorAtToken(g.peek(), "expected a number after %s", strings.ToUpper(tok.Value))
+		}
+		value, err := g.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		*target = value
+	}
+
+	if g.peek().Type == TokenSemicolon {
+		g.next()
+	}
+	if tok := g.peek(); tok.Type != TokenEOF {
+		return nil, g.errorAtToken(tok, "unexpected %s", tok.Type)
+	}
+
+	return stmt, nil
+}
+
+// bareMethod returns the method of a statement without FROM, such as "SELECT BLOCKS
+// BLOCK 1 10": a lone unaliased name followed by the end of the query or a clause
+func (g *grammar) bareMethod(fields []*SelectField) *Ident {
+	if len(fields) != 1 || fields[0].Alias != nil {
+		return nil
+	}
+	ident, ok := fields[0].Expr.(*Ident)
+	if !ok || strings.Contains(ident.Name, ".") {
+		return nil
+	}
+	tok := g.peek()
+	switch {
+	case tok.Type == TokenEOF || tok.Type == TokenSemicolon:
+		return ident
+	case tok.Type == TokenIdent:
+		keyword := strings.ToUpper(tok.Value)
+		if blockKeywords[keyword] || clauseKeywords[keyword] || keyword == "TX" {
+			return ident
+		}
+	}
+	return nil
+}
+
+// clauseKeywords start the clauses that may follow a BLOCK clause
+var clauseKeywords = map[string]bool{
+	"WHERE":  true,
+	"GROUP":  true,
+	"HAVING": true,
+	"ORDER":  true,
+	"LIMIT":  true,
+	"OFFSET": true,
+}
+
+// blockKeywords start a block range clause
+var blockKeywords = map[string]bool{
+	"BLOCK":   true,
+	"SINCE":   true,
+	"UNTIL":   true,
+	"LAST":    true,
+	"AT":      true,
+	"BETWEEN": true,
+}
+
+// parseBlockClause parses a block range clause after its leading keyword
+func (g *grammar) parseBlockClause(blockTok Token) (*BlockClause, error) {
+	clause := &BlockClause{Pos: blockTok.Pos, Keyword: strings.ToUpper(blockTok.Value)}
+
+	switch clause.Keyword {
+	case "LAST":
+		if !g.startsBound(g.peek()) {
+			return nil, g.errorAtToken(g.peek(), "expected a number of blocks after LAST")
+		}
+		last, err := g.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		clause.Last = last
+		if !g.acceptKeyword("BLOCKS") && !g.acceptKeyword("BLOCK") {
+			return nil, g.errorAtToken(g.peek(), "expected BLOCKS after LAST %s", nodeText(last))
+		}
+		return clause, nil
+
+	case "SINCE":
+		from, err := g.parseBound("SINCE")
+		if err != nil {
+			return nil, err
+		}
+		clause.From = from
+		if g.acceptKeyword("UNTIL") {
+			if clause.To, err = g.parseBound("UNTIL"); err != nil {
+				return nil, err
+			}
+		}
+		return clause, nil
+
+	case "UNTIL", "AT":
+		to, err := g.parseBound(clause.Keyword)
+		if err != nil {
+			return nil, err
+		}
+		clause.To = to
+		return clause, nil
+
+	case "BETWEEN":
+		from, err := g.parseBound("BETWEEN")
+		if err != nil {
+			return nil, err
+		}
+		clause.From = from
+		if !g.acceptKeyword("AND") {
+			return nil, g.errorAtToken(g.peek(), "expected AND in BETWEEN")
+		}
+		if clause.To, err = g.parseBound("AND"); err != nil {
+			return nil, err
+		}
+		return clause, nil
+	}
+
+	if !g.startsBound(g.peek()) {
+		return nil, g.errorAtToken(g.peek(), "BLOCK keyword requires both from and to block numbers")
+	}
+	from, err := g.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	clause.From = from
+
+	if g.acceptKeyword("TO") {
+		if clause.To, err = g.parseBound("TO"); err != nil {
+			return nil, err
+		}
+	} else if g.startsBound(g.peek()) {
+		if clause.To, err = g.parseOperand(); err != nil {
+			return nil, err
+		}
+	}
+	return clause, nil
+}
+
+// startsFunction reports whether the tokens at i name the function of a CALL query:
+// a name followed by its arguments, or a quoted declaration
+func (g *grammar) startsFunction(i int) bool {
+	tok := g.tokens[i]
+	switch tok.Type {
+	case TokenString:
+		return true
+	case TokenIdent:
+		return g.tokens[i+1].Type == TokenLParen
+	}
+	return false
+}
+
+// parseCallClause parses the function of a CALL query and its arguments, which a
+// quoted declaration without parameters may leave out
+func (g *grammar) parseCallClause(callTok Token) (*CallClause, error) {
+	clause := &CallClause{Pos: callTok.Pos}
+
+	fn := g.next()
+	if fn.Type == TokenString {
+		clause.Function = &StringLit{Pos: fn.Pos, Value: fn.Value}
+		if g.peek().Type != TokenLParen {
+			return clause, nil
+		}
+	} else {
+		clause.Function = &Ident{Pos: fn.Pos, Name: fn.Value}
+	}
+	g.next()
+
+	if g.peek().Type == TokenRParen {
+		g.next()
+		return clause, nil
+	}
+	for {
+		arg, err := g.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		clause.Args = append(clause.Args, arg)
+
+		next := g.next()
+		if next.Type == TokenRParen {
+			return clause, nil
+		}
+		if next.Type != TokenComma {
+			return nil, g.errorAtToken(next, "expected ',' or ')' in %s arguments", nodeText(clause.Function))
+		}
+	}
+}
+
+// parseSlotClause parses the list of storage slots after SLOT
+func (g *grammar) parseSlotClause(slotTok Token) (*SlotClause, error) {
+	slots, err := g.parseNumberList("a storage slot after SLOT")
+	if err != nil {
+		return nil, err
+	}
+	return &SlotClause{Pos: slotTok.Pos, Slots: slots}, nil
+}
+
+// parseTokenIDClause parses the list of token IDs after TOKEN_ID
+func (g *grammar) parseTokenIDClause(idTok Token) (*TokenIDClause, error) {
+	ids, err := g.parseNumberList("a token ID after TOKEN_ID")
+	if err != nil {
+		return nil, err
+	}
+	return &TokenIDClause{Pos: idTok.Pos, IDs: ids}, nil
+}
+
+// parseNumberList parses a comma-separated list of integers, reporting what was
+// expected when an entry is not one
+func (g *grammar) parseNumberList(expected string) ([]Expr, error) {
+	var numbers []Expr
+	for {
+		if tok := g.peek(); tok.Type != TokenNumber && tok.Type != TokenHex && !tok.IsOperator("-") {
+			return nil, g.errorAtToken(tok, "expected %s", expected)
+		}
+		number, err := g.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+
+		if g.peek().Type != TokenComma {
+			return numbers, nil
+		}
+		g.next()
+	}
+}
+
+// parseTokenClause parses the list of tokens after TOKEN, parenthesised or not
+func (g *grammar) parseTokenClause(tokenTok Token) (*TokenClause, error) {
+	tokens, err := g.parseOperandList("a token address", "token", "TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	return &TokenClause{Pos: tokenTok.Pos, Tokens: tokens}, nil
+}
+
+// parseTxClause parses the list of transaction hashes after TX, parenthesised or not
+func (g *grammar) parseTxClause(txTok Token) (*TxClause, error) {
+	hashes, err := g.parseOperandList("a transaction hash", "transaction", "TX")
+	if err != nil {
+		return nil, err
+	}
+	return &TxClause{Pos: txTok.Pos, Hashes: hashes}, nil
+}
+
+// parseCollectionClause parses the list of collections after COLLECTION,
+// parenthesised or not
+func (g *grammar) parseCollectionClause(collectionTok Token) (*CollectionClause, error) {
+	collections, err := g.parseOperandList("a collection address", "collection", "COLLECTION")
+	if err != nil {
+		return nil, err
+	}
+	return &CollectionClause{Pos: collectionTok.Pos, Collections: collections}, nil
+}
+
+// parseOperandList parses the list of operands after keyword, parenthesised or not.
+// expected and list describe an entry and the list in errors.
+func (g *grammar) parseOperandList(expected, list, keyword string) ([]Expr, error) {
+	var operands []Expr
+	parens := g.peek().Type == TokenLParen
+	if parens {
+		g.next()
+	}
+	for {
+		tok := g.peek()
+		upper := strings.ToUpper(tok.Value)
+		if !g.startsOperand(tok) || (tok.Type == TokenIdent && (blockKeywords[upper] || clauseKeywords[upper])) {
+			return nil, g.errorAtToken(tok, "expected %s after %s", expected, keyword)
+		}
+		operand, err := g.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+
+		next := g.peek()
+		switch {
+		case next.Type == TokenComma:
+			g.next()
+		case parens && next.Type == TokenRParen:
+			g.next()
+			return operands, nil
+		case parens:
+			return nil, g.errorAtToken(next, "expected ',' or ')' in %s list", list)
+		default:
+			return operands, nil
+		}
+	}
+}
+
+// parseBound parses the block number required after keyword
+func (g *grammar) parseBound(keyword string) (Expr, error) {
+	if !g.startsBound(g.peek()) {
+		return nil, g.errorAtToken(g.peek(), "expected a block number after %s", keyword)
+	}
+	return g.parseOperand()
+}
+
+// startsBound reports whether tok can begin a block bound
+func (g *grammar) startsBound(tok Token) bool {
+	if tok.Type == TokenIdent && clauseKeywords[strings.ToUpper(tok.Value)] {
+		return false
+	}
+	return g.startsOperand(tok)
+}
+
+// parseGroupBy parses the keys following GROUP BY: key (',' key)*
+func (g *grammar) parseGroupBy() ([]Expr, error) {
+	var keys []Expr
+	for {
+		if tok := g.peek(); tok.Type != TokenIdent {
+			return nil, g.errorAtToken(tok, "expected a column name in GROUP BY")
+		}
+		key, err := g.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+
+		if g.peek().Type != TokenComma {
+			return keys, nil
+		}
+		g.next()
+	}
+}
+
+// parseOrderBy parses the sort keys following ORDER BY: key [ASC|DESC] (',' key [ASC|DESC])*
+func (g *grammar) parseOrderBy() ([]*OrderItem, error) {
+	var items []*OrderItem
+	for {
+		tok := g.peek()
+		if tok.Type != TokenIdent {
+			return nil, g.errorAtToken(tok, "expected a column name in ORDER BY")
+		}
+		key, err := g.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		item := &OrderItem{Pos: tok.Pos, Expr: key}
+		if g.acceptKeyword("DESC") {
+			item.Desc = true
+		} else {
+			g.acceptKeyword("ASC")
+		}
+		items = append(items, item)
+
+		if g.peek().Type != TokenComma {
+			return items, nil
+		}
+		g.next()
+	}
+}
+
+// parseFields parses the select list: '*' | field (',' field)*
+func (g *grammar) parseFields() ([]*SelectField, error) {
+	var fields []*SelectField
+	for {
+		tok := g.peek()
+		if tok.IsOperator("*") {
+			g.next()
+			fields = append(fields, &SelectField{Pos: tok.Pos, Star: true})
+		} else {
+			if tok.Type != TokenIdent {
+				return nil, g.errorAtToken(tok, errInvalidFormat)
+			}
+			expr, err := g.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			field := &SelectField{Pos: tok.Pos, Expr: expr}
+			if g.acceptKeyword("AS") {
+				alias := g.next()
+				if alias.Type != TokenIdent && alias.Type != TokenString {
+					return nil, g.errorAtToken(alias, "expected alias after AS")
+				}
+				field.Alias = &Ident{Pos: alias.Pos, Name: alias.Value}
+			}
+			fields = append(fields, field)
+		}
+
+		if g.peek().Type != TokenComma {
+			return fields, nil
+		}
+		g.next()
+	}
+}
+
+// parseSource parses what follows FROM: a method call such as TRANSACTIONS(0x...)
+// or, in the short form "SELECT <method> FROM <address>", a single operand or a
+// parenthesised list of them
+func (g *grammar) parseSource(fromTok Token) (*FromClause, error) {
+	clause := &FromClause{Pos: fromTok.Pos}
+
+	tok := g.peek()
+	// "SELECT STATE_DIFF FROM TX <hash>" reads no address; the TX clause follows
+	if next := g.tokens[g.pos+1]; tok.Is("TX") && (next.Type == TokenHex || next.Type == TokenLParen) {
+		return clause, nil
+	}
+	if tok.Type == TokenIdent && g.tokens[g.pos+1].Type == TokenLParen {
+		g.next()
+		g.next()
+		clause.Method = &Ident{Pos: tok.Pos, Name: tok.Value}
+		clause.Call = true
+
+		if g.peek().Type == TokenRParen {
+			g.next()
+			return clause, nil
+		}
+		for {
+			arg, err := g.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			clause.Args = append(clause.Args, arg)
+
+			next := g.next()
+			if next.Type == TokenRParen {
+				return clause, nil
+			}
+			if next.Type != TokenComma {
+				return nil, g.errorAtToken(next, "expected ',' or ')' in %s arguments", clause.Method.Name)
+			}
+		}
+	}
+
+	// A parenthesised list of addresses, as in "SELECT LOGS FROM (0x..., 0x...)"
+	if tok.Type == TokenLParen {
+		g.next()
+		for {
+			arg, err := g.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			clause.Args = append(clause.Args, arg)
+
+			next := g.next()
+			if next.Type == TokenRParen {
+				return clause, nil
+			}
+			if next.Type != TokenComma {
+				return nil, g.errorAtToken(next, "expected ',' or ')' in address list")
+			}
+		}
+	}
+
+	operand, err := g.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	clause.Args = []Expr{operand}
+	return clause, nil
+}
+
+// startsOperand reports whether tok can begin an operand
+func (g *grammar) startsOperand(tok Token) bool {
+	switch tok.Type {
+	case TokenNumber, TokenHex, TokenString, TokenIdent:
+		return true
+	case TokenOperator:
+		return tok.Value == "-"
+	}
+	return false
+}
+
+// parseOperand parses: ['-'] (NUMBER [unit] | HEX | STRING | IDENT ['.' label ...] | call)
+func (g *grammar) parseOperand() (Expr, error) {
+	if tok := g.peek(); tok.Type == TokenIdent && g.tokens[g.pos+1].Type == TokenLParen {
+		return g.parseCall()
+	}
+
+	tok := g.next()
+	switch tok.Type {
+	case TokenNumber:
+		lit := &NumberLit{Pos: tok.Pos, Value: tok.Value}
+		if unit := g.peek(); unit.Type == TokenIdent && isUnit(unit.Value) {
+			g.next()
+			lit.Unit = strings.ToLower(unit.Value)
+		}
+		return lit, nil
+	case TokenHex:
+		return &HexLit{Pos: tok.Pos, Value: tok.Value}, nil
+	case TokenString:
+		return &StringLit{Pos: tok.Pos, Value: tok.Value}, nil
+	case TokenIdent:
+		return g.parseName(tok), nil
+	case TokenOperator:
+		if tok.Value == "-" {
+			x, err := g.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return &UnaryExpr{Pos: tok.Pos, Op: "-", X: x}, nil
+		}
+	}
+	return nil, g.errorAtToken(tok, "unexpected %s", tok.Type)
+}
+
+// parseName parses an identifier together with any ".label" parts written directly
+// after it, as in the ENS name vitalik.eth
+func (g *grammar) parseName(first Token) *Ident {
+	ident := &Ident{Pos: first.Pos, Name: first.Value}
+	last := first
+	for dot := g.peek(); dot.Type == TokenDot && adjacent(last, dot); dot = g.peek() {
+		label := g.tokens[g.pos+1]
+		if (label.Type != TokenIdent && label.Type != TokenNumber) || !adjacent(dot, label) {
+			break
+		}
+		g.next()
+		g.next()
+		ident.Name += "." + label.Value
+		last = label
+	}
+	return ident
+}
+
+// adjacent reports whether b starts right where a ends, with no space in between
+func adjacent(a, b Token) bool {
+	return a.Pos.Line == b.Pos.Line && a.Pos.Column+len(a.Raw) == b.Pos.Column
+}
+
+// parseCall parses a function call: IDENT '(' ('*' | operand (',' operand)*) ')'
+func (g *grammar) parseCall() (Expr, error) {
+	name := g.next()
+	g.next()
+	call := &CallExpr{Pos: name.Pos, Func: &Ident{Pos: name.Pos, Name: name.Value}}
+
+	if tok := g.peek(); tok.IsOperator("*") {
+		g.next()
+		call.Star = true
+	} else if tok.Type != TokenRParen {
+		for {
+			arg, err := g.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+			if g.peek().Type != TokenComma {
+				break
+			}
+			g.next()
+		}
+	}
+
+	if closing := g.next(); closing.Type != TokenRParen {
+		return nil, g.errorAtToken(closing, "expected ')' to close %s", name.Value)
+	}
+	return call, nil
+}
+
+// comparisonOperators maps the accepted comparison spellings to their canonical form
+var comparisonOperators = map[string]string{
+	"=":  "=",
+	"!=": "!=",
+	"<>": "!=",
+	"<":  "<",
+	">":  ">",
+	"<=": "<=",
+	">=": ">=",
+}
+
+// parseExpr parses a boolean expression:
+//
+//	expr      := and (OR and)*
+//	and       := not (AND not)*
+//	not       := NOT not | predicate
+//	predicate := primary [cmp primary | [NOT] IN (list) | [NOT] BETWEEN primary AND primary | IS [NOT] NULL]
+//	primary   := '(' expr ')' | operand
+func (g *grammar) parseExpr() (Expr, error) {
+	left, err := g.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for g.peek().Is("OR") {
+		op := g.next()
+		right, err := g.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Pos: op.Pos, Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (g *grammar) parseAnd() (Expr, error) {
+	left, err := g.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for g.peek().Is("AND") {
+		op := g.next()
+		right, err := g.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Pos: op.Pos, Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (g *grammar) parseNot() (Expr, error) {
+	if tok := g.peek(); tok.Is("NOT") {
+		g.next()
+		x, err := g.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Pos: tok.Pos, Op: "NOT", X: x}, nil
+	}
+	return g.parsePredicate()
+}
+
+func (g *grammar) parsePredicate() (Expr, error) {
+	left, err := g.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := g.peek()
+	if tok.Type == TokenOperator {
+		op, ok := comparisonOperators[tok.Value]
+		if !ok {
+			return nil, g.errorAtToken(tok, "unexpected operator %s", tok.Value)
+		}
+		g.next()
+		right, err := g.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpr{Pos: tok.Pos, Op: op, Left: left, Right: right}, nil
+	}
+
+	if tok.Is("IS") {
+		g.next()
+		not := g.acceptKeyword("NOT")
+		if !g.acceptKeyword("NULL") {
+			return nil, g.errorAtToken(g.peek(), "expected NULL after IS")
+		}
+		return &IsNullExpr{Pos: tok.Pos, X: left, Not: not}, nil
+	}
+
+	not := false
+	if tok.Is("NOT") {
+		g.next()
+		not = true
+	}
+
+	switch next := g.peek(); {
+	case next.Is("IN"):
+		g.next()
+		list, err := g.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &InExpr{Pos: tok.Pos, X: left, List: list, Not: not}, nil
+	case next.Is("BETWEEN"):
+		g.next()
+		low, err := g.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if !g.acceptKeyword("AND") {
+			return nil, g.errorAtToken(g.peek(), "expected AND in BETWEEN")
+		}
+		high, err := g.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{Pos: tok.Pos, X: left, Low: low, High: high, Not: not}, nil
+	case not:
+		return nil, g.errorAtToken(next, "expected IN or BETWEEN after NOT")
+	}
+
+	return left, nil
+}
+
+// parseList parses a parenthesised, comma-separated list of operands
+func (g *grammar) parseList() ([]Expr, error) {
+	if tok := g.next(); tok.Type != TokenLParen {
+		return nil, g.errorAtToken(tok, "expected '(' to start list")
+	}
+
+	var items []Expr
+	for {
+		item, err := g.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		tok := g.next()
+		if tok.Type == TokenRParen {
+			return items, nil
+		}
+		if tok.Type != TokenComma {
+			return nil, g.errorAtToken(tok, "expected ',' or ')' in list")
+		}
+	}
+}
+
+func (g *grammar) parsePrimary() (Expr, error) {
+	if tok := g.peek(); tok.Type == TokenLParen {
+		g.next()
+		expr, err := g.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := g.next(); closing.Type != TokenRParen {
+			return nil, g.errorAtToken(closing, "expected ')'")
+		}
+		return expr, nil
+	}
+	return g.parseOperand()
+}
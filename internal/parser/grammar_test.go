@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStatement_AST(t *testing.T) {
+	stmt, err := ParseStatement("SELECT logs FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 100 200;")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(stmt.Fields) != 1 {
+		t.Fatalf("Expected 1 field, got %d", len(stmt.Fields))
+	}
+	if method, ok := stmt.Fields[0].Expr.(*Ident); !ok || method.Name != "logs" {
+		t.Errorf("Expected method logs, got %#v", stmt.Fields[0].Expr)
+	}
+
+	if stmt.From.Method != nil || len(stmt.From.Args) != 1 {
+		t.Fatalf("Expected short-form FROM with one operand, got %#v", stmt.From)
+	}
+	from, ok := stmt.From.Args[0].(*HexLit)
+	if !ok {
+		t.Fatalf("Expected FROM to be a hex literal, got %T", stmt.From.Args[0])
+	}
+	if from.Pos != (Position{Line: 1, Column: 18}) {
+		t.Errorf("Expected FROM at column 18, got %s", from.Pos)
+	}
+
+	if stmt.Block == nil {
+		t.Fatal("Expected block clause")
+	}
+	if lit, ok := stmt.Block.From.(*NumberLit); !ok || lit.Value != "100" {
+		t.Errorf("Expected from block 100, got %#v", stmt.Block.From)
+	}
+	if lit, ok := stmt.Block.To.(*NumberLit); !ok || lit.Value != "200" {
+		t.Errorf("Expected to block 200, got %#v", stmt.Block.To)
+	}
+}
+
+func TestParseStatement_ErrorPositions(t *testing.T) {
+	tests := []struct {
+		name          string
+		queryStr      string
+		expectedErr   string
+		expectedPos   Position
+		expectedToken string
+	}{
+		{
+			name:          "Missing SELECT",
+			queryStr:      "FETCH BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedErr:   "invalid query format",
+			expectedPos:   Position{Line: 1, Column: 1},
+			expectedToken: "FETCH",
+		},
+		{
+			name:          "Trailing garbage on second line",
+			queryStr:      "SELECT BALANCE\nFROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e extra",
+			expectedErr:   "unexpected identifier",
+			expectedPos:   Position{Line: 2, Column: 49},
+			expectedToken: "extra",
+		},
+		{
+			name:          "Operator instead of address",
+			queryStr:      "SELECT BALANCE FROM =",
+			expectedErr:   "unexpected operator",
+			expectedPos:   Position{Line: 1, Column: 21},
+			expectedToken: "=",
+		},
+		{
+			name:        "BLOCK without bounds",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK",
+			expectedErr: "BLOCK keyword requires both from and to block numbers",
+			expectedPos: Position{Line: 1, Column: 66},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseStatement(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+
+			syntaxErr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("Expected *SyntaxError, got %T", err)
+			}
+
+			if !strings.Contains(syntaxErr.Msg, tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, syntaxErr.Msg)
+			}
+
+			if syntaxErr.Pos != tt.expectedPos {
+				t.Errorf("Expected position %s, got %s", tt.expectedPos, syntaxErr.Pos)
+			}
+
+			if syntaxErr.Token != tt.expectedToken {
+				t.Errorf("Expected token %q, got %q", tt.expectedToken, syntaxErr.Token)
+			}
+		})
+	}
+}
+
+func TestParseQuery_SemanticErrorPositions(t *testing.T) {
+	parser := NewParser()
+
+	_, err := parser.ParseQuery("SELECT LOGS\nFROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e\nBLOCK 100 abc")
+	if err == nil {
+		t.Fatal("Expected error for invalid to block")
+	}
+
+	if !strings.Contains(err.Error(), "line 3, column 11") {
+		t.Errorf("Expected error to point at line 3, column 11, got '%s'", err.Error())
+	}
+
+	if !strings.Contains(err.Error(), `near "abc"`) {
+		t.Errorf("Expected error to quote the offending token, got '%s'", err.Error())
+	}
+}
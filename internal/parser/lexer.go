@@ -0,0 +1,287 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenType identifies the lexical class of a token
+type TokenType int
+
+const (
+	TokenEOF TokenType = iota
+	TokenIdent
+	TokenNumber
+	TokenHex
+	TokenString
+	TokenOperator
+	TokenComma
+	TokenDot
+	TokenLParen
+	TokenRParen
+	TokenSemicolon
+)
+
+var tokenNames = map[TokenType]string{
+	TokenEOF:       "end of input",
+	TokenIdent:     "identifier",
+	TokenNumber:    "number",
+	TokenHex:       "hex literal",
+	TokenString:    "string",
+	TokenOperator:  "operator",
+	TokenComma:     "','",
+	TokenDot:       "'.'",
+	TokenLParen:    "'('",
+	TokenRParen:    "')'",
+	TokenSemicolon: "';'",
+}
+
+func (t TokenType) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("token(%d)", int(t))
+}
+
+// Position is a 1-based line and column in the query text
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// Token is a single lexical unit of an EVMQL query
+type Token struct {
+	Type  TokenType
+	Value string // unquoted value for strings, raw text otherwise
+	Raw   string // text exactly as it appeared in the input
+	Pos   Position
+}
+
+// Is reports whether the token is the given keyword (case-insensitive)
+func (t Token) Is(keyword string) bool {
+	return t.Type == TokenIdent && strings.EqualFold(t.Value, keyword)
+}
+
+// IsOperator reports whether the token is the given operator
+func (t Token) IsOperator(op string) bool {
+	return t.Type == TokenOperator && t.Value == op
+}
+
+// Lexer splits a query string into tokens
+type Lexer struct {
+	input  string
+	offset int
+	line   int
+	column int
+}
+
+// NewLexer creates a lexer for the given input
+func NewLexer(input string) *Lexer {
+	return &Lexer{input: input, line: 1, column: 1}
+}
+
+// Tokenize lexes the whole input, ending with a TokenEOF token
+func Tokenize(input string) ([]Token, error) {
+	lexer := NewLexer(input)
+	var tokens []Token
+	for {
+		tok, err := lexer.Next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.Type == TokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+// Next returns the next token from the input
+func (l *Lexer) Next() (Token, error) {
+	if err := l.skipWhitespaceAndComments(); err != nil {
+		return Token{}, err
+	}
+
+	start := l.offset
+	pos := l.position()
+
+	if l.offset >= len(l.input) {
+		return Token{Type: TokenEOF, Pos: pos}, nil
+	}
+
+	r := l.peek()
+	switch {
+	case r == '\'' || r == '"':
+		return l.lexString(pos)
+	case r == '0' && (l.peekAt(1) == 'x' || l.peekAt(1) == 'X'):
+		l.advance()
+		l.advance()
+		l.consumeWhile(isIdentRune)
+		return l.token(TokenHex, start, pos), nil
+	case unicode.IsDigit(r):
+		l.consumeWhile(unicode.IsDigit)
+		// Decimal fraction, as in "1.5 ether"
+		if l.peek() == '.' && unicode.IsDigit(l.peekAt(1)) {
+			l.advance()
+			l.consumeWhile(unicode.IsDigit)
+		}
+		// Reject things like "123abc" rather than splitting them silently
+		if isIdentStart(l.peek()) {
+			l.consumeWhile(isIdentRune)
+			return Token{}, newSyntaxError(pos, l.input[start:l.offset], "invalid number")
+		}
+		return l.token(TokenNumber, start, pos), nil
+	case isIdentStart(r):
+		l.consumeWhile(isIdentRune)
+		return l.token(TokenIdent, start, pos), nil
+	}
+
+	l.advance()
+	switch r {
+	case ',':
+		return l.token(TokenComma, start, pos), nil
+	case '.':
+		return l.token(TokenDot, start, pos), nil
+	case '(':
+		return l.token(TokenLParen, start, pos), nil
+	case ')':
+		return l.token(TokenRParen, start, pos), nil
+	case ';':
+		return l.token(TokenSemicolon, start, pos), nil
+	case '=', '*', '+', '-', '/':
+		return l.token(TokenOperator, start, pos), nil
+	case '<':
+		if l.peek() == '=' || l.peek() == '>' {
+			l.advance()
+		}
+		return l.token(TokenOperator, start, pos), nil
+	case '>':
+		if l.peek() == '=' {
+			l.advance()
+		}
+		return l.token(TokenOperator, start, pos), nil
+	case '!':
+		if l.peek() == '=' {
+			l.advance()
+			return l.token(TokenOperator, start, pos), nil
+		}
+	}
+
+	return Token{}, newSyntaxError(pos, l.input[start:l.offset], "unexpected character")
+}
+
+// lexString scans a single- or double-quoted string; a doubled quote escapes itself
+func (l *Lexer) lexString(pos Position) (Token, error) {
+	start := l.offset
+	quote := l.advance()
+
+	var value strings.Builder
+	for {
+		if l.offset >= len(l.input) {
+			return Token{}, newSyntaxError(pos, TruncateForDisplay(l.input[start:], 20), "unterminated string")
+		}
+		r := l.advance()
+		if r == quote {
+			if l.peek() != quote {
+				break
+			}
+			l.advance()
+		}
+		value.WriteRune(r)
+	}
+
+	return Token{Type: TokenString, Value: value.String(), Raw: l.input[start:l.offset], Pos: pos}, nil
+}
+
+// skipWhitespaceAndComments skips blanks, control characters, "-- line" and "/* block */" comments
+func (l *Lexer) skipWhitespaceAndComments() error {
+	for l.offset < len(l.input) {
+		r := l.peek()
+		switch {
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			l.advance()
+		case r == '-' && l.peekAt(1) == '-':
+			for l.offset < len(l.input) && l.peek() != '\n' {
+				l.advance()
+			}
+		case r == '/' && l.peekAt(1) == '*':
+			pos := l.position()
+			l.advance()
+			l.advance()
+			for {
+				if l.offset >= len(l.input) {
+					return newSyntaxError(pos, "/*", "unterminated comment")
+				}
+				if l.peek() == '*' && l.peekAt(1) == '/' {
+					l.advance()
+					l.advance()
+					break
+				}
+				l.advance()
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *Lexer) token(typ TokenType, start int, pos Position) Token {
+	text := l.input[start:l.offset]
+	return Token{Type: typ, Value: text, Raw: text, Pos: pos}
+}
+
+func (l *Lexer) position() Position {
+	return Position{Line: l.line, Column: l.column}
+}
+
+func (l *Lexer) peek() rune {
+	return l.peekAt(0)
+}
+
+// peekAt returns the rune n runes ahead of the current offset, or 0 at end of input
+func (l *Lexer) peekAt(n int) rune {
+	offset := l.offset
+	for i := 0; ; i++ {
+		if offset >= len(l.input) {
+			return 0
+		}
+		r, size := utf8.DecodeRuneInString(l.input[offset:])
+		if i == n {
+			return r
+		}
+		offset += size
+	}
+}
+
+func (l *Lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.input[l.offset:])
+	l.offset += size
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return r
+}
+
+func (l *Lexer) consumeWhile(pred func(rune) bool) {
+	for l.offset < len(l.input) && pred(l.peek()) {
+		l.advance()
+	}
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || (r < utf8.RuneSelf && unicode.IsLetter(r))
+}
+
+func isIdentRune(r rune) bool {
+	return isIdentStart(r) || (r < utf8.RuneSelf && unicode.IsDigit(r))
+}
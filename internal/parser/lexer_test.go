@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize_TokenTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []TokenType
+		values   []string
+	}{
+		{
+			name:     "Keywords and address",
+			input:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expected: []TokenType{TokenIdent, TokenIdent, TokenIdent, TokenHex, TokenEOF},
+			values:   []string{"SELECT", "BALANCE", "FROM", "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", ""},
+		},
+		{
+			name:     "Numbers",
+			input:    "1000000 1.5 0",
+			expected: []TokenType{TokenNumber, TokenNumber, TokenNumber, TokenEOF},
+			values:   []string{"1000000", "1.5", "0", ""},
+		},
+		{
+			name:     "Strings with escaped quotes",
+			input:    `'Transfer(address,address,uint256)' "it''s" 'it''s'`,
+			expected: []TokenType{TokenString, TokenString, TokenString, TokenEOF},
+			values:   []string{"Transfer(address,address,uint256)", "it''s", "it's", ""},
+		},
+		{
+			name:     "Operators",
+			input:    "= != <> < > <= >= * - + /",
+			expected: []TokenType{TokenOperator, TokenOperator, TokenOperator, TokenOperator, TokenOperator, TokenOperator, TokenOperator, TokenOperator, TokenOperator, TokenOperator, TokenOperator, TokenEOF},
+			values:   []string{"=", "!=", "<>", "<", ">", "<=", ">=", "*", "-", "+", "/", ""},
+		},
+		{
+			name:     "Punctuation",
+			input:    "(a, b.c);",
+			expected: []TokenType{TokenLParen, TokenIdent, TokenComma, TokenIdent, TokenDot, TokenIdent, TokenRParen, TokenSemicolon, TokenEOF},
+			values:   []string{"(", "a", ",", "b", ".", "c", ")", ";", ""},
+		},
+		{
+			name:     "Comments are skipped",
+			input:    "SELECT -- trailing comment\n/* block\ncomment */ LOGS",
+			expected: []TokenType{TokenIdent, TokenIdent, TokenEOF},
+			values:   []string{"SELECT", "LOGS", ""},
+		},
+		{
+			name:     "Identifiers with underscores and digits",
+			input:    "block_number topic0 _x",
+			expected: []TokenType{TokenIdent, TokenIdent, TokenIdent, TokenEOF},
+			values:   []string{"block_number", "topic0", "_x", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := Tokenize(tt.input)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if len(tokens) != len(tt.expected) {
+				t.Fatalf("Expected %d tokens, got %d: %v", len(tt.expected), len(tokens), tokens)
+			}
+
+			for i, tok := range tokens {
+				if tok.Type != tt.expected[i] {
+					t.Errorf("Token %d: expected type %s, got %s", i, tt.expected[i], tok.Type)
+				}
+				if tok.Value != tt.values[i] {
+					t.Errorf("Token %d: expected value %q, got %q", i, tt.values[i], tok.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenize_Positions(t *testing.T) {
+	tokens, err := Tokenize("SELECT LOGS\n  FROM 0xabc\n-- comment\nBLOCK 1 2")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []Position{
+		{Line: 1, Column: 1},
+		{Line: 1, Column: 8},
+		{Line: 2, Column: 3},
+		{Line: 2, Column: 8},
+		{Line: 4, Column: 1},
+		{Line: 4, Column: 7},
+		{Line: 4, Column: 9},
+	}
+
+	for i, pos := range expected {
+		if tokens[i].Pos != pos {
+			t.Errorf("Token %d (%s): expected position %s, got %s", i, tokens[i].Raw, pos, tokens[i].Pos)
+		}
+	}
+}
+
+func TestTokenize_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectedErr string
+		expectedPos Position
+	}{
+		{
+			name:        "Unterminated string",
+			input:       "SELECT 'abc",
+			expectedErr: "unterminated string",
+			expectedPos: Position{Line: 1, Column: 8},
+		},
+		{
+			name:        "Unterminated comment",
+			input:       "SELECT\n  /* never closed",
+			expectedErr: "unterminated comment",
+			expectedPos: Position{Line: 2, Column: 3},
+		},
+		{
+			name:        "Unexpected character",
+			input:       "SELECT @",
+			expectedErr: "unexpected character",
+			expectedPos: Position{Line: 1, Column: 8},
+		},
+		{
+			name:        "Malformed number",
+			input:       "BLOCK 123abc",
+			expectedErr: "invalid number",
+			expectedPos: Position{Line: 1, Column: 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Tokenize(tt.input)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+
+			syntaxErr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("Expected *SyntaxError, got %T", err)
+			}
+
+			if !strings.Contains(syntaxErr.Msg, tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, syntaxErr.Msg)
+			}
+
+			if syntaxErr.Pos != tt.expectedPos {
+				t.Errorf("Expected position %s, got %s", tt.expectedPos, syntaxErr.Pos)
+			}
+		})
+	}
+}
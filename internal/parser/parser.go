@@ -2,96 +2,61 @@ package parser
 
 import (
 	"errors"
-	"fmt"
-	"math/big"
 	"strings"
 
 	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 )
 
 // Parser struct to handle parsing logic
-type Parser struct{}
+type Parser struct {
+	aliases          map[string]common.Address
+	abis             map[common.Address]abi.ABI
+	showGasEstimates bool
+}
 
 // NewParser creates a new instance of Parser
 func NewParser() *Parser {
 	return &Parser{}
 }
 
-// ParseQuery parses the EVMQL query string and returns a Query object
-func (p *Parser) ParseQuery(queryStr string) (*queries.Query, error) {
-	queryStr = SanitizeInput(queryStr)
-
-	if len(queryStr) == 0 {
-		return nil, errors.New("query cannot be empty")
-	}
-
-	if len(queryStr) > 10000 {
-		return nil, errors.New("query too long: maximum 10000 characters")
+// SetAliases sets the names, such as contracts of the network and address book
+// entries, that queries may use in place of an address. Names match case-insensitively.
+func (p *Parser) SetAliases(aliases map[string]common.Address) {
+	p.aliases = make(map[string]common.Address, len(aliases))
+	for name, address := range aliases {
+		p.aliases[strings.ToLower(name)] = address
 	}
+}
 
-	parts := strings.Fields(queryStr)
-	if len(parts) < 4 || strings.ToUpper(parts[0]) != "SELECT" || strings.ToUpper(parts[2]) != "FROM" {
-		return nil, errors.New("invalid query format; expected SELECT <method> FROM <address>")
-	}
+// SetABIs sets the contract ABIs whose events LOGS queries may select by name
+func (p *Parser) SetABIs(abis map[common.Address]abi.ABI) {
+	p.abis = abis
+}
 
-	method := strings.ToUpper(parts[1])
-	validMethods := map[string]bool{
-		"BALANCE":      true,
-		"LOGS":         true,
-		"TRANSACTIONS": true,
-	}
-	if !validMethods[method] {
-		return nil, fmt.Errorf("unsupported method: %s (supported: BALANCE, LOGS, TRANSACTIONS)", method)
-	}
+// SetShowGasEstimates sets whether "SELECT CALL ..." also reports the gas each call
+// would take, as if gas_estimate had been selected
+func (p *Parser) SetShowGasEstimates(show bool) {
+	p.showGasEstimates = show
+}
 
-	// Initialize the query
-	query := &queries.Query{
-		Type:   "SELECT",
-		Method: method,
+// ParseQuery parses the EVMQL query string and returns a Query object
+func (p *Parser) ParseQuery(queryStr string) (*queries.Query, error) {
+	// The limit applies to the text as given, since that is what gets lexed
+	if len(queryStr) > 10000 {
+		return nil, errors.New("query too long: maximum 10000 characters")
 	}
 
-	// Parse and sanitize the address
-	address := NormalizeAddress(parts[3])
-	if !ValidateAddressFormat(address) {
-		return nil, fmt.Errorf("invalid Ethereum address format: %s", TruncateForDisplay(parts[3], 50))
-	}
-	if !common.IsHexAddress(address) {
-		return nil, fmt.Errorf("invalid Ethereum address: %s (must be 42 character hex starting with 0x)", TruncateForDisplay(address, 50))
+	if len(SanitizeInput(queryStr)) == 0 {
+		return nil, errors.New("query cannot be empty")
 	}
-	query.Address = common.HexToAddress(address)
-
-	// Parse optional block range
-	if len(parts) > 4 && strings.ToUpper(parts[4]) == "BLOCK" {
-		if len(parts) < 7 {
-			return nil, errors.New("BLOCK keyword requires both from and to block numbers")
-		}
-
-		fromBlockStr := strings.TrimSpace(parts[5])
-		toBlockStr := strings.TrimSpace(parts[6])
-
-		fromBlock, ok := new(big.Int).SetString(fromBlockStr, 10)
-		if !ok || fromBlock.Sign() < 0 {
-			return nil, fmt.Errorf("invalid from block: %s (must be non-negative integer)", TruncateForDisplay(fromBlockStr, 20))
-		}
-
-		toBlock, ok := new(big.Int).SetString(toBlockStr, 10)
-		if !ok || toBlock.Sign() < 0 {
-			return nil, fmt.Errorf("invalid to block: %s (must be non-negative integer)", TruncateForDisplay(toBlockStr, 20))
-		}
-
-		if fromBlock.Cmp(toBlock) > 0 {
-			return nil, fmt.Errorf("from block cannot be greater than to block")
-		}
-
-		blockRange := new(big.Int).Sub(toBlock, fromBlock)
-		if blockRange.Cmp(big.NewInt(10000)) > 0 {
-			return nil, fmt.Errorf("block range too large: %d blocks (maximum: 10000)", blockRange.Int64())
-		}
 
-		query.FromBlock = fromBlock
-		query.ToBlock = toBlock
+	// Lex the original text so that error positions match what the user typed
+	stmt, err := ParseStatement(queryStr)
+	if err != nil {
+		return nil, err
 	}
 
-	return query, nil
+	return buildQuery(stmt, p.aliases, p.abis, p.showGasEstimates)
 }
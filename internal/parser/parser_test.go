@@ -2,9 +2,11 @@ package parser
 
 import (
 	"math/big"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/devlongs/evmql/queries"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -175,6 +177,11 @@ func TestParseQuery_InvalidQueries(t *testing.T) {
 			queryStr:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e " + strings.Repeat("X", 10000),
 			expectedErr: "query too long",
 		},
+		{
+			name:        "Query padded with stripped characters",
+			queryStr:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e" + strings.Repeat("\x00", 10000),
+			expectedErr: "query too long",
+		},
 		{
 			name:        "Missing to block",
 			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1000000",
@@ -235,3 +242,1473 @@ func TestParseQuery_EdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestParseQuery_Projection(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryStr       string
+		expectedMethod string
+		expectedFields []string
+	}{
+		{
+			name:           "Short form selects every field",
+			queryStr:       "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedMethod: "TRANSACTIONS",
+			expectedFields: nil,
+		},
+		{
+			name:           "Column list with keyword-named columns",
+			queryStr:       "SELECT hash, from, to, value, block_number FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) BLOCK 1 2",
+			expectedMethod: "TRANSACTIONS",
+			expectedFields: []string{"hash", "from", "to", "value", "block_number"},
+		},
+		{
+			name:           "Aliases",
+			queryStr:       "SELECT value AS wei, block_number AS 'block' FROM transactions(0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+			expectedMethod: "TRANSACTIONS",
+			expectedFields: []string{"wei", "block"},
+		},
+		{
+			name:           "Star",
+			queryStr:       "SELECT * FROM BALANCE(0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+			expectedMethod: "BALANCE",
+			expectedFields: []string{"address", "balance", "block_number", "block_timestamp"},
+		},
+		{
+			name:           "Optional column",
+			queryStr:       "SELECT ens_name, * FROM BALANCE(0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+			expectedMethod: "BALANCE",
+			expectedFields: []string{"ens_name", "address", "balance", "block_number", "block_timestamp"},
+		},
+		{
+			name:           "Receipt columns",
+			queryStr:       "SELECT tx_hash, status, gas_used FROM RECEIPTS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) BLOCK 1 2",
+			expectedMethod: "RECEIPTS",
+			expectedFields: []string{"tx_hash", "status", "gas_used"},
+		},
+		{
+			name:           "Receipt columns of transactions",
+			queryStr:       "SELECT hash, status, effective_gas_price FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) WHERE contract_address IS NOT NULL",
+			expectedMethod: "TRANSACTIONS",
+			expectedFields: []string{"hash", "status", "effective_gas_price"},
+		},
+		{
+			name:           "Column named like a method",
+			queryStr:       "SELECT balance FROM BALANCE(0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+			expectedMethod: "BALANCE",
+			expectedFields: []string{"balance"},
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if query.Method != tt.expectedMethod {
+				t.Errorf("Expected method %s, got %s", tt.expectedMethod, query.Method)
+			}
+
+			if len(query.Fields) != len(tt.expectedFields) {
+				t.Fatalf("Expected %d fields, got %d", len(tt.expectedFields), len(query.Fields))
+			}
+			for i, name := range tt.expectedFields {
+				if query.Fields[i].Name != name {
+					t.Errorf("Field %d: expected %s, got %s", i, name, query.Fields[i].Name)
+				}
+			}
+
+			if query.Address != common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e") {
+				t.Errorf("Unexpected address %s", query.Address.Hex())
+			}
+		})
+	}
+}
+
+func TestParseQuery_ProjectionErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Unknown column",
+			queryStr:    "SELECT hash, colour FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+			expectedErr: "unknown column: colour",
+		},
+		{
+			name:        "Fields without a method source",
+			queryStr:    "SELECT hash, value FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedErr: "expected <method>(<address>) after FROM",
+		},
+		{
+			name:        "Unsupported method call",
+			queryStr:    "SELECT hash FROM BLOCKZ(0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+			expectedErr: "unsupported method: BLOCKZ",
+		},
+		{
+			name:        "Missing address argument",
+			queryStr:    "SELECT hash FROM TRANSACTIONS()",
+			expectedErr: "expects exactly one address argument",
+		},
+		{
+			name:        "Missing alias",
+			queryStr:    "SELECT hash AS FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+			expectedErr: "invalid query format",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseQuery_OrderLimitOffset(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryStr       string
+		expectedOrder  []string
+		expectedDesc   []bool
+		expectedLimit  int
+		expectedOffset int
+	}{
+		{
+			name:          "Limit only",
+			queryStr:      "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LIMIT 10",
+			expectedLimit: 10,
+		},
+		{
+			name:           "Order by with direction, limit and offset",
+			queryStr:       "SELECT hash, value FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) BLOCK 1 2 WHERE value > 0 ORDER BY value DESC, block_number asc LIMIT 5 OFFSET 20",
+			expectedOrder:  []string{"value", "block_number"},
+			expectedDesc:   []bool{true, false},
+			expectedLimit:  5,
+			expectedOffset: 20,
+		},
+		{
+			name:           "Offset before limit",
+			queryStr:       "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 2 OFFSET 3 LIMIT 4;",
+			expectedLimit:  4,
+			expectedOffset: 3,
+		},
+		{
+			name:          "Order by alias",
+			queryStr:      "SELECT value AS wei FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) ORDER BY wei",
+			expectedOrder: []string{"value"},
+			expectedDesc:  []bool{false},
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if len(query.OrderBy) != len(tt.expectedOrder) {
+				t.Fatalf("Expected %d sort keys, got %d", len(tt.expectedOrder), len(query.OrderBy))
+			}
+			for i, name := range tt.expectedOrder {
+				if query.OrderBy[i].Expr.String() != name {
+					t.Errorf("Sort key %d: expected %s, got %s", i, name, query.OrderBy[i].Expr)
+				}
+				if query.OrderBy[i].Desc != tt.expectedDesc[i] {
+					t.Errorf("Sort key %d: expected desc=%v, got %v", i, tt.expectedDesc[i], query.OrderBy[i].Desc)
+				}
+			}
+
+			if query.Limit != tt.expectedLimit {
+				t.Errorf("Expected limit %d, got %d", tt.expectedLimit, query.Limit)
+			}
+			if query.Offset != tt.expectedOffset {
+				t.Errorf("Expected offset %d, got %d", tt.expectedOffset, query.Offset)
+			}
+		})
+	}
+}
+
+func TestParseQuery_OrderLimitOffsetErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Order without by",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e ORDER value",
+			expectedErr: "expected BY after ORDER",
+		},
+		{
+			name:        "Unknown sort column",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e ORDER BY colour",
+			expectedErr: "unknown column: colour",
+		},
+		{
+			name:        "Zero limit",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LIMIT 0",
+			expectedErr: "LIMIT must be a positive integer",
+		},
+		{
+			name:        "Negative offset",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LIMIT 1 OFFSET -1",
+			expectedErr: "invalid OFFSET",
+		},
+		{
+			name:        "Fractional limit",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LIMIT 1.5",
+			expectedErr: "invalid LIMIT",
+		},
+		{
+			name:        "Limit too large",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LIMIT 10001",
+			expectedErr: "invalid LIMIT",
+		},
+		{
+			name:        "Duplicate limit",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LIMIT 1 LIMIT 2",
+			expectedErr: "duplicate LIMIT clause",
+		},
+		{
+			name:        "Limit without a value",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LIMIT",
+			expectedErr: "expected a number after LIMIT",
+		},
+		{
+			name:        "Where after limit",
+			queryStr:    "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LIMIT 1 WHERE value > 0",
+			expectedErr: "unexpected",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseQuery_BlockTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		queryStr  string
+		fromBlock string
+		toBlock   string
+	}{
+		{
+			name:      "Latest balance",
+			queryStr:  "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK latest",
+			fromBlock: "latest",
+			toBlock:   "latest",
+		},
+		{
+			name:      "Tags are case-insensitive",
+			queryStr:  "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK Finalized SAFE",
+			fromBlock: "finalized",
+			toBlock:   "safe",
+		},
+		{
+			name:      "Hex block numbers",
+			queryStr:  "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 0x12ab34 0x12AB40",
+			fromBlock: "1223476",
+			toBlock:   "1223488",
+		},
+		{
+			name:      "Earliest is block zero",
+			queryStr:  "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK earliest 100",
+			fromBlock: "0",
+			toBlock:   "100",
+		},
+		{
+			name:      "Single block followed by WHERE",
+			queryStr:  "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK pending WHERE value > 0",
+			fromBlock: "pending",
+			toBlock:   "pending",
+		},
+		{
+			name:      "Open range to latest is left to the executor",
+			queryStr:  "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 latest",
+			fromBlock: "1",
+			toBlock:   "latest",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got := queries.FormatBlock(query.FromBlock); got != tt.fromBlock {
+				t.Errorf("Expected from block %s, got %s", tt.fromBlock, got)
+			}
+			if got := queries.FormatBlock(query.ToBlock); got != tt.toBlock {
+				t.Errorf("Expected to block %s, got %s", tt.toBlock, got)
+			}
+		})
+	}
+
+	for _, queryStr := range []string{
+		"SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK newest latest",
+		"SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 0x 10",
+		"SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 gwei 10",
+	} {
+		if _, err := parser.ParseQuery(queryStr); err == nil || !strings.Contains(err.Error(), "invalid from block") {
+			t.Errorf("Expected invalid from block error for %q, got %v", queryStr, err)
+		}
+	}
+}
+
+func TestParseQuery_RelativeBlockRanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		fromBlock   string
+		toBlock     string
+		blockWindow int64
+	}{
+		{
+			name:        "Last N blocks",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LAST 500 BLOCKS",
+			fromBlock:   "<nil>",
+			toBlock:     "latest",
+			blockWindow: 500,
+		},
+		{
+			name:      "Block to latest",
+			queryStr:  "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 19000000 TO latest WHERE removed",
+			fromBlock: "19000000",
+			toBlock:   "latest",
+		},
+		{
+			name:      "Since",
+			queryStr:  "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e SINCE 19000000",
+			fromBlock: "19000000",
+			toBlock:   "latest",
+		},
+		{
+			name:      "Since until",
+			queryStr:  "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e SINCE 100 UNTIL 200 LIMIT 5",
+			fromBlock: "100",
+			toBlock:   "200",
+		},
+		{
+			name:      "Until",
+			queryStr:  "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e UNTIL finalized",
+			fromBlock: "<nil>",
+			toBlock:   "finalized",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			from := "<nil>"
+			if query.FromBlock != nil {
+				from = queries.FormatBlock(query.FromBlock)
+			}
+			if from != tt.fromBlock {
+				t.Errorf("Expected from block %s, got %s", tt.fromBlock, from)
+			}
+			if got := queries.FormatBlock(query.ToBlock); got != tt.toBlock {
+				t.Errorf("Expected to block %s, got %s", tt.toBlock, got)
+			}
+			if query.BlockWindow != tt.blockWindow {
+				t.Errorf("Expected block window %d, got %d", tt.blockWindow, query.BlockWindow)
+			}
+		})
+	}
+}
+
+func TestParseQuery_RelativeBlockRangeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Last without BLOCKS",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LAST 500",
+			expectedErr: "expected BLOCKS after LAST 500",
+		},
+		{
+			name:        "Last zero blocks",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LAST 0 BLOCKS",
+			expectedErr: "invalid block count",
+		},
+		{
+			name:        "Last too many blocks",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LAST 20000 BLOCKS",
+			expectedErr: "block range too large",
+		},
+		{
+			name:        "To without a bound",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 TO",
+			expectedErr: "expected a block number after TO",
+		},
+		{
+			name:        "Since after until",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e SINCE 200 UNTIL 100",
+			expectedErr: "from block cannot be greater than to block",
+		},
+		{
+			name:        "Balance window",
+			queryStr:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LAST 10 BLOCKS",
+			expectedErr: "BALANCE reads a single block",
+		},
+		{
+			name:        "Balance with a second block",
+			queryStr:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 100 200",
+			expectedErr: "BALANCE reads a single block; use BLOCK <number> without a second block",
+		},
+		{
+			name:        "Storage with a TO bound",
+			queryStr:    "SELECT STORAGE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e SLOT 0 BLOCK 100 TO latest",
+			expectedErr: "STORAGE reads a single block",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseQuery_MultipleAddresses(t *testing.T) {
+	first := "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+	second := "0x0000000000000000000000000000000000000001"
+
+	tests := []struct {
+		name              string
+		queryStr          string
+		expectedAddresses []string
+	}{
+		{
+			name:              "Short form list",
+			queryStr:          "SELECT LOGS FROM (" + first + ", " + second + ") BLOCK 1 2",
+			expectedAddresses: []string{first, second},
+		},
+		{
+			name:              "Repeated addresses",
+			queryStr:          "SELECT BALANCE FROM (" + first + ", " + second + ", " + strings.ToLower(first) + ")",
+			expectedAddresses: []string{first, second},
+		},
+		{
+			name:              "Method arguments",
+			queryStr:          "SELECT hash, address FROM TRANSACTIONS(" + second + ", " + first + ") BLOCK 1 2",
+			expectedAddresses: []string{second, first},
+		},
+		{
+			name:              "Single address",
+			queryStr:          "SELECT LOGS FROM " + first + " BLOCK 1 2",
+			expectedAddresses: []string{first},
+		},
+		{
+			name:              "Addresses from WHERE",
+			queryStr:          "SELECT * FROM LOGS BLOCK 1 2 WHERE address IN (" + second + ", " + first + ") AND log_index > 1",
+			expectedAddresses: []string{second, first},
+		},
+		{
+			name:              "Address equality in WHERE",
+			queryStr:          "SELECT from, value FROM TRANSACTIONS BLOCK 1 2 WHERE value > 0 AND address = " + first,
+			expectedAddresses: []string{first},
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(query.Addresses) != len(tt.expectedAddresses) {
+				t.Fatalf("Expected %d addresses, got %v", len(tt.expectedAddresses), query.Addresses)
+			}
+			for i, address := range tt.expectedAddresses {
+				if query.Addresses[i] != common.HexToAddress(address) {
+					t.Errorf("Address %d: expected %s, got %s", i, address, query.Addresses[i].Hex())
+				}
+			}
+			if query.Address != query.Addresses[0] {
+				t.Errorf("Expected Address to be the first address, got %s", query.Address.Hex())
+			}
+		})
+	}
+}
+
+func TestParseQuery_MultipleAddressErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Unclosed list",
+			queryStr:    "SELECT LOGS FROM (0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1 2",
+			expectedErr: "expected ',' or ')' in address list",
+		},
+		{
+			name:        "Invalid address in list",
+			queryStr:    "SELECT LOGS FROM (0x742d35Cc6634C0532925a3b844Bc454e4438f44e, 0x1234) BLOCK 1 2",
+			expectedErr: "invalid Ethereum address",
+		},
+		{
+			name:        "Method without any address",
+			queryStr:    "SELECT * FROM LOGS BLOCK 1 2 WHERE log_index > 1",
+			expectedErr: "LOGS needs an address",
+		},
+		{
+			name:        "Address condition under OR",
+			queryStr:    "SELECT * FROM LOGS BLOCK 1 2 WHERE address = 0x742d35Cc6634C0532925a3b844Bc454e4438f44e OR removed",
+			expectedErr: "LOGS needs an address",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseQuery_ENSNames(t *testing.T) {
+	address := "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+
+	tests := []struct {
+		name              string
+		queryStr          string
+		expectedAddresses int
+		expectedNames     []string
+		expectedWhere     string
+	}{
+		{
+			name:          "Short form",
+			queryStr:      "SELECT BALANCE FROM vitalik.eth",
+			expectedNames: []string{"vitalik.eth"},
+		},
+		{
+			name:          "Quoted name with a hyphen and subdomain",
+			queryStr:      "SELECT LOGS FROM 'Pay.My-Name.eth' LAST 10 BLOCKS",
+			expectedNames: []string{"pay.my-name.eth"},
+		},
+		{
+			name:              "Mixed address list",
+			queryStr:          "SELECT hash, ens_name FROM TRANSACTIONS(" + address + ", vitalik.eth, VITALIK.ETH) BLOCK 1 2",
+			expectedAddresses: 1,
+			expectedNames:     []string{"vitalik.eth"},
+		},
+		{
+			name:          "Name in WHERE",
+			queryStr:      "SELECT * FROM LOGS BLOCK 1 2 WHERE address IN (vitalik.eth, 'nick.eth')",
+			expectedNames: []string{"vitalik.eth", "nick.eth"},
+			expectedWhere: "address IN (vitalik.eth, nick.eth)",
+		},
+		{
+			name:              "Name compared with a column",
+			queryStr:          "SELECT LOGS FROM " + address + " BLOCK 1 2 WHERE address != vitalik.eth",
+			expectedAddresses: 1,
+			expectedWhere:     "address != vitalik.eth",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(query.Addresses) != tt.expectedAddresses {
+				t.Errorf("Expected %d addresses, got %v", tt.expectedAddresses, query.Addresses)
+			}
+			if strings.Join(query.Names, ",") != strings.Join(tt.expectedNames, ",") {
+				t.Errorf("Expected names %v, got %v", tt.expectedNames, query.Names)
+			}
+			if tt.expectedWhere != "" && query.Where.String() != tt.expectedWhere {
+				t.Errorf("Expected WHERE %s, got %s", tt.expectedWhere, query.Where)
+			}
+		})
+	}
+}
+
+func TestParseQuery_ENSNameErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Name without a dot",
+			queryStr:    "SELECT BALANCE FROM vitalik",
+			expectedErr: "invalid Ethereum address format",
+		},
+		{
+			name:        "Empty label",
+			queryStr:    "SELECT BALANCE FROM 'vitalik..eth'",
+			expectedErr: "invalid Ethereum address format",
+		},
+		{
+			name:        "Name compared with a non-address column",
+			queryStr:    "SELECT LOGS FROM vitalik.eth BLOCK 1 2 WHERE topic1 = vitalik.eth",
+			expectedErr: "expected hash value for column topic1",
+		},
+		{
+			name:        "Spaced name",
+			queryStr:    "SELECT BALANCE FROM vitalik. eth",
+			expectedErr: "unexpected '.'",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseQuery_Aliases(t *testing.T) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	treasury := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	valueAlias := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	parser := NewParser()
+	parser.SetAliases(map[string]common.Address{"USDC": usdc, "treasury": treasury, "value": valueAlias})
+
+	tests := []struct {
+		name              string
+		queryStr          string
+		expectedAddresses []common.Address
+		expectedWhere     string
+	}{
+		{
+			name:              "Contract in FROM",
+			queryStr:          "SELECT LOGS FROM usdc BLOCK 1 2",
+			expectedAddresses: []common.Address{usdc},
+		},
+		{
+			name:              "Aliases in a list",
+			queryStr:          "SELECT BALANCE FROM (Treasury, USDC, " + treasury.Hex() + ")",
+			expectedAddresses: []common.Address{treasury, usdc},
+		},
+		{
+			name:              "Alias in WHERE",
+			queryStr:          "SELECT * FROM LOGS BLOCK 1 2 WHERE address = usdc",
+			expectedAddresses: []common.Address{usdc},
+			expectedWhere:     "address = " + usdc.Hex(),
+		},
+		{
+			name:              "Column named like an alias",
+			queryStr:          "SELECT TRANSACTIONS FROM value BLOCK 1 2 WHERE value > 0 AND to IN (usdc, treasury)",
+			expectedAddresses: []common.Address{valueAlias},
+			expectedWhere:     "(value > 0 AND to IN (" + usdc.Hex() + ", " + treasury.Hex() + "))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(query.Addresses) != len(tt.expectedAddresses) {
+				t.Fatalf("Expected addresses %v, got %v", tt.expectedAddresses, query.Addresses)
+			}
+			for i, address := range tt.expectedAddresses {
+				if query.Addresses[i] != address {
+					t.Errorf("Address %d: expected %s, got %s", i, address.Hex(), query.Addresses[i].Hex())
+				}
+			}
+			if tt.expectedWhere != "" && query.Where.String() != tt.expectedWhere {
+				t.Errorf("Expected WHERE %s, got %s", tt.expectedWhere, query.Where)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Unknown name",
+			queryStr:    "SELECT LOGS FROM dai BLOCK 1 2",
+			expectedErr: "invalid Ethereum address format: dai",
+		},
+		{
+			name:        "Alias compared with a number column",
+			queryStr:    "SELECT LOGS FROM usdc BLOCK 1 2 WHERE log_index = treasury",
+			expectedErr: "expected int value for column log_index",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got %v", tt.expectedErr, err)
+			}
+		})
+	}
+
+	if _, err := NewParser().ParseQuery("SELECT LOGS FROM usdc BLOCK 1 2"); err == nil {
+		t.Error("Expected aliases to be set per parser")
+	}
+}
+
+func TestParseQuery_Blocks(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryStr       string
+		expectedFields []string
+		fromBlock      string
+		toBlock        string
+	}{
+		{
+			name:      "Without FROM",
+			queryStr:  "SELECT BLOCKS BLOCK 19000000 19000100",
+			fromBlock: "19000000",
+			toBlock:   "19000100",
+		},
+		{
+			name:      "Without FROM or a block range",
+			queryStr:  "select blocks;",
+			fromBlock: "",
+			toBlock:   "",
+		},
+		{
+			name:           "Columns of a bare method",
+			queryStr:       "SELECT number, gas_used, base_fee FROM BLOCKS BLOCK 19000000 WHERE miner = 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedFields: []string{"number", "gas_used", "base_fee"},
+			fromBlock:      "19000000",
+			toBlock:        "19000000",
+		},
+		{
+			name:           "Empty call",
+			queryStr:       "SELECT number, tx_count FROM BLOCKS() SINCE 19000000 UNTIL 19000010 ORDER BY tx_count DESC LIMIT 3",
+			expectedFields: []string{"number", "tx_count"},
+			fromBlock:      "19000000",
+			toBlock:        "19000010",
+		},
+		{
+			name:           "Aggregates",
+			queryStr:       "SELECT miner, COUNT(*), AVG(gas_used) FROM BLOCKS BLOCK 19000000 19000100 GROUP BY miner",
+			expectedFields: []string{"miner", "COUNT(*)", "AVG(gas_used)"},
+			fromBlock:      "19000000",
+			toBlock:        "19000100",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if query.Method != "BLOCKS" {
+				t.Errorf("Expected method BLOCKS, got %s", query.Method)
+			}
+			if len(query.Addresses) != 0 || len(query.Names) != 0 {
+				t.Errorf("Expected no addresses, got %v %v", query.Addresses, query.Names)
+			}
+
+			if len(query.Fields) != len(tt.expectedFields) {
+				t.Fatalf("Expected %d fields, got %d", len(tt.expectedFields), len(query.Fields))
+			}
+			for i, name := range tt.expectedFields {
+				if query.Fields[i].Name != name {
+					t.Errorf("Field %d: expected %s, got %s", i, name, query.Fields[i].Name)
+				}
+			}
+
+			if got := blockString(query.FromBlock); got != tt.fromBlock {
+				t.Errorf("Expected from block %q, got %q", tt.fromBlock, got)
+			}
+			if got := blockString(query.ToBlock); got != tt.toBlock {
+				t.Errorf("Expected to block %q, got %q", tt.toBlock, got)
+			}
+		})
+	}
+}
+
+func TestParseQuery_BlocksErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Address in short form",
+			queryStr:    "SELECT BLOCKS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedErr: "BLOCKS does not take an address",
+		},
+		{
+			name:        "Address argument",
+			queryStr:    "SELECT number FROM BLOCKS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+			expectedErr: "BLOCKS does not take an address",
+		},
+		{
+			name:        "No address column",
+			queryStr:    "SELECT BLOCKS WHERE address = 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedErr: "unknown column: address",
+		},
+		{
+			name:        "Method needing an address without FROM",
+			queryStr:    "SELECT BALANCE BLOCK 19000000",
+			expectedErr: "BALANCE needs an address",
+		},
+		{
+			name:        "Unknown method without FROM",
+			queryStr:    "SELECT BLOCKZ LAST 10 BLOCKS",
+			expectedErr: "unsupported method: BLOCKZ",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+// blockString renders a block bound for comparison, "" when it is unset
+func blockString(block *big.Int) string {
+	if block == nil {
+		return ""
+	}
+	return block.String()
+}
+
+func TestParseQuery_AccountState(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryStr       string
+		expectedMethod string
+		expectedSlots  []common.Hash
+		block          string
+	}{
+		{
+			name:           "Code",
+			queryStr:       "SELECT CODE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 19000000",
+			expectedMethod: "CODE",
+			block:          "19000000",
+		},
+		{
+			name:           "Nonce",
+			queryStr:       "SELECT nonce FROM NONCE(0x742d35Cc6634C0532925a3b844Bc454e4438f44e)",
+			expectedMethod: "NONCE",
+		},
+		{
+			name:           "Storage slots",
+			queryStr:       "SELECT STORAGE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e SLOT 0, 0x0a BLOCK 19000000",
+			expectedMethod: "STORAGE",
+			expectedSlots:  []common.Hash{common.BigToHash(big.NewInt(0)), common.BigToHash(big.NewInt(10))},
+			block:          "19000000",
+		},
+		{
+			name:           "Storage slot as a full word",
+			queryStr:       "SELECT slot, value FROM STORAGE(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) SLOT 0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc",
+			expectedMethod: "STORAGE",
+			expectedSlots:  []common.Hash{common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")},
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			if query.Method != tt.expectedMethod {
+				t.Errorf("Expected method %s, got %s", tt.expectedMethod, query.Method)
+			}
+			if len(query.Slots) != len(tt.expectedSlots) {
+				t.Fatalf("Expected %d slots, got %d", len(tt.expectedSlots), len(query.Slots))
+			}
+			for i, slot := range tt.expectedSlots {
+				if query.Slots[i] != slot {
+					t.Errorf("Slot %d: expected %s, got %s", i, slot.Hex(), query.Slots[i].Hex())
+				}
+			}
+			if got := blockString(query.FromBlock); got != tt.block {
+				t.Errorf("Expected block %q, got %q", tt.block, got)
+			}
+		})
+	}
+}
+
+func TestParseQuery_AccountStateErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Storage without a slot",
+			queryStr:    "SELECT STORAGE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedErr: "STORAGE needs a slot",
+		},
+		{
+			name:        "Slot outside STORAGE",
+			queryStr:    "SELECT CODE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e SLOT 0",
+			expectedErr: "SLOT is only supported for STORAGE",
+		},
+		{
+			name:        "Negative slot",
+			queryStr:    "SELECT STORAGE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e SLOT -1",
+			expectedErr: "invalid storage slot: -1",
+		},
+		{
+			name:        "Slot wider than a word",
+			queryStr:    "SELECT STORAGE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e SLOT 0x01" + strings.Repeat("00", 32),
+			expectedErr: "invalid storage slot",
+		},
+		{
+			name:        "Missing slot",
+			queryStr:    "SELECT STORAGE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e SLOT BLOCK 1",
+			expectedErr: "expected a storage slot after SLOT",
+		},
+		{
+			name:        "Block range",
+			queryStr:    "SELECT NONCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LAST 10 BLOCKS",
+			expectedErr: "NONCE reads a single block",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseQuery_Tokens(t *testing.T) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	dai := common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	holder := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+
+	parser := NewParser()
+	parser.SetAliases(map[string]common.Address{"usdc": usdc, "dai": dai})
+
+	tests := []struct {
+		name              string
+		queryStr          string
+		expectedMethod    string
+		expectedAddresses []common.Address
+		expectedTokens    []common.Address
+		expectedNames     []string
+		block             string
+	}{
+		{
+			name:              "Balances of several tokens",
+			queryStr:          "SELECT TOKEN_BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e TOKEN (usdc, dai, 0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48) BLOCK 19000000",
+			expectedMethod:    "TOKEN_BALANCE",
+			expectedAddresses: []common.Address{holder},
+			expectedTokens:    []common.Address{usdc, dai},
+			block:             "19000000",
+		},
+		{
+			name:              "Single token without parentheses",
+			queryStr:          "SELECT symbol, amount FROM TOKEN_BALANCE(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) TOKEN usdc WHERE balance > 0",
+			expectedMethod:    "TOKEN_BALANCE",
+			expectedAddresses: []common.Address{holder},
+			expectedTokens:    []common.Address{usdc},
+		},
+		{
+			name:           "ENS names",
+			queryStr:       "SELECT TOKEN_BALANCE FROM vitalik.eth TOKEN (dai.eth, usdc, 'DAI.eth')",
+			expectedMethod: "TOKEN_BALANCE",
+			expectedTokens: []common.Address{usdc},
+			expectedNames:  []string{"dai.eth"},
+		},
+		{
+			name:              "Token info",
+			queryStr:          "SELECT TOKEN_INFO FROM (usdc, dai)",
+			expectedMethod:    "TOKEN_INFO",
+			expectedAddresses: []common.Address{usdc, dai},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if query.Method != tt.expectedMethod {
+				t.Errorf("Expected method %s, got %s", tt.expectedMethod, query.Method)
+			}
+			if !reflect.DeepEqual(query.Addresses, tt.expectedAddresses) {
+				t.Errorf("Expected addresses %v, got %v", tt.expectedAddresses, query.Addresses)
+			}
+			if !reflect.DeepEqual(query.Tokens, tt.expectedTokens) {
+				t.Errorf("Expected tokens %v, got %v", tt.expectedTokens, query.Tokens)
+			}
+			if !reflect.DeepEqual(query.TokenNames, tt.expectedNames) {
+				t.Errorf("Expected token names %v, got %v", tt.expectedNames, query.TokenNames)
+			}
+			if got := blockString(query.FromBlock); got != tt.block {
+				t.Errorf("Expected block %q, got %q", tt.block, got)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Balance without a token",
+			queryStr:    "SELECT TOKEN_BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedErr: "TOKEN_BALANCE needs a token",
+		},
+		{
+			name:        "Token outside TOKEN_BALANCE",
+			queryStr:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e TOKEN usdc",
+			expectedErr: "TOKEN is only supported for TOKEN_BALANCE",
+		},
+		{
+			name:        "Missing token",
+			queryStr:    "SELECT TOKEN_BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e TOKEN BLOCK 1",
+			expectedErr: "expected a token address after TOKEN",
+		},
+		{
+			name:        "Invalid token",
+			queryStr:    "SELECT TOKEN_BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e TOKEN (usdc, 0x1234)",
+			expectedErr: "invalid Ethereum address",
+		},
+		{
+			name:        "Block range",
+			queryStr:    "SELECT TOKEN_INFO FROM usdc LAST 10 BLOCKS",
+			expectedErr: "TOKEN_INFO reads a single block",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParseQuery_Transfers(t *testing.T) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	wallet := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+
+	parser := NewParser()
+	parser.SetAliases(map[string]common.Address{"usdc": usdc})
+
+	query, err := parser.ParseQuery("SELECT token, from, to, amount FROM TRANSFERS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) BLOCK 19000000 19000100 WHERE token = usdc AND standard = 'ERC-20'")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if query.Method != "TRANSFERS" || query.Address != wallet {
+		t.Errorf("Expected TRANSFERS of %s, got %s of %s", wallet.Hex(), query.Method, query.Address.Hex())
+	}
+	if got := blockString(query.FromBlock) + "-" + blockString(query.ToBlock); got != "19000000-19000100" {
+		t.Errorf("Expected blocks 19000000-19000100, got %s", got)
+	}
+	if got := query.Where.String(); !strings.Contains(got, "token = "+usdc.Hex()) {
+		t.Errorf("Expected the token condition to use the usdc alias, got %s", got)
+	}
+
+	_, err = parser.ParseQuery("SELECT * FROM TRANSFERS LAST 10 BLOCKS")
+	if err == nil || !strings.Contains(err.Error(), "TRANSFERS needs an address") {
+		t.Errorf("Expected an error about the missing address, got: %v", err)
+	}
+}
+
+func TestParseQuery_NFTs(t *testing.T) {
+	punks := common.HexToAddress("0xb47e3cd837dDF8e4c57F05d70Ab865de6e193BBB")
+	wallet := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+
+	parser := NewParser()
+	parser.SetAliases(map[string]common.Address{"punks": punks})
+
+	tests := []struct {
+		name                string
+		queryStr            string
+		expectedMethod      string
+		expectedAddresses   []common.Address
+		expectedCollections []common.Address
+		expectedNames       []string
+		expectedIDs         []*big.Int
+		block               string
+	}{
+		{
+			name:                "Holdings in a collection",
+			queryStr:            "SELECT NFTS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e COLLECTION punks LAST 5000 BLOCKS",
+			expectedMethod:      "NFTS",
+			expectedAddresses:   []common.Address{wallet},
+			expectedCollections: []common.Address{punks},
+		},
+		{
+			name:                "Several collections",
+			queryStr:            "SELECT token_id, amount FROM NFTS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) COLLECTION (punks, 0xb47e3cd837dDF8e4c57F05d70Ab865de6e193BBB, 0x57f1887a8BF19b14fC0dF6Fd9B2acc9Af147eA85)",
+			expectedMethod:      "NFTS",
+			expectedAddresses:   []common.Address{wallet},
+			expectedCollections: []common.Address{punks, common.HexToAddress("0x57f1887a8BF19b14fC0dF6Fd9B2acc9Af147eA85")},
+		},
+		{
+			name:                "ENS names",
+			queryStr:            "SELECT NFTS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e COLLECTION (punks, 'Nouns.eth', nouns.eth)",
+			expectedMethod:      "NFTS",
+			expectedAddresses:   []common.Address{wallet},
+			expectedCollections: []common.Address{punks},
+			expectedNames:       []string{"nouns.eth"},
+		},
+		{
+			name:              "Owners of token IDs",
+			queryStr:          "SELECT OWNER FROM punks TOKEN_ID 1234, 0x10, 1234 BLOCK 19000000",
+			expectedMethod:    "OWNER",
+			expectedAddresses: []common.Address{punks},
+			expectedIDs:       []*big.Int{big.NewInt(1234), big.NewInt(16)},
+			block:             "19000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if query.Method != tt.expectedMethod {
+				t.Errorf("Expected method %s, got %s", tt.expectedMethod, query.Method)
+			}
+			if !reflect.DeepEqual(query.AddressList(), tt.expectedAddresses) {
+				t.Errorf("Expected addresses %v, got %v", tt.expectedAddresses, query.AddressList())
+			}
+			if !reflect.DeepEqual(query.Collections, tt.expectedCollections) {
+				t.Errorf("Expected collections %v, got %v", tt.expectedCollections, query.Collections)
+			}
+			if !reflect.DeepEqual(query.CollectionNames, tt.expectedNames) {
+				t.Errorf("Expected collection names %v, got %v", tt.expectedNames, query.CollectionNames)
+			}
+			if !reflect.DeepEqual(query.TokenIDs, tt.expectedIDs) {
+				t.Errorf("Expected token IDs %v, got %v", tt.expectedIDs, query.TokenIDs)
+			}
+			if tt.block != "" {
+				if got := blockString(query.FromBlock); got != tt.block {
+					t.Errorf("Expected block %q, got %q", tt.block, got)
+				}
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Holdings without a collection",
+			queryStr:    "SELECT NFTS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+			expectedErr: "NFTS needs a collection",
+		},
+		{
+			name:        "Owner without a token ID",
+			queryStr:    "SELECT OWNER FROM punks",
+			expectedErr: "OWNER needs a token ID",
+		},
+		{
+			name:        "Collection outside NFTS",
+			queryStr:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e COLLECTION punks",
+			expectedErr: "COLLECTION is only supported for NFTS",
+		},
+		{
+			name:        "Token ID outside OWNER",
+			queryStr:    "SELECT NFTS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e COLLECTION punks TOKEN_ID 1",
+			expectedErr: "TOKEN_ID is only supported for OWNER",
+		},
+		{
+			name:        "Missing token ID",
+			queryStr:    "SELECT OWNER FROM punks TOKEN_ID WHERE owner IS NULL",
+			expectedErr: "expected a token ID after TOKEN_ID",
+		},
+		{
+			name:        "Negative token ID",
+			queryStr:    "SELECT OWNER FROM punks TOKEN_ID -1",
+			expectedErr: "invalid token ID",
+		},
+		{
+			name:        "Owner over a block range",
+			queryStr:    "SELECT OWNER FROM punks TOKEN_ID 1 LAST 10 BLOCKS",
+			expectedErr: "OWNER reads a single block",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParseQuery_Traces(t *testing.T) {
+	wallet := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	parser := NewParser()
+
+	for _, tt := range []struct {
+		queryStr string
+		method   string
+	}{
+		{queryStr: "SELECT INTERNAL_TRANSFERS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 19000000 19000100", method: "INTERNAL_TRANSFERS"},
+		{queryStr: "SELECT from, to, value, error FROM TRACES(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) BLOCK 19000000 19000100 WHERE depth > 0 AND type = 'CALL'", method: "TRACES"},
+	} {
+		query, err := parser.ParseQuery(tt.queryStr)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", tt.queryStr, err)
+		}
+		if query.Method != tt.method || query.Address != wallet {
+			t.Errorf("Expected %s of %s, got %s of %s", tt.method, wallet.Hex(), query.Method, query.Address.Hex())
+		}
+		if got := blockString(query.FromBlock) + "-" + blockString(query.ToBlock); got != "19000000-19000100" {
+			t.Errorf("Expected blocks 19000000-19000100, got %s", got)
+		}
+	}
+}
+
+func TestParseQuery_StateDiff(t *testing.T) {
+	wallet := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	first := common.HexToHash("0x88df016429689c079f3b2f6ad39fa052532c56795b733da78a91ebe6a713944b")
+	second := common.HexToHash("0x2f1c5c2b44f771e942a8506148e256f94f1a464babc938ae0690c6e34cd79190")
+	parser := NewParser()
+
+	tests := []struct {
+		name              string
+		queryStr          string
+		expectedAddresses []common.Address
+		expectedHashes    []common.Hash
+		block             string
+	}{
+		{
+			name:           "Every account of a transaction",
+			queryStr:       "SELECT STATE_DIFF FROM TX 0x88df016429689c079f3b2f6ad39fa052532c56795b733da78a91ebe6a713944b",
+			expectedHashes: []common.Hash{first},
+		},
+		{
+			name:              "One account of several transactions",
+			queryStr:          "SELECT field, slot, before, after FROM STATE_DIFF(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) TX (0x88df016429689c079f3b2f6ad39fa052532c56795b733da78a91ebe6a713944b, 0x2f1c5c2b44f771e942a8506148e256f94f1a464babc938ae0690c6e34cd79190)",
+			expectedAddresses: []common.Address{wallet},
+			expectedHashes:    []common.Hash{first, second},
+		},
+		{
+			name:     "Every account of a block",
+			queryStr: "SELECT STATE_DIFF BLOCK 19000000 WHERE field = 'storage'",
+			block:    "19000000",
+		},
+		{
+			name:              "One account of a block",
+			queryStr:          "SELECT STATE_DIFF FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 19000000",
+			expectedAddresses: []common.Address{wallet},
+			block:             "19000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if query.Method != "STATE_DIFF" {
+				t.Errorf("Expected method STATE_DIFF, got %s", query.Method)
+			}
+			if query.AnyAddress != (len(tt.expectedAddresses) == 0) {
+				t.Errorf("Expected AnyAddress to be %v", len(tt.expectedAddresses) == 0)
+			}
+			if len(tt.expectedAddresses) > 0 && !reflect.DeepEqual(query.AddressList(), tt.expectedAddresses) {
+				t.Errorf("Expected addresses %v, got %v", tt.expectedAddresses, query.AddressList())
+			}
+			if !reflect.DeepEqual(query.TxHashes, tt.expectedHashes) {
+				t.Errorf("Expected transactions %v, got %v", tt.expectedHashes, query.TxHashes)
+			}
+			if tt.block != "" {
+				if got := blockString(query.FromBlock); got != tt.block {
+					t.Errorf("Expected block %q, got %q", tt.block, got)
+				}
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Short transaction hash",
+			queryStr:    "SELECT STATE_DIFF FROM TX 0x88df0164",
+			expectedErr: "invalid transaction hash",
+		},
+		{
+			name:        "Transaction outside STATE_DIFF",
+			queryStr:    "SELECT TRACES FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e TX 0x88df016429689c079f3b2f6ad39fa052532c56795b733da78a91ebe6a713944b",
+			expectedErr: "TX is only supported for STATE_DIFF",
+		},
+		{
+			name:        "Transaction and block range",
+			queryStr:    "SELECT STATE_DIFF FROM TX 0x88df016429689c079f3b2f6ad39fa052532c56795b733da78a91ebe6a713944b BLOCK 19000000",
+			expectedErr: "not both",
+		},
+		{
+			name:        "Fields without a method",
+			queryStr:    "SELECT field, before FROM TX 0x88df016429689c079f3b2f6ad39fa052532c56795b733da78a91ebe6a713944b",
+			expectedErr: "expected <method>(<address>) after FROM",
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestParseQuery_Pending(t *testing.T) {
+	router := common.HexToAddress("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D")
+	parser := NewParser()
+
+	query, err := parser.ParseQuery("SELECT hash, from, method FROM PENDING(0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D, 'swapExactTokensForTokens(uint256,uint256,address[],address,uint256)') WHERE pool = 'pending' AND max_priority_fee_per_gas > 1000000000")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if query.Method != "PENDING" || query.Address != router {
+		t.Errorf("Expected PENDING of %s, got %s of %s", router.Hex(), query.Method, query.Address.Hex())
+	}
+	if query.Function == nil || query.Function.RawName != "swapExactTokensForTokens" {
+		t.Errorf("Expected the swapExactTokensForTokens function, got %v", query.Function)
+	}
+	if query.FromBlock != nil || query.ToBlock != nil {
+		t.Errorf("Expected no block range, got %s-%s", blockString(query.FromBlock), blockString(query.ToBlock))
+	}
+
+	_, err = parser.ParseQuery("SELECT PENDING FROM 0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D LAST 10 BLOCKS")
+	if err == nil || !strings.Contains(err.Error(), "PENDING reads the transaction pool and takes no block range") {
+		t.Errorf("Expected an error for a block range, got: %v", err)
+	}
+}
+
+func TestParseQuery_Fees(t *testing.T) {
+	parser := NewParser()
+
+	query, err := parser.ParseQuery("SELECT block_number, base_fee, reward_p50 FROM FEES BLOCK 19000000 19000100 WHERE gas_used_bps > 9000")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if query.Method != "FEES" || len(query.Addresses) != 0 {
+		t.Errorf("Expected FEES without addresses, got %s of %v", query.Method, query.Addresses)
+	}
+	if from, to := blockString(query.FromBlock), blockString(query.ToBlock); from != "19000000" || to != "19000100" {
+		t.Errorf("Expected blocks 19000000-19000100, got %s-%s", from, to)
+	}
+
+	if _, err := parser.ParseQuery("SELECT FEES BLOCK 19000000 19000100"); err != nil {
+		t.Errorf("Expected no error for FEES without FROM, got: %v", err)
+	}
+
+	_, err = parser.ParseQuery("SELECT * FROM FEES BLOCK 19000000 19000100 WHERE reward_p99 > 0")
+	if err == nil || !strings.Contains(err.Error(), "reward_p99") {
+		t.Errorf("Expected an error for an unknown percentile, got: %v", err)
+	}
+}
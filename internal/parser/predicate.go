@@ -0,0 +1,329 @@
+package parser
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// units maps ether denominations to their power of ten in wei
+var units = map[string]int64{
+	"wei":    0,
+	"kwei":   3,
+	"mwei":   6,
+	"gwei":   9,
+	"szabo":  12,
+	"finney": 15,
+	"ether":  18,
+	"eth":    18,
+}
+
+func isUnit(name string) bool {
+	_, ok := units[strings.ToLower(name)]
+	return ok
+}
+
+// buildPredicate type-checks a WHERE expression against the schema of the queried method
+func buildPredicate(expr Expr, schema queries.Schema) (queries.Expr, error) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if e.Op == "AND" || e.Op == "OR" {
+			left, err := buildPredicate(e.Left, schema)
+			if err != nil {
+				return nil, err
+			}
+			right, err := buildPredicate(e.Right, schema)
+			if err != nil {
+				return nil, err
+			}
+			return &queries.Logical{Op: e.Op, Left: left, Right: right}, nil
+		}
+		if isEventRef(e.Left, schema) || isEventRef(e.Right, schema) {
+			return buildEventComparison(e, schema)
+		}
+		return buildComparison(e, schema)
+
+	case *UnaryExpr:
+		if e.Op == "NOT" {
+			x, err := buildPredicate(e.X, schema)
+			if err != nil {
+				return nil, err
+			}
+			return &queries.Not{X: x}, nil
+		}
+
+	case *InExpr:
+		if isEventRef(e.X, schema) {
+			return buildEventIn(e)
+		}
+		column, field, err := buildColumn(e.X, schema)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]queries.Expr, len(e.List))
+		for i, item := range e.List {
+			value, err := coerceLiteral(item, field)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = &queries.Literal{Value: value}
+		}
+		return &queries.In{X: column, List: list, Negate: e.Not}, nil
+
+	case *BetweenExpr:
+		column, field, err := buildColumn(e.X, schema)
+		if err != nil {
+			return nil, err
+		}
+		if !field.Type.Ordered() {
+			return nil, errorAt(e.X, "BETWEEN is not supported for %s column %s", field.Type, field.Name)
+		}
+		low, err := coerceLiteral(e.Low, field)
+		if err != nil {
+			return nil, err
+		}
+		high, err := coerceLiteral(e.High, field)
+		if err != nil {
+			return nil, err
+		}
+		return &queries.Between{
+			X:      column,
+			Low:    &queries.Literal{Value: low},
+			High:   &queries.Literal{Value: high},
+			Negate: e.Not,
+		}, nil
+
+	case *IsNullExpr:
+		column, _, err := buildColumn(e.X, schema)
+		if err != nil {
+			return nil, err
+		}
+		return &queries.IsNull{X: column, Negate: e.Not}, nil
+
+	case *Ident:
+		// A bare boolean column, as in "WHERE removed"
+		if _, isLiteral := boolLiteral(e); !isLiteral {
+			column, field, err := buildColumn(e, schema)
+			if err != nil {
+				return nil, err
+			}
+			if field.Type == queries.TypeBool {
+				return column, nil
+			}
+		}
+	}
+
+	return nil, errorAt(expr, "expected a condition")
+}
+
+// buildComparison type-checks "a <op> b" where at least one side is a column
+func buildComparison(e *BinaryExpr, schema queries.Schema) (queries.Expr, error) {
+	left, leftField, leftErr := buildColumn(e.Left, schema)
+	right, rightField, rightErr := buildColumn(e.Right, schema)
+
+	switch {
+	case leftErr == nil && rightErr == nil:
+		if leftField.Type != rightField.Type {
+			return nil, errorAt(e, "cannot compare %s column %s with %s column %s", leftField.Type, leftField.Name, rightField.Type, rightField.Name)
+		}
+	case leftErr == nil:
+		if !isLiteral(e.Right) {
+			return nil, rightErr
+		}
+		value, err := coerceLiteral(e.Right, leftField)
+		if err != nil {
+			return nil, err
+		}
+		right, rightField = &queries.Literal{Value: value}, leftField
+	case rightErr == nil:
+		if !isLiteral(e.Left) {
+			return nil, leftErr
+		}
+		value, err := coerceLiteral(e.Left, rightField)
+		if err != nil {
+			return nil, err
+		}
+		left, leftField = &queries.Literal{Value: value}, rightField
+	default:
+		if isLiteral(e.Left) && isLiteral(e.Right) {
+			return nil, errorAt(e, "comparison must reference at least one column")
+		}
+		if !isLiteral(e.Left) {
+			return nil, leftErr
+		}
+		return nil, rightErr
+	}
+
+	if e.Op != "=" && e.Op != "!=" && !leftField.Type.Ordered() {
+		return nil, errorAt(e, "operator %s is not supported for %s column %s", e.Op, leftField.Type, leftField.Name)
+	}
+
+	return &queries.Comparison{Op: e.Op, Left: left, Right: right}, nil
+}
+
+// buildColumn resolves an identifier to a field of the schema
+func buildColumn(expr Expr, schema queries.Schema) (queries.Expr, queries.Field, error) {
+	// Aggregates are columns of grouped rows, named like "COUNT(*)"
+	if call, ok := expr.(*CallExpr); ok {
+		if !aggregateFuncs[strings.ToUpper(call.Func.Name)] {
+			return nil, queries.Field{}, errorAt(expr, "unknown function: %s (supported: %s)", call.Func.Name, supportedAggregates())
+		}
+		field, ok := schema.Lookup(callText(call))
+		if !ok {
+			return nil, queries.Field{}, errorAt(expr, "aggregate %s is not allowed here; filter groups with HAVING", callText(call))
+		}
+		return &queries.ColumnRef{Name: field.Name}, field, nil
+	}
+
+	ident, ok := expr.(*Ident)
+	if !ok {
+		return nil, queries.Field{}, errorAt(expr, "expected a column name")
+	}
+	if _, isLiteral := boolLiteral(ident); isLiteral || strings.EqualFold(ident.Name, "NULL") {
+		return nil, queries.Field{}, errorAt(expr, "expected a column name")
+	}
+	field, ok := schema.Lookup(ident.Name)
+	if !ok {
+		if _, logs := schema.Lookup("topic0"); logs && isArg(ident.Name) && !hasArgs(schema) {
+			return nil, queries.Field{}, errorAt(expr, "unknown column: %s (name the event to decode, as in LOGS(<address>, 'Transfer'))", ident.Name)
+		}
+		return nil, queries.Field{}, errorAt(expr, "unknown column: %s (available: %s)", ident.Name, strings.Join(schema.Names(), ", "))
+	}
+	return &queries.ColumnRef{Name: field.Name}, field, nil
+}
+
+// isLiteral reports whether expr is a constant rather than a column reference
+func isLiteral(expr Expr) bool {
+	switch e := expr.(type) {
+	case *NumberLit, *HexLit, *StringLit, *AliasLit:
+		return true
+	case *UnaryExpr:
+		return e.Op == "-"
+	case *Ident:
+		_, ok := boolLiteral(e)
+		name := strings.Contains(e.Name, ".") && !isArg(e.Name) && !isResult(e.Name)
+		return ok || strings.EqualFold(e.Name, "NULL") || name
+	}
+	return false
+}
+
+func boolLiteral(ident *Ident) (bool, bool) {
+	switch strings.ToUpper(ident.Name) {
+	case "TRUE":
+		return true, true
+	case "FALSE":
+		return false, true
+	}
+	return false, false
+}
+
+// coerceLiteral converts a literal to the Go value used for the field's type
+func coerceLiteral(expr Expr, field queries.Field) (interface{}, error) {
+	if ident, ok := expr.(*Ident); ok && strings.EqualFold(ident.Name, "NULL") {
+		return nil, errorAt(expr, "NULL cannot be compared with =; use IS NULL")
+	}
+
+	switch field.Type {
+	case queries.TypeInt:
+		if value, ok := intLiteral(expr); ok {
+			return value, nil
+		}
+	case queries.TypeAddress:
+		if alias, ok := expr.(*AliasLit); ok {
+			return alias.Address, nil
+		}
+		if text, ok := hexText(expr); ok && common.IsHexAddress(text) {
+			return common.HexToAddress(text), nil
+		}
+		if name, ok := ensName(expr); ok {
+			return queries.ENSName(name), nil
+		}
+	case queries.TypeHash:
+		if text, ok := hexText(expr); ok && len(text) == 2+2*common.HashLength {
+			if b, err := hexutil.Decode(text); err == nil {
+				return common.BytesToHash(b), nil
+			}
+		}
+		// Indexed address parameters are stored in topics left-padded to 32 bytes
+		if isTopic(field.Name) {
+			if alias, ok := expr.(*AliasLit); ok {
+				return common.BytesToHash(alias.Address.Bytes()), nil
+			}
+			if text, ok := hexText(expr); ok && common.IsHexAddress(text) {
+				return common.BytesToHash(common.HexToAddress(text).Bytes()), nil
+			}
+		}
+	case queries.TypeBytes:
+		if text, ok := hexText(expr); ok {
+			if b, err := hexutil.Decode(text); err == nil {
+				return b, nil
+			}
+		}
+	case queries.TypeString:
+		if lit, ok := expr.(*StringLit); ok {
+			return lit.Value, nil
+		}
+	case queries.TypeTime:
+		if t, ok := timeLiteral(expr); ok {
+			return t, nil
+		}
+	case queries.TypeBool:
+		if ident, ok := expr.(*Ident); ok {
+			if value, ok := boolLiteral(ident); ok {
+				return value, nil
+			}
+		}
+	}
+
+	return nil, errorAt(expr, "expected %s value for column %s", field.Type, field.Name)
+}
+
+// intLiteral parses decimal (optionally with a denomination), hex and negated integers
+func intLiteral(expr Expr) (*big.Int, bool) {
+	switch e := expr.(type) {
+	case *NumberLit:
+		value, ok := new(big.Rat).SetString(e.Value)
+		if !ok {
+			return nil, false
+		}
+		if exp, ok := units[e.Unit]; ok && exp > 0 {
+			scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil)
+			value.Mul(value, new(big.Rat).SetInt(scale))
+		}
+		if !value.IsInt() {
+			return nil, false
+		}
+		return new(big.Int).Set(value.Num()), true
+	case *HexLit:
+		if len(e.Value) <= 2 {
+			return nil, false
+		}
+		return new(big.Int).SetString(e.Value[2:], 16)
+	case *UnaryExpr:
+		if e.Op != "-" {
+			return nil, false
+		}
+		value, ok := intLiteral(e.X)
+		if !ok {
+			return nil, false
+		}
+		return value.Neg(value), true
+	}
+	return nil, false
+}
+
+// hexText returns the text of a hex literal, or of a string holding one
+func hexText(expr Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *HexLit:
+		return e.Value, true
+	case *StringLit:
+		if strings.HasPrefix(e.Value, "0x") || strings.HasPrefix(e.Value, "0X") {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
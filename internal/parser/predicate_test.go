@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/devlongs/evmql/queries"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testAddress = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+
+func TestParseQuery_Where(t *testing.T) {
+	tests := []struct {
+		name     string
+		queryStr string
+		expected string
+	}{
+		{
+			name:     "Units and address equality",
+			queryStr: "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE value > 1 ether AND gas_price < 30 gwei AND to = " + testAddress,
+			expected: "((value > 1000000000000000000 AND gas_price < 30000000000) AND to = " + testAddress + ")",
+		},
+		{
+			name:     "Precedence of AND over OR",
+			queryStr: "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE nonce = 1 OR nonce = 2 AND value = 0",
+			expected: "(nonce = 1 OR (nonce = 2 AND value = 0))",
+		},
+		{
+			name:     "Parentheses and NOT",
+			queryStr: "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE NOT (nonce = 1 OR nonce = 2)",
+			expected: "NOT (nonce = 1 OR nonce = 2)",
+		},
+		{
+			name:     "IN, BETWEEN and IS NULL",
+			queryStr: "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE type IN (0, 2) AND block_number NOT BETWEEN 0x1 AND 10 AND to IS NULL",
+			expected: "((type IN (0, 2) AND block_number NOT BETWEEN 1 AND 10) AND to IS NULL)",
+		},
+		{
+			name:     "Literal on the left and <> operator",
+			queryStr: "SELECT LOGS FROM " + testAddress + " BLOCK 1 2 WHERE 1.5 gwei <> log_index",
+			expected: "1500000000 != log_index",
+		},
+		{
+			name:     "Bare boolean column",
+			queryStr: "SELECT LOGS FROM " + testAddress + " BLOCK 1 2 WHERE NOT removed",
+			expected: "NOT removed",
+		},
+		{
+			name:     "Balance fields",
+			queryStr: "SELECT BALANCE FROM " + testAddress + " WHERE balance >= 0.5 ether",
+			expected: "balance >= 500000000000000000",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if query.Where == nil {
+				t.Fatal("Expected WHERE predicate to be set")
+			}
+			if query.Where.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, query.Where.String())
+			}
+		})
+	}
+}
+
+func TestParseQuery_WhereTypedLiterals(t *testing.T) {
+	parser := NewParser()
+	hash := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+	query, err := parser.ParseQuery("SELECT LOGS FROM " + testAddress + " BLOCK 1 2 WHERE topic0 = " + hash + " AND address = '" + testAddress + "'")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	terms := queries.Conjuncts(query.Where)
+	if len(terms) != 2 {
+		t.Fatalf("Expected 2 conjuncts, got %d", len(terms))
+	}
+
+	topic := terms[0].(*queries.Comparison).Right.(*queries.Literal).Value
+	if topic != common.HexToHash(hash) {
+		t.Errorf("Expected topic0 literal to be a common.Hash, got %T %v", topic, topic)
+	}
+
+	address := terms[1].(*queries.Comparison).Right.(*queries.Literal).Value
+	if address != common.HexToAddress(testAddress) {
+		t.Errorf("Expected address literal to be a common.Address, got %T %v", address, address)
+	}
+
+	matched, err := queries.Matches(query.Where, queries.Record{
+		"topic0":  common.HexToHash(hash),
+		"address": common.HexToAddress(testAddress),
+		"value":   big.NewInt(1),
+	})
+	if err != nil || !matched {
+		t.Errorf("Expected record to match, got %v (err: %v)", matched, err)
+	}
+}
+
+func TestParseQuery_WhereErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Unknown column",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE colour = 1",
+			expectedErr: "unknown column: colour",
+		},
+		{
+			name:        "Column from another method",
+			queryStr:    "SELECT BALANCE FROM " + testAddress + " WHERE topic0 = 1",
+			expectedErr: "unknown column: topic0",
+		},
+		{
+			name:        "Wrong literal type",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE to = 42",
+			expectedErr: "expected address value for column to",
+		},
+		{
+			name:        "Fractional wei",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE value > 1.5 wei",
+			expectedErr: "expected int value for column value",
+		},
+		{
+			name:        "Ordering on addresses",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE from > " + testAddress,
+			expectedErr: "operator > is not supported for address column from",
+		},
+		{
+			name:        "Mismatched column types",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE from = value",
+			expectedErr: "cannot compare address column from with int column value",
+		},
+		{
+			name:        "Equality with NULL",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE to = NULL",
+			expectedErr: "use IS NULL",
+		},
+		{
+			name:        "Non-boolean condition",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE value",
+			expectedErr: "expected a condition",
+		},
+		{
+			name:        "Two literals",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE 1 = 1",
+			expectedErr: "comparison must reference at least one column",
+		},
+		{
+			name:        "Unclosed parenthesis",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE (nonce = 1",
+			expectedErr: "expected ')'",
+		},
+		{
+			name:        "IS without NULL",
+			queryStr:    "SELECT TRANSACTIONS FROM " + testAddress + " BLOCK 1 2 WHERE to IS 5",
+			expectedErr: "expected NULL after IS",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"math/big"
+	"time"
+)
+
+// timestampLayouts are the accepted timestamp spellings; those without a zone are UTC
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimestamp parses a date or date-time string
+func parseTimestamp(text string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// timeLiteral converts a quoted timestamp or a number of Unix seconds into a time
+func timeLiteral(expr Expr) (time.Time, bool) {
+	switch e := expr.(type) {
+	case *StringLit:
+		return parseTimestamp(e.Value)
+	case *NumberLit:
+		if e.Unit != "" {
+			return time.Time{}, false
+		}
+		if seconds, ok := new(big.Int).SetString(e.Value, 10); ok && seconds.IsInt64() {
+			return time.Unix(seconds.Int64(), 0).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// buildTime converts a time range bound
+func buildTime(expr Expr, which string) (*time.Time, error) {
+	t, ok := timeLiteral(expr)
+	if !ok {
+		return nil, errorAt(expr, "invalid %s time: %s (expected a timestamp such as '2024-01-01T00:00:00Z' or '2024-01-01')", which, TruncateForDisplay(nodeText(expr), 40))
+	}
+	return &t, nil
+}
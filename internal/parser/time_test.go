@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devlongs/evmql/queries"
+)
+
+func TestParseQuery_TimeRanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		fromTime    string
+		toTime      string
+		blockWindow int64
+	}{
+		{
+			name:        "Balance at a date",
+			queryStr:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e AT '2024-01-01'",
+			toTime:      "2024-01-01T00:00:00Z",
+			blockWindow: 1,
+		},
+		{
+			name:        "Logs at a date-time",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e AT '2024-01-01 12:30'",
+			toTime:      "2024-01-01T12:30:00Z",
+			blockWindow: 1,
+		},
+		{
+			name:     "Between dates",
+			queryStr: "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BETWEEN '2024-01-01' AND '2024-01-02' WHERE removed",
+			fromTime: "2024-01-01T00:00:00Z",
+			toTime:   "2024-01-02T00:00:00Z",
+		},
+		{
+			name:     "Between with zones and unix seconds",
+			queryStr: "SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BETWEEN '2024-01-01T02:00:00+02:00' AND 1704067260",
+			fromTime: "2024-01-01T00:00:00Z",
+			toTime:   "2024-01-01T00:01:00Z",
+		},
+	}
+
+	parser := NewParser()
+	format := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parser.ParseQuery(tt.queryStr)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got := format(query.FromTime); got != tt.fromTime {
+				t.Errorf("Expected from time '%s', got '%s'", tt.fromTime, got)
+			}
+			if got := format(query.ToTime); got != tt.toTime {
+				t.Errorf("Expected to time '%s', got '%s'", tt.toTime, got)
+			}
+			if query.FromBlock != nil || query.ToBlock != nil {
+				t.Errorf("Expected no block bounds, got %v to %v", query.FromBlock, query.ToBlock)
+			}
+			if query.BlockWindow != tt.blockWindow {
+				t.Errorf("Expected block window %d, got %d", tt.blockWindow, query.BlockWindow)
+			}
+		})
+	}
+}
+
+func TestParseQuery_TimeRangeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		queryStr    string
+		expectedErr string
+	}{
+		{
+			name:        "Malformed timestamp",
+			queryStr:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e AT '2024-13-01'",
+			expectedErr: "invalid AT time",
+		},
+		{
+			name:        "Timestamp with a unit",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BETWEEN 1 ether AND '2024-01-01'",
+			expectedErr: "invalid start time",
+		},
+		{
+			name:        "Missing AND",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BETWEEN '2024-01-01' '2024-01-02'",
+			expectedErr: "expected AND in BETWEEN",
+		},
+		{
+			name:        "Reversed range",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BETWEEN '2024-01-02' AND '2024-01-01'",
+			expectedErr: "start time cannot be after end time",
+		},
+		{
+			name:        "Balance over a time range",
+			queryStr:    "SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BETWEEN '2024-01-01' AND '2024-01-02'",
+			expectedErr: "BALANCE reads a single block",
+		},
+		{
+			name:        "Timestamp compared to a word",
+			queryStr:    "SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LAST 10 BLOCKS WHERE block_timestamp > 'yesterday'",
+			expectedErr: "yesterday",
+		},
+	}
+
+	parser := NewParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.ParseQuery(tt.queryStr)
+			if err == nil {
+				t.Fatalf("Expected error containing '%s', got none", tt.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got '%s'", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseQuery_BlockTimestampFilter(t *testing.T) {
+	parser := NewParser()
+	query, err := parser.ParseQuery("SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LAST 10 BLOCKS WHERE block_timestamp >= '2024-01-01 12:00'")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	tests := []struct {
+		timestamp time.Time
+		expected  bool
+	}{
+		{timestamp: time.Date(2024, 1, 1, 11, 59, 59, 0, time.UTC), expected: false},
+		{timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), expected: true},
+		{timestamp: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), expected: true},
+	}
+	for _, tt := range tests {
+		ok, err := queries.Matches(query.Where, queries.Record{"block_timestamp": tt.timestamp})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if ok != tt.expected {
+			t.Errorf("Expected %v for %s, got %v", tt.expected, tt.timestamp, ok)
+		}
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"time"
 
 	"github.com/devlongs/evmql/internal/executor"
+	"github.com/devlongs/evmql/internal/format"
 	"github.com/devlongs/evmql/internal/logger"
 	"github.com/devlongs/evmql/internal/parser"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 // holds REPL configuration
@@ -19,11 +21,21 @@ type Config struct {
 	MaxHistoryLen int
 	ColorOutput   bool
 	ShowTimings   bool
+	OutputFormat  string
+	PrettyPrint   bool
+	Labels        map[common.Address]string // names shown next to known addresses
 }
 
 // Start initializes and runs the REPL loop for querying
 func Start(parser *parser.Parser, executor *executor.QueryExecutor, config Config) {
 	scanner := bufio.NewScanner(os.Stdin)
+
+	formatter, err := format.New(config.OutputFormat, format.Options{Pretty: config.PrettyPrint, Labels: config.Labels})
+	if err != nil {
+		logger.Warn("falling back to table output", "error", err)
+		formatter = &format.TableFormatter{Labels: config.Labels}
+	}
+
 	fmt.Println("Entering EVMQL interactive mode. Type your query, or type 'exit' to quit.")
 	fmt.Println("Type 'help' for available commands.")
 
@@ -74,8 +86,11 @@ func Start(parser *parser.Parser, executor *executor.QueryExecutor, config Confi
 			continue
 		}
 
-		logger.Info("query executed", "method", query.Method, "address", query.Address.Hex())
-		fmt.Printf("Result: %v\n", result)
+		logger.Info("query executed", "method", query.Method, "address", query.Address.Hex(), "rows", result.Len())
+		if err := formatter.Format(os.Stdout, result); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			continue
+		}
 
 		// Show execution time if enabled
 		if config.ShowTimings {
@@ -92,13 +107,62 @@ func Start(parser *parser.Parser, executor *executor.QueryExecutor, config Confi
 func showHelp() {
 	fmt.Println("Available commands:")
 	fmt.Println("  SELECT BALANCE FROM <address> [BLOCK <number>] - Get account balance")
+	fmt.Println("  SELECT CODE|NONCE FROM <address> [BLOCK <number>] - Get contract code or account nonce")
+	fmt.Println("  SELECT STORAGE FROM <address> SLOT <slot>, ... [BLOCK <number>] - Get storage slots")
+	fmt.Println("  SELECT TOKEN_BALANCE FROM <holder> TOKEN (<token>, ...) [BLOCK <number>] - Get ERC-20 balances")
+	fmt.Println("  SELECT TOKEN_INFO FROM <token> [BLOCK <number>] - Get a token's name, symbol, decimals and total supply")
+	fmt.Println("  SELECT CALL <function>(<args>) FROM <address> [SENDER <address>] [BLOCK <number>] - Call a read-only contract function")
+	fmt.Println("  SELECT GAS_ESTIMATE FOR CALL <function>(<args>) FROM <address> [SENDER <address>] [BLOCK <number>] - Estimate the gas of a call")
+	fmt.Println("  SELECT OWNER FROM <collection> TOKEN_ID <id>, ... [BLOCK <number>] - Get the owners of ERC-721 tokens")
+	fmt.Println("  SELECT NFTS FROM <wallet> COLLECTION <collection>, ... [BLOCK <from> [<to>]] - Get the NFTs a wallet holds")
 	fmt.Println("  SELECT LOGS FROM <address> BLOCK <from> <to> - Get logs within block range")
-	fmt.Println("  SELECT TRANSACTIONS FROM <address> [BLOCK <from> <to>] - Get transactions")
+	fmt.Println("  SELECT TRANSACTIONS FROM <address> [BLOCK <from> [<to>]] - Get transactions")
+	fmt.Println("  SELECT TRANSFERS FROM <address> [BLOCK <from> [<to>]] - Get ERC-20, ERC-721 and ERC-1155 transfers")
+	fmt.Println("  SELECT TRACES|INTERNAL_TRANSFERS FROM <address> [BLOCK <from> [<to>]] - Get call traces or internal ether transfers")
+	fmt.Println("  SELECT PENDING FROM <address> - Get the transactions of the pool sent from or to an address")
+	fmt.Println("  SELECT STATE_DIFF [FROM <address>] TX <hash>, ... | BLOCK <from> [<to>] - Get the balances, nonces, code and storage transactions changed")
+	fmt.Println("  SELECT RECEIPTS FROM <address> [BLOCK <from> [<to>]] - Get transaction receipts (status, gas used, ...)")
+	fmt.Println("  SELECT BLOCKS [BLOCK <from> [<to>]] - Get block headers (no address needed)")
+	fmt.Println("  SELECT FEES [BLOCK <from> [<to>]] - Get base fees, gas used and priority fee percentiles of blocks")
+	fmt.Println("  FROM (<address>, <address>, ...) - Query several addresses at once")
+	fmt.Println("  Addresses may be ENS names such as vitalik.eth; select ens_name for reverse names")
+	fmt.Println("  Addresses may also be names from the network's contracts or the address book, e.g. usdc")
+	fmt.Println("  Blocks may be numbers, hex (0x12ab34) or latest, pending, safe, finalized, earliest")
+	fmt.Println("  Ranges: BLOCK <from> TO <to>, SINCE <from> [UNTIL <to>], UNTIL <to>, LAST <n> BLOCKS")
+	fmt.Println("  Times: AT '<timestamp>', BETWEEN '<timestamp>' AND '<timestamp>' (UTC, e.g. '2024-01-01 12:00')")
+	fmt.Println("  SELECT <column>[ AS <alias>], ... FROM <method>(<address>) ... - Select specific columns")
+	fmt.Println("  ... WHERE <condition> - Filter results (=, !=, <, >, <=, >=, IN, BETWEEN, IS NULL, AND/OR/NOT)")
+	fmt.Println("  ... WHERE event = '<signature>' - Match logs by event, e.g. 'Transfer(address,address,uint256)'")
+	fmt.Println("  SELECT * FROM LOGS LAST <n> BLOCKS WHERE event = ... - Match logs of every contract (up to 1000 blocks)")
+	fmt.Println("  SELECT args.<name>, ... FROM LOGS(<address>, '<event>') - Decode an event by ABI name or declaration")
+	fmt.Println("  SELECT args.<name>, ... FROM TRANSACTIONS(<address>, '<function>') - Decode calls of one function")
+	fmt.Println("  TRANSACTIONS have selector, method, args and calls (multicall contents) columns")
+	fmt.Println("  TRANSACTIONS also have the receipt columns status, gas_used, effective_gas_price, contract_address, ...")
+	fmt.Println("  ... GROUP BY <column>, ... HAVING <condition> - Aggregate with COUNT, SUM, AVG, MIN, MAX")
+	fmt.Println("  ... ORDER BY <column> [ASC|DESC], ... LIMIT <n> OFFSET <m> - Sort and page results")
 	fmt.Println("  exit, quit - Exit the program")
 	fmt.Println("  help - Show this help message")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	fmt.Println("  SELECT BALANCE FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK finalized")
+	fmt.Println("  SELECT address, ens_name, balance FROM BALANCE(vitalik.eth, nick.eth)")
+	fmt.Println("  SELECT address, is_contract, size FROM CODE(0x742d35Cc6634C0532925a3b844Bc454e4438f44e, usdc)")
+	fmt.Println("  SELECT STORAGE FROM usdc SLOT 0, 1 BLOCK finalized")
+	fmt.Println("  SELECT TOKEN_BALANCE FROM vitalik.eth TOKEN (usdc, dai)")
+	fmt.Println("  SELECT token, from, to, amount FROM TRANSFERS(vitalik.eth) LAST 1000 BLOCKS")
+	fmt.Println("  SELECT CALL 'balanceOf(address)(uint256)'(vitalik.eth) FROM usdc BLOCK 19000000")
 	fmt.Println("  SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1000000 1100000")
+	fmt.Println("  SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e LAST 500 BLOCKS")
+	fmt.Println("  SELECT LOGS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BETWEEN '2024-01-01' AND '2024-01-02'")
+	fmt.Println("  SELECT LOGS FROM usdc LAST 100 BLOCKS WHERE event = 'Transfer(address,address,uint256)' AND topic2 = 0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	fmt.Println("  SELECT args.from, args.to, args.value FROM LOGS(usdc, 'Transfer(address indexed from, address indexed to, uint256 value)') LAST 100 BLOCKS")
+	fmt.Println("  SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1000000 1000100 WHERE value > 1 ether")
+	fmt.Println("  SELECT hash, method, args FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) LAST 100 BLOCKS WHERE method = 'transfer'")
+	fmt.Println("  SELECT hash, from, to, value AS wei FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) BLOCK 1000000 1000100")
+	fmt.Println("  SELECT TRANSACTIONS FROM 0x742d35Cc6634C0532925a3b844Bc454e4438f44e BLOCK 1000000 1000100 ORDER BY value DESC LIMIT 10")
+	fmt.Println("  SELECT hash, to, method FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) LAST 100 BLOCKS WHERE status = 0")
+	fmt.Println("  SELECT number, gas_used, base_fee FROM BLOCKS LAST 50 BLOCKS WHERE tx_count > 200")
+	fmt.Println("  SELECT from, COUNT(*), SUM(value) FROM TRANSACTIONS(0x742d35Cc6634C0532925a3b844Bc454e4438f44e) BLOCK 1000000 1000100 GROUP BY from HAVING COUNT(*) > 5")
 	fmt.Println()
 }
@@ -0,0 +1,151 @@
+// Package selectors identifies contract functions by the 4-byte selector that
+// starts transaction input, using a bundled list of common function signatures.
+package selectors
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+//go:embed functions.txt
+var functionList string
+
+var (
+	loadOnce  sync.Once
+	functions map[[4]byte]abi.Method
+)
+
+// Lookup returns the bundled function with the given selector
+func Lookup(selector []byte) (abi.Method, bool) {
+	if len(selector) < 4 {
+		return abi.Method{}, false
+	}
+	loadOnce.Do(load)
+	method, ok := functions[[4]byte(selector[:4])]
+	return method, ok
+}
+
+// load parses the bundled signatures, keeping the first one for each selector
+func load() {
+	functions = make(map[[4]byte]abi.Method)
+	for _, line := range strings.Split(functionList, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		method, err := ParseSignature(line)
+		if err != nil {
+			panic(fmt.Sprintf("invalid bundled signature %s: %v", line, err))
+		}
+		if _, seen := functions[[4]byte(method.ID)]; !seen {
+			functions[[4]byte(method.ID)] = method
+		}
+	}
+}
+
+// ParseSignature builds a function from its canonical signature, such as
+// "transfer(address,uint256)" or "aggregate((address,bytes)[])". Signatures do not
+// name parameters, so tuple fields are called f0, f1 and so on.
+func ParseSignature(signature string) (abi.Method, error) {
+	open := strings.IndexByte(signature, '(')
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return abi.Method{}, fmt.Errorf("malformed signature %q", signature)
+	}
+	name := signature[:open]
+
+	types, err := splitTypes(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return abi.Method{}, err
+	}
+	inputs := make(abi.Arguments, len(types))
+	for i, typ := range types {
+		t, err := newType(typ)
+		if err != nil {
+			return abi.Method{}, err
+		}
+		inputs[i] = abi.Argument{Type: t}
+	}
+	return abi.NewMethod(name, name, abi.Function, "nonpayable", false, false, inputs, nil), nil
+}
+
+// newType builds an ABI type, including tuples written as "(address,bytes)[]"
+func newType(typ string) (abi.Type, error) {
+	if !strings.HasPrefix(typ, "(") {
+		return abi.NewType(typ, "", nil)
+	}
+	components, suffix, err := tupleComponents(typ)
+	if err != nil {
+		return abi.Type{}, err
+	}
+	return abi.NewType("tuple"+suffix, "", components)
+}
+
+// tupleComponents returns the fields of a tuple type and its array suffix, if any
+func tupleComponents(typ string) ([]abi.ArgumentMarshaling, string, error) {
+	depth, end := 0, -1
+	for i, r := range typ {
+		if r == '(' {
+			depth++
+		} else if r == ')' {
+			depth--
+			if depth == 0 {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return nil, "", fmt.Errorf("unbalanced parentheses in %q", typ)
+	}
+
+	types, err := splitTypes(typ[1:end])
+	if err != nil {
+		return nil, "", err
+	}
+	components := make([]abi.ArgumentMarshaling, len(types))
+	for i, field := range types {
+		component := abi.ArgumentMarshaling{Name: fmt.Sprintf("f%d", i), Type: field}
+		if strings.HasPrefix(field, "(") {
+			nested, suffix, err := tupleComponents(field)
+			if err != nil {
+				return nil, "", err
+			}
+			component.Type, component.Components = "tuple"+suffix, nested
+		}
+		components[i] = component
+	}
+	return components, typ[end+1:], nil
+}
+
+// splitTypes splits a comma-separated type list on the commas outside of tuples
+func splitTypes(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var types []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				types = append(types, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	types = append(types, list[start:])
+	for _, typ := range types {
+		if typ == "" {
+			return nil, fmt.Errorf("empty type in %q", list)
+		}
+	}
+	return types, nil
+}
@@ -0,0 +1,62 @@
+package selectors
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		selector  string
+		signature string
+	}{
+		{"0xa9059cbb", "transfer(address,uint256)"},
+		{"0x095ea7b3", "approve(address,uint256)"},
+		{"0x23b872dd", "transferFrom(address,address,uint256)"},
+		{"0xd0e30db0", "deposit()"},
+		{"0xac9650d8", "multicall(bytes[])"},
+		{"0x252dba42", "aggregate((address,bytes)[])"},
+		{"0x82ad56cb", "aggregate3((address,bool,bytes)[])"},
+		{"0x414bf389", "exactInputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))"},
+		{"0x6a761202", "execTransaction(address,uint256,bytes,uint8,uint256,uint256,uint256,address,address,bytes)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.signature, func(t *testing.T) {
+			method, ok := Lookup(hexutil.MustDecode(tt.selector))
+			if !ok {
+				t.Fatalf("Expected %s to be known", tt.selector)
+			}
+			if method.Sig != tt.signature {
+				t.Errorf("Expected %s, got %s", tt.signature, method.Sig)
+			}
+		})
+	}
+
+	if _, ok := Lookup(hexutil.MustDecode("0xdeadbeef")); ok {
+		t.Error("Expected an unknown selector not to be found")
+	}
+	if _, ok := Lookup([]byte{0xa9, 0x05}); ok {
+		t.Error("Expected a short selector not to be found")
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	method, err := ParseSignature("tryAggregate(bool,(address,(uint256,bytes)[])[])")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if method.Sig != "tryAggregate(bool,(address,(uint256,bytes)[])[])" {
+		t.Errorf("Unexpected signature %s", method.Sig)
+	}
+	if len(method.Inputs) != 2 {
+		t.Errorf("Expected 2 inputs, got %d", len(method.Inputs))
+	}
+
+	for _, signature := range []string{"transfer", "transfer(address,,uint256)", "f((address,bytes)", "f(strin)", "(address)"} {
+		if _, err := ParseSignature(signature); err == nil {
+			t.Errorf("Expected %q to be rejected", signature)
+		}
+	}
+}
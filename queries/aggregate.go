@@ -0,0 +1,94 @@
+package queries
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Aggregate is an aggregate function evaluated over the rows of a group, such as SUM(value)
+type Aggregate struct {
+	Func string // COUNT, SUM, AVG, MIN or MAX
+	Arg  Expr   // nil for COUNT(*)
+}
+
+// String returns the canonical name of the aggregate, which is also the
+// field name its result is stored under in grouped records
+func (a Aggregate) String() string {
+	if a.Arg == nil {
+		return a.Func + "(*)"
+	}
+	return a.Func + "(" + a.Arg.String() + ")"
+}
+
+// Accumulator computes an Aggregate incrementally. SUM and AVG use arbitrary
+// precision integers, so totals of wei values never overflow.
+type Accumulator struct {
+	agg   Aggregate
+	count int64
+	sum   *big.Int
+	best  interface{}
+}
+
+// NewAccumulator returns an empty accumulator for agg
+func NewAccumulator(agg Aggregate) *Accumulator {
+	return &Accumulator{agg: agg, sum: new(big.Int)}
+}
+
+// Add feeds a record into the aggregate. NULL values are ignored, except by COUNT(*).
+func (a *Accumulator) Add(rec Record) error {
+	if a.agg.Arg == nil {
+		a.count++
+		return nil
+	}
+
+	value, err := a.agg.Arg.Eval(rec)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return nil
+	}
+	a.count++
+
+	switch a.agg.Func {
+	case "SUM", "AVG":
+		n, ok := value.(*big.Int)
+		if !ok {
+			return fmt.Errorf("%s expects integer values, got %T", a.agg.Func, value)
+		}
+		a.sum.Add(a.sum, n)
+	case "MIN", "MAX":
+		if a.best == nil {
+			a.best = value
+			return nil
+		}
+		cmp, err := CompareValues(value, a.best)
+		if err != nil {
+			return err
+		}
+		if (a.agg.Func == "MIN" && cmp < 0) || (a.agg.Func == "MAX" && cmp > 0) {
+			a.best = value
+		}
+	}
+	return nil
+}
+
+// Result returns the value of the aggregate. Everything but COUNT is NULL over
+// an empty group; AVG is exact and truncated towards zero, like wei amounts.
+func (a *Accumulator) Result() interface{} {
+	switch a.agg.Func {
+	case "COUNT":
+		return big.NewInt(a.count)
+	case "SUM":
+		if a.count == 0 {
+			return nil
+		}
+		return new(big.Int).Set(a.sum)
+	case "AVG":
+		if a.count == 0 {
+			return nil
+		}
+		return new(big.Int).Quo(a.sum, big.NewInt(a.count))
+	}
+	return a.best
+}
@@ -0,0 +1,76 @@
+package queries
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// ArgPrefix starts the names of the columns holding decoded ABI arguments, as in args.value
+const ArgPrefix = "args."
+
+// ResultPrefix starts the names of the columns holding the return values of a CALL
+// with several of them, as in result.reserve0
+const ResultPrefix = "result."
+
+// ArgName returns the name under which the argument at index is decoded, standing
+// in arg0, arg1 and so on for unnamed arguments
+func ArgName(arg abi.Argument, index int) string {
+	if arg.Name == "" {
+		return fmt.Sprintf("arg%d", index)
+	}
+	return arg.Name
+}
+
+// ArgFields returns the columns of decoded ABI arguments. They are NULL in rows that
+// fail to decode.
+func ArgFields(args abi.Arguments) Schema {
+	fields := make(Schema, len(args))
+	for i, arg := range args {
+		fields[i] = Field{Name: ArgPrefix + ArgName(arg, i), Type: ArgType(arg.Type, arg.Indexed), Nullable: true}
+	}
+	return fields
+}
+
+// ResultFields returns the columns of the return values of a called function. A
+// single value is held in result; several are rendered together in result as a JSON
+// object and each in its own result.<name> column. A function declared without
+// return types leaves the raw return data in result.
+func ResultFields(outputs abi.Arguments) Schema {
+	switch len(outputs) {
+	case 0:
+		return Schema{{Name: "result", Type: TypeBytes, Nullable: true}}
+	case 1:
+		return Schema{{Name: "result", Type: ArgType(outputs[0].Type, false), Nullable: true}}
+	}
+	fields := Schema{{Name: "result", Type: TypeString, Nullable: true}}
+	for i, output := range outputs {
+		fields = append(fields, Field{Name: ResultPrefix + ArgName(output, i), Type: ArgType(output.Type, false), Nullable: true})
+	}
+	return fields
+}
+
+// ArgType returns the column type of an ABI argument. Indexed strings, bytes, arrays
+// and tuples are only stored as their hash; other arrays and tuples are rendered as JSON.
+func ArgType(t abi.Type, indexed bool) Type {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		return TypeInt
+	case abi.AddressTy:
+		return TypeAddress
+	case abi.BoolTy:
+		return TypeBool
+	case abi.FixedBytesTy, abi.FunctionTy:
+		return TypeBytes
+	}
+	if indexed {
+		return TypeHash
+	}
+	switch t.T {
+	case abi.StringTy:
+		return TypeString
+	case abi.BytesTy:
+		return TypeBytes
+	}
+	return TypeString
+}
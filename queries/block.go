@@ -0,0 +1,44 @@
+package queries
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// blockTags maps the symbolic block names to the negative numbers go-ethereum uses
+// for them. Tags are kept in Query.FromBlock and Query.ToBlock in that form until
+// the executor resolves them against the node.
+var blockTags = map[string]rpc.BlockNumber{
+	"latest":    rpc.LatestBlockNumber,
+	"pending":   rpc.PendingBlockNumber,
+	"safe":      rpc.SafeBlockNumber,
+	"finalized": rpc.FinalizedBlockNumber,
+	"earliest":  rpc.EarliestBlockNumber,
+}
+
+// BlockTag returns the block number for a tag such as "latest" or "finalized"
+func BlockTag(name string) (*big.Int, bool) {
+	tag, ok := blockTags[strings.ToLower(name)]
+	if !ok {
+		return nil, false
+	}
+	return big.NewInt(tag.Int64()), true
+}
+
+// IsBlockTag reports whether n is a tag that still needs resolving
+func IsBlockTag(n *big.Int) bool {
+	return n != nil && n.Sign() < 0
+}
+
+// FormatBlock renders a block number, or the name of a tag
+func FormatBlock(n *big.Int) string {
+	if n == nil {
+		return "latest"
+	}
+	if IsBlockTag(n) && n.IsInt64() {
+		return rpc.BlockNumber(n.Int64()).String()
+	}
+	return n.String()
+}
@@ -0,0 +1,497 @@
+package queries
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Expr is a typed expression evaluated against a Record.
+// Evaluation follows SQL three-valued logic: a nil result means NULL (unknown).
+type Expr interface {
+	Eval(rec Record) (interface{}, error)
+	String() string
+}
+
+// ColumnRef reads a field of the record
+type ColumnRef struct {
+	Name string
+}
+
+// Literal is a constant value
+type Literal struct {
+	Value interface{}
+}
+
+// Comparison compares two values with =, !=, <, >, <= or >=
+type Comparison struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+// Logical combines two boolean expressions with AND or OR
+type Logical struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+// Not negates a boolean expression
+type Not struct {
+	X Expr
+}
+
+// In tests membership of a value in a list
+type In struct {
+	X      Expr
+	List   []Expr
+	Negate bool
+}
+
+// Between tests whether a value lies within an inclusive range
+type Between struct {
+	X      Expr
+	Low    Expr
+	High   Expr
+	Negate bool
+}
+
+// IsNull tests whether a value is NULL
+type IsNull struct {
+	X      Expr
+	Negate bool
+}
+
+func (e *ColumnRef) Eval(rec Record) (interface{}, error) {
+	return rec[e.Name], nil
+}
+
+func (e *Literal) Eval(rec Record) (interface{}, error) {
+	return e.Value, nil
+}
+
+func (e *Comparison) Eval(rec Record) (interface{}, error) {
+	left, err := e.Left.Eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.Right.Eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	cmp, err := CompareValues(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Op {
+	case "=":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case ">":
+		return cmp > 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("unsupported comparison operator: %s", e.Op)
+}
+
+func (e *Logical) Eval(rec Record) (interface{}, error) {
+	left, err := evalBool(e.Left, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit where the result is already decided
+	if left != nil {
+		if e.Op == "AND" && !*left {
+			return false, nil
+		}
+		if e.Op == "OR" && *left {
+			return true, nil
+		}
+	}
+
+	right, err := evalBool(e.Right, rec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Op {
+	case "AND":
+		if right != nil && !*right {
+			return false, nil
+		}
+		if left == nil || right == nil {
+			return nil, nil
+		}
+		return true, nil
+	case "OR":
+		if right != nil && *right {
+			return true, nil
+		}
+		if left == nil || right == nil {
+			return nil, nil
+		}
+		return false, nil
+	}
+	return nil, fmt.Errorf("unsupported logical operator: %s", e.Op)
+}
+
+func (e *Not) Eval(rec Record) (interface{}, error) {
+	value, err := evalBool(e.X, rec)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return !*value, nil
+}
+
+func (e *In) Eval(rec Record) (interface{}, error) {
+	value, err := e.X.Eval(rec)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	sawNull := false
+	for _, item := range e.List {
+		candidate, err := item.Eval(rec)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			sawNull = true
+			continue
+		}
+		cmp, err := CompareValues(value, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if cmp == 0 {
+			return !e.Negate, nil
+		}
+	}
+
+	if sawNull {
+		return nil, nil
+	}
+	return e.Negate, nil
+}
+
+func (e *Between) Eval(rec Record) (interface{}, error) {
+	value, err := e.X.Eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	low, err := e.Low.Eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	high, err := e.High.Eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil || low == nil || high == nil {
+		return nil, nil
+	}
+
+	lowCmp, err := CompareValues(value, low)
+	if err != nil {
+		return nil, err
+	}
+	highCmp, err := CompareValues(value, high)
+	if err != nil {
+		return nil, err
+	}
+
+	inside := lowCmp >= 0 && highCmp <= 0
+	return inside != e.Negate, nil
+}
+
+func (e *IsNull) Eval(rec Record) (interface{}, error) {
+	value, err := e.X.Eval(rec)
+	if err != nil {
+		return nil, err
+	}
+	return (value == nil) != e.Negate, nil
+}
+
+func (e *ColumnRef) String() string { return e.Name }
+
+func (e *Literal) String() string {
+	switch v := e.Value.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case time.Time:
+		return "'" + FormatValue(v) + "'"
+	}
+	return FormatValue(e.Value)
+}
+
+func (e *Comparison) String() string {
+	return fmt.Sprintf("%s %s %s", e.Left, e.Op, e.Right)
+}
+
+func (e *Logical) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.Left, e.Op, e.Right)
+}
+
+func (e *Not) String() string { return fmt.Sprintf("NOT %s", e.X) }
+
+func (e *In) String() string {
+	items := make([]string, len(e.List))
+	for i, item := range e.List {
+		items[i] = item.String()
+	}
+	op := "IN"
+	if e.Negate {
+		op = "NOT IN"
+	}
+	return fmt.Sprintf("%s %s (%s)", e.X, op, strings.Join(items, ", "))
+}
+
+func (e *Between) String() string {
+	op := "BETWEEN"
+	if e.Negate {
+		op = "NOT BETWEEN"
+	}
+	return fmt.Sprintf("%s %s %s AND %s", e.X, op, e.Low, e.High)
+}
+
+func (e *IsNull) String() string {
+	if e.Negate {
+		return fmt.Sprintf("%s IS NOT NULL", e.X)
+	}
+	return fmt.Sprintf("%s IS NULL", e.X)
+}
+
+// Matches reports whether the record satisfies the predicate; a nil predicate matches everything
+func Matches(where Expr, rec Record) (bool, error) {
+	if where == nil {
+		return true, nil
+	}
+	value, err := evalBool(where, rec)
+	if err != nil {
+		return false, err
+	}
+	return value != nil && *value, nil
+}
+
+// Conjuncts splits a predicate into its top-level AND terms
+func Conjuncts(expr Expr) []Expr {
+	if expr == nil {
+		return nil
+	}
+	if logical, ok := expr.(*Logical); ok && logical.Op == "AND" {
+		return append(Conjuncts(logical.Left), Conjuncts(logical.Right)...)
+	}
+	return []Expr{expr}
+}
+
+// EqualityTerm returns the column and the candidate values of a "column = x" or
+// "column IN (...)" condition
+func EqualityTerm(term Expr) (string, []Expr, bool) {
+	var column Expr
+	var list []Expr
+	switch e := term.(type) {
+	case *Comparison:
+		if e.Op != "=" {
+			return "", nil, false
+		}
+		column, list = e.Left, []Expr{e.Right}
+		if _, ok := column.(*ColumnRef); !ok {
+			column, list = e.Right, []Expr{e.Left}
+		}
+	case *In:
+		if e.Negate {
+			return "", nil, false
+		}
+		column, list = e.X, e.List
+	default:
+		return "", nil, false
+	}
+	ref, ok := column.(*ColumnRef)
+	if !ok {
+		return "", nil, false
+	}
+	return ref.Name, list, true
+}
+
+// References reports whether expr reads the named column
+func References(expr Expr, column string) bool {
+	switch e := expr.(type) {
+	case *ColumnRef:
+		return e.Name == column
+	case *Comparison:
+		return References(e.Left, column) || References(e.Right, column)
+	case *Logical:
+		return References(e.Left, column) || References(e.Right, column)
+	case *Not:
+		return References(e.X, column)
+	case *In:
+		if References(e.X, column) {
+			return true
+		}
+		for _, item := range e.List {
+			if References(item, column) {
+				return true
+			}
+		}
+	case *Between:
+		return References(e.X, column) || References(e.Low, column) || References(e.High, column)
+	case *IsNull:
+		return References(e.X, column)
+	}
+	return false
+}
+
+// MapLiterals returns a copy of expr with every literal value replaced by fn(value),
+// leaving expr itself untouched
+func MapLiterals(expr Expr, fn func(value interface{}) (interface{}, error)) (Expr, error) {
+	mapAll := func(exprs ...Expr) ([]Expr, error) {
+		mapped := make([]Expr, len(exprs))
+		for i, e := range exprs {
+			m, err := MapLiterals(e, fn)
+			if err != nil {
+				return nil, err
+			}
+			mapped[i] = m
+		}
+		return mapped, nil
+	}
+
+	switch e := expr.(type) {
+	case *Literal:
+		value, err := fn(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &Literal{Value: value}, nil
+	case *Comparison:
+		m, err := mapAll(e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &Comparison{Op: e.Op, Left: m[0], Right: m[1]}, nil
+	case *Logical:
+		m, err := mapAll(e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &Logical{Op: e.Op, Left: m[0], Right: m[1]}, nil
+	case *Not:
+		m, err := mapAll(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: m[0]}, nil
+	case *In:
+		m, err := mapAll(append([]Expr{e.X}, e.List...)...)
+		if err != nil {
+			return nil, err
+		}
+		return &In{X: m[0], List: m[1:], Negate: e.Negate}, nil
+	case *Between:
+		m, err := mapAll(e.X, e.Low, e.High)
+		if err != nil {
+			return nil, err
+		}
+		return &Between{X: m[0], Low: m[1], High: m[2], Negate: e.Negate}, nil
+	case *IsNull:
+		m, err := mapAll(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return &IsNull{X: m[0], Negate: e.Negate}, nil
+	}
+	return expr, nil
+}
+
+// evalBool evaluates a boolean expression, returning nil for NULL
+func evalBool(expr Expr, rec Record) (*bool, error) {
+	value, err := expr.Eval(rec)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("expression %s is not a boolean", expr)
+	}
+	return &b, nil
+}
+
+// CompareValues orders two non-nil values of the same type
+func CompareValues(a, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case *big.Int:
+		if y, ok := b.(*big.Int); ok {
+			return x.Cmp(y), nil
+		}
+	case common.Address:
+		if y, ok := b.(common.Address); ok {
+			return bytes.Compare(x[:], y[:]), nil
+		}
+	case common.Hash:
+		if y, ok := b.(common.Hash); ok {
+			return bytes.Compare(x[:], y[:]), nil
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case !x:
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+// FormatValue renders a field value for display
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return "0x" + common.Bytes2Hex(v)
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,138 @@
+package queries
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestExprEval(t *testing.T) {
+	wallet := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	record := Record{
+		"from":    wallet,
+		"value":   big.NewInt(1500),
+		"removed": false,
+		// "to" is missing, i.e. NULL
+	}
+
+	value := &ColumnRef{Name: "value"}
+	lit := func(v interface{}) Expr { return &Literal{Value: v} }
+
+	tests := []struct {
+		name     string
+		expr     Expr
+		expected bool
+	}{
+		{"Equal", &Comparison{Op: "=", Left: value, Right: lit(big.NewInt(1500))}, true},
+		{"Not equal", &Comparison{Op: "!=", Left: value, Right: lit(big.NewInt(1500))}, false},
+		{"Greater than", &Comparison{Op: ">", Left: value, Right: lit(big.NewInt(1000))}, true},
+		{"Less or equal", &Comparison{Op: "<=", Left: value, Right: lit(big.NewInt(1499))}, false},
+		{"Address equality", &Comparison{Op: "=", Left: &ColumnRef{Name: "from"}, Right: lit(wallet)}, true},
+		{"In list", &In{X: &ColumnRef{Name: "from"}, List: []Expr{lit(other), lit(wallet)}}, true},
+		{"Not in list", &In{X: &ColumnRef{Name: "from"}, List: []Expr{lit(other)}, Negate: true}, true},
+		{"Between inclusive", &Between{X: value, Low: lit(big.NewInt(1500)), High: lit(big.NewInt(2000))}, true},
+		{"Not between", &Between{X: value, Low: lit(big.NewInt(1)), High: lit(big.NewInt(2)), Negate: true}, true},
+		{"Is null", &IsNull{X: &ColumnRef{Name: "to"}}, true},
+		{"Is not null", &IsNull{X: &ColumnRef{Name: "from"}, Negate: true}, true},
+		{"Comparison with NULL is unknown", &Comparison{Op: "=", Left: &ColumnRef{Name: "to"}, Right: lit(wallet)}, false},
+		{"NOT of unknown stays unknown", &Not{X: &Comparison{Op: "=", Left: &ColumnRef{Name: "to"}, Right: lit(wallet)}}, false},
+		{
+			"AND with false short-circuits",
+			&Logical{Op: "AND", Left: &ColumnRef{Name: "removed"}, Right: &Comparison{Op: "=", Left: &ColumnRef{Name: "to"}, Right: lit(wallet)}},
+			false,
+		},
+		{
+			"OR with unknown and true",
+			&Logical{Op: "OR", Left: &Comparison{Op: "=", Left: &ColumnRef{Name: "to"}, Right: lit(wallet)}, Right: &Comparison{Op: ">", Left: value, Right: lit(big.NewInt(0))}},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := Matches(tt.expr, record)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("Expected %v for %s, got %v", tt.expected, tt.expr, ok)
+			}
+		})
+	}
+}
+
+func TestMatches_NilPredicate(t *testing.T) {
+	ok, err := Matches(nil, Record{})
+	if err != nil || !ok {
+		t.Errorf("Expected nil predicate to match, got %v (err: %v)", ok, err)
+	}
+}
+
+func TestCompareValues_TypeMismatch(t *testing.T) {
+	if _, err := CompareValues(big.NewInt(1), "1"); err == nil {
+		t.Error("Expected error comparing *big.Int with string")
+	}
+}
+
+func TestConjuncts(t *testing.T) {
+	a := &IsNull{X: &ColumnRef{Name: "a"}}
+	b := &IsNull{X: &ColumnRef{Name: "b"}}
+	c := &IsNull{X: &ColumnRef{Name: "c"}}
+
+	expr := &Logical{Op: "AND", Left: &Logical{Op: "AND", Left: a, Right: b}, Right: c}
+	if terms := Conjuncts(expr); len(terms) != 3 {
+		t.Errorf("Expected 3 conjuncts, got %d", len(terms))
+	}
+
+	or := &Logical{Op: "OR", Left: a, Right: b}
+	if terms := Conjuncts(or); len(terms) != 1 {
+		t.Errorf("Expected OR to be a single conjunct, got %d", len(terms))
+	}
+}
+
+func TestEqualityTerm(t *testing.T) {
+	one := &Literal{Value: big.NewInt(1)}
+	tests := []struct {
+		name     string
+		expr     Expr
+		column   string
+		expected int // number of candidate values, -1 when expr is not an equality
+	}{
+		{name: "Equality", expr: &Comparison{Op: "=", Left: &ColumnRef{Name: "a"}, Right: one}, column: "a", expected: 1},
+		{name: "Reversed equality", expr: &Comparison{Op: "=", Left: one, Right: &ColumnRef{Name: "a"}}, column: "a", expected: 1},
+		{name: "IN list", expr: &In{X: &ColumnRef{Name: "a"}, List: []Expr{one, one}}, column: "a", expected: 2},
+		{name: "NOT IN", expr: &In{X: &ColumnRef{Name: "a"}, List: []Expr{one}, Negate: true}, expected: -1},
+		{name: "Inequality", expr: &Comparison{Op: ">", Left: &ColumnRef{Name: "a"}, Right: one}, expected: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			column, list, ok := EqualityTerm(tt.expr)
+			if tt.expected < 0 {
+				if ok {
+					t.Errorf("Expected no equality, got %s = %v", column, list)
+				}
+				return
+			}
+			if !ok || column != tt.column || len(list) != tt.expected {
+				t.Errorf("Expected %s with %d values, got %s with %v (%v)", tt.column, tt.expected, column, list, ok)
+			}
+		})
+	}
+}
+
+func TestExprString(t *testing.T) {
+	expr := &Logical{
+		Op:    "AND",
+		Left:  &Comparison{Op: ">", Left: &ColumnRef{Name: "value"}, Right: &Literal{Value: big.NewInt(10)}},
+		Right: &In{X: &ColumnRef{Name: "name"}, List: []Expr{&Literal{Value: "it's"}}, Negate: true},
+	}
+
+	expected := "(value > 10 AND name NOT IN ('it''s'))"
+	if expr.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, expr.String())
+	}
+}
@@ -2,15 +2,180 @@ package queries
 
 import (
 	"math/big"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 )
 
 // Query represents a parsed query ready for execution
 type Query struct {
-	Type      string
-	Address   common.Address
-	Method    string
+	Type    string
+	Address common.Address // the first of Addresses
+
+	// Addresses lists every account or contract the query reads, in the order given
+	Addresses []common.Address
+
+	// Names lists ENS names read alongside Addresses; the executor resolves them at
+	// the query's block and appends the results to Addresses
+	Names []string
+
+	// AnyAddress marks a LOGS query without an address, which matches the logs of
+	// every contract by topic alone, or a STATE_DIFF query reporting every account
+	AnyAddress bool
+
+	Method string
+
+	// Event restricts LOGS to the logs of one event and decodes its arguments into
+	// args.<name> columns
+	Event *abi.Event
+
+	// Function restricts TRANSACTIONS and PENDING to calls of one function and decodes its
+	// arguments into args.<name> columns
+	Function *abi.Method
+
+	// Slots lists the storage slots read by STORAGE
+	Slots []common.Hash
+
+	// Tokens lists the ERC-20 contracts whose balances TOKEN_BALANCE reads
+	Tokens []common.Address
+
+	// TokenNames lists ENS names read alongside Tokens; the executor resolves them at
+	// the query's block and appends the results to Tokens
+	TokenNames []string
+
+	// Collections lists the NFT contracts whose tokens NFTS looks for in each wallet
+	Collections []common.Address
+
+	// CollectionNames lists ENS names read alongside Collections; the executor resolves
+	// them at the query's block and appends the results to Collections
+	CollectionNames []string
+
+	// TokenIDs lists the NFTs whose owners OWNER reads in each collection
+	TokenIDs []*big.Int
+
+	// TxHashes lists the transactions whose state changes STATE_DIFF reads instead of
+	// the blocks of the range
+	TxHashes []common.Hash
+
+	// Call is the function CALL runs, or GAS_ESTIMATE estimates, against each address,
+	// with its arguments in CallArgs as the Go values the ABI package packs. Arguments
+	// written as ENS names are held as ENSName until the executor resolves them.
+	Call     *abi.Method
+	CallArgs []interface{}
+
+	// Sender is the account CALL and GAS_ESTIMATE call from; nil for the zero address
+	Sender *common.Address
+
+	// SenderName is Sender given as an ENS name, which the executor resolves at the
+	// query's block
+	SenderName string
+
 	FromBlock *big.Int
 	ToBlock   *big.Int
+
+	// BlockWindow is the number of blocks ending at ToBlock (the latest block when
+	// nil) to scan when FromBlock is not set; 0 means the executor's default
+	BlockWindow int64
+
+	// FromTime and ToTime bound the range by block timestamp instead; the executor
+	// turns them into FromBlock and ToBlock
+	FromTime *time.Time
+	ToTime   *time.Time
+
+	Where      Expr         // optional filter over the method's Schema
+	Fields     []SelectItem // projected columns; empty selects every field of the Schema
+	GroupBy    []Expr
+	Aggregates []Aggregate // aggregates computed per group, referenced by name from Fields, Having and OrderBy
+	Having     Expr        // optional filter over the grouped rows
+	OrderBy    []OrderItem
+	Limit      int // maximum number of rows; 0 means unlimited
+	Offset     int // number of rows to skip
+}
+
+// stateMethods read account state at a single block instead of scanning a range
+var stateMethods = map[string]bool{
+	"BALANCE":       true,
+	"CALL":          true,
+	"CODE":          true,
+	"GAS_ESTIMATE":  true,
+	"NONCE":         true,
+	"OWNER":         true,
+	"STORAGE":       true,
+	"TOKEN_BALANCE": true,
+	"TOKEN_INFO":    true,
+}
+
+// IsStateMethod reports whether method reads account state at a single block
+func IsStateMethod(method string) bool {
+	return stateMethods[method]
+}
+
+// SelectItem is one projected column of a query
+type SelectItem struct {
+	Name string // output column name (the alias, if one was given)
+	Expr Expr
+	Type Type
+}
+
+// OrderItem is one sort key of an ORDER BY clause
+type OrderItem struct {
+	Expr Expr
+	Desc bool
+}
+
+// AddressList returns the addresses the query reads, falling back to Address when
+// neither Addresses nor Names is set
+func (q *Query) AddressList() []common.Address {
+	if q.AnyAddress || len(q.Addresses) > 0 || len(q.Names) > 0 {
+		return q.Addresses
+	}
+	return []common.Address{q.Address}
+}
+
+// Grouped reports whether the query collapses its rows into groups
+func (q *Query) Grouped() bool {
+	return len(q.GroupBy) > 0 || len(q.Aggregates) > 0 || q.Having != nil
+}
+
+// Schema returns the fields of the query's records: those of its method, followed
+// by the decoded arguments of its event or function, or the return values of its call
+func (q *Query) Schema() Schema {
+	schema := Schemas[q.Method]
+	switch {
+	case q.Event != nil:
+		return append(append(Schema(nil), schema...), ArgFields(q.Event.Inputs)...)
+	case q.Function != nil:
+		return append(append(Schema(nil), schema...), ArgFields(q.Function.Inputs)...)
+	case q.Call != nil && q.Method == "CALL":
+		return append(append(Schema(nil), schema...), ResultFields(q.Call.Outputs)...)
+	}
+	return schema
+}
+
+// Uses reports whether the query reads the named field of its method's records
+func (q *Query) Uses(column string) bool {
+	if len(q.Fields) == 0 {
+		// SELECT * reads every field of the schema except the optional ones
+		if field, ok := q.Schema().Lookup(column); ok && !field.Optional {
+			return true
+		}
+	}
+	exprs := []Expr{q.Where}
+	for _, item := range q.Fields {
+		exprs = append(exprs, item.Expr)
+	}
+	exprs = append(exprs, q.GroupBy...)
+	for _, agg := range q.Aggregates {
+		exprs = append(exprs, agg.Arg)
+	}
+	for _, item := range q.OrderBy {
+		exprs = append(exprs, item.Expr)
+	}
+	for _, expr := range exprs {
+		if References(expr, column) {
+			return true
+		}
+	}
+	return false
 }
@@ -88,3 +88,37 @@ func TestQueryWithNilBlocks(t *testing.T) {
 		t.Error("Expected nil to block")
 	}
 }
+
+func TestQueryUses(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    *Query
+		column   string
+		expected bool
+	}{
+		{name: "Field of SELECT *", query: &Query{Method: "BLOCKS"}, column: "base_fee", expected: true},
+		{name: "Optional field of SELECT *", query: &Query{Method: "BALANCE"}, column: "ens_name", expected: false},
+		{name: "Field of another method", query: &Query{Method: "BLOCKS"}, column: "ens_name", expected: false},
+		{name: "Timestamp of a method without one", query: &Query{Method: "PENDING"}, column: "block_timestamp", expected: false},
+		{
+			name:     "Optional field in WHERE",
+			query:    &Query{Method: "BALANCE", Where: &Comparison{Op: "=", Left: &ColumnRef{Name: "ens_name"}, Right: &Literal{Value: "vitalik.eth"}}},
+			column:   "ens_name",
+			expected: true,
+		},
+		{
+			name:     "Field left out of the selection",
+			query:    &Query{Method: "BLOCKS", Fields: []SelectItem{{Name: "number", Expr: &ColumnRef{Name: "number"}}}},
+			column:   "base_fee",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Uses(tt.column); got != tt.expected {
+				t.Errorf("Expected Uses(%q) to be %v, got %v", tt.column, tt.expected, got)
+			}
+		})
+	}
+}
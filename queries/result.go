@@ -0,0 +1,40 @@
+package queries
+
+import "math/big"
+
+// Column describes one column of a ResultSet
+type Column struct {
+	Name string
+	Type Type
+}
+
+// ResultSet is the tabular result of a query: every row holds one value per column,
+// in column order, with nil for NULL
+type ResultSet struct {
+	Columns []Column
+	Rows    [][]interface{}
+	Meta    ResultMeta
+}
+
+// ResultMeta records how a result was produced, so that it can be reproduced later
+type ResultMeta struct {
+	// FromBlock and ToBlock are the concrete blocks the query ran against, with any
+	// tags such as "latest" resolved; both are nil when the query is not tied to blocks
+	FromBlock *big.Int
+	ToBlock   *big.Int
+}
+
+// ColumnIndex returns the position of the named column, or -1
+func (rs *ResultSet) ColumnIndex(name string) int {
+	for i, column := range rs.Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Len returns the number of rows
+func (rs *ResultSet) Len() int {
+	return len(rs.Rows)
+}
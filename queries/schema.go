@@ -0,0 +1,353 @@
+package queries
+
+import (
+	"strings"
+)
+
+// Type is the value type of a result field
+type Type int
+
+const (
+	TypeInt     Type = iota // *big.Int
+	TypeAddress             // common.Address
+	TypeHash                // common.Hash
+	TypeBytes               // []byte
+	TypeString              // string
+	TypeBool                // bool
+	TypeTime                // time.Time, in UTC
+)
+
+var typeNames = map[Type]string{
+	TypeInt:     "int",
+	TypeAddress: "address",
+	TypeHash:    "hash",
+	TypeBytes:   "bytes",
+	TypeString:  "string",
+	TypeBool:    "bool",
+	TypeTime:    "time",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Ordered reports whether values of the type support <, >, <= and >=
+func (t Type) Ordered() bool {
+	return t == TypeInt || t == TypeString || t == TypeTime
+}
+
+// Field describes a single named value produced by a query method
+type Field struct {
+	Name     string
+	Type     Type
+	Nullable bool
+	Optional bool // costs extra requests, so it is only produced when named; SELECT * leaves it out
+}
+
+// Schema is the ordered list of fields a query method produces
+type Schema []Field
+
+// Lookup returns the field with the given (case-insensitive) name
+func (s Schema) Lookup(name string) (Field, bool) {
+	for _, field := range s {
+		if strings.EqualFold(field.Name, name) {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// Default returns the fields selected by SELECT *, leaving out optional ones
+func (s Schema) Default() Schema {
+	var fields Schema
+	for _, field := range s {
+		if !field.Optional {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Names returns the field names in schema order
+func (s Schema) Names() []string {
+	names := make([]string, len(s))
+	for i, field := range s {
+		names[i] = field.Name
+	}
+	return names
+}
+
+// Schemas holds the result fields of every query method
+var Schemas = map[string]Schema{
+	"BALANCE": {
+		{Name: "address", Type: TypeAddress},
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true}, // primary ENS name of address
+		{Name: "balance", Type: TypeInt},
+		{Name: "block_number", Type: TypeInt, Nullable: true},
+		{Name: "block_timestamp", Type: TypeTime, Nullable: true},
+	},
+	"BLOCKS": {
+		{Name: "number", Type: TypeInt},
+		{Name: "hash", Type: TypeHash},
+		{Name: "parent_hash", Type: TypeHash},
+		{Name: "timestamp", Type: TypeTime},
+		{Name: "miner", Type: TypeAddress}, // the fee recipient
+		{Name: "gas_used", Type: TypeInt},
+		{Name: "gas_limit", Type: TypeInt},
+		{Name: "base_fee", Type: TypeInt, Nullable: true}, // from London
+		{Name: "tx_count", Type: TypeInt},
+		{Name: "blob_gas_used", Type: TypeInt, Nullable: true},     // from Cancun
+		{Name: "withdrawals_root", Type: TypeHash, Nullable: true}, // from Shanghai
+	},
+	"FEES": {
+		{Name: "block_number", Type: TypeInt},
+		{Name: "block_timestamp", Type: TypeTime},
+		{Name: "base_fee", Type: TypeInt},
+		{Name: "gas_used_bps", Type: TypeInt}, // gas used in basis points of the gas limit, 10000 when full
+		{Name: "reward_p10", Type: TypeInt},   // priority fee paid at the 10th percentile of gas used
+		{Name: "reward_p25", Type: TypeInt},
+		{Name: "reward_p50", Type: TypeInt},
+		{Name: "reward_p75", Type: TypeInt},
+		{Name: "reward_p90", Type: TypeInt},
+		{Name: "blob_base_fee", Type: TypeInt, Nullable: true}, // from Cancun
+		{Name: "blob_gas_used_bps", Type: TypeInt, Nullable: true},
+	},
+	"CALL": {
+		{Name: "address", Type: TypeAddress},
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "function", Type: TypeString},              // signature of the called function
+		{Name: "error", Type: TypeString, Nullable: true}, // revert reason, or why the result did not decode
+		{Name: "block_number", Type: TypeInt, Nullable: true},
+		{Name: "block_timestamp", Type: TypeTime, Nullable: true},
+		// Estimating costs a request per address, so it is only done when named
+		{Name: "gas_estimate", Type: TypeInt, Nullable: true, Optional: true},
+	},
+	"GAS_ESTIMATE": {
+		{Name: "address", Type: TypeAddress}, // the called contract
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "function", Type: TypeString},
+		{Name: "sender", Type: TypeAddress},                   // the zero address unless SENDER is given
+		{Name: "gas_estimate", Type: TypeInt, Nullable: true}, // NULL when the call reverts or needs more than gas_limit
+		{Name: "gas_limit", Type: TypeInt},                    // the most gas the estimate may reach
+		{Name: "error", Type: TypeString, Nullable: true},
+		{Name: "block_number", Type: TypeInt, Nullable: true},
+		{Name: "block_timestamp", Type: TypeTime, Nullable: true},
+	},
+	"CODE": {
+		{Name: "address", Type: TypeAddress},
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "code", Type: TypeBytes},
+		{Name: "size", Type: TypeInt},
+		{Name: "code_hash", Type: TypeHash},   // keccak256 of code
+		{Name: "is_contract", Type: TypeBool}, // false for accounts without code or with an EIP-7702 delegation
+		{Name: "block_number", Type: TypeInt, Nullable: true},
+		{Name: "block_timestamp", Type: TypeTime, Nullable: true},
+	},
+	"NONCE": {
+		{Name: "address", Type: TypeAddress},
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "nonce", Type: TypeInt},
+		{Name: "block_number", Type: TypeInt, Nullable: true},
+		{Name: "block_timestamp", Type: TypeTime, Nullable: true},
+	},
+	"OWNER": {
+		{Name: "address", Type: TypeAddress}, // the collection
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "token_id", Type: TypeInt},
+		{Name: "owner", Type: TypeAddress, Nullable: true}, // NULL for burned and ERC-1155 tokens
+		{Name: "block_number", Type: TypeInt, Nullable: true},
+		{Name: "block_timestamp", Type: TypeTime, Nullable: true},
+	},
+	"STORAGE": {
+		{Name: "address", Type: TypeAddress},
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "slot", Type: TypeHash},
+		{Name: "value", Type: TypeHash},
+		{Name: "block_number", Type: TypeInt, Nullable: true},
+		{Name: "block_timestamp", Type: TypeTime, Nullable: true},
+	},
+	"TOKEN_BALANCE": {
+		{Name: "address", Type: TypeAddress}, // the holder
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "token", Type: TypeAddress},
+		{Name: "symbol", Type: TypeString, Nullable: true},
+		{Name: "decimals", Type: TypeInt, Nullable: true},
+		{Name: "balance", Type: TypeInt, Nullable: true},   // in the token's smallest unit
+		{Name: "amount", Type: TypeString, Nullable: true}, // balance scaled by decimals, as in "1.5"
+		{Name: "block_number", Type: TypeInt, Nullable: true},
+		{Name: "block_timestamp", Type: TypeTime, Nullable: true},
+	},
+	"TOKEN_INFO": {
+		{Name: "address", Type: TypeAddress},
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "name", Type: TypeString, Nullable: true},
+		{Name: "symbol", Type: TypeString, Nullable: true},
+		{Name: "decimals", Type: TypeInt, Nullable: true},
+		{Name: "total_supply", Type: TypeInt, Nullable: true},
+		{Name: "block_number", Type: TypeInt, Nullable: true},
+		{Name: "block_timestamp", Type: TypeTime, Nullable: true},
+	},
+	"NFTS": {
+		{Name: "address", Type: TypeAddress}, // the wallet
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "collection", Type: TypeAddress},
+		{Name: "standard", Type: TypeString}, // ERC-721 or ERC-1155
+		{Name: "token_id", Type: TypeInt},
+		{Name: "amount", Type: TypeInt}, // 1 for ERC-721 tokens
+		{Name: "block_number", Type: TypeInt},
+		{Name: "block_timestamp", Type: TypeTime},
+	},
+	"LOGS": {
+		{Name: "address", Type: TypeAddress},
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "topic0", Type: TypeHash, Nullable: true},
+		{Name: "topic1", Type: TypeHash, Nullable: true},
+		{Name: "topic2", Type: TypeHash, Nullable: true},
+		{Name: "topic3", Type: TypeHash, Nullable: true},
+		{Name: "data", Type: TypeBytes},
+		{Name: "block_number", Type: TypeInt},
+		{Name: "block_timestamp", Type: TypeTime},
+		{Name: "block_hash", Type: TypeHash},
+		{Name: "tx_hash", Type: TypeHash},
+		{Name: "tx_index", Type: TypeInt},
+		{Name: "log_index", Type: TypeInt},
+		{Name: "removed", Type: TypeBool},
+	},
+	"TRANSACTIONS": {
+		{Name: "address", Type: TypeAddress}, // the queried address the transaction was sent from or to
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "hash", Type: TypeHash},
+		{Name: "from", Type: TypeAddress},
+		{Name: "to", Type: TypeAddress, Nullable: true},
+		{Name: "value", Type: TypeInt},
+		{Name: "gas", Type: TypeInt},
+		{Name: "gas_price", Type: TypeInt},
+		{Name: "max_fee_per_gas", Type: TypeInt},
+		{Name: "max_priority_fee_per_gas", Type: TypeInt},
+		{Name: "nonce", Type: TypeInt},
+		{Name: "input", Type: TypeBytes},
+		{Name: "selector", Type: TypeBytes, Nullable: true}, // first 4 bytes of input
+		{Name: "method", Type: TypeString, Nullable: true},  // name of the called function, when known
+		{Name: "args", Type: TypeString, Nullable: true},    // decoded arguments as a JSON object
+		{Name: "calls", Type: TypeString, Nullable: true},   // calls batched by a multicall, as a JSON array
+		{Name: "type", Type: TypeInt},
+		{Name: "block_number", Type: TypeInt},
+		{Name: "block_timestamp", Type: TypeTime},
+		{Name: "block_hash", Type: TypeHash},
+		{Name: "tx_index", Type: TypeInt},
+		// Receipt fields cost a request per block, so they are only fetched when named
+		{Name: "status", Type: TypeInt, Nullable: true, Optional: true},
+		{Name: "gas_used", Type: TypeInt, Nullable: true, Optional: true},
+		{Name: "cumulative_gas_used", Type: TypeInt, Nullable: true, Optional: true},
+		{Name: "effective_gas_price", Type: TypeInt, Nullable: true, Optional: true},
+		{Name: "contract_address", Type: TypeAddress, Nullable: true, Optional: true},
+		{Name: "logs_count", Type: TypeInt, Nullable: true, Optional: true},
+		{Name: "blob_gas_used", Type: TypeInt, Nullable: true, Optional: true},
+		{Name: "blob_gas_price", Type: TypeInt, Nullable: true, Optional: true},
+	},
+	"PENDING": {
+		{Name: "address", Type: TypeAddress}, // the queried address the transaction was sent from or to
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "hash", Type: TypeHash},
+		{Name: "from", Type: TypeAddress},
+		{Name: "to", Type: TypeAddress, Nullable: true},
+		{Name: "value", Type: TypeInt},
+		{Name: "gas", Type: TypeInt},
+		{Name: "gas_price", Type: TypeInt},
+		{Name: "max_fee_per_gas", Type: TypeInt},
+		{Name: "max_priority_fee_per_gas", Type: TypeInt},
+		{Name: "nonce", Type: TypeInt},
+		{Name: "input", Type: TypeBytes},
+		{Name: "selector", Type: TypeBytes, Nullable: true},
+		{Name: "method", Type: TypeString, Nullable: true},
+		{Name: "args", Type: TypeString, Nullable: true},
+		{Name: "calls", Type: TypeString, Nullable: true},
+		{Name: "type", Type: TypeInt},
+		{Name: "pool", Type: TypeString}, // pending when executable, queued when waiting on an earlier nonce
+	},
+	"TRANSFERS": {
+		{Name: "address", Type: TypeAddress}, // the queried wallet, the sender or the recipient
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "standard", Type: TypeString}, // ERC-20, ERC-721 or ERC-1155
+		{Name: "token", Type: TypeAddress},   // the contract that emitted the transfer
+		{Name: "from", Type: TypeAddress},
+		{Name: "to", Type: TypeAddress},
+		{Name: "amount", Type: TypeInt},                       // 1 for ERC-721
+		{Name: "token_id", Type: TypeInt, Nullable: true},     // NULL for ERC-20
+		{Name: "operator", Type: TypeAddress, Nullable: true}, // ERC-1155 only
+		{Name: "block_number", Type: TypeInt},
+		{Name: "block_timestamp", Type: TypeTime},
+		{Name: "block_hash", Type: TypeHash},
+		{Name: "tx_hash", Type: TypeHash},
+		{Name: "tx_index", Type: TypeInt},
+		{Name: "log_index", Type: TypeInt},
+	},
+	"TRACES":             traceSchema,
+	"INTERNAL_TRANSFERS": traceSchema,
+	"STATE_DIFF": {
+		{Name: "address", Type: TypeAddress}, // the account whose state changed
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "tx_hash", Type: TypeHash},
+		{Name: "tx_index", Type: TypeInt},
+		{Name: "field", Type: TypeString},              // balance, nonce, code or storage
+		{Name: "slot", Type: TypeHash, Nullable: true}, // storage only
+		{Name: "before", Type: TypeString},             // decimal for balance and nonce, hex for code and storage
+		{Name: "after", Type: TypeString},              // zero or empty once the account is destroyed
+		{Name: "delta", Type: TypeInt, Nullable: true}, // after minus before, for balance and nonce
+		{Name: "block_number", Type: TypeInt},
+		{Name: "block_timestamp", Type: TypeTime},
+	},
+	"RECEIPTS": {
+		{Name: "address", Type: TypeAddress}, // the queried address the transaction was sent from or to
+		{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+		{Name: "tx_hash", Type: TypeHash},
+		{Name: "from", Type: TypeAddress},
+		{Name: "to", Type: TypeAddress, Nullable: true},
+		{Name: "status", Type: TypeInt, Nullable: true}, // 1 on success, 0 on failure; NULL before Byzantium
+		{Name: "gas_used", Type: TypeInt},
+		{Name: "cumulative_gas_used", Type: TypeInt},
+		{Name: "effective_gas_price", Type: TypeInt, Nullable: true},
+		{Name: "contract_address", Type: TypeAddress, Nullable: true}, // the contract a deployment created
+		{Name: "logs_count", Type: TypeInt},
+		{Name: "blob_gas_used", Type: TypeInt, Nullable: true}, // blob transactions only
+		{Name: "blob_gas_price", Type: TypeInt, Nullable: true},
+		{Name: "type", Type: TypeInt},
+		{Name: "block_number", Type: TypeInt},
+		{Name: "block_timestamp", Type: TypeTime},
+		{Name: "block_hash", Type: TypeHash},
+		{Name: "tx_index", Type: TypeInt},
+	},
+}
+
+// traceSchema is shared by TRACES and INTERNAL_TRANSFERS, which reports the call frames
+// of TRACES that moved ether inside a transaction
+var traceSchema = Schema{
+	{Name: "address", Type: TypeAddress}, // the queried address the frame was called from or to
+	{Name: "ens_name", Type: TypeString, Nullable: true, Optional: true},
+	{Name: "tx_hash", Type: TypeHash},
+	{Name: "tx_index", Type: TypeInt},
+	{Name: "trace_address", Type: TypeString}, // position in the call tree, as in "0.2"; "" for the transaction itself
+	{Name: "depth", Type: TypeInt},            // 0 for the transaction itself
+	{Name: "type", Type: TypeString},          // CALL, STATICCALL, DELEGATECALL, CALLCODE, CREATE, CREATE2 or SELFDESTRUCT
+	{Name: "from", Type: TypeAddress},
+	{Name: "to", Type: TypeAddress, Nullable: true},
+	{Name: "value", Type: TypeInt},
+	{Name: "gas", Type: TypeInt},
+	{Name: "gas_used", Type: TypeInt},
+	{Name: "input", Type: TypeBytes},
+	{Name: "output", Type: TypeBytes, Nullable: true},
+	{Name: "error", Type: TypeString, Nullable: true}, // why the frame failed, as in "execution reverted: <reason>"
+	{Name: "block_number", Type: TypeInt},
+	{Name: "block_timestamp", Type: TypeTime},
+}
+
+// ENSName is an address literal written as an ENS name, such as "vitalik.eth". The
+// executor resolves it to a common.Address before the query runs.
+type ENSName string
+
+// Record holds the field values of a single result item, keyed by field name.
+// A missing or nil value is NULL.
+type Record map[string]interface{}